@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -276,6 +277,65 @@ func (as *AnalyticsService) GetAnalytics(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(results)
 }
 
+// GetProductViews returns product page views per game over the last `hours` hours
+// (default 24). Product pages are identified by their id or slug query parameter.
+func (as *AnalyticsService) GetProductViews(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if hoursParam := r.URL.Query().Get("hours"); hoursParam != "" {
+		parsed, err := strconv.Atoi(hoursParam)
+		if err != nil || parsed < 1 || parsed > 24*365 {
+			http.Error(w, "hours must be between 1 and 8760", http.StatusBadRequest)
+			return
+		}
+		hours = parsed
+	}
+
+	query := `SELECT 
+		extractURLParameter(page_url, 'id') as game_id,
+		extractURLParameter(page_url, 'slug') as slug,
+		count() as views
+		FROM analytics.page_views 
+		WHERE timestamp >= now() - toIntervalHour(?) 
+		AND position(page_url, 'product-details.html') > 0
+		GROUP BY game_id, slug 
+		ORDER BY views DESC`
+
+	rows, err := as.db.Query(query, hours)
+	if err != nil {
+		log.Printf("Error querying product views: %v", err)
+		http.Error(w, "Failed to get product views", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		var gameID string
+		var slug string
+		var views uint64
+
+		err := rows.Scan(&gameID, &slug, &views)
+		if err != nil {
+			log.Printf("Error scanning row: %v", err)
+			continue
+		}
+
+		// Skip views of the product page without a game reference
+		if gameID == "" && slug == "" {
+			continue
+		}
+
+		results = append(results, map[string]interface{}{
+			"game_id": gameID,
+			"slug":    slug,
+			"views":   views,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
 func getClientIP(r *http.Request) string {
 	// Try to get IP from X-Forwarded-For header
 	forwarded := r.Header.Get("X-Forwarded-For")
@@ -313,6 +373,7 @@ func main() {
 	router.HandleFunc("/api/analytics/pagetime", analyticsService.TrackPageTime).Methods("POST")
 	router.HandleFunc("/api/analytics/sessiontime", analyticsService.TrackSessionTime).Methods("POST")
 	router.HandleFunc("/api/analytics/data", analyticsService.GetAnalytics).Methods("GET")
+	router.HandleFunc("/api/analytics/product-views", analyticsService.GetProductViews).Methods("GET")
 
 	// Health check
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,85 @@
+// Fills the product page with the game named by the ?slug= query parameter,
+// the product page URL game-service publishes as each game's canonical URL
+class LugxProductDetails {
+    constructor(config = {}) {
+        this.apiUrl = config.apiUrl || this.getApiUrl();
+        this.slug = new URLSearchParams(window.location.search).get('slug');
+
+        if (this.slug) {
+            this.load();
+        }
+    }
+
+    getApiUrl() {
+        // Same host as the storefront, game-service node port
+        const hostname = window.location.hostname;
+        if (hostname === 'localhost' || hostname === '127.0.0.1') {
+            return 'http://localhost:30080/api/v1';
+        } else {
+            return `${window.location.protocol}//${hostname}:30080/api/v1`;
+        }
+    }
+
+    async load() {
+        try {
+            // Old slugs redirect to the current one, which fetch follows
+            const response = await fetch(`${this.apiUrl}/games/by-slug/${encodeURIComponent(this.slug)}`);
+            if (!response.ok) {
+                this.showNotFound();
+                return;
+            }
+
+            const body = await response.json();
+            this.render(body.data);
+        } catch (error) {
+            console.warn('Failed to load game:', error);
+        }
+    }
+
+    render(game) {
+        document.title = `Lugx Gaming - ${game.name}`;
+        this.setText('product-name', game.name);
+        this.setText('product-breadcrumb-name', game.name);
+        this.setText('product-price', `$${Number(game.price).toFixed(2)}`);
+        this.setText('product-id', game.id);
+
+        const genre = document.getElementById('product-genre');
+        if (genre) {
+            const label = genre.querySelector('span');
+            const link = document.createElement('a');
+            link.textContent = game.category;
+            link.href = 'shop.html';
+            genre.replaceChildren(label, ' ', link);
+        }
+
+        const addToCart = document.querySelector('#qty button');
+        if (addToCart && !game.purchasable) {
+            addToCart.disabled = true;
+        }
+
+        // Keep the address bar on the current slug after a rename
+        if (game.slug && game.slug !== this.slug) {
+            const url = new URL(window.location.href);
+            url.searchParams.set('slug', game.slug);
+            window.history.replaceState(null, '', url);
+        }
+    }
+
+    showNotFound() {
+        document.title = 'Lugx Gaming - Game not found';
+        this.setText('product-name', 'Game not found');
+        this.setText('product-breadcrumb-name', 'Game not found');
+        this.setText('product-price', '');
+    }
+
+    setText(id, text) {
+        const element = document.getElementById(id);
+        if (element) {
+            element.textContent = text;
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    window.lugxProductDetails = new LugxProductDetails();
+});
@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"game-service/models"
+)
+
+// AnalyticsClient talks to analytics-service
+type AnalyticsClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAnalyticsClient creates a new analytics-service client
+func NewAnalyticsClient() *AnalyticsClient {
+	baseURL := os.Getenv("ANALYTICS_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:30082"
+	}
+
+	return &AnalyticsClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetProductViews retrieves product page views per game over the last `hours` hours
+func (c *AnalyticsClient) GetProductViews(hours int) ([]models.ProductViews, error) {
+	url := fmt.Sprintf("%s/api/analytics/product-views?hours=%d", c.baseURL, hours)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get product views: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get product views: analytics-service returned %d", resp.StatusCode)
+	}
+
+	var views []models.ProductViews
+	if err := json.NewDecoder(resp.Body).Decode(&views); err != nil {
+		return nil, fmt.Errorf("failed to decode product views: %v", err)
+	}
+
+	return views, nil
+}
@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"game-service/models"
+)
+
+// OrderClient talks to order-service
+type OrderClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOrderClient creates a new order-service client
+func NewOrderClient() *OrderClient {
+	baseURL := os.Getenv("ORDER_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:30081"
+	}
+
+	return &OrderClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetGameSales retrieves units sold per game over the last `hours` hours
+func (c *OrderClient) GetGameSales(hours int) ([]models.GameSales, error) {
+	url := fmt.Sprintf("%s/api/v1/orders/stats/game-sales?hours=%d", c.baseURL, hours)
+
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game sales: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get game sales: order-service returned %d", resp.StatusCode)
+	}
+
+	var response struct {
+		GameSales []models.GameSales `json:"game_sales"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode game sales: %v", err)
+	}
+
+	return response.GameSales, nil
+}
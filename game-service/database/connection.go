@@ -81,6 +81,31 @@ func createTables() error {
 		return fmt.Errorf("failed to create games table: %v", err)
 	}
 
+	createSlugTables := `
+	ALTER TABLE games ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_games_slug ON games(slug);
+
+	-- Old slugs are kept so that links to renamed games keep working
+	CREATE TABLE IF NOT EXISTS game_slug_redirects (
+		slug VARCHAR(255) PRIMARY KEY,
+		game_id INTEGER NOT NULL REFERENCES games(id) ON DELETE CASCADE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_game_slug_redirects_game_id ON game_slug_redirects(game_id);
+	`
+
+	_, err = DB.Exec(createSlugTables)
+	if err != nil {
+		return fmt.Errorf("failed to create slug tables: %v", err)
+	}
+
+	// Games can be listed in the catalog without being for sale
+	_, err = DB.Exec(`ALTER TABLE games ADD COLUMN IF NOT EXISTS purchasable BOOLEAN NOT NULL DEFAULT TRUE`)
+	if err != nil {
+		return fmt.Errorf("failed to add purchasable column: %v", err)
+	}
+
 	log.Println("Database tables created/verified successfully")
 	return nil
 }
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"game-service/models"
+	"game-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ChartHandler struct {
+	chartService *service.ChartService
+}
+
+// NewChartHandler creates a new chart handler
+func NewChartHandler() *ChartHandler {
+	return &ChartHandler{
+		chartService: service.NewChartService(),
+	}
+}
+
+// GetTopSellers handles GET /charts/top-sellers
+func (h *ChartHandler) GetTopSellers(c *gin.Context) {
+	h.getChart(c, service.ChartTopSellers)
+}
+
+// GetTrending handles GET /charts/trending
+func (h *ChartHandler) GetTrending(c *gin.Context) {
+	h.getChart(c, service.ChartTrending)
+}
+
+// getChart serves a chart with the window and limit query parameters
+func (h *ChartHandler) getChart(c *gin.Context, name string) {
+	window := c.DefaultQuery("window", "7d")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid limit",
+			Message: "Limit must be a number",
+		})
+		return
+	}
+
+	chart, err := h.chartService.GetChart(name, window, limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidChart) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to retrieve chart",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Chart retrieved successfully",
+		Data:    chart,
+	})
+}
@@ -1,23 +1,30 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"game-service/models"
+	"game-service/repository"
 	"game-service/service"
 
 	"github.com/gin-gonic/gin"
 )
 
 type GameHandler struct {
-	gameService *service.GameService
+	gameService    *service.GameService
+	sitemapService *service.SitemapService
 }
 
 // NewGameHandler creates a new game handler
 func NewGameHandler() *GameHandler {
 	return &GameHandler{
-		gameService: service.NewGameService(),
+		gameService:    service.NewGameService(),
+		sitemapService: service.NewSitemapService(),
 	}
 }
 
@@ -75,8 +82,46 @@ func (h *GameHandler) GetGame(c *gin.Context) {
 	})
 }
 
+// GetGameBySlug handles GET /games/by-slug/:slug
+func (h *GameHandler) GetGameBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+
+	game, err := h.gameService.GetGameBySlug(slug)
+	if errors.Is(err, repository.ErrGameNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Game not found",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to retrieve game",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Old slugs permanently redirect to the game's current slug
+	if game.Slug != slug {
+		c.Redirect(http.StatusMovedPermanently, "/api/v1/games/by-slug/"+url.PathEscape(game.Slug))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Game retrieved successfully",
+		Data:    game,
+	})
+}
+
 // GetAllGames handles GET /games
 func (h *GameHandler) GetAllGames(c *gin.Context) {
+	// A list of IDs turns this into a batch lookup
+	if idsParam, ok := c.GetQuery("ids"); ok {
+		h.getGamesByIDs(c, idsParam)
+		return
+	}
+
 	// Check if category filter is provided
 	category := c.Query("category")
 	
@@ -103,6 +148,161 @@ func (h *GameHandler) GetAllGames(c *gin.Context) {
 	})
 }
 
+// getGamesByIDs handles GET /games?ids=1,2,3
+func (h *GameHandler) getGamesByIDs(c *gin.Context, idsParam string) {
+	var ids []int
+	for _, part := range strings.Split(idsParam, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid game ID",
+				Message: fmt.Sprintf("Game ID %q must be a number", part),
+			})
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	result, err := h.gameService.GetGamesByIDs(ids)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidGameIDs) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to retrieve games",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Games retrieved successfully",
+		Data:    result,
+	})
+}
+
+// GetReleaseCalendar handles GET /games/releases
+func (h *GameHandler) GetReleaseCalendar(c *gin.Context) {
+	calendar, err := h.gameService.GetReleaseCalendar(c.Query("from"), c.Query("to"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidReleaseWindow) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to retrieve release calendar",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "Release calendar retrieved successfully",
+		Data:    calendar,
+	})
+}
+
+// GetReleaseCalendarICS handles GET /feeds/releases.ics
+func (h *GameHandler) GetReleaseCalendarICS(c *gin.Context) {
+	ics, err := h.gameService.GetReleaseCalendarICS(c.Query("from"), c.Query("to"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidReleaseWindow) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.ErrorResponse{
+			Error:   "Failed to generate release calendar",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="lugx-releases.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", ics)
+}
+
+// GetGamesAtomFeed handles GET /feeds/games.atom
+func (h *GameHandler) GetGamesAtomFeed(c *gin.Context) {
+	feed, err := h.gameService.GetGamesAtomFeed(requestURL(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to generate games feed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", feed)
+}
+
+// GetSitemap handles GET /sitemap.xml
+func (h *GameHandler) GetSitemap(c *gin.Context) {
+	sitemap, err := h.sitemapService.GetSitemap()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to generate sitemap",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", sitemap)
+}
+
+// GetSitemapChunk handles GET /sitemaps/games-:n.xml
+func (h *GameHandler) GetSitemapChunk(c *gin.Context) {
+	var number int
+	file := c.Param("file")
+	if _, err := fmt.Sscanf(file, "games-%d.xml", &number); err != nil || file != fmt.Sprintf("games-%d.xml", number) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Sitemap not found",
+			Message: "Sitemap files are named games-<n>.xml",
+		})
+		return
+	}
+
+	sitemap, err := h.sitemapService.GetSitemapChunk(number)
+	if errors.Is(err, service.ErrSitemapNotFound) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Sitemap not found",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to generate sitemap",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", sitemap)
+}
+
+// requestURL reconstructs the absolute URL of the current request, honouring proxy headers
+func requestURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+
+	host := c.Request.Host
+	if forwardedHost := c.GetHeader("X-Forwarded-Host"); forwardedHost != "" {
+		host = forwardedHost
+	}
+
+	return scheme + "://" + host + c.Request.URL.Path
+}
+
 // UpdateGame handles PUT /games/:id
 func (h *GameHandler) UpdateGame(c *gin.Context) {
 	idStr := c.Param("id")
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"game-service/database"
+	"game-service/repository"
 	"game-service/routes"
 
 	"github.com/joho/godotenv"
@@ -22,6 +23,11 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// Give games created before slugs were introduced a slug
+	if err := repository.NewGameRepository().AssignMissingSlugs(); err != nil {
+		log.Fatalf("Failed to assign game slugs: %v", err)
+	}
+
 	// Setup routes
 	router := routes.SetupRoutes()
 
@@ -36,9 +42,17 @@ func main() {
 	log.Printf("  GET    /api/v1/health")
 	log.Printf("  POST   /api/v1/games")
 	log.Printf("  GET    /api/v1/games")
+	log.Printf("  GET    /api/v1/games/releases")
 	log.Printf("  GET    /api/v1/games/:id")
+	log.Printf("  GET    /api/v1/games/by-slug/:slug")
 	log.Printf("  PUT    /api/v1/games/:id")
 	log.Printf("  DELETE /api/v1/games/:id")
+	log.Printf("  GET    /api/v1/charts/top-sellers")
+	log.Printf("  GET    /api/v1/charts/trending")
+	log.Printf("  GET    /api/v1/feeds/releases.ics")
+	log.Printf("  GET    /api/v1/feeds/games.atom")
+	log.Printf("  GET    /sitemap.xml")
+	log.Printf("  GET    /sitemaps/games-:n.xml")
 
 	if err := router.Run(":" + port); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// GameSales represents the sales volume of a game as reported by order-service
+type GameSales struct {
+	GameID     int     `json:"game_id"`
+	UnitsSold  int     `json:"units_sold"`
+	Revenue    float64 `json:"revenue"`
+	OrderCount int     `json:"order_count"`
+}
+
+// ProductViews represents product page views as reported by analytics-service.
+// A view references the game either by ID or by slug, depending on the page URL.
+type ProductViews struct {
+	GameID string `json:"game_id"`
+	Slug   string `json:"slug"`
+	Views  int    `json:"views"`
+}
+
+// ChartEntry represents a ranked game within a chart
+type ChartEntry struct {
+	Rank      int     `json:"rank"`
+	Game      *Game   `json:"game"`
+	UnitsSold int     `json:"units_sold"`
+	Revenue   float64 `json:"revenue"`
+	Views     int     `json:"views"`
+	Score     float64 `json:"score"`
+}
+
+// Chart represents a ranking of games over a time window
+type Chart struct {
+	Name        string       `json:"name"`
+	Window      string       `json:"window"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Entries     []ChartEntry `json:"entries"`
+}
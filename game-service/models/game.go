@@ -8,11 +8,14 @@ import (
 type Game struct {
 	ID           int       `json:"id" db:"id"`
 	Name         string    `json:"name" db:"name" binding:"required"`
+	Slug         string    `json:"slug" db:"slug"`
 	Category     string    `json:"category" db:"category" binding:"required"`
 	ReleasedDate time.Time `json:"released_date" db:"released_date" binding:"required"`
 	Price        float64   `json:"price" db:"price" binding:"required,min=0"`
+	Purchasable  bool      `json:"purchasable" db:"purchasable"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	CanonicalURL string    `json:"canonical_url,omitempty" db:"-"`
 }
 
 // CreateGameRequest represents the request body for creating a game
@@ -21,6 +24,7 @@ type CreateGameRequest struct {
 	Category     string  `json:"category" binding:"required"`
 	ReleasedDate string  `json:"released_date" binding:"required"` // Format: "2006-01-02"
 	Price        float64 `json:"price" binding:"required,min=0"`
+	Purchasable  *bool   `json:"purchasable,omitempty"` // Defaults to true
 }
 
 // UpdateGameRequest represents the request body for updating a game
@@ -29,6 +33,13 @@ type UpdateGameRequest struct {
 	Category     *string  `json:"category,omitempty"`
 	ReleasedDate *string  `json:"released_date,omitempty"` // Format: "2006-01-02"
 	Price        *float64 `json:"price,omitempty"`
+	Purchasable  *bool    `json:"purchasable,omitempty"`
+}
+
+// BatchGamesResponse represents the result of fetching several games by ID
+type BatchGamesResponse struct {
+	Games      []*Game `json:"games"`       // In the order the IDs were requested
+	MissingIDs []int   `json:"missing_ids"` // Requested IDs that do not exist
 }
 
 // ErrorResponse represents an error response
@@ -42,3 +53,23 @@ type SuccessResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
+
+// ReleaseCalendar represents the games released within a date window
+type ReleaseCalendar struct {
+	From  string  `json:"from"` // Format: "2006-01-02"
+	To    string  `json:"to"`   // Format: "2006-01-02"
+	Games []*Game `json:"games"`
+}
+
+// SitemapChunkStat summarises one ID range of games covered by a single sitemap file
+type SitemapChunkStat struct {
+	Chunk       int       `db:"chunk"`
+	Count       int       `db:"count"`
+	LastUpdated time.Time `db:"last_updated"`
+}
+
+// SitemapEntry represents a game page listed in the sitemap
+type SitemapEntry struct {
+	Slug      string    `db:"slug"`
+	UpdatedAt time.Time `db:"updated_at"`
+}
@@ -2,13 +2,19 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"game-service/database"
 	"game-service/models"
+
+	"github.com/lib/pq"
 )
 
+// ErrGameNotFound is returned when no game has the requested slug
+var ErrGameNotFound = errors.New("game not found")
+
 type GameRepository struct {
 	db *sql.DB
 }
@@ -22,9 +28,21 @@ func NewGameRepository() *GameRepository {
 
 // CreateGame creates a new game in the database
 func (r *GameRepository) CreateGame(game *models.Game) (*models.Game, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	slug, err := uniqueSlug(tx, Slugify(game.Name), 0)
+	if err != nil {
+		return nil, err
+	}
+	game.Slug = slug
+
 	query := `
-		INSERT INTO games (name, category, released_date, price, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO games (name, slug, category, released_date, price, purchasable, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id, created_at, updated_at
 	`
 	
@@ -32,20 +50,24 @@ func (r *GameRepository) CreateGame(game *models.Game) (*models.Game, error) {
 	game.CreatedAt = now
 	game.UpdatedAt = now
 
-	err := r.db.QueryRow(query, game.Name, game.Category, game.ReleasedDate, game.Price, game.CreatedAt, game.UpdatedAt).
+	err = tx.QueryRow(query, game.Name, game.Slug, game.Category, game.ReleasedDate, game.Price, game.Purchasable, game.CreatedAt, game.UpdatedAt).
 		Scan(&game.ID, &game.CreatedAt, &game.UpdatedAt)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to create game: %v", err)
 	}
 
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit game: %v", err)
+	}
+
 	return game, nil
 }
 
 // GetGameByID retrieves a game by its ID
 func (r *GameRepository) GetGameByID(id int) (*models.Game, error) {
 	query := `
-		SELECT id, name, category, released_date, price, created_at, updated_at
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
 		FROM games
 		WHERE id = $1
 	`
@@ -54,9 +76,11 @@ func (r *GameRepository) GetGameByID(id int) (*models.Game, error) {
 	err := r.db.QueryRow(query, id).Scan(
 		&game.ID,
 		&game.Name,
+		&game.Slug,
 		&game.Category,
 		&game.ReleasedDate,
 		&game.Price,
+		&game.Purchasable,
 		&game.CreatedAt,
 		&game.UpdatedAt,
 	)
@@ -71,10 +95,94 @@ func (r *GameRepository) GetGameByID(id int) (*models.Game, error) {
 	return game, nil
 }
 
+// GetGameBySlug retrieves a game by its current slug or by one of its old slugs.
+// Callers can compare the returned game's Slug with the requested one to detect a redirect.
+func (r *GameRepository) GetGameBySlug(slug string) (*models.Game, error) {
+	query := `
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
+		FROM games
+		WHERE slug = $1
+		   OR id = (SELECT game_id FROM game_slug_redirects WHERE slug = $1)
+		ORDER BY slug = $1 DESC
+		LIMIT 1
+	`
+
+	game := &models.Game{}
+	err := r.db.QueryRow(query, slug).Scan(
+		&game.ID,
+		&game.Name,
+		&game.Slug,
+		&game.Category,
+		&game.ReleasedDate,
+		&game.Price,
+		&game.Purchasable,
+		&game.CreatedAt,
+		&game.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: no game has the slug %s", ErrGameNotFound, slug)
+		}
+		return nil, fmt.Errorf("failed to get game: %v", err)
+	}
+
+	return game, nil
+}
+
+// AssignMissingSlugs generates slugs for games created before slugs existed
+func (r *GameRepository) AssignMissingSlugs() error {
+	rows, err := r.db.Query(`SELECT id, name FROM games WHERE slug IS NULL ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to query games without slug: %v", err)
+	}
+
+	type pending struct {
+		id   int
+		name string
+	}
+	var games []pending
+	for rows.Next() {
+		var g pending
+		if err := rows.Scan(&g.id, &g.name); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan game: %v", err)
+		}
+		games = append(games, g)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate games: %v", err)
+	}
+
+	for _, g := range games {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %v", err)
+		}
+
+		slug, err := uniqueSlug(tx, Slugify(g.name), g.id)
+		if err == nil {
+			// Guard against another replica having filled it in meanwhile
+			_, err = tx.Exec(`UPDATE games SET slug = $1 WHERE id = $2 AND slug IS NULL`, slug, g.id)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to assign slug to game %d: %v", g.id, err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to assign slug to game %d: %v", g.id, err)
+		}
+	}
+
+	return nil
+}
+
 // GetAllGames retrieves all games from the database
 func (r *GameRepository) GetAllGames() ([]*models.Game, error) {
 	query := `
-		SELECT id, name, category, released_date, price, created_at, updated_at
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
 		FROM games
 		ORDER BY created_at DESC
 	`
@@ -91,9 +199,11 @@ func (r *GameRepository) GetAllGames() ([]*models.Game, error) {
 		err := rows.Scan(
 			&game.ID,
 			&game.Name,
+			&game.Slug,
 			&game.Category,
 			&game.ReleasedDate,
 			&game.Price,
+			&game.Purchasable,
 			&game.CreatedAt,
 			&game.UpdatedAt,
 		)
@@ -123,6 +233,7 @@ func (r *GameRepository) UpdateGame(id int, updates *models.UpdateGameRequest) (
 	args := []interface{}{}
 	argIndex := 1
 
+	oldName := currentGame.Name
 	if updates.Name != nil {
 		setParts = append(setParts, fmt.Sprintf("name = $%d", argIndex))
 		args = append(args, *updates.Name)
@@ -155,6 +266,13 @@ func (r *GameRepository) UpdateGame(id int, updates *models.UpdateGameRequest) (
 		currentGame.Price = *updates.Price
 	}
 
+	if updates.Purchasable != nil {
+		setParts = append(setParts, fmt.Sprintf("purchasable = $%d", argIndex))
+		args = append(args, *updates.Purchasable)
+		argIndex++
+		currentGame.Purchasable = *updates.Purchasable
+	}
+
 	if len(setParts) == 0 {
 		return currentGame, nil // No updates to perform
 	}
@@ -199,11 +317,34 @@ func (r *GameRepository) UpdateGame(id int, updates *models.UpdateGameRequest) (
 		RETURNING updated_at
 	`, setClause, argIndex)
 
-	err = r.db.QueryRow(query, args...).Scan(&currentGame.UpdatedAt)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(query, args...).Scan(&currentGame.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update game: %v", err)
 	}
 
+	// Only regenerate the slug when the rename actually changes it, so that
+	// cosmetic edits such as capitalisation keep existing links stable
+	if base := Slugify(currentGame.Name); base != Slugify(oldName) {
+		slug, err := uniqueSlug(tx, base, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := changeSlug(tx, id, currentGame.Slug, slug); err != nil {
+			return nil, err
+		}
+		currentGame.Slug = slug
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit game update: %v", err)
+	}
+
 	return currentGame, nil
 }
 
@@ -231,7 +372,7 @@ func (r *GameRepository) DeleteGame(id int) error {
 // GetGamesByCategory retrieves games by category
 func (r *GameRepository) GetGamesByCategory(category string) ([]*models.Game, error) {
 	query := `
-		SELECT id, name, category, released_date, price, created_at, updated_at
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
 		FROM games
 		WHERE category = $1
 		ORDER BY created_at DESC
@@ -249,9 +390,74 @@ func (r *GameRepository) GetGamesByCategory(category string) ([]*models.Game, er
 		err := rows.Scan(
 			&game.ID,
 			&game.Name,
+			&game.Slug,
+			&game.Category,
+			&game.ReleasedDate,
+			&game.Price,
+			&game.Purchasable,
+			&game.CreatedAt,
+			&game.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan game: %v", err)
+		}
+		games = append(games, game)
+	}
+
+	return games, nil
+}
+
+// GetGamesReleasedBetween retrieves games released within [from, to], earliest first
+func (r *GameRepository) GetGamesReleasedBetween(from, to time.Time) ([]*models.Game, error) {
+	query := `
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
+		FROM games
+		WHERE released_date BETWEEN $1 AND $2
+		ORDER BY released_date ASC, name ASC
+	`
+
+	rows, err := r.db.Query(query, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get games by release date: %v", err)
+	}
+	defer rows.Close()
+
+	return scanGames(rows)
+}
+
+// GetRecentlyAddedOrReleasedGames retrieves games that were added to the catalog
+// or released between since and until
+func (r *GameRepository) GetRecentlyAddedOrReleasedGames(since, until time.Time) ([]*models.Game, error) {
+	query := `
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
+		FROM games
+		WHERE created_at BETWEEN $1 AND $2
+		   OR released_date BETWEEN $1 AND $2
+		ORDER BY GREATEST(created_at, released_date) DESC
+	`
+
+	rows, err := r.db.Query(query, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recent games: %v", err)
+	}
+	defer rows.Close()
+
+	return scanGames(rows)
+}
+
+// scanGames reads all games from rows selected with the standard game columns
+func scanGames(rows *sql.Rows) ([]*models.Game, error) {
+	var games []*models.Game
+	for rows.Next() {
+		game := &models.Game{}
+		err := rows.Scan(
+			&game.ID,
+			&game.Name,
+			&game.Slug,
 			&game.Category,
 			&game.ReleasedDate,
 			&game.Price,
+			&game.Purchasable,
 			&game.CreatedAt,
 			&game.UpdatedAt,
 		)
@@ -261,5 +467,121 @@ func (r *GameRepository) GetGamesByCategory(category string) ([]*models.Game, er
 		games = append(games, game)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate games: %v", err)
+	}
+
 	return games, nil
 }
+
+// GetSitemapChunkStats groups games into ID ranges of chunkSize and returns the
+// number of games and latest update time per range, used to detect changed sitemaps
+func (r *GameRepository) GetSitemapChunkStats(chunkSize int) ([]models.SitemapChunkStat, error) {
+	query := `
+		SELECT (id - 1) / $1 AS chunk, COUNT(*), MAX(updated_at)
+		FROM games
+		GROUP BY chunk
+		ORDER BY chunk
+	`
+
+	rows, err := r.db.Query(query, chunkSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sitemap stats: %v", err)
+	}
+	defer rows.Close()
+
+	var stats []models.SitemapChunkStat
+	for rows.Next() {
+		var stat models.SitemapChunkStat
+		if err := rows.Scan(&stat.Chunk, &stat.Count, &stat.LastUpdated); err != nil {
+			return nil, fmt.Errorf("failed to scan sitemap stats: %v", err)
+		}
+		stats = append(stats, stat)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sitemap stats: %v", err)
+	}
+
+	return stats, nil
+}
+
+// GetSitemapEntries retrieves the slug and last update time of games with IDs in [fromID, toID]
+func (r *GameRepository) GetSitemapEntries(fromID, toID int) ([]models.SitemapEntry, error) {
+	query := `
+		SELECT slug, updated_at
+		FROM games
+		WHERE id BETWEEN $1 AND $2
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, fromID, toID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sitemap entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.SitemapEntry
+	for rows.Next() {
+		var entry models.SitemapEntry
+		if err := rows.Scan(&entry.Slug, &entry.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan sitemap entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate sitemap entries: %v", err)
+	}
+
+	return entries, nil
+}
+
+// GetGamesByIDs retrieves the games with the given IDs in a single query.
+// IDs that do not exist are simply absent from the result.
+func (r *GameRepository) GetGamesByIDs(ids []int) ([]*models.Game, error) {
+	query := `
+		SELECT id, name, slug, category, released_date, price, purchasable, created_at, updated_at
+		FROM games
+		WHERE id = ANY($1)
+	`
+
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get games by IDs: %v", err)
+	}
+	defer rows.Close()
+
+	return scanGames(rows)
+}
+
+// GetGameIDsBySlugs resolves current and old slugs to game IDs
+func (r *GameRepository) GetGameIDsBySlugs(slugs []string) (map[string]int, error) {
+	query := `
+		SELECT slug, id FROM games WHERE slug = ANY($1)
+		UNION ALL
+		SELECT slug, game_id FROM game_slug_redirects WHERE slug = ANY($1)
+	`
+
+	rows, err := r.db.Query(query, pq.Array(slugs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve slugs: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int)
+	for rows.Next() {
+		var slug string
+		var id int
+		if err := rows.Scan(&slug, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan slug: %v", err)
+		}
+		ids[slug] = id
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate slugs: %v", err)
+	}
+
+	return ids, nil
+}
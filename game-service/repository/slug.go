@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Slugify converts a game name into a URL-friendly slug, e.g.
+// "The Witcher 3: Wild Hunt" becomes "the-witcher-3-wild-hunt".
+// The result is not guaranteed to be unique; see uniqueSlug.
+func Slugify(name string) string {
+	var b strings.Builder
+	pendingDash := false
+
+	// Decompose accented characters so that "é" becomes "e" + combining mark
+	for _, r := range norm.NFKD.String(strings.ToLower(name)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// Drop combining marks left over from decomposition
+			continue
+		case r == '\'' || r == '’':
+			// "Assassin's Creed" -> "assassins-creed"
+			continue
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			if pendingDash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			pendingDash = false
+			b.WriteRune(r)
+		default:
+			pendingDash = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > 200 {
+		slug = strings.TrimRight(slug[:200], "-")
+	}
+	if slug == "" {
+		slug = "game"
+	}
+
+	return slug
+}
+
+// uniqueSlug returns base, or base suffixed with "-2", "-3", ... if it is already
+// used by another game, either as its current slug or as a redirect.
+// gameID is the game the slug is for, or 0 for a game that is not yet stored.
+func uniqueSlug(tx *sql.Tx, base string, gameID int) (string, error) {
+	query := `
+		SELECT EXISTS (SELECT 1 FROM games WHERE slug = $1 AND id <> $2)
+			OR EXISTS (SELECT 1 FROM game_slug_redirects WHERE slug = $1 AND game_id <> $2)
+	`
+
+	candidate := base
+	for i := 2; ; i++ {
+		var taken bool
+		if err := tx.QueryRow(query, candidate, gameID).Scan(&taken); err != nil {
+			return "", fmt.Errorf("failed to check slug availability: %v", err)
+		}
+		if !taken {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// changeSlug moves a game to newSlug and keeps oldSlug as a redirect
+func changeSlug(tx *sql.Tx, gameID int, oldSlug, newSlug string) error {
+	if oldSlug != "" {
+		_, err := tx.Exec(`
+			INSERT INTO game_slug_redirects (slug, game_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (slug) DO NOTHING
+		`, oldSlug, gameID, time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to store slug redirect: %v", err)
+		}
+	}
+
+	// A game renamed back to an earlier name reclaims its old slug
+	if _, err := tx.Exec(`DELETE FROM game_slug_redirects WHERE slug = $1 AND game_id = $2`, newSlug, gameID); err != nil {
+		return fmt.Errorf("failed to remove slug redirect: %v", err)
+	}
+
+	if _, err := tx.Exec(`UPDATE games SET slug = $1 WHERE id = $2`, newSlug, gameID); err != nil {
+		return fmt.Errorf("failed to update slug: %v", err)
+	}
+
+	return nil
+}
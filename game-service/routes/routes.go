@@ -26,6 +26,11 @@ func SetupRoutes() *gin.Engine {
 
 	// Initialize handlers
 	gameHandler := handlers.NewGameHandler()
+	chartHandler := handlers.NewChartHandler()
+
+	// Sitemaps live at the root so the storefront can proxy them as-is
+	router.GET("/sitemap.xml", gameHandler.GetSitemap)
+	router.GET("/sitemaps/:file", gameHandler.GetSitemapChunk)
 
 	// API version 1 routes
 	v1 := router.Group("/api/v1")
@@ -37,11 +42,27 @@ func SetupRoutes() *gin.Engine {
 		games := v1.Group("/games")
 		{
 			games.POST("", gameHandler.CreateGame)           // Create a new game
-			games.GET("", gameHandler.GetAllGames)           // Get all games (with optional category filter)
+			games.GET("", gameHandler.GetAllGames)           // Get all games (with optional category filter or ids batch lookup)
+			games.GET("/releases", gameHandler.GetReleaseCalendar) // Get games released within a date window
 			games.GET("/:id", gameHandler.GetGame)           // Get game by ID
+			games.GET("/by-slug/:slug", gameHandler.GetGameBySlug) // Get game by slug (old slugs redirect)
 			games.PUT("/:id", gameHandler.UpdateGame)        // Update game by ID
 			games.DELETE("/:id", gameHandler.DeleteGame)     // Delete game by ID
 		}
+
+		// Chart routes (window: 24h, 7d or 30d)
+		charts := v1.Group("/charts")
+		{
+			charts.GET("/top-sellers", chartHandler.GetTopSellers) // Games ranked by units sold
+			charts.GET("/trending", chartHandler.GetTrending)      // Games ranked by sales and page views
+		}
+
+		// Feed routes for calendar apps and feed readers
+		feeds := v1.Group("/feeds")
+		{
+			feeds.GET("/releases.ics", gameHandler.GetReleaseCalendarICS) // iCalendar feed of upcoming releases
+			feeds.GET("/games.atom", gameHandler.GetGamesAtomFeed)        // Atom feed of newly added and released games
+		}
 	}
 
 	return router
@@ -0,0 +1,314 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"game-service/clients"
+	"game-service/models"
+	"game-service/repository"
+)
+
+const (
+	ChartTopSellers = "top-sellers"
+	ChartTrending   = "trending"
+
+	// maxChartEntries is how many ranked games are cached per chart
+	maxChartEntries = 100
+
+	// Trending blends normalised sales volume and product page views
+	trendingSalesWeight = 0.6
+	trendingViewsWeight = 0.4
+)
+
+// ErrInvalidChart is returned when a chart, window or limit is not supported
+var ErrInvalidChart = errors.New("invalid chart")
+
+// chartWindows maps the supported window names to their length in hours
+var chartWindows = map[string]int{
+	"24h": 24,
+	"7d":  7 * 24,
+	"30d": 30 * 24,
+}
+
+// ChartService ranks games by combining sales from order-service with product
+// page views from analytics-service. Charts are cached in memory and refreshed
+// in the background every CHARTS_REFRESH_INTERVAL (default 10m).
+type ChartService struct {
+	repo            *repository.GameRepository
+	orders          *clients.OrderClient
+	analytics       *clients.AnalyticsClient
+	refreshInterval time.Duration
+
+	startOnce sync.Once
+	mu        sync.RWMutex
+	charts    map[string]*models.Chart
+}
+
+// NewChartService creates a new chart service
+func NewChartService() *ChartService {
+	refreshInterval := 10 * time.Minute
+	if value := os.Getenv("CHARTS_REFRESH_INTERVAL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			refreshInterval = parsed
+		}
+	}
+
+	return &ChartService{
+		repo:            repository.NewGameRepository(),
+		orders:          clients.NewOrderClient(),
+		analytics:       clients.NewAnalyticsClient(),
+		refreshInterval: refreshInterval,
+		charts:          make(map[string]*models.Chart),
+	}
+}
+
+// GetChart returns a cached chart, limited to the top `limit` entries.
+// The first call computes the charts synchronously and starts the refresh schedule.
+func (s *ChartService) GetChart(name, window string, limit int) (*models.Chart, error) {
+	if name != ChartTopSellers && name != ChartTrending {
+		return nil, fmt.Errorf("%w: unknown chart %s", ErrInvalidChart, name)
+	}
+	if _, ok := chartWindows[window]; !ok {
+		return nil, fmt.Errorf("%w: invalid window %s. Use 24h, 7d or 30d", ErrInvalidChart, window)
+	}
+	if limit < 1 || limit > maxChartEntries {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidChart, maxChartEntries)
+	}
+
+	s.startOnce.Do(func() {
+		s.refreshAll()
+		go s.refreshLoop()
+	})
+
+	s.mu.RLock()
+	chart, ok := s.charts[chartKey(name, window)]
+	s.mu.RUnlock()
+
+	if !ok {
+		// The initial refresh failed; try again rather than serving nothing
+		if err := s.refreshWindow(window); err != nil {
+			return nil, err
+		}
+		s.mu.RLock()
+		chart = s.charts[chartKey(name, window)]
+		s.mu.RUnlock()
+	}
+
+	limited := *chart
+	if len(limited.Entries) > limit {
+		limited.Entries = limited.Entries[:limit]
+	}
+
+	return &limited, nil
+}
+
+// refreshLoop refreshes every chart on the configured schedule
+func (s *ChartService) refreshLoop() {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.refreshAll()
+	}
+}
+
+// refreshAll refreshes the charts of every window, keeping stale charts on failure
+func (s *ChartService) refreshAll() {
+	for window := range chartWindows {
+		if err := s.refreshWindow(window); err != nil {
+			log.Printf("Failed to refresh %s charts: %v", window, err)
+		}
+	}
+}
+
+// refreshWindow recomputes both charts for one window
+func (s *ChartService) refreshWindow(window string) error {
+	hours := chartWindows[window]
+
+	sales, err := s.orders.GetGameSales(hours)
+	if err != nil {
+		return err
+	}
+
+	// Page views only refine the ranking, so a missing analytics-service
+	// should not take the charts down with it
+	views, err := s.analytics.GetProductViews(hours)
+	if err != nil {
+		log.Printf("Charts for %s computed without page views: %v", window, err)
+		views = nil
+	}
+
+	entries, err := s.collectEntries(sales, views)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	topSellers := rankTopSellers(entries)
+	trending := rankTrending(entries)
+
+	s.mu.Lock()
+	s.charts[chartKey(ChartTopSellers, window)] = &models.Chart{
+		Name: ChartTopSellers, Window: window, GeneratedAt: now, Entries: topSellers,
+	}
+	s.charts[chartKey(ChartTrending, window)] = &models.Chart{
+		Name: ChartTrending, Window: window, GeneratedAt: now, Entries: trending,
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// collectEntries merges sales and views per game and attaches the game details.
+// Games that no longer exist in the catalog are dropped.
+func (s *ChartService) collectEntries(sales []models.GameSales, views []models.ProductViews) ([]models.ChartEntry, error) {
+	byGame := make(map[int]*models.ChartEntry)
+	entryFor := func(gameID int) *models.ChartEntry {
+		entry, ok := byGame[gameID]
+		if !ok {
+			entry = &models.ChartEntry{}
+			byGame[gameID] = entry
+		}
+		return entry
+	}
+
+	for _, sale := range sales {
+		entry := entryFor(sale.GameID)
+		entry.UnitsSold += sale.UnitsSold
+		entry.Revenue += sale.Revenue
+	}
+
+	// Views reference games by ID or by slug depending on the product page URL
+	var slugs []string
+	for _, view := range views {
+		if view.GameID == "" && view.Slug != "" {
+			slugs = append(slugs, view.Slug)
+		}
+	}
+	slugIDs := map[string]int{}
+	if len(slugs) > 0 {
+		resolved, err := s.repo.GetGameIDsBySlugs(slugs)
+		if err != nil {
+			return nil, err
+		}
+		slugIDs = resolved
+	}
+
+	for _, view := range views {
+		gameID, err := strconv.Atoi(view.GameID)
+		if err != nil {
+			id, ok := slugIDs[view.Slug]
+			if !ok {
+				continue
+			}
+			gameID = id
+		}
+		entryFor(gameID).Views += view.Views
+	}
+
+	ids := make([]int, 0, len(byGame))
+	for id := range byGame {
+		ids = append(ids, id)
+	}
+	games, err := s.repo.GetGamesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]models.ChartEntry, 0, len(games))
+	for _, game := range games {
+		game.CanonicalURL = productPageURL(storefrontBaseURL(), game.Slug)
+		entry := *byGame[game.ID]
+		entry.Game = game
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// rankTopSellers orders games by units sold, breaking ties by revenue and then views
+func rankTopSellers(entries []models.ChartEntry) []models.ChartEntry {
+	ranked := make([]models.ChartEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.UnitsSold > 0 {
+			entry.Score = float64(entry.UnitsSold)
+			ranked = append(ranked, entry)
+		}
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].UnitsSold != ranked[j].UnitsSold {
+			return ranked[i].UnitsSold > ranked[j].UnitsSold
+		}
+		if ranked[i].Revenue != ranked[j].Revenue {
+			return ranked[i].Revenue > ranked[j].Revenue
+		}
+		if ranked[i].Views != ranked[j].Views {
+			return ranked[i].Views > ranked[j].Views
+		}
+		return ranked[i].Game.ID < ranked[j].Game.ID
+	})
+
+	return assignRanks(ranked)
+}
+
+// rankTrending orders games by a weighted blend of sales and page views, each
+// normalised against the best performing game in the window
+func rankTrending(entries []models.ChartEntry) []models.ChartEntry {
+	maxUnits, maxViews := 0, 0
+	for _, entry := range entries {
+		if entry.UnitsSold > maxUnits {
+			maxUnits = entry.UnitsSold
+		}
+		if entry.Views > maxViews {
+			maxViews = entry.Views
+		}
+	}
+
+	ranked := make([]models.ChartEntry, 0, len(entries))
+	for _, entry := range entries {
+		score := 0.0
+		if maxUnits > 0 {
+			score += trendingSalesWeight * float64(entry.UnitsSold) / float64(maxUnits)
+		}
+		if maxViews > 0 {
+			score += trendingViewsWeight * float64(entry.Views) / float64(maxViews)
+		}
+		if score == 0 {
+			continue
+		}
+		entry.Score = score
+		ranked = append(ranked, entry)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Game.ID < ranked[j].Game.ID
+	})
+
+	return assignRanks(ranked)
+}
+
+// assignRanks numbers the entries from 1 and trims them to maxChartEntries
+func assignRanks(ranked []models.ChartEntry) []models.ChartEntry {
+	if len(ranked) > maxChartEntries {
+		ranked = ranked[:maxChartEntries]
+	}
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+	return ranked
+}
+
+func chartKey(name, window string) string {
+	return name + "/" + window
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"game-service/models"
+)
+
+const (
+	// defaultCalendarDays is how far ahead the release calendar looks when no end date is given
+	defaultCalendarDays = 90
+	// maxCalendarDays bounds the window a single calendar request may cover
+	maxCalendarDays = 731
+	// atomFeedDays is how far back the Atom feed looks for added and released games
+	atomFeedDays = 30
+)
+
+// ErrInvalidReleaseWindow is returned when the from/to dates of a release calendar are malformed
+var ErrInvalidReleaseWindow = errors.New("invalid release window")
+
+// GetReleaseCalendar retrieves games released within the given window.
+// from defaults to today and to defaults to defaultCalendarDays after from.
+func (s *GameService) GetReleaseCalendar(fromStr, toStr string) (*models.ReleaseCalendar, error) {
+	from, to, err := parseReleaseWindow(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+
+	games, err := s.repo.GetGamesReleasedBetween(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release calendar: %v", err)
+	}
+	if games == nil {
+		games = []*models.Game{}
+	}
+	s.withCanonicalURLs(games...)
+
+	return &models.ReleaseCalendar{
+		From:  from.Format("2006-01-02"),
+		To:    to.Format("2006-01-02"),
+		Games: games,
+	}, nil
+}
+
+// parseReleaseWindow validates the from/to dates of a release calendar request
+func parseReleaseWindow(fromStr, toStr string) (time.Time, time.Time, error) {
+	from := time.Now().UTC().Truncate(24 * time.Hour)
+	if fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid from date. Use YYYY-MM-DD: %v", ErrInvalidReleaseWindow, err)
+		}
+		from = parsed
+	}
+
+	to := from.AddDate(0, 0, defaultCalendarDays)
+	if toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: invalid to date. Use YYYY-MM-DD: %v", ErrInvalidReleaseWindow, err)
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: to date must not be before from date", ErrInvalidReleaseWindow)
+	}
+	if to.Sub(from) > maxCalendarDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: date window cannot exceed %d days", ErrInvalidReleaseWindow, maxCalendarDays)
+	}
+
+	return from, to, nil
+}
+
+// GetReleaseCalendarICS renders the release calendar as an iCalendar (RFC 5545) feed
+func (s *GameService) GetReleaseCalendarICS(fromStr, toStr string) ([]byte, error) {
+	calendar, err := s.GetReleaseCalendar(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeICSLine(&buf, "BEGIN:VCALENDAR")
+	writeICSLine(&buf, "VERSION:2.0")
+	writeICSLine(&buf, "PRODID:-//LUGX Gaming//Release Calendar//EN")
+	writeICSLine(&buf, "CALSCALE:GREGORIAN")
+	writeICSLine(&buf, "METHOD:PUBLISH")
+	writeICSLine(&buf, "X-WR-CALNAME:LUGX Gaming Releases")
+
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, game := range calendar.Games {
+		writeICSLine(&buf, "BEGIN:VEVENT")
+		writeICSLine(&buf, fmt.Sprintf("UID:game-%d-release@lugx-gaming", game.ID))
+		writeICSLine(&buf, "DTSTAMP:"+stamp)
+		writeICSLine(&buf, "LAST-MODIFIED:"+game.UpdatedAt.UTC().Format("20060102T150405Z"))
+		// Releases are all-day events; DTEND is exclusive
+		writeICSLine(&buf, "DTSTART;VALUE=DATE:"+game.ReleasedDate.Format("20060102"))
+		writeICSLine(&buf, "DTEND;VALUE=DATE:"+game.ReleasedDate.AddDate(0, 0, 1).Format("20060102"))
+		writeICSLine(&buf, "SUMMARY:"+escapeICSText(game.Name))
+		writeICSLine(&buf, "DESCRIPTION:"+escapeICSText(fmt.Sprintf("%s release - $%.2f", game.Category, game.Price)))
+		writeICSLine(&buf, "CATEGORIES:"+escapeICSText(game.Category))
+		writeICSLine(&buf, "URL:"+game.CanonicalURL)
+		writeICSLine(&buf, "TRANSP:TRANSPARENT")
+		writeICSLine(&buf, "END:VEVENT")
+	}
+
+	writeICSLine(&buf, "END:VCALENDAR")
+	return buf.Bytes(), nil
+}
+
+// writeICSLine writes a CRLF terminated content line, folding it at 75 octets
+func writeICSLine(buf *bytes.Buffer, line string) {
+	limit := 75
+	for len(line) > limit {
+		// Never split inside a multi-byte UTF-8 sequence
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards the limit
+		limit = 74
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// escapeICSText escapes a TEXT property value
+func escapeICSText(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(text)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Link      atomLink     `xml:"link"`
+	Category  atomCategory `xml:"category"`
+	Summary   string       `xml:"summary"`
+}
+
+// GetGamesAtomFeed renders an Atom (RFC 4287) feed of games added to the catalog
+// or released within the last atomFeedDays days. selfURL is the feed's own URL.
+func (s *GameService) GetGamesAtomFeed(selfURL string) ([]byte, error) {
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -atomFeedDays)
+
+	games, err := s.repo.GetRecentlyAddedOrReleasedGames(since, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get games feed: %v", err)
+	}
+	s.withCanonicalURLs(games...)
+
+	feed := atomFeed{
+		ID:      selfURL,
+		Title:   "LUGX Gaming - New and Released Games",
+		Updated: now.Format(time.RFC3339),
+		Author:  atomAuthor{Name: "LUGX Gaming"},
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: selfURL},
+			{Rel: "alternate", Type: "text/html", Href: s.storefrontURL + "/shop.html"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	var latest time.Time
+	addEntry := func(game *models.Game, kind, title string, at time.Time) {
+		if at.After(latest) {
+			latest = at
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			// Tag URIs keep entry IDs stable even if the game is renamed
+			ID:        fmt.Sprintf("tag:lugx-gaming,2024:game/%d/%s", game.ID, kind),
+			Title:     title,
+			Updated:   at.UTC().Format(time.RFC3339),
+			Published: at.UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Type: "text/html", Href: game.CanonicalURL},
+			Category:  atomCategory{Term: game.Category},
+			Summary: fmt.Sprintf("%s (%s) releases on %s for $%.2f",
+				game.Name, game.Category, game.ReleasedDate.Format("2006-01-02"), game.Price),
+		})
+	}
+
+	for _, game := range games {
+		if !game.CreatedAt.Before(since) && !game.CreatedAt.After(now) {
+			addEntry(game, "added", "New in store: "+game.Name, game.CreatedAt)
+		}
+		if !game.ReleasedDate.Before(since) && !game.ReleasedDate.After(now) {
+			addEntry(game, "released", "Out now: "+game.Name, game.ReleasedDate)
+		}
+	}
+
+	sort.SliceStable(feed.Entries, func(i, j int) bool {
+		return feed.Entries[i].Updated > feed.Entries[j].Updated
+	})
+
+	if !latest.IsZero() {
+		feed.Updated = latest.UTC().Format(time.RFC3339)
+	}
+
+	output, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render games feed: %v", err)
+	}
+
+	return append([]byte(xml.Header), output...), nil
+}
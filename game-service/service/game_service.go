@@ -1,7 +1,11 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
 	"game-service/models"
@@ -9,13 +13,50 @@ import (
 )
 
 type GameService struct {
-	repo *repository.GameRepository
+	repo          *repository.GameRepository
+	storefrontURL string
 }
 
 // NewGameService creates a new game service
 func NewGameService() *GameService {
 	return &GameService{
-		repo: repository.NewGameRepository(),
+		repo:          repository.NewGameRepository(),
+		storefrontURL: storefrontBaseURL(),
+	}
+}
+
+// storefrontBaseURL returns the public URL of the front-end without a trailing slash
+func storefrontBaseURL() string {
+	storefrontURL := os.Getenv("STOREFRONT_BASE_URL")
+	if storefrontURL == "" {
+		storefrontURL = "http://localhost:30000"
+	}
+	return strings.TrimRight(storefrontURL, "/")
+}
+
+// publicBaseURL returns the public URL of game-service itself without a trailing slash
+func publicBaseURL() string {
+	publicURL := os.Getenv("PUBLIC_BASE_URL")
+	if publicURL == "" {
+		publicURL = "http://localhost:30080"
+	}
+	return strings.TrimRight(publicURL, "/")
+}
+
+// CanonicalURL returns the storefront product page URL for a game slug
+func (s *GameService) CanonicalURL(slug string) string {
+	return productPageURL(s.storefrontURL, slug)
+}
+
+// productPageURL builds the storefront product page URL for a game slug
+func productPageURL(storefrontURL, slug string) string {
+	return fmt.Sprintf("%s/product-details.html?slug=%s", storefrontURL, url.QueryEscape(slug))
+}
+
+// withCanonicalURLs fills in the canonical URL of each game
+func (s *GameService) withCanonicalURLs(games ...*models.Game) {
+	for _, game := range games {
+		game.CanonicalURL = s.CanonicalURL(game.Slug)
 	}
 }
 
@@ -33,6 +74,10 @@ func (s *GameService) CreateGame(req *models.CreateGameRequest) (*models.Game, e
 		Category:     req.Category,
 		ReleasedDate: releaseDate,
 		Price:        req.Price,
+		Purchasable:  true,
+	}
+	if req.Purchasable != nil {
+		game.Purchasable = *req.Purchasable
 	}
 
 	// Save to database
@@ -41,6 +86,7 @@ func (s *GameService) CreateGame(req *models.CreateGameRequest) (*models.Game, e
 		return nil, fmt.Errorf("failed to create game: %v", err)
 	}
 
+	s.withCanonicalURLs(createdGame)
 	return createdGame, nil
 }
 
@@ -50,6 +96,17 @@ func (s *GameService) GetGameByID(id int) (*models.Game, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.withCanonicalURLs(game)
+	return game, nil
+}
+
+// GetGameBySlug retrieves a game by its current or a previous slug
+func (s *GameService) GetGameBySlug(slug string) (*models.Game, error) {
+	game, err := s.repo.GetGameBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	s.withCanonicalURLs(game)
 	return game, nil
 }
 
@@ -59,9 +116,63 @@ func (s *GameService) GetAllGames() ([]*models.Game, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get games: %v", err)
 	}
+	s.withCanonicalURLs(games...)
 	return games, nil
 }
 
+// maxBatchGameIDs limits how many games can be fetched in a single batch request
+const maxBatchGameIDs = 100
+
+// ErrInvalidGameIDs is returned when a batch lookup asks for no games or too many
+var ErrInvalidGameIDs = errors.New("invalid game IDs")
+
+// GetGamesByIDs retrieves several games with a single query. Games are returned
+// in the requested order, duplicates are collapsed and unknown IDs are reported
+// separately.
+func (s *GameService) GetGamesByIDs(ids []int) (*models.BatchGamesResponse, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("%w: at least one game ID is required", ErrInvalidGameIDs)
+	}
+
+	seen := make(map[int]bool, len(ids))
+	uniqueIDs := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+
+	if len(uniqueIDs) > maxBatchGameIDs {
+		return nil, fmt.Errorf("%w: cannot fetch more than %d games at once", ErrInvalidGameIDs, maxBatchGameIDs)
+	}
+
+	games, err := s.repo.GetGamesByIDs(uniqueIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get games: %v", err)
+	}
+	s.withCanonicalURLs(games...)
+
+	byID := make(map[int]*models.Game, len(games))
+	for _, game := range games {
+		byID[game.ID] = game
+	}
+
+	response := &models.BatchGamesResponse{
+		Games:      make([]*models.Game, 0, len(uniqueIDs)),
+		MissingIDs: []int{},
+	}
+	for _, id := range uniqueIDs {
+		if game, ok := byID[id]; ok {
+			response.Games = append(response.Games, game)
+		} else {
+			response.MissingIDs = append(response.MissingIDs, id)
+		}
+	}
+
+	return response, nil
+}
+
 // UpdateGame updates an existing game
 func (s *GameService) UpdateGame(id int, req *models.UpdateGameRequest) (*models.Game, error) {
 	// Validate date format if provided
@@ -81,6 +192,7 @@ func (s *GameService) UpdateGame(id int, req *models.UpdateGameRequest) (*models
 	if err != nil {
 		return nil, err
 	}
+	s.withCanonicalURLs(updatedGame)
 
 	return updatedGame, nil
 }
@@ -100,5 +212,6 @@ func (s *GameService) GetGamesByCategory(category string) ([]*models.Game, error
 	if err != nil {
 		return nil, fmt.Errorf("failed to get games by category: %v", err)
 	}
+	s.withCanonicalURLs(games...)
 	return games, nil
 }
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"game-service/models"
+	"game-service/repository"
+)
+
+// maxSitemapURLs is the per-file URL limit of the sitemap protocol
+const maxSitemapURLs = 50000
+
+// ErrSitemapNotFound is returned for a sitemap file number with no games
+var ErrSitemapNotFound = errors.New("sitemap not found")
+
+// SitemapService generates sitemap.xml for the storefront's game pages, the
+// product-details.html?slug= URLs the storefront resolves through the by-slug API.
+// Games are split into sitemap files by ID range; each file is cached and only
+// regenerated when the games in its range change, so large catalogs are not
+// rebuilt in full on every request or replica.
+type SitemapService struct {
+	repo          *repository.GameRepository
+	storefrontURL string
+	publicURL     string
+	chunkSize     int
+
+	mu     sync.Mutex
+	chunks map[int]*sitemapChunk
+}
+
+// sitemapChunk is a rendered sitemap file together with the fingerprint it was built from
+type sitemapChunk struct {
+	stat models.SitemapChunkStat
+	xml  []byte
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// NewSitemapService creates a new sitemap service.
+// SITEMAP_MAX_URLS lowers the per-file limit, which is mainly useful for testing the index.
+func NewSitemapService() *SitemapService {
+	chunkSize := maxSitemapURLs
+	if value := os.Getenv("SITEMAP_MAX_URLS"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 && n < maxSitemapURLs {
+			chunkSize = n
+		}
+	}
+
+	return &SitemapService{
+		repo:          repository.NewGameRepository(),
+		storefrontURL: storefrontBaseURL(),
+		publicURL:     publicBaseURL(),
+		chunkSize:     chunkSize,
+		chunks:        make(map[int]*sitemapChunk),
+	}
+}
+
+// GetSitemap returns the root sitemap.xml. While every game fits into a single
+// file it is a plain URL set; beyond that it is a sitemap index of game sitemaps.
+func (s *SitemapService) GetSitemap() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, err := s.refresh()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stats) == 0 {
+		return renderXML(sitemapURLSet{URLs: []sitemapURL{}})
+	}
+	if len(stats) == 1 {
+		return s.chunks[stats[0].Chunk].xml, nil
+	}
+
+	index := sitemapIndex{}
+	for _, stat := range stats {
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{
+			Loc:     s.ChunkURL(stat.Chunk),
+			LastMod: stat.LastUpdated.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return renderXML(index)
+}
+
+// GetSitemapChunk returns the game sitemap file with the given number (starting at 1)
+func (s *SitemapService) GetSitemapChunk(number int) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	chunk, ok := s.chunks[number-1]
+	if !ok {
+		return nil, fmt.Errorf("%w: no games in sitemap %d", ErrSitemapNotFound, number)
+	}
+
+	return chunk.xml, nil
+}
+
+// ChunkURL returns the public URL of a game sitemap file. The files are
+// served by game-service, not the storefront, so they live under PUBLIC_BASE_URL.
+func (s *SitemapService) ChunkURL(chunk int) string {
+	return fmt.Sprintf("%s/sitemaps/games-%d.xml", s.publicURL, chunk+1)
+}
+
+// refresh compares the current per-range fingerprints with the cached ones and
+// regenerates only the ranges that changed. Callers must hold s.mu.
+func (s *SitemapService) refresh() ([]models.SitemapChunkStat, error) {
+	stats, err := s.repo.GetSitemapChunkStats(s.chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[int]bool, len(stats))
+	for _, stat := range stats {
+		current[stat.Chunk] = true
+
+		cached, ok := s.chunks[stat.Chunk]
+		if ok && cached.stat.Count == stat.Count && cached.stat.LastUpdated.Equal(stat.LastUpdated) {
+			continue
+		}
+
+		chunkXML, err := s.renderChunk(stat.Chunk)
+		if err != nil {
+			return nil, err
+		}
+		s.chunks[stat.Chunk] = &sitemapChunk{stat: stat, xml: chunkXML}
+	}
+
+	// Drop ranges whose games have all been deleted
+	for chunk := range s.chunks {
+		if !current[chunk] {
+			delete(s.chunks, chunk)
+		}
+	}
+
+	return stats, nil
+}
+
+// renderChunk builds the URL set for the games in one ID range
+func (s *SitemapService) renderChunk(chunk int) ([]byte, error) {
+	fromID := chunk*s.chunkSize + 1
+	toID := (chunk + 1) * s.chunkSize
+
+	entries, err := s.repo.GetSitemapEntries(fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	urlSet := sitemapURLSet{URLs: make([]sitemapURL, 0, len(entries))}
+	for _, entry := range entries {
+		urlSet.URLs = append(urlSet.URLs, sitemapURL{
+			Loc:     productPageURL(s.storefrontURL, entry.Slug),
+			LastMod: entry.UpdatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return renderXML(urlSet)
+}
+
+// renderXML marshals v as an XML document
+func renderXML(v interface{}) ([]byte, error) {
+	output, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render sitemap: %v", err)
+	}
+	return append([]byte(xml.Header), output...), nil
+}
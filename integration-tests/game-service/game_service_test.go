@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"net/http"
 	"testing"
@@ -14,11 +15,13 @@ const gameServiceBaseURL = "http://localhost:30080"
 type Game struct {
 	ID           int       `json:"id"`
 	Name         string    `json:"name"`
+	Slug         string    `json:"slug"`
 	Category     string    `json:"category"`
 	ReleasedDate time.Time `json:"released_date"`
 	Price        float64   `json:"price"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+	CanonicalURL string    `json:"canonical_url"`
 }
 
 type SuccessResponse struct {
@@ -399,3 +402,327 @@ func TestInvalidGameCreation(t *testing.T) {
 		t.Errorf("Expected status code 400 for invalid request, got %d", resp.StatusCode)
 	}
 }
+
+func TestGetGameBySlug(t *testing.T) {
+	// Use a unique name so the slug is not suffixed because of earlier runs
+	suffix := time.Now().UnixNano()
+	gameRequest := CreateGameRequest{
+		Name:         fmt.Sprintf("Slug Test Game %d", suffix),
+		Category:     "Adventure",
+		ReleasedDate: "2024-05-01",
+		Price:        19.99,
+	}
+
+	jsonData, err := json.Marshal(gameRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal game request: %v", err)
+	}
+
+	resp, err := http.Post(gameServiceBaseURL+"/api/v1/games", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var createResponse struct {
+		Data Game `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&createResponse); err != nil {
+		t.Fatalf("Failed to decode create response: %v", err)
+	}
+
+	oldSlug := createResponse.Data.Slug
+	expectedSlug := fmt.Sprintf("slug-test-game-%d", suffix)
+	if oldSlug != expectedSlug {
+		t.Errorf("Expected slug %s, got %s", expectedSlug, oldSlug)
+	}
+
+	getResp, err := http.Get(fmt.Sprintf("%s/api/v1/games/by-slug/%s", gameServiceBaseURL, oldSlug))
+	if err != nil {
+		t.Fatalf("Failed to get game by slug: %v", err)
+	}
+	defer getResp.Body.Close()
+
+	if getResp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", getResp.StatusCode)
+	}
+
+	var getResponse struct {
+		Data Game `json:"data"`
+	}
+	if err := json.NewDecoder(getResp.Body).Decode(&getResponse); err != nil {
+		t.Fatalf("Failed to decode get response: %v", err)
+	}
+
+	if getResponse.Data.ID != createResponse.Data.ID {
+		t.Errorf("Expected game ID %d, got %d", createResponse.Data.ID, getResponse.Data.ID)
+	}
+
+	if getResponse.Data.CanonicalURL == "" {
+		t.Errorf("Expected a canonical URL in the response")
+	}
+
+	// Rename the game; the old slug should now redirect to the new one
+	newName := fmt.Sprintf("Renamed Slug Test Game %d", suffix)
+	updateData, err := json.Marshal(UpdateGameRequest{Name: &newName})
+	if err != nil {
+		t.Fatalf("Failed to marshal update request: %v", err)
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/games/%d", gameServiceBaseURL, createResponse.Data.ID), bytes.NewBuffer(updateData))
+	if err != nil {
+		t.Fatalf("Failed to create update request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	updateResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update game: %v", err)
+	}
+	updateResp.Body.Close()
+
+	noRedirectClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	redirectResp, err := noRedirectClient.Get(fmt.Sprintf("%s/api/v1/games/by-slug/%s", gameServiceBaseURL, oldSlug))
+	if err != nil {
+		t.Fatalf("Failed to get game by old slug: %v", err)
+	}
+	defer redirectResp.Body.Close()
+
+	if redirectResp.StatusCode != http.StatusMovedPermanently {
+		t.Errorf("Expected status code 301 for old slug, got %d", redirectResp.StatusCode)
+	}
+
+	expectedLocation := fmt.Sprintf("/api/v1/games/by-slug/renamed-slug-test-game-%d", suffix)
+	if location := redirectResp.Header.Get("Location"); location != expectedLocation {
+		t.Errorf("Expected redirect to %s, got %s", expectedLocation, location)
+	}
+}
+
+func TestReleaseCalendarAndFeeds(t *testing.T) {
+	releaseDate := time.Now().AddDate(0, 0, 14).Format("2006-01-02")
+	gameRequest := CreateGameRequest{
+		Name:         fmt.Sprintf("Upcoming Release Test Game %d", time.Now().UnixNano()),
+		Category:     "Action",
+		ReleasedDate: releaseDate,
+		Price:        59.99,
+	}
+
+	jsonData, err := json.Marshal(gameRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal game request: %v", err)
+	}
+
+	resp, err := http.Post(gameServiceBaseURL+"/api/v1/games", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create game: %v", err)
+	}
+	resp.Body.Close()
+
+	calendarResp, err := http.Get(fmt.Sprintf("%s/api/v1/games/releases?from=%s&to=%s", gameServiceBaseURL, releaseDate, releaseDate))
+	if err != nil {
+		t.Fatalf("Failed to get release calendar: %v", err)
+	}
+	defer calendarResp.Body.Close()
+
+	if calendarResp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", calendarResp.StatusCode)
+	}
+
+	var calendarResponse struct {
+		Data struct {
+			Games []Game `json:"games"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(calendarResp.Body).Decode(&calendarResponse); err != nil {
+		t.Fatalf("Failed to decode release calendar response: %v", err)
+	}
+
+	found := false
+	for _, game := range calendarResponse.Data.Games {
+		if game.Name == gameRequest.Name {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected %s in the release calendar", gameRequest.Name)
+	}
+
+	icsResp, err := http.Get(gameServiceBaseURL + "/api/v1/feeds/releases.ics")
+	if err != nil {
+		t.Fatalf("Failed to get iCalendar feed: %v", err)
+	}
+	defer icsResp.Body.Close()
+
+	if contentType := icsResp.Header.Get("Content-Type"); contentType != "text/calendar; charset=utf-8" {
+		t.Errorf("Expected iCalendar content type, got %s", contentType)
+	}
+
+	atomResp, err := http.Get(gameServiceBaseURL + "/api/v1/feeds/games.atom")
+	if err != nil {
+		t.Fatalf("Failed to get Atom feed: %v", err)
+	}
+	defer atomResp.Body.Close()
+
+	if contentType := atomResp.Header.Get("Content-Type"); contentType != "application/atom+xml; charset=utf-8" {
+		t.Errorf("Expected Atom content type, got %s", contentType)
+	}
+
+	// An invalid window should be rejected
+	badResp, err := http.Get(gameServiceBaseURL + "/api/v1/games/releases?from=2024-02-01&to=2024-01-01")
+	if err != nil {
+		t.Fatalf("Failed to get release calendar: %v", err)
+	}
+	defer badResp.Body.Close()
+
+	if badResp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for invalid window, got %d", badResp.StatusCode)
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	resp, err := http.Get(gameServiceBaseURL + "/sitemap.xml")
+	if err != nil {
+		t.Fatalf("Failed to get sitemap: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", resp.StatusCode)
+	}
+
+	// The root sitemap is either a URL set or, for large catalogs, a sitemap index
+	var sitemap struct {
+		XMLName  xml.Name `xml:"http://www.sitemaps.org/schemas/sitemap/0.9"`
+		URLs     []string `xml:"url>loc"`
+		Sitemaps []string `xml:"sitemap>loc"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&sitemap); err != nil {
+		t.Fatalf("Failed to decode sitemap: %v", err)
+	}
+
+	if sitemap.XMLName.Local != "urlset" && sitemap.XMLName.Local != "sitemapindex" {
+		t.Errorf("Expected urlset or sitemapindex root element, got %s", sitemap.XMLName.Local)
+	}
+
+	t.Logf("Sitemap %s lists %d URLs and %d sitemaps", sitemap.XMLName.Local, len(sitemap.URLs), len(sitemap.Sitemaps))
+}
+
+func TestCharts(t *testing.T) {
+	for _, chart := range []string{"top-sellers", "trending"} {
+		resp, err := http.Get(fmt.Sprintf("%s/api/v1/charts/%s?window=7d&limit=5", gameServiceBaseURL, chart))
+		if err != nil {
+			t.Fatalf("Failed to get %s chart: %v", chart, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status code 200 for %s chart, got %d", chart, resp.StatusCode)
+			continue
+		}
+
+		var response struct {
+			Data struct {
+				Name    string `json:"name"`
+				Window  string `json:"window"`
+				Entries []struct {
+					Rank int  `json:"rank"`
+					Game Game `json:"game"`
+				} `json:"entries"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode %s chart: %v", chart, err)
+		}
+
+		if len(response.Data.Entries) > 5 {
+			t.Errorf("Expected at most 5 entries in %s chart, got %d", chart, len(response.Data.Entries))
+		}
+
+		for i, entry := range response.Data.Entries {
+			if entry.Rank != i+1 {
+				t.Errorf("Expected rank %d, got %d", i+1, entry.Rank)
+			}
+		}
+	}
+
+	resp, err := http.Get(gameServiceBaseURL + "/api/v1/charts/trending?window=1y")
+	if err != nil {
+		t.Fatalf("Failed to get trending chart: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for invalid window, got %d", resp.StatusCode)
+	}
+}
+
+func TestBatchGetGames(t *testing.T) {
+	var ids []int
+	for i := 0; i < 2; i++ {
+		gameRequest := CreateGameRequest{
+			Name:         fmt.Sprintf("Batch Test Game %d", i+1),
+			Category:     "Puzzle",
+			ReleasedDate: "2024-04-01",
+			Price:        9.99,
+		}
+
+		jsonData, err := json.Marshal(gameRequest)
+		if err != nil {
+			t.Fatalf("Failed to marshal game request: %v", err)
+		}
+
+		resp, err := http.Post(gameServiceBaseURL+"/api/v1/games", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to create game: %v", err)
+		}
+		defer resp.Body.Close()
+
+		var createResponse struct {
+			Data Game `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&createResponse); err != nil {
+			t.Fatalf("Failed to decode create response: %v", err)
+		}
+		ids = append(ids, createResponse.Data.ID)
+	}
+
+	// Request in reverse order together with an ID that does not exist
+	missingID := 999999999
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/games?ids=%d,%d,%d", gameServiceBaseURL, ids[1], missingID, ids[0]))
+	if err != nil {
+		t.Fatalf("Failed to batch get games: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data struct {
+			Games      []Game `json:"games"`
+			MissingIDs []int  `json:"missing_ids"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode batch response: %v", err)
+	}
+
+	if len(response.Data.Games) != 2 {
+		t.Fatalf("Expected 2 games, got %d", len(response.Data.Games))
+	}
+
+	if response.Data.Games[0].ID != ids[1] || response.Data.Games[1].ID != ids[0] {
+		t.Errorf("Expected games in requested order %d,%d, got %d,%d",
+			ids[1], ids[0], response.Data.Games[0].ID, response.Data.Games[1].ID)
+	}
+
+	if len(response.Data.MissingIDs) != 1 || response.Data.MissingIDs[0] != missingID {
+		t.Errorf("Expected missing IDs [%d], got %v", missingID, response.Data.MissingIDs)
+	}
+}
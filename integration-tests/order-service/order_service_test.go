@@ -4,32 +4,52 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
 
 const orderServiceBaseURL = "http://localhost:30081"
 
+// Orders are priced from the game catalog, so tests create their games in game-service first
+const gameServiceBaseURL = "http://localhost:30080"
+
 type Order struct {
 	ID          string      `json:"id"`
 	CustomerID  string      `json:"customer_id"`
 	TotalPrice  float64     `json:"total_price"`
+	Currency    string      `json:"currency"`
+	DiscountTotal float64   `json:"discount_total"`
+	TaxTotal    float64     `json:"tax_total"`
+	PricesIncludeTax bool   `json:"prices_include_tax"`
 	Status      string      `json:"status"`
 	OrderDate   time.Time   `json:"order_date"`
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
 	Items       []OrderItem `json:"items,omitempty"`
+	Discounts   []OrderDiscount `json:"discounts,omitempty"`
+}
+
+type OrderDiscount struct {
+	Code   *string `json:"code"`
+	Amount float64 `json:"amount"`
 }
 
 type OrderItem struct {
-	ID       string  `json:"id"`
-	OrderID  string  `json:"order_id"`
-	GameID   int     `json:"game_id"`
-	GameName string  `json:"game_name"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
-	Subtotal float64 `json:"subtotal"`
+	ID           string   `json:"id"`
+	OrderID      string   `json:"order_id"`
+	GameID       int      `json:"game_id"`
+	GameName     string   `json:"game_name"`
+	Price        float64  `json:"price"`
+	Quantity     int      `json:"quantity"`
+	Subtotal     float64  `json:"subtotal"`
+	CatalogPrice *float64 `json:"catalog_price,omitempty"`
+	Discount     float64  `json:"discount"`
+	Tax          float64  `json:"tax"`
+	Gift         *Gift    `json:"gift,omitempty"`
 }
 
 type CreateOrderRequest struct {
@@ -44,6 +64,16 @@ type CreateOrderRequest struct {
 
 type UpdateStatusRequest struct {
 	Status string `json:"status"`
+	Actor  string `json:"actor,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type StatusChange struct {
+	FromStatus *string   `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Actor      string    `json:"actor"`
+	Reason     string    `json:"reason"`
+	ChangedAt  time.Time `json:"changed_at"`
 }
 
 type OrderStats struct {
@@ -65,14 +95,54 @@ type GetOrderResponse struct {
 }
 
 type GetOrdersResponse struct {
-	Orders []Order `json:"orders"`
-	Total  int     `json:"total"`
+	Orders     []Order `json:"orders"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"next_cursor"`
 }
 
 type OrderStatsResponse struct {
 	Stats OrderStats `json:"stats"`
 }
 
+// createCatalogGame creates a game in game-service and returns its ID
+func createCatalogGame(t *testing.T, name string, price float64, purchasable bool) int {
+	t.Helper()
+
+	gameRequest := map[string]interface{}{
+		"name":          name,
+		"category":      "Action",
+		"released_date": "2024-01-01",
+		"price":         price,
+		"purchasable":   purchasable,
+	}
+
+	jsonData, err := json.Marshal(gameRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal game request: %v", err)
+	}
+
+	resp, err := http.Post(gameServiceBaseURL+"/api/v1/games", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create catalog game: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when creating catalog game, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode catalog game response: %v", err)
+	}
+
+	return response.Data.ID
+}
+
 func TestOrderServiceHealth(t *testing.T) {
 	resp, err := http.Get(orderServiceBaseURL + "/health")
 	if err != nil {
@@ -95,13 +165,13 @@ func TestCreateOrder(t *testing.T) {
 			Quantity int     `json:"quantity"`
 		}{
 			{
-				GameID:   1,
+				GameID:   createCatalogGame(t, "Test Game 1", 29.99, true),
 				GameName: "Test Game 1",
 				Price:    29.99,
 				Quantity: 2,
 			},
 			{
-				GameID:   2,
+				GameID:   createCatalogGame(t, "Test Game 2", 39.99, true),
 				GameName: "Test Game 2",
 				Price:    39.99,
 				Quantity: 1,
@@ -222,7 +292,7 @@ func TestCreateAndUpdateOrderStatus(t *testing.T) {
 			Quantity int     `json:"quantity"`
 		}{
 			{
-				GameID:   3,
+				GameID:   createCatalogGame(t, "Status Test Game", 49.99, true),
 				GameName: "Status Test Game",
 				Price:    49.99,
 				Quantity: 1,
@@ -248,36 +318,18 @@ func TestCreateAndUpdateOrderStatus(t *testing.T) {
 
 	createdOrder := createResponse.Order
 
-	// Update the order status
-	statusUpdate := UpdateStatusRequest{
-		Status: "shipped",
-	}
-
-	updateData, err := json.Marshal(statusUpdate)
-	if err != nil {
-		t.Fatalf("Failed to marshal status update: %v", err)
-	}
-
-	client := &http.Client{}
-	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/orders/%s/status", orderServiceBaseURL, createdOrder.ID), bytes.NewBuffer(updateData))
-	if err != nil {
-		t.Fatalf("Failed to create status update request: %v", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	updateResp, err := client.Do(req)
-	if err != nil {
-		t.Fatalf("Failed to update order status: %v", err)
-	}
-	defer updateResp.Body.Close()
-
-	if updateResp.StatusCode != http.StatusOK {
-		t.Errorf("Expected status code 200 for status update, got %d", updateResp.StatusCode)
-	}
+	// Walk the order through the allowed transitions up to shipped
+	var statusUpdate UpdateStatusRequest
+	for _, status := range []string{"confirmed", "processing", "shipped"} {
+		statusUpdate = UpdateStatusRequest{
+			Status: status,
+		}
 
-	var updateResponse map[string]interface{}
-	if err := json.NewDecoder(updateResp.Body).Decode(&updateResponse); err != nil {
-		t.Fatalf("Failed to decode update response: %v", err)
+		updateResp := updateOrderStatus(t, createdOrder.ID, statusUpdate)
+		if updateResp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status code 200 for status update to %s, got %d", status, updateResp.StatusCode)
+		}
+		updateResp.Body.Close()
 	}
 
 	// Verify the update was successful by fetching the order again
@@ -297,6 +349,29 @@ func TestCreateAndUpdateOrderStatus(t *testing.T) {
 	}
 }
 
+// updateOrderStatus sends a status update for an order
+func updateOrderStatus(t *testing.T, orderID string, statusUpdate UpdateStatusRequest) *http.Response {
+	t.Helper()
+
+	updateData, err := json.Marshal(statusUpdate)
+	if err != nil {
+		t.Fatalf("Failed to marshal status update: %v", err)
+	}
+
+	req, err := http.NewRequest("PUT", fmt.Sprintf("%s/api/v1/orders/%s/status", orderServiceBaseURL, orderID), bytes.NewBuffer(updateData))
+	if err != nil {
+		t.Fatalf("Failed to create status update request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to update order status: %v", err)
+	}
+
+	return resp
+}
+
 func TestGetSpecificOrder(t *testing.T) {
 	// Create an order first
 	orderRequest := CreateOrderRequest{
@@ -308,7 +383,7 @@ func TestGetSpecificOrder(t *testing.T) {
 			Quantity int     `json:"quantity"`
 		}{
 			{
-				GameID:   4,
+				GameID:   createCatalogGame(t, "Specific Order Test Game", 24.99, true),
 				GameName: "Specific Order Test Game",
 				Price:    24.99,
 				Quantity: 3,
@@ -375,7 +450,7 @@ func TestGetOrdersByCustomer(t *testing.T) {
 				Quantity int     `json:"quantity"`
 			}{
 				{
-					GameID:   i + 10,
+					GameID:   createCatalogGame(t, fmt.Sprintf("Customer Test Game %d", i+1), 19.99, true),
 					GameName: fmt.Sprintf("Customer Test Game %d", i+1),
 					Price:    19.99,
 					Quantity: 1,
@@ -437,7 +512,7 @@ func TestDeleteOrder(t *testing.T) {
 			Quantity int     `json:"quantity"`
 		}{
 			{
-				GameID:   99,
+				GameID:   createCatalogGame(t, "Order To Delete", 9.99, true),
 				GameName: "Order To Delete",
 				Price:    9.99,
 				Quantity: 1,
@@ -519,3 +594,2007 @@ func TestInvalidOrderCreation(t *testing.T) {
 		t.Errorf("Expected status code 400 for invalid request, got %d", resp.StatusCode)
 	}
 }
+
+func TestOrderUsesCatalogPrice(t *testing.T) {
+	gameID := createCatalogGame(t, "Catalog Price Test Game", 59.99, true)
+
+	// The client tries to buy the game for a cent under a different name
+	orderRequest := map[string]interface{}{
+		"customer_id": "customer_catalog_test",
+		"items": []map[string]interface{}{
+			{"game_id": gameID, "game_name": "Cheap Game", "price": 0.01, "quantity": 2},
+		},
+	}
+
+	jsonData, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal order request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/orders", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", resp.StatusCode)
+	}
+
+	var response CreateOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	item := response.Order.Items[0]
+	if item.Price != 59.99 || item.GameName != "Catalog Price Test Game" {
+		t.Errorf("Expected catalog name and price, got %s at %.2f", item.GameName, item.Price)
+	}
+
+	if item.CatalogPrice == nil || *item.CatalogPrice != 59.99 {
+		t.Errorf("Expected catalog price 59.99 to be recorded, got %v", item.CatalogPrice)
+	}
+
+	if response.Order.TotalPrice != 59.99*2 {
+		t.Errorf("Expected total price %.2f, got %.2f", 59.99*2, response.Order.TotalPrice)
+	}
+}
+
+func TestOrderRejectsUnknownAndUnpurchasableGames(t *testing.T) {
+	unpurchasableID := createCatalogGame(t, "Unreleased Test Game", 69.99, false)
+
+	for _, gameID := range []int{999999999, unpurchasableID} {
+		orderRequest := map[string]interface{}{
+			"customer_id": "customer_catalog_test",
+			"items": []map[string]interface{}{
+				{"game_id": gameID, "quantity": 1},
+			},
+		}
+
+		jsonData, err := json.Marshal(orderRequest)
+		if err != nil {
+			t.Fatalf("Failed to marshal order request: %v", err)
+		}
+
+		resp, err := http.Post(orderServiceBaseURL+"/api/v1/orders", "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			t.Fatalf("Failed to create order: %v", err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status code 400 for game %d, got %d", gameID, resp.StatusCode)
+		}
+	}
+}
+
+func TestIllegalStatusTransitionAndHistory(t *testing.T) {
+	orderRequest := map[string]interface{}{
+		"customer_id": "customer_history_test",
+		"items": []map[string]interface{}{
+			{"game_id": createCatalogGame(t, "History Test Game", 14.99, true), "quantity": 1},
+		},
+	}
+
+	jsonData, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal order request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/orders", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var createResponse CreateOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&createResponse); err != nil {
+		t.Fatalf("Failed to decode create response: %v", err)
+	}
+	orderID := createResponse.Order.ID
+
+	cancelResp := updateOrderStatus(t, orderID, UpdateStatusRequest{Status: "cancelled", Actor: "support", Reason: "Customer request"})
+	cancelResp.Body.Close()
+	if cancelResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when cancelling, got %d", cancelResp.StatusCode)
+	}
+
+	// A cancelled order can never be delivered
+	deliverResp := updateOrderStatus(t, orderID, UpdateStatusRequest{Status: "delivered"})
+	deliverResp.Body.Close()
+	if deliverResp.StatusCode != http.StatusConflict {
+		t.Errorf("Expected status code 409 for cancelled -> delivered, got %d", deliverResp.StatusCode)
+	}
+
+	historyResp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s/history", orderServiceBaseURL, orderID))
+	if err != nil {
+		t.Fatalf("Failed to get order history: %v", err)
+	}
+	defer historyResp.Body.Close()
+
+	if historyResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 for history, got %d", historyResp.StatusCode)
+	}
+
+	var historyResponse struct {
+		History []StatusChange `json:"history"`
+	}
+	if err := json.NewDecoder(historyResp.Body).Decode(&historyResponse); err != nil {
+		t.Fatalf("Failed to decode history response: %v", err)
+	}
+
+	if len(historyResponse.History) != 2 {
+		t.Fatalf("Expected 2 history entries (created, cancelled), got %d", len(historyResponse.History))
+	}
+
+	cancelled := historyResponse.History[1]
+	if cancelled.FromStatus == nil || *cancelled.FromStatus != "pending" || cancelled.ToStatus != "cancelled" {
+		t.Errorf("Expected pending -> cancelled, got %v -> %s", cancelled.FromStatus, cancelled.ToStatus)
+	}
+
+	if cancelled.Actor != "support" || cancelled.Reason != "Customer request" {
+		t.Errorf("Expected actor and reason to be recorded, got %q and %q", cancelled.Actor, cancelled.Reason)
+	}
+}
+
+type Cart struct {
+	ID         string     `json:"id"`
+	CustomerID *string    `json:"customer_id"`
+	GuestToken *string    `json:"guest_token"`
+	Status     string     `json:"status"`
+	Items      []CartItem `json:"items"`
+	ItemCount  int        `json:"item_count"`
+	TotalPrice float64    `json:"total_price"`
+}
+
+type CartItem struct {
+	GameID    int     `json:"game_id"`
+	GameName  string  `json:"game_name"`
+	UnitPrice float64 `json:"unit_price"`
+	Quantity  int     `json:"quantity"`
+	Subtotal  float64 `json:"subtotal"`
+	Available bool    `json:"available"`
+}
+
+// cartRequest sends a cart request with the given identity headers
+func cartRequest(t *testing.T, method, path string, headers map[string]string, body interface{}) *http.Response {
+	t.Helper()
+
+	reader := bytes.NewBuffer(nil)
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("Failed to marshal cart request: %v", err)
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, orderServiceBaseURL+"/api/v1/cart"+path, reader)
+	if err != nil {
+		t.Fatalf("Failed to create cart request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send cart request: %v", err)
+	}
+
+	return resp
+}
+
+func TestGuestCartMergeAndCheckout(t *testing.T) {
+	firstGameID := createCatalogGame(t, "Cart Test Game A", 10.00, true)
+	secondGameID := createCatalogGame(t, "Cart Test Game B", 5.50, true)
+	customerID := fmt.Sprintf("customer_cart_test_%d", time.Now().UnixNano())
+
+	// A guest without a token gets a new cart
+	resp := cartRequest(t, http.MethodPost, "/items", nil, map[string]interface{}{"game_id": firstGameID, "quantity": 1})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when adding to a guest cart, got %d", resp.StatusCode)
+	}
+
+	var guestResponse struct {
+		Cart Cart `json:"cart"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&guestResponse); err != nil {
+		t.Fatalf("Failed to decode cart response: %v", err)
+	}
+	if guestResponse.Cart.GuestToken == nil || *guestResponse.Cart.GuestToken == "" {
+		t.Fatalf("Expected a guest token for the new cart")
+	}
+	guestHeaders := map[string]string{"X-Cart-Token": *guestResponse.Cart.GuestToken}
+
+	addResp := cartRequest(t, http.MethodPost, "/items", guestHeaders, map[string]interface{}{"game_id": secondGameID, "quantity": 2})
+	addResp.Body.Close()
+	if addResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when adding a second game, got %d", addResp.StatusCode)
+	}
+
+	// The customer already had the first game in their cart
+	customerHeaders := map[string]string{"X-Customer-ID": customerID}
+	customerResp := cartRequest(t, http.MethodPost, "/items", customerHeaders, map[string]interface{}{"game_id": firstGameID, "quantity": 1})
+	customerResp.Body.Close()
+	if customerResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when adding to the customer cart, got %d", customerResp.StatusCode)
+	}
+
+	mergeResp := cartRequest(t, http.MethodPost, "/merge", map[string]string{
+		"X-Customer-ID": customerID,
+		"X-Cart-Token":  *guestResponse.Cart.GuestToken,
+	}, nil)
+	defer mergeResp.Body.Close()
+	if mergeResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when merging, got %d", mergeResp.StatusCode)
+	}
+
+	var mergeResponse struct {
+		Cart Cart `json:"cart"`
+	}
+	if err := json.NewDecoder(mergeResp.Body).Decode(&mergeResponse); err != nil {
+		t.Fatalf("Failed to decode merge response: %v", err)
+	}
+	if mergeResponse.Cart.ItemCount != 4 {
+		t.Errorf("Expected 4 items after merging, got %d", mergeResponse.Cart.ItemCount)
+	}
+	if mergeResponse.Cart.TotalPrice != 31.00 {
+		t.Errorf("Expected merged total 31.00, got %.2f", mergeResponse.Cart.TotalPrice)
+	}
+
+	// The guest cart cannot be used after the merge
+	guestResp := cartRequest(t, http.MethodGet, "", guestHeaders, nil)
+	guestResp.Body.Close()
+	if guestResp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code 404 for a merged guest cart, got %d", guestResp.StatusCode)
+	}
+
+	checkoutResp := cartRequest(t, http.MethodPost, "/checkout", customerHeaders, nil)
+	defer checkoutResp.Body.Close()
+	if checkoutResp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201 for checkout, got %d", checkoutResp.StatusCode)
+	}
+
+	var orderResponse CreateOrderResponse
+	if err := json.NewDecoder(checkoutResp.Body).Decode(&orderResponse); err != nil {
+		t.Fatalf("Failed to decode checkout response: %v", err)
+	}
+	if orderResponse.Order.CustomerID != customerID || orderResponse.Order.Status != "pending" {
+		t.Errorf("Expected a pending order for %s, got %s for %s", customerID, orderResponse.Order.Status, orderResponse.Order.CustomerID)
+	}
+	if orderResponse.Order.TotalPrice != 31.00 || len(orderResponse.Order.Items) != 2 {
+		t.Errorf("Expected 2 items totalling 31.00, got %d items totalling %.2f", len(orderResponse.Order.Items), orderResponse.Order.TotalPrice)
+	}
+
+	// Checking out again finds a fresh, empty cart
+	emptyResp := cartRequest(t, http.MethodPost, "/checkout", customerHeaders, nil)
+	emptyResp.Body.Close()
+	if emptyResp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 when checking out an empty cart, got %d", emptyResp.StatusCode)
+	}
+}
+
+func TestUpdateAndRemoveCartItems(t *testing.T) {
+	gameID := createCatalogGame(t, "Cart Update Test Game", 20.00, true)
+	headers := map[string]string{"X-Customer-ID": fmt.Sprintf("customer_cart_update_%d", time.Now().UnixNano())}
+
+	addResp := cartRequest(t, http.MethodPost, "/items", headers, map[string]interface{}{"game_id": gameID, "quantity": 1})
+	addResp.Body.Close()
+	if addResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when adding, got %d", addResp.StatusCode)
+	}
+
+	updateResp := cartRequest(t, http.MethodPut, fmt.Sprintf("/items/%d", gameID), headers, map[string]interface{}{"quantity": 3})
+	defer updateResp.Body.Close()
+	if updateResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when updating, got %d", updateResp.StatusCode)
+	}
+
+	var updateResponse struct {
+		Cart Cart `json:"cart"`
+	}
+	if err := json.NewDecoder(updateResp.Body).Decode(&updateResponse); err != nil {
+		t.Fatalf("Failed to decode update response: %v", err)
+	}
+	if len(updateResponse.Cart.Items) != 1 || updateResponse.Cart.Items[0].Quantity != 3 || updateResponse.Cart.TotalPrice != 60.00 {
+		t.Errorf("Expected 3 units totalling 60.00, got %+v", updateResponse.Cart)
+	}
+
+	removeResp := cartRequest(t, http.MethodDelete, fmt.Sprintf("/items/%d", gameID), headers, nil)
+	removeResp.Body.Close()
+	if removeResp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when removing, got %d", removeResp.StatusCode)
+	}
+
+	missingResp := cartRequest(t, http.MethodDelete, fmt.Sprintf("/items/%d", gameID), headers, nil)
+	missingResp.Body.Close()
+	if missingResp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code 404 when removing a game that is not in the cart, got %d", missingResp.StatusCode)
+	}
+}
+
+type Refund struct {
+	ID               string       `json:"id"`
+	OrderID          string       `json:"order_id"`
+	Status           string       `json:"status"`
+	Amount           float64      `json:"amount"`
+	PaymentAttemptID *string      `json:"payment_attempt_id"`
+	Items            []RefundItem `json:"items"`
+}
+
+type RefundItem struct {
+	OrderItemID string  `json:"order_item_id"`
+	Quantity    int     `json:"quantity"`
+	Amount      float64 `json:"amount"`
+}
+
+type PaymentAttempt struct {
+	ID             string  `json:"id"`
+	OrderID        string  `json:"order_id"`
+	Status         string  `json:"status"`
+	Amount         float64 `json:"amount"`
+	CapturedAmount float64 `json:"captured_amount"`
+	RefundedAmount float64 `json:"refunded_amount"`
+	FailureReason  *string `json:"failure_reason"`
+}
+
+// createPendingOrder places an order for units of one newly created catalog game
+func createPendingOrder(t *testing.T, customerID string, price float64, quantity int) Order {
+	t.Helper()
+
+	orderRequest := map[string]interface{}{
+		"customer_id": customerID,
+		"items": []map[string]interface{}{
+			{"game_id": createCatalogGame(t, "Payment Test Game", price, true), "quantity": quantity},
+		},
+	}
+
+	jsonData, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal order request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/orders", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when creating order, got %d", resp.StatusCode)
+	}
+
+	var createResponse CreateOrderResponse
+	if err := json.NewDecoder(resp.Body).Decode(&createResponse); err != nil {
+		t.Fatalf("Failed to decode create response: %v", err)
+	}
+
+	return createResponse.Order
+}
+
+// postPayment sends a payment request and decodes the payment attempt from the response
+func postPayment(t *testing.T, path string, body interface{}) (int, PaymentAttempt) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal payment request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+path, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to send payment request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Payment PaymentAttempt `json:"payment"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+
+	return resp.StatusCode, response.Payment
+}
+
+// waitForOrderStatus polls an order until it reaches the expected status, as
+// payment outcomes arrive asynchronously through webhooks
+func waitForOrderStatus(t *testing.T, orderID, expected string) {
+	t.Helper()
+
+	var status string
+	for i := 0; i < 20; i++ {
+		resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s", orderServiceBaseURL, orderID))
+		if err != nil {
+			t.Fatalf("Failed to get order: %v", err)
+		}
+
+		var response GetOrderResponse
+		json.NewDecoder(resp.Body).Decode(&response)
+		resp.Body.Close()
+
+		status = response.Order.Status
+		if status == expected {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected order %s to become %s, still %s", orderID, expected, status)
+}
+
+// payOrder pays an order in full through the mock provider, authorizing and
+// capturing a payment for it
+func payOrder(t *testing.T, orderID string) {
+	t.Helper()
+
+	status, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", orderID), nil)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when creating payment, got %d", status)
+	}
+	status, _ = postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200 when authorizing, got %d", status)
+	}
+	status, _ = postPayment(t, "/api/v1/payments/"+payment.ID+"/capture", nil)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200 when capturing, got %d", status)
+	}
+}
+
+func TestPaymentConfirmsOrder(t *testing.T) {
+	order := createPendingOrder(t, "customer_payment_test", 25.00, 1)
+
+	status, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", order.ID), nil)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when creating payment, got %d", status)
+	}
+	if payment.Status != "requires_authorization" || payment.Amount != 25.00 {
+		t.Errorf("Expected a 25.00 payment requiring authorization, got %s for %.2f", payment.Status, payment.Amount)
+	}
+
+	status, payment = postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	if status != http.StatusOK || payment.Status != "authorized" {
+		t.Fatalf("Expected payment to be authorized, got status code %d and %s", status, payment.Status)
+	}
+
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	status, payment = postPayment(t, "/api/v1/payments/"+payment.ID+"/capture", nil)
+	if status != http.StatusOK || payment.Status != "captured" || payment.CapturedAmount != 25.00 {
+		t.Errorf("Expected 25.00 to be captured, got status code %d, %s and %.2f", status, payment.Status, payment.CapturedAmount)
+	}
+
+	// A captured payment cannot be voided
+	status, _ = postPayment(t, "/api/v1/payments/"+payment.ID+"/void", nil)
+	if status != http.StatusConflict {
+		t.Errorf("Expected status code 409 when voiding a captured payment, got %d", status)
+	}
+}
+
+func TestDeclinedPaymentCancelsOrder(t *testing.T) {
+	order := createPendingOrder(t, "customer_payment_declined_test", 15.00, 1)
+
+	_, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", order.ID), nil)
+
+	status, payment := postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_declined"})
+	if status != http.StatusPaymentRequired {
+		t.Fatalf("Expected status code 402 for a declined payment, got %d", status)
+	}
+	if payment.Status != "failed" || payment.FailureReason == nil || *payment.FailureReason != "card_declined" {
+		t.Errorf("Expected a failed payment declined with card_declined, got %+v", payment)
+	}
+
+	waitForOrderStatus(t, order.ID, "cancelled")
+}
+
+func TestPaymentOfCancelledOrderNotCaptured(t *testing.T) {
+	order := createPendingOrder(t, "customer_payment_cancelled_test", 20.00, 1)
+
+	_, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", order.ID), nil)
+	status, payment := postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected payment to be authorized, got status code %d", status)
+	}
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	resp := updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: "cancelled", Reason: "customer changed their mind"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 when cancelling, got %d", resp.StatusCode)
+	}
+
+	// The customer must not be charged for a cancelled order
+	status, _ = postPayment(t, "/api/v1/payments/"+payment.ID+"/capture", nil)
+	if status != http.StatusConflict {
+		t.Errorf("Expected status code 409 when capturing a payment of a cancelled order, got %d", status)
+	}
+}
+
+func TestPaymentWebhookRequiresSignature(t *testing.T) {
+	body := []byte(`{"id":"evt_unsigned","type":"payment.authorized","payment_id":"pi_unknown"}`)
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/payments/webhook", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		t.Fatalf("Failed to send webhook: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected status code 401 for an unsigned webhook, got %d", resp.StatusCode)
+	}
+}
+
+// postRefund sends a refund request and decodes the refund from the response
+func postRefund(t *testing.T, path string, body interface{}) (int, Refund) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Failed to marshal refund request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+path, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to send refund request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Refund Refund `json:"refund"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+
+	return resp.StatusCode, response.Refund
+}
+
+func TestLineItemRefundWithApproval(t *testing.T) {
+	order := createPendingOrder(t, "customer_refund_test", 10.00, 2)
+
+	_, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", order.ID), nil)
+	postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	waitForOrderStatus(t, order.ID, "confirmed")
+	if status, _ := postPayment(t, "/api/v1/payments/"+payment.ID+"/capture", nil); status != http.StatusOK {
+		t.Fatalf("Expected status code 200 when capturing, got %d", status)
+	}
+
+	// Refund one of the two units
+	status, refund := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", order.ID), map[string]interface{}{
+		"reason": "One copy bought by mistake",
+		"items":  []map[string]interface{}{{"order_item_id": order.Items[0].ID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when requesting refund, got %d", status)
+	}
+	if refund.Status != "requested" || refund.Amount != 10.00 || len(refund.Items) != 1 {
+		t.Errorf("Expected a requested 10.00 refund for one item, got %+v", refund)
+	}
+
+	status, refund = postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", map[string]string{"actor": "support"})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200 when approving refund, got %d", status)
+	}
+	if refund.Status != "completed" || refund.PaymentAttemptID == nil || *refund.PaymentAttemptID != payment.ID {
+		t.Errorf("Expected refund to be completed through payment %s, got %+v", payment.ID, refund)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s", orderServiceBaseURL, order.ID))
+	if err != nil {
+		t.Fatalf("Failed to get order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var orderResponse struct {
+		Order struct {
+			TotalPrice     float64 `json:"total_price"`
+			RefundedAmount float64 `json:"refunded_amount"`
+			NetTotal       float64 `json:"net_total"`
+		} `json:"order"`
+	}
+	json.NewDecoder(resp.Body).Decode(&orderResponse)
+	if orderResponse.Order.RefundedAmount != 10.00 || orderResponse.Order.NetTotal != 10.00 {
+		t.Errorf("Expected 10.00 refunded and 10.00 net, got %.2f and %.2f", orderResponse.Order.RefundedAmount, orderResponse.Order.NetTotal)
+	}
+
+	// Only 10.00 is left to refund
+	status, _ = postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", order.ID), map[string]interface{}{
+		"reason": "Too much",
+		"amount": 15.00,
+	})
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 when refunding more than is left, got %d", status)
+	}
+}
+
+func TestRejectRefund(t *testing.T) {
+	order := createPendingOrder(t, "customer_refund_reject_test", 30.00, 1)
+
+	// Pending orders have not been paid
+	status, _ := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", order.ID), map[string]interface{}{"reason": "Not paid"})
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 when refunding a pending order, got %d", status)
+	}
+
+	updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: "confirmed"}).Body.Close()
+
+	status, refund := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", order.ID), map[string]interface{}{
+		"reason": "Changed my mind",
+		"amount": 5.00,
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when requesting refund, got %d", status)
+	}
+
+	status, refund = postRefund(t, "/api/v1/refunds/"+refund.ID+"/reject", map[string]string{"note": "Outside the refund window"})
+	if status != http.StatusOK || refund.Status != "rejected" {
+		t.Errorf("Expected the refund to be rejected, got status code %d and %s", status, refund.Status)
+	}
+
+	// A rejected refund cannot be approved
+	status, _ = postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", nil)
+	if status != http.StatusConflict {
+		t.Errorf("Expected status code 409 when approving a rejected refund, got %d", status)
+	}
+}
+
+func TestRefundRequiresCapturedPayment(t *testing.T) {
+	// Cancelled orders were never charged
+	cancelled := createPendingOrder(t, "customer_refund_cancelled_test", 12.00, 1)
+	updateOrderStatus(t, cancelled.ID, UpdateStatusRequest{Status: "cancelled"}).Body.Close()
+	status, _ := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", cancelled.ID), map[string]interface{}{"reason": "Cancelled"})
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 when refunding a cancelled order, got %d", status)
+	}
+
+	// Paid orders keep their captured money when cancelled, so they are refunded as usual
+	paidCancelled := createPendingOrder(t, "customer_refund_paid_cancelled_test", 12.00, 1)
+	payOrder(t, paidCancelled.ID)
+	waitForOrderStatus(t, paidCancelled.ID, "confirmed")
+	updateOrderStatus(t, paidCancelled.ID, UpdateStatusRequest{Status: "cancelled"}).Body.Close()
+	status, refund := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", paidCancelled.ID), map[string]interface{}{"reason": "Cancelled after payment"})
+	if status != http.StatusCreated || refund.Amount != 12.00 {
+		t.Fatalf("Expected a 12.00 refund of the cancelled paid order, got status code %d and %+v", status, refund)
+	}
+	status, refund = postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", nil)
+	if status != http.StatusOK || refund.Status != "completed" || refund.PaymentAttemptID == nil {
+		t.Errorf("Expected the refund to be paid back through the captured payment, got status code %d and %+v", status, refund)
+	}
+
+	// Authorized payments are voided rather than refunded
+	authorized := createPendingOrder(t, "customer_refund_authorized_test", 12.00, 1)
+	_, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", authorized.ID), nil)
+	postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	waitForOrderStatus(t, authorized.ID, "confirmed")
+	status, _ = postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", authorized.ID), map[string]interface{}{"reason": "Not captured"})
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 when refunding an authorized payment, got %d", status)
+	}
+
+	// Orders without payments are only settled outside the provider when the approval says so
+	legacy := createPendingOrder(t, "customer_refund_legacy_test", 12.00, 1)
+	updateOrderStatus(t, legacy.ID, UpdateStatusRequest{Status: "confirmed"}).Body.Close()
+	status, refund = postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", legacy.ID), map[string]interface{}{"reason": "Paid by bank transfer"})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when requesting refund, got %d", status)
+	}
+	status, failed := postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", nil)
+	if status != http.StatusBadGateway || failed.Status != "failed" {
+		t.Errorf("Expected the refund to fail without settle_outside_provider, got status code %d and %s", status, failed.Status)
+	}
+	status, refund = postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", map[string]interface{}{"settle_outside_provider": true})
+	if status != http.StatusOK || refund.Status != "completed" || refund.PaymentAttemptID != nil {
+		t.Errorf("Expected the refund to be completed without a payment, got status code %d and %+v", status, refund)
+	}
+}
+
+// postOrderWithIdempotencyKey creates an order with an Idempotency-Key header
+func postOrderWithIdempotencyKey(t *testing.T, key string, orderRequest interface{}) (*http.Response, CreateOrderResponse) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Errorf("Failed to marshal order request: %v", err)
+		return nil, CreateOrderResponse{}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, orderServiceBaseURL+"/api/v1/orders", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Errorf("Failed to create request: %v", err)
+		return nil, CreateOrderResponse{}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("Failed to create order: %v", err)
+		return nil, CreateOrderResponse{}
+	}
+	defer resp.Body.Close()
+
+	var response CreateOrderResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+
+	return resp, response
+}
+
+func TestIdempotentOrderCreation(t *testing.T) {
+	gameID := createCatalogGame(t, "Idempotency Test Game", 12.50, true)
+	key := fmt.Sprintf("idempotency-test-%d", time.Now().UnixNano())
+	orderRequest := map[string]interface{}{
+		"customer_id": "customer_idempotency_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	}
+
+	// Concurrent retries with the same key must create a single order
+	const requests = 5
+	orderIDs := make([]string, requests)
+	statusCodes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, response := postOrderWithIdempotencyKey(t, key, orderRequest)
+			if resp != nil {
+				statusCodes[i] = resp.StatusCode
+				orderIDs[i] = response.Order.ID
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < requests; i++ {
+		if statusCodes[i] != http.StatusCreated {
+			t.Errorf("Expected status code 201 for request %d, got %d", i, statusCodes[i])
+		}
+		if orderIDs[i] == "" || orderIDs[i] != orderIDs[0] {
+			t.Errorf("Expected every request to return order %s, request %d got %q", orderIDs[0], i, orderIDs[i])
+		}
+	}
+
+	// A later retry is replayed
+	resp, response := postOrderWithIdempotencyKey(t, key, orderRequest)
+	if resp == nil {
+		t.FailNow()
+	}
+	if resp.Header.Get("Idempotent-Replayed") != "true" || response.Order.ID != orderIDs[0] {
+		t.Errorf("Expected order %s to be replayed, got %s (replayed header %q)", orderIDs[0], response.Order.ID, resp.Header.Get("Idempotent-Replayed"))
+	}
+
+	// The same key with a different payload is rejected
+	orderRequest["items"] = []map[string]interface{}{{"game_id": gameID, "quantity": 2}}
+	resp, _ = postOrderWithIdempotencyKey(t, key, orderRequest)
+	if resp == nil {
+		t.FailNow()
+	}
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 for a reused key, got %d", resp.StatusCode)
+	}
+}
+
+// createPromotion creates a promotion and returns its ID
+func createPromotion(t *testing.T, promotion map[string]interface{}) string {
+	t.Helper()
+
+	jsonData, err := json.Marshal(promotion)
+	if err != nil {
+		t.Fatalf("Failed to marshal promotion request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/promotions", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create promotion: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when creating promotion, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Promotion struct {
+			ID string `json:"id"`
+		} `json:"promotion"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode promotion response: %v", err)
+	}
+
+	return response.Promotion.ID
+}
+
+func postOrder(t *testing.T, orderRequest interface{}) (int, Order) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(orderRequest)
+	if err != nil {
+		t.Fatalf("Failed to marshal order request: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/orders", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response CreateOrderResponse
+	json.NewDecoder(resp.Body).Decode(&response)
+
+	return resp.StatusCode, response.Order
+}
+
+func TestCouponDiscountsOrder(t *testing.T) {
+	gameID := createCatalogGame(t, "Coupon Test Game", 40.00, true)
+	code := fmt.Sprintf("QUARTER%d", time.Now().UnixNano())
+	createPromotion(t, map[string]interface{}{
+		"name":               "Quarter off",
+		"code":               code,
+		"type":               "percentage",
+		"value":              25,
+		"game_ids":           []int{gameID},
+		"per_customer_limit": 1,
+	})
+
+	customerID := fmt.Sprintf("customer_coupon_test_%d", time.Now().UnixNano())
+	orderRequest := map[string]interface{}{
+		"customer_id":  customerID,
+		"items":        []map[string]interface{}{{"game_id": gameID, "quantity": 2}},
+		"coupon_codes": []string{strings.ToLower(code)},
+	}
+
+	status, order := postOrder(t, orderRequest)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	if order.TotalPrice != 60.00 || order.DiscountTotal != 20.00 {
+		t.Errorf("Expected total 60.00 after a 20.00 discount, got %.2f after %.2f", order.TotalPrice, order.DiscountTotal)
+	}
+	if len(order.Items) != 1 || order.Items[0].Discount != 20.00 {
+		t.Errorf("Expected the discount to be stored on the line, got %+v", order.Items)
+	}
+
+	// Listings break the discount down like the order itself
+	status, listing := searchOrders(t, "customer_id="+customerID)
+	if status != http.StatusOK || len(listing.Orders) != 1 {
+		t.Fatalf("Expected the order to be listed, got status code %d and %d orders", status, len(listing.Orders))
+	}
+	if discounts := listing.Orders[0].Discounts; len(discounts) != 1 || discounts[0].Amount != 20.00 || discounts[0].Code == nil {
+		t.Errorf("Expected the listed order to show the 20.00 coupon discount, got %+v", discounts)
+	}
+
+	// The coupon can be used once per customer
+	status, _ = postOrder(t, orderRequest)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 for a second use of the coupon, got %d", status)
+	}
+}
+
+func TestBuyXGetYCouponUsageLimit(t *testing.T) {
+	gameID := createCatalogGame(t, "Bundle Coupon Test Game", 10.00, true)
+	code := fmt.Sprintf("B2G1%d", time.Now().UnixNano())
+	createPromotion(t, map[string]interface{}{
+		"name":         "Buy 2 get 1 free",
+		"code":         code,
+		"type":         "buy_x_get_y",
+		"buy_quantity": 2,
+		"get_quantity": 1,
+		"usage_limit":  1,
+	})
+
+	orderRequest := map[string]interface{}{
+		"customer_id":  "customer_bundle_test",
+		"items":        []map[string]interface{}{{"game_id": gameID, "quantity": 3}},
+		"coupon_codes": []string{code},
+	}
+
+	status, order := postOrder(t, orderRequest)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	if order.TotalPrice != 20.00 {
+		t.Errorf("Expected one of three units to be free (total 20.00), got %.2f", order.TotalPrice)
+	}
+
+	// The only use is gone, even for another customer
+	orderRequest["customer_id"] = "customer_bundle_test_2"
+	status, _ = postOrder(t, orderRequest)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 for a used up coupon, got %d", status)
+	}
+
+	// Unknown coupons are rejected rather than ignored
+	orderRequest["coupon_codes"] = []string{"NO-SUCH-COUPON"}
+	status, _ = postOrder(t, orderRequest)
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status code 422 for an unknown coupon, got %d", status)
+	}
+}
+
+func TestTaxExclusiveOrder(t *testing.T) {
+	gameID := createCatalogGame(t, "Sales Tax Test Game", 10.00, true)
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_sales_tax_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+		"country":     "US",
+		"region":      "CA",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	// 7.25% of 10.00 is 0.725, rounded half up and added on top
+	if order.PricesIncludeTax || order.TaxTotal != 0.73 || order.TotalPrice != 10.73 {
+		t.Errorf("Expected 0.73 tax added for a total of 10.73, got %.2f tax (inclusive %v) and total %.2f",
+			order.TaxTotal, order.PricesIncludeTax, order.TotalPrice)
+	}
+	if len(order.Items) != 1 || order.Items[0].Tax != 0.73 {
+		t.Errorf("Expected the tax to be stored on the line, got %+v", order.Items)
+	}
+}
+
+func TestTaxInclusiveOrder(t *testing.T) {
+	gameID := createCatalogGame(t, "VAT Test Game", 11.90, true)
+
+	status, created := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_vat_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+		"country":     "de",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s", orderServiceBaseURL, created.ID))
+	if err != nil {
+		t.Fatalf("Failed to get order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Order struct {
+			Order
+			TaxLines []struct {
+				Name          string  `json:"name"`
+				Rate          float64 `json:"rate"`
+				TaxableAmount float64 `json:"taxable_amount"`
+				Amount        float64 `json:"amount"`
+			} `json:"tax_lines"`
+		} `json:"order"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode order: %v", err)
+	}
+
+	// 19% VAT is already part of the 11.90 price
+	order := response.Order
+	if !order.PricesIncludeTax || order.TaxTotal != 1.90 || order.TotalPrice != 11.90 {
+		t.Errorf("Expected 1.90 VAT included in a total of 11.90, got %.2f tax (inclusive %v) and total %.2f",
+			order.TaxTotal, order.PricesIncludeTax, order.TotalPrice)
+	}
+	if len(order.TaxLines) != 1 || order.TaxLines[0].Name != "VAT" || order.TaxLines[0].TaxableAmount != 10.00 {
+		t.Errorf("Expected one VAT line on a net amount of 10.00, got %+v", order.TaxLines)
+	}
+}
+
+func TestOrderTotalsAreExact(t *testing.T) {
+	gameID := createCatalogGame(t, "Exact Money Test Game", 19.99, true)
+
+	status, created := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_exact_money_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 3}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s", orderServiceBaseURL, created.ID))
+	if err != nil {
+		t.Fatalf("Failed to get order: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Decode the raw JSON so the amounts are compared exactly as the service wrote them
+	var response struct {
+		Order struct {
+			TotalPrice json.Number `json:"total_price"`
+			Currency   string      `json:"currency"`
+			Items      []struct {
+				Subtotal json.Number `json:"subtotal"`
+			} `json:"items"`
+		} `json:"order"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
+		t.Fatalf("Failed to decode order: %v", err)
+	}
+
+	order := response.Order
+	if order.TotalPrice != "59.97" || len(order.Items) != 1 || order.Items[0].Subtotal != "59.97" {
+		t.Errorf("Expected 3 x 19.99 to total exactly 59.97, got %+v", order)
+	}
+	if order.Currency == "" {
+		t.Errorf("Expected the order to record its currency")
+	}
+}
+
+// getInvoice downloads the invoice of an order in the given format
+func getInvoice(t *testing.T, orderID, format string) (int, string, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s/invoice?format=%s", orderServiceBaseURL, orderID, format))
+	if err != nil {
+		t.Fatalf("Failed to get invoice: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read invoice: %v", err)
+	}
+	return resp.StatusCode, resp.Header.Get("X-Invoice-Number"), body
+}
+
+func TestInvoiceIssuedOnConfirmation(t *testing.T) {
+	gameID := createCatalogGame(t, "Invoice Test Game", 25.00, true)
+
+	var orderIDs []string
+	for i := 0; i < 2; i++ {
+		status, order := postOrder(t, map[string]interface{}{
+			"customer_id": "customer_invoice_test",
+			"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 2}},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected status code 201, got %d", status)
+		}
+		orderIDs = append(orderIDs, order.ID)
+	}
+
+	// Pending orders have no invoice yet
+	if status, _, _ := getInvoice(t, orderIDs[0], "html"); status != http.StatusNotFound {
+		t.Errorf("Expected status code 404 before confirmation, got %d", status)
+	}
+
+	var numbers []string
+	for _, orderID := range orderIDs {
+		resp := updateOrderStatus(t, orderID, UpdateStatusRequest{Status: "confirmed"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200 confirming the order, got %d", resp.StatusCode)
+		}
+
+		status, number, html := getInvoice(t, orderID, "html")
+		if status != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", status)
+		}
+		if !strings.Contains(string(html), number) || !strings.Contains(string(html), "Invoice Test Game") {
+			t.Errorf("Expected the HTML invoice to show number %s and the line items", number)
+		}
+		numbers = append(numbers, number)
+	}
+
+	prefix := fmt.Sprintf("INV-%d-", time.Now().Year())
+	if !strings.HasPrefix(numbers[0], prefix) || !strings.HasPrefix(numbers[1], prefix) || numbers[1] <= numbers[0] {
+		t.Errorf("Expected increasing invoice numbers of this year, got %v", numbers)
+	}
+
+	status, number, pdf := getInvoice(t, orderIDs[0], "pdf")
+	if status != http.StatusOK || number != numbers[0] || !bytes.HasPrefix(pdf, []byte("%PDF-")) {
+		t.Errorf("Expected the PDF of invoice %s, got status %d and number %s", numbers[0], status, number)
+	}
+}
+
+type OrderLicenseKeys struct {
+	OrderID     string `json:"order_id"`
+	Fulfillment *struct {
+		Status    string  `json:"status"`
+		Attempts  int     `json:"attempts"`
+		LastError *string `json:"last_error"`
+	} `json:"fulfillment"`
+	Items []struct {
+		OrderItemID string   `json:"order_item_id"`
+		GameID      int      `json:"game_id"`
+		LicenseKeys []string `json:"license_keys"`
+	} `json:"items"`
+}
+
+// importLicenseKeys adds license keys of a game to the key store
+func importLicenseKeys(t *testing.T, gameID int, keys []string) {
+	t.Helper()
+
+	jsonData, err := json.Marshal(map[string]interface{}{"game_id": gameID, "keys": keys})
+	if err != nil {
+		t.Fatalf("Failed to marshal license keys: %v", err)
+	}
+
+	resp, err := http.Post(orderServiceBaseURL+"/api/v1/license-keys", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		t.Fatalf("Failed to import license keys: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected status code 201 when importing license keys, got %d", resp.StatusCode)
+	}
+}
+
+// getLicenseKeys asks for the license keys of an order as the given customer
+func getLicenseKeys(t *testing.T, orderID, customerID string) (int, OrderLicenseKeys) {
+	t.Helper()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/v1/orders/%s/license-keys", orderServiceBaseURL, orderID), nil)
+	if err != nil {
+		t.Fatalf("Failed to create license keys request: %v", err)
+	}
+	req.Header.Set("X-Customer-ID", customerID)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to get license keys: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response OrderLicenseKeys
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode license keys: %v", err)
+		}
+	}
+	return resp.StatusCode, response
+}
+
+func TestLicenseKeysDeliveredOnCapture(t *testing.T) {
+	gameID := createCatalogGame(t, "License Key Test Game", 15.00, true)
+	suffix := time.Now().UnixNano()
+	importLicenseKeys(t, gameID, []string{
+		fmt.Sprintf("KEY-A-%d", suffix), fmt.Sprintf("KEY-B-%d", suffix), fmt.Sprintf("KEY-C-%d", suffix),
+	})
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_license_key_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 2}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	// Confirming an unpaid order issues no keys
+	resp := updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: "confirmed"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 confirming the order, got %d", resp.StatusCode)
+	}
+	status, keys := getLicenseKeys(t, order.ID, "customer_license_key_test")
+	if status != http.StatusOK || keys.Fulfillment != nil || len(keys.Items) != 0 {
+		t.Errorf("Expected no fulfillment before payment, got %d %+v %+v", status, keys.Fulfillment, keys.Items)
+	}
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	payOrder(t, order.ID)
+	waitForOrderStatus(t, order.ID, "delivered")
+
+	status, keys = getLicenseKeys(t, order.ID, "customer_license_key_test")
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", status)
+	}
+	if keys.Fulfillment == nil || keys.Fulfillment.Status != "fulfilled" {
+		t.Errorf("Expected a fulfilled fulfillment, got %+v", keys.Fulfillment)
+	}
+	if len(keys.Items) != 1 || len(keys.Items[0].LicenseKeys) != 2 || keys.Items[0].LicenseKeys[0] == keys.Items[0].LicenseKeys[1] {
+		t.Errorf("Expected two distinct keys for the line, got %+v", keys.Items)
+	}
+
+	// Keys are only revealed to the customer who placed the order
+	if status, _ := getLicenseKeys(t, order.ID, "someone_else"); status != http.StatusNotFound {
+		t.Errorf("Expected status code 404 for another customer, got %d", status)
+	}
+}
+
+func TestFulfillmentRetriedWhenKeysArrive(t *testing.T) {
+	gameID := createCatalogGame(t, "Out Of Keys Test Game", 15.00, true)
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_fulfillment_retry_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	payOrder(t, order.ID)
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	// Without keys the order stays confirmed and the failure is recorded
+	_, keys := getLicenseKeys(t, order.ID, "customer_fulfillment_retry_test")
+	if keys.Fulfillment == nil || keys.Fulfillment.Status != "pending" || keys.Fulfillment.Attempts < 1 || keys.Fulfillment.LastError == nil {
+		t.Errorf("Expected a pending fulfillment with a failed attempt, got %+v", keys.Fulfillment)
+	}
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	importLicenseKeys(t, gameID, []string{fmt.Sprintf("KEY-RETRY-%d", time.Now().UnixNano())})
+	waitForOrderStatus(t, order.ID, "delivered")
+}
+
+func TestOrderDeliveredByHandGetsLicenseKeys(t *testing.T) {
+	gameID := createCatalogGame(t, "Delivered By Hand Test Game", 15.00, true)
+	customerID := fmt.Sprintf("customer_delivered_by_hand_%d", time.Now().UnixNano())
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": customerID,
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	payOrder(t, order.ID)
+	waitForOrderStatus(t, order.ID, "confirmed")
+
+	// Moving the order on by hand while it waits for keys does not skip its fulfillment
+	for _, next := range []string{"processing", "shipped", "delivered"} {
+		resp := updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: next})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200 when moving to %s, got %d", next, resp.StatusCode)
+		}
+	}
+
+	key := fmt.Sprintf("KEY-BY-HAND-%d", time.Now().UnixNano())
+	importLicenseKeys(t, gameID, []string{key})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		_, keys := getLicenseKeys(t, order.ID, customerID)
+		if keys.Fulfillment != nil && keys.Fulfillment.Status == "fulfilled" {
+			if len(keys.Items) != 1 || len(keys.Items[0].LicenseKeys) != 1 || keys.Items[0].LicenseKeys[0] != key {
+				t.Errorf("Expected the imported key to be issued, got %+v", keys.Items)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the fulfillment of the delivered order to complete, got %+v", keys.Fulfillment)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// searchOrders calls GET /orders with the given query string
+func searchOrders(t *testing.T, query string) (int, GetOrdersResponse) {
+	t.Helper()
+
+	resp, err := http.Get(orderServiceBaseURL + "/api/v1/orders?" + query)
+	if err != nil {
+		t.Fatalf("Failed to search orders: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var response GetOrdersResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode orders: %v", err)
+		}
+	}
+	return resp.StatusCode, response
+}
+
+func TestSearchOrdersWithCursor(t *testing.T) {
+	gameID := createCatalogGame(t, "Search Test Game", 10.00, true)
+	customerID := fmt.Sprintf("customer_search_%d", time.Now().UnixNano())
+	for _, quantity := range []int{3, 1, 2} {
+		status, _ := postOrder(t, map[string]interface{}{
+			"customer_id": customerID,
+			"items":       []map[string]interface{}{{"game_id": gameID, "quantity": quantity}},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected status code 201, got %d", status)
+		}
+	}
+
+	query := fmt.Sprintf("customer_id=%s&game_id=%d&status=pending&sort=total_price&order=asc&page_size=2", customerID, gameID)
+	status, first := searchOrders(t, query)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", status)
+	}
+	if first.Total != 3 || len(first.Orders) != 2 || first.NextCursor == "" {
+		t.Fatalf("Expected 2 of 3 orders and a cursor, got %d of %d, cursor %q", len(first.Orders), first.Total, first.NextCursor)
+	}
+
+	status, second := searchOrders(t, query+"&cursor="+first.NextCursor)
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", status)
+	}
+	if len(second.Orders) != 1 || second.NextCursor != "" {
+		t.Fatalf("Expected the last order without a cursor, got %d orders, cursor %q", len(second.Orders), second.NextCursor)
+	}
+	if second.Total != 0 {
+		t.Errorf("Expected no total on a page fetched by cursor, got %d", second.Total)
+	}
+
+	var totals []float64
+	for _, order := range append(first.Orders, second.Orders...) {
+		totals = append(totals, order.TotalPrice)
+	}
+	if totals[0] != 10.00 || totals[1] != 20.00 || totals[2] != 30.00 {
+		t.Errorf("Expected totals 10, 20, 30 in order, got %v", totals)
+	}
+
+	_, filtered := searchOrders(t, fmt.Sprintf("customer_id=%s&min_total=15&max_total=25", customerID))
+	if filtered.Total != 1 || len(filtered.Orders) != 1 || filtered.Orders[0].TotalPrice != 20.00 {
+		t.Errorf("Expected only the 20.00 order between 15 and 25, got %+v", filtered.Orders)
+	}
+
+	// A cursor cannot be reused with another sort
+	if status, _ := searchOrders(t, fmt.Sprintf("customer_id=%s&cursor=%s", customerID, first.NextCursor)); status != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for a cursor of another sort, got %d", status)
+	}
+	if status, _ := searchOrders(t, "status=lost"); status != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for an unknown status, got %d", status)
+	}
+}
+
+type OrderStatistics struct {
+	TotalOrders     int     `json:"total_orders"`
+	DeliveredOrders int     `json:"delivered_orders"`
+	TotalRevenue    float64 `json:"total_revenue"`
+	Buckets         []struct {
+		Start           time.Time      `json:"start"`
+		OrderCount      int            `json:"order_count"`
+		DeliveredOrders int            `json:"delivered_orders"`
+		StatusCounts    map[string]int `json:"status_counts"`
+	} `json:"buckets"`
+	TopGames []struct {
+		GameID    int         `json:"game_id"`
+		UnitsSold int         `json:"units_sold"`
+		Revenue   json.Number `json:"revenue"`
+	} `json:"top_games"`
+	TopCategories []struct {
+		Category string `json:"category"`
+	} `json:"top_categories"`
+}
+
+func TestOrderStatisticsByHour(t *testing.T) {
+	gameID := createCatalogGame(t, "Statistics Test Game", 450.00, true)
+	suffix := time.Now().UnixNano()
+	importLicenseKeys(t, gameID, []string{fmt.Sprintf("KEY-STATS-A-%d", suffix), fmt.Sprintf("KEY-STATS-B-%d", suffix)})
+
+	for i := 0; i < 2; i++ {
+		status, order := postOrder(t, map[string]interface{}{
+			"customer_id": "customer_statistics_test",
+			"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected status code 201, got %d", status)
+		}
+		payOrder(t, order.ID)
+		waitForOrderStatus(t, order.ID, "delivered")
+	}
+
+	from := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+	resp, err := http.Get(orderServiceBaseURL + "/api/v1/orders/stats?granularity=hour&top=100&from=" + from)
+	if err != nil {
+		t.Fatalf("Failed to get order statistics: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Statistics OrderStatistics `json:"statistics"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode statistics: %v", err)
+	}
+	stats := response.Statistics
+
+	if stats.DeliveredOrders < 2 || stats.TotalRevenue < 900 || len(stats.Buckets) == 0 {
+		t.Errorf("Expected at least the two delivered orders in hourly buckets, got %+v", stats)
+	}
+	for _, bucket := range stats.Buckets {
+		if bucket.Start.Minute() != 0 || bucket.Start.Second() != 0 {
+			t.Errorf("Expected buckets to start on the hour, got %s", bucket.Start)
+		}
+	}
+
+	found := false
+	for _, game := range stats.TopGames {
+		if game.GameID == gameID {
+			found = true
+			if game.UnitsSold != 2 || game.Revenue.String() != "900" {
+				t.Errorf("Expected 2 units and 900 revenue for the game, got %d and %s", game.UnitsSold, game.Revenue)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected game %d among the top games", gameID)
+	}
+	if len(stats.TopCategories) == 0 {
+		t.Errorf("Expected the Action category among the top categories")
+	}
+
+	resp2, err := http.Get(orderServiceBaseURL + "/api/v1/orders/stats?granularity=year")
+	if err != nil {
+		t.Fatalf("Failed to get order statistics: %v", err)
+	}
+	resp2.Body.Close()
+	if resp2.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for an unknown granularity, got %d", resp2.StatusCode)
+	}
+}
+
+func TestCancelledOrderReleasesCoupon(t *testing.T) {
+	gameID := createCatalogGame(t, "Released Coupon Test Game", 20.00, true)
+	code := fmt.Sprintf("ONCE%d", time.Now().UnixNano())
+	createPromotion(t, map[string]interface{}{
+		"name":        "Single use",
+		"code":        code,
+		"type":        "fixed",
+		"value":       5,
+		"usage_limit": 1,
+	})
+
+	orderRequest := map[string]interface{}{
+		"customer_id":  "customer_release_test",
+		"items":        []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+		"coupon_codes": []string{code},
+	}
+	status, order := postOrder(t, orderRequest)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	resp := updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: "cancelled", Reason: "Customer request"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 cancelling the order, got %d", resp.StatusCode)
+	}
+
+	// The cancelled order gave its use back, so the coupon works again
+	orderRequest["customer_id"] = "customer_release_test_2"
+	status, order = postOrder(t, orderRequest)
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201 with the released coupon, got %d", status)
+	}
+	if order.TotalPrice != 15.00 {
+		t.Errorf("Expected the coupon to take 5.00 off (total 15.00), got %.2f", order.TotalPrice)
+	}
+}
+
+type Notification struct {
+	ID        string  `json:"id"`
+	OrderID   string  `json:"order_id"`
+	Event     string  `json:"event"`
+	Recipient string  `json:"recipient"`
+	Locale    string  `json:"locale"`
+	Status    string  `json:"status"`
+	Attempts  int     `json:"attempts"`
+	LastError *string `json:"last_error"`
+}
+
+func getOrderNotifications(t *testing.T, orderID string) []Notification {
+	t.Helper()
+
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/orders/%s/notifications", orderServiceBaseURL, orderID))
+	if err != nil {
+		t.Fatalf("Failed to get order notifications: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 getting order notifications, got %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Notifications []Notification `json:"notifications"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+	return response.Notifications
+}
+
+// waitForNotificationSent polls until the email of an event was sent; the
+// service picks up due emails every few seconds
+func waitForNotificationSent(t *testing.T, orderID, event string) Notification {
+	t.Helper()
+
+	var notification Notification
+	for i := 0; i < 60; i++ {
+		for _, n := range getOrderNotifications(t, orderID) {
+			if n.Event == event {
+				notification = n
+			}
+		}
+		if notification.Status == "sent" {
+			return notification
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected the %s email of order %s to be sent, got %+v", event, orderID, notification)
+	return notification
+}
+
+func TestOrderEmailNotifications(t *testing.T) {
+	gameID := createCatalogGame(t, "Email Test Game", 30.00, true)
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id":    "customer_email_test",
+		"customer_email": "customer_email_test@example.com",
+		"locale":         "de-AT",
+		"items":          []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	placed := waitForNotificationSent(t, order.ID, "order_placed")
+	if placed.Recipient != "customer_email_test@example.com" {
+		t.Errorf("Expected the email to go to the customer, got %s", placed.Recipient)
+	}
+	if placed.Locale != "de" {
+		t.Errorf("Expected de-AT to be sent in German, got locale %s", placed.Locale)
+	}
+
+	// Confirming an order by hand does not pay it
+	resp := updateOrderStatus(t, order.ID, UpdateStatusRequest{Status: "confirmed", Reason: "Confirmed by hand"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 confirming the order, got %d", resp.StatusCode)
+	}
+	for _, n := range getOrderNotifications(t, order.ID) {
+		if n.Event == "order_paid" {
+			t.Errorf("Expected no paid email for an unpaid order, got %+v", n)
+		}
+	}
+
+	status, order = postOrder(t, map[string]interface{}{
+		"customer_id":    "customer_email_test",
+		"customer_email": "customer_email_test@example.com",
+		"items":          []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	payOrder(t, order.ID)
+	waitForNotificationSent(t, order.ID, "order_paid")
+
+	// Orders without an email address get no emails
+	status, order = postOrder(t, map[string]interface{}{
+		"customer_id": "customer_email_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	if notifications := getOrderNotifications(t, order.ID); len(notifications) != 0 {
+		t.Errorf("Expected no emails for an order without an email address, got %d", len(notifications))
+	}
+
+	status, _ = postOrder(t, map[string]interface{}{
+		"customer_id":    "customer_email_test",
+		"customer_email": "not an email",
+		"items":          []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for an invalid email address, got %d", status)
+	}
+}
+
+type WebhookSubscription struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	Active     bool     `json:"active"`
+	Secret     string   `json:"secret"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ResponseStatus *int    `json:"response_status"`
+	Error          *string `json:"error"`
+}
+
+type WebhookDelivery struct {
+	ID         string                   `json:"id"`
+	EventType  string                   `json:"event_type"`
+	Payload    json.RawMessage          `json:"payload"`
+	Status     string                   `json:"status"`
+	Attempts   int                      `json:"attempts"`
+	LastError  *string                  `json:"last_error"`
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log"`
+}
+
+func sendWebhookRequest(t *testing.T, method, path string, body interface{}) (int, map[string]json.RawMessage) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(jsonData)
+	}
+	req, _ := http.NewRequest(method, orderServiceBaseURL+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var response map[string]json.RawMessage
+	json.NewDecoder(resp.Body).Decode(&response)
+	return resp.StatusCode, response
+}
+
+// waitForWebhookAttempt polls the delivery log of a subscription until the
+// delivery of an order's event has been attempted
+func waitForWebhookAttempt(t *testing.T, subscriptionID, orderID string) WebhookDelivery {
+	t.Helper()
+
+	for i := 0; i < 60; i++ {
+		_, response := sendWebhookRequest(t, http.MethodGet, "/api/v1/webhooks/"+subscriptionID+"/deliveries", nil)
+		var deliveries []WebhookDelivery
+		json.Unmarshal(response["deliveries"], &deliveries)
+		for _, delivery := range deliveries {
+			if strings.Contains(string(delivery.Payload), orderID) && delivery.Attempts > 0 {
+				return delivery
+			}
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+
+	t.Fatalf("Expected a webhook delivery of order %s to be attempted", orderID)
+	return WebhookDelivery{}
+}
+
+func TestOrderWebhookDeliveries(t *testing.T) {
+	status, _ := sendWebhookRequest(t, http.MethodPost, "/api/v1/webhooks", map[string]interface{}{
+		"url":         "ftp://partner.example.com/hooks",
+		"event_types": []string{"order.created"},
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for a non-HTTP URL, got %d", status)
+	}
+	status, _ = sendWebhookRequest(t, http.MethodPost, "/api/v1/webhooks", map[string]interface{}{
+		"url":         "https://partner.example.com/hooks",
+		"event_types": []string{"order.shipped"},
+	})
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected status code 400 for an unknown event type, got %d", status)
+	}
+
+	// Loopback addresses are refused when dialing, so every attempt fails
+	status, response := sendWebhookRequest(t, http.MethodPost, "/api/v1/webhooks", map[string]interface{}{
+		"url":         "http://127.0.0.1:9/hooks",
+		"event_types": []string{"order.created", "order.created"},
+		"description": "Integration test",
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	var subscription WebhookSubscription
+	json.Unmarshal(response["subscription"], &subscription)
+	defer sendWebhookRequest(t, http.MethodDelete, "/api/v1/webhooks/"+subscription.ID, nil)
+
+	if !strings.HasPrefix(subscription.Secret, "whsec_") {
+		t.Errorf("Expected a generated secret, got %q", subscription.Secret)
+	}
+	if len(subscription.EventTypes) != 1 || !subscription.Active {
+		t.Errorf("Expected an active subscription to order.created, got %+v", subscription)
+	}
+
+	_, response = sendWebhookRequest(t, http.MethodGet, "/api/v1/webhooks/"+subscription.ID, nil)
+	var fetched WebhookSubscription
+	json.Unmarshal(response["subscription"], &fetched)
+	if fetched.ID != subscription.ID || fetched.Secret != "" {
+		t.Errorf("Expected the subscription without its secret, got %+v", fetched)
+	}
+
+	gameID := createCatalogGame(t, "Webhook Test Game", 15.00, true)
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_webhook_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	delivery := waitForWebhookAttempt(t, subscription.ID, order.ID)
+	if delivery.EventType != "order.created" || delivery.Status != "pending" || delivery.LastError == nil {
+		t.Errorf("Expected a failed order.created delivery waiting for its retry, got %+v", delivery)
+	}
+	var event struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Data Order  `json:"data"`
+	}
+	json.Unmarshal(delivery.Payload, &event)
+	if event.ID == "" || event.Type != "order.created" || event.Data.ID != order.ID {
+		t.Errorf("Expected the event envelope of the order, got %s", delivery.Payload)
+	}
+
+	_, response = sendWebhookRequest(t, http.MethodGet, "/api/v1/webhook-deliveries/"+delivery.ID, nil)
+	var logged WebhookDelivery
+	json.Unmarshal(response["delivery"], &logged)
+	if len(logged.AttemptLog) == 0 || logged.AttemptLog[0].Error == nil ||
+		!strings.Contains(*logged.AttemptLog[0].Error, "webhook address not allowed") {
+		t.Errorf("Expected the refused attempt in the delivery log, got %+v", logged.AttemptLog)
+	}
+
+	status, response = sendWebhookRequest(t, http.MethodPost, "/api/v1/webhook-deliveries/"+delivery.ID+"/redeliver", nil)
+	if status != http.StatusAccepted {
+		t.Fatalf("Expected status code 202 redelivering, got %d", status)
+	}
+	var redelivered WebhookDelivery
+	json.Unmarshal(response["delivery"], &redelivered)
+	if redelivered.Status != "pending" || redelivered.Attempts != 0 {
+		t.Errorf("Expected the delivery to be pending with fresh attempts, got %+v", redelivered)
+	}
+
+	status, _ = sendWebhookRequest(t, http.MethodPut, "/api/v1/webhooks/"+subscription.ID, map[string]interface{}{
+		"url":         "http://127.0.0.1:9/hooks",
+		"event_types": []string{"*"},
+		"active":      false,
+	})
+	if status != http.StatusOK {
+		t.Fatalf("Expected status code 200 pausing the subscription, got %d", status)
+	}
+	status, _ = sendWebhookRequest(t, http.MethodPost, "/api/v1/webhook-deliveries/"+delivery.ID+"/redeliver", nil)
+	if status != http.StatusConflict {
+		t.Errorf("Expected status code 409 redelivering to a paused subscription, got %d", status)
+	}
+
+	status, _ = sendWebhookRequest(t, http.MethodDelete, "/api/v1/webhooks/"+subscription.ID, nil)
+	if status != http.StatusOK {
+		t.Errorf("Expected status code 200 deleting the subscription, got %d", status)
+	}
+	status, _ = sendWebhookRequest(t, http.MethodGet, "/api/v1/webhook-deliveries/"+delivery.ID, nil)
+	if status != http.StatusNotFound {
+		t.Errorf("Expected the deliveries to be deleted with their subscription, got status code %d", status)
+	}
+}
+
+func getOrderExport(t *testing.T, query string) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := http.Get(orderServiceBaseURL + "/api/v1/orders/export?" + query)
+	if err != nil {
+		t.Fatalf("Failed to export orders: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read order export: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestOrderExport(t *testing.T) {
+	gameID := createCatalogGame(t, "Export Test Game", 12.50, true)
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": "customer_export_test",
+		"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 2}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+	day := order.OrderDate.UTC().Format("2006-01-02")
+
+	resp, body := getOrderExport(t, "format=csv&columns=id,customer_id,total_price,item_count&from="+day+"&to="+day)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", resp.StatusCode, body)
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/csv") {
+		t.Errorf("Expected a CSV export, got %s", resp.Header.Get("Content-Type"))
+	}
+	if !strings.HasPrefix(body, "id,customer_id,total_price,item_count\n") {
+		t.Errorf("Expected the selected columns as the header, got %q", strings.SplitN(body, "\n", 2)[0])
+	}
+	expectedLine := fmt.Sprintf("%s,customer_export_test,%.2f,2\n", order.ID, order.TotalPrice)
+	if !strings.Contains(body, expectedLine) {
+		t.Errorf("Expected the export to contain %q", expectedLine)
+	}
+
+	resp, body = getOrderExport(t, "format=ndjson&rows=items&columns=order_id,game_id,quantity,order_date&timezone=Asia/Tokyo&from="+day+"&to="+day)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d: %s", resp.StatusCode, body)
+	}
+	found := false
+	for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+		var item struct {
+			OrderID   string `json:"order_id"`
+			GameID    int    `json:"game_id"`
+			Quantity  int    `json:"quantity"`
+			OrderDate string `json:"order_date"`
+		}
+		if err := json.Unmarshal([]byte(line), &item); err != nil {
+			t.Fatalf("Expected one JSON object per line, got %q: %v", line, err)
+		}
+		if item.OrderID == order.ID {
+			found = true
+			if item.GameID != gameID || item.Quantity != 2 || !strings.HasSuffix(item.OrderDate, "+09:00") {
+				t.Errorf("Expected the item of the order in Tokyo time, got %+v", item)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected the export to contain the items of order %s", order.ID)
+	}
+
+	for _, query := range []string{"format=xlsx", "columns=id,password", "timezone=Mars/Olympus", "from=2026-02-01&to=2026-01-01"} {
+		if resp, _ := getOrderExport(t, query); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status code 400 for %s, got %d", query, resp.StatusCode)
+		}
+	}
+}
+
+type Gift struct {
+	ID                  string   `json:"id"`
+	OrderItemID         string   `json:"order_item_id"`
+	Code                string   `json:"code"`
+	RecipientCustomerID string   `json:"recipient_customer_id"`
+	Message             string   `json:"message"`
+	Status              string   `json:"status"`
+	RevokedQuantity     int      `json:"revoked_quantity"`
+	LicenseKeys         []string `json:"license_keys"`
+}
+
+// sendGiftRequest calls a gift endpoint as the given customer and decodes the gift or gifts it returns
+func sendGiftRequest(t *testing.T, method, path, customerID string, body interface{}) (int, []Gift) {
+	t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		jsonData, _ := json.Marshal(body)
+		reader = bytes.NewBuffer(jsonData)
+	}
+	req, _ := http.NewRequest(method, orderServiceBaseURL+path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Customer-ID", customerID)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var response struct {
+		Gift  *Gift  `json:"gift"`
+		Gifts []Gift `json:"gifts"`
+	}
+	json.NewDecoder(resp.Body).Decode(&response)
+	if response.Gift != nil {
+		return resp.StatusCode, []Gift{*response.Gift}
+	}
+	return resp.StatusCode, response.Gifts
+}
+
+func TestGiftRedemptionAndRevocation(t *testing.T) {
+	gameID := createCatalogGame(t, "Gift Test Game", 20.00, true)
+	suffix := time.Now().UnixNano()
+	giftKeys := []string{fmt.Sprintf("GIFT-KEY-A-%d", suffix), fmt.Sprintf("GIFT-KEY-B-%d", suffix)}
+	importLicenseKeys(t, gameID, giftKeys)
+	buyer := fmt.Sprintf("customer_gift_buyer_%d", suffix)
+	recipient := fmt.Sprintf("customer_gift_recipient_%d", suffix)
+
+	status, order := postOrder(t, map[string]interface{}{
+		"customer_id": buyer,
+		"items": []map[string]interface{}{{
+			"game_id":  gameID,
+			"quantity": 2,
+			"gift":     map[string]string{"recipient_customer_id": recipient, "message": "Happy birthday!"},
+		}},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("Expected status code 201, got %d", status)
+	}
+
+	// Only the buyer sees the code
+	status, gifts := sendGiftRequest(t, "GET", "/api/v1/orders/"+order.ID+"/gifts", buyer, nil)
+	if status != http.StatusOK || len(gifts) != 1 || gifts[0].Code == "" || gifts[0].Status != "issued" {
+		t.Fatalf("Expected one issued gift with a code, got %d %+v", status, gifts)
+	}
+	code := gifts[0].Code
+	if status, _ := sendGiftRequest(t, "GET", "/api/v1/orders/"+order.ID+"/gifts", recipient, nil); status != http.StatusNotFound {
+		t.Errorf("Expected status code 404 for another customer, got %d", status)
+	}
+
+	// Listings show the gift without its code
+	status, listing := searchOrders(t, "customer_id="+buyer)
+	if status != http.StatusOK || len(listing.Orders) != 1 || len(listing.Orders[0].Items) != 1 {
+		t.Fatalf("Expected the order to be listed with its line, got status code %d and %+v", status, listing.Orders)
+	}
+	if gift := listing.Orders[0].Items[0].Gift; gift == nil || gift.Status != "issued" || gift.Code != "" {
+		t.Errorf("Expected the listed line to show an issued gift without its code, got %+v", gift)
+	}
+
+	// Unpaid orders cannot be redeemed
+	if status, _ := sendGiftRequest(t, "POST", "/api/v1/gifts/redeem", recipient, map[string]string{"code": code}); status != http.StatusConflict {
+		t.Errorf("Expected status code 409 before the order is paid, got %d", status)
+	}
+
+	_, payment := postPayment(t, fmt.Sprintf("/api/v1/orders/%s/payments", order.ID), nil)
+	postPayment(t, "/api/v1/payments/"+payment.ID+"/authorize", map[string]string{"payment_method": "pm_card_visa"})
+	if status, _ := postPayment(t, "/api/v1/payments/"+payment.ID+"/capture", nil); status != http.StatusOK {
+		t.Fatalf("Expected status code 200 when capturing, got %d", status)
+	}
+	waitForOrderStatus(t, order.ID, "delivered")
+
+	// The buyer does not get the keys of the gifted line
+	if status, keys := getLicenseKeys(t, order.ID, buyer); status != http.StatusOK || len(keys.Items) != 0 {
+		t.Errorf("Expected no license keys for the buyer, got %d %+v", status, keys.Items)
+	}
+
+	if status, _ := sendGiftRequest(t, "POST", "/api/v1/gifts/redeem", "someone_else", map[string]string{"code": code}); status != http.StatusForbidden {
+		t.Errorf("Expected status code 403 for another customer, got %d", status)
+	}
+	status, gifts = sendGiftRequest(t, "POST", "/api/v1/gifts/redeem", recipient, map[string]string{"code": strings.ToLower(code)})
+	if status != http.StatusOK || len(gifts) != 1 || gifts[0].Status != "redeemed" || len(gifts[0].LicenseKeys) != 2 {
+		t.Fatalf("Expected the gift to be redeemed with its two license keys, got %d %+v", status, gifts)
+	}
+	for _, key := range gifts[0].LicenseKeys {
+		if key != giftKeys[0] && key != giftKeys[1] {
+			t.Errorf("Expected the imported keys, got %s", key)
+		}
+	}
+	if status, _ := sendGiftRequest(t, "POST", "/api/v1/gifts/redeem", recipient, map[string]string{"code": code}); status != http.StatusConflict {
+		t.Errorf("Expected status code 409 when redeeming twice, got %d", status)
+	}
+	if status, _ := sendGiftRequest(t, "POST", "/api/v1/gifts/redeem", recipient, map[string]string{"code": "AAAA-AAAA-AAAA-AAAA"}); status != http.StatusNotFound {
+		t.Errorf("Expected status code 404 for an unknown code, got %d", status)
+	}
+
+	status, gifts = sendGiftRequest(t, "GET", "/api/v1/gifts", recipient, nil)
+	if status != http.StatusOK || len(gifts) != 1 || gifts[0].Message != "Happy birthday!" || len(gifts[0].LicenseKeys) != 2 {
+		t.Fatalf("Expected the redeemed gift with its license keys, got %d %+v", status, gifts)
+	}
+
+	refundGiftedUnit := func() {
+		t.Helper()
+		status, refund := postRefund(t, fmt.Sprintf("/api/v1/orders/%s/refunds", order.ID), map[string]interface{}{
+			"reason": "Friend already owns it",
+			"items":  []map[string]interface{}{{"order_item_id": order.Items[0].ID, "quantity": 1}},
+		})
+		if status != http.StatusCreated {
+			t.Fatalf("Expected status code 201 when requesting refund, got %d", status)
+		}
+		if status, _ := postRefund(t, "/api/v1/refunds/"+refund.ID+"/approve", nil); status != http.StatusOK {
+			t.Fatalf("Expected status code 200 when approving refund, got %d", status)
+		}
+	}
+
+	// Refunding one unit revokes only that unit and takes one key away
+	refundGiftedUnit()
+	status, gifts = sendGiftRequest(t, "GET", "/api/v1/gifts", recipient, nil)
+	if status != http.StatusOK || len(gifts) != 1 || gifts[0].Status != "redeemed" || gifts[0].RevokedQuantity != 1 ||
+		len(gifts[0].LicenseKeys) != 1 {
+		t.Errorf("Expected the gift to keep one unit and one license key, got %d %+v", status, gifts)
+	}
+
+	// Refunding the other unit revokes the gift
+	refundGiftedUnit()
+	status, gifts = sendGiftRequest(t, "GET", "/api/v1/gifts", recipient, nil)
+	if status != http.StatusOK || len(gifts) != 1 || gifts[0].Status != "revoked" || gifts[0].RevokedQuantity != 2 ||
+		len(gifts[0].LicenseKeys) != 0 {
+		t.Errorf("Expected the gift to be revoked without license keys, got %d %+v", status, gifts)
+	}
+}
+
+func TestInvalidGiftOrders(t *testing.T) {
+	gameID := createCatalogGame(t, "Invalid Gift Test Game", 5.00, true)
+
+	for _, gift := range []map[string]string{
+		{},
+		{"recipient_email": "not an email"},
+		{"recipient_customer_id": "customer_gift_self"},
+	} {
+		status, _ := postOrder(t, map[string]interface{}{
+			"customer_id": "customer_gift_self",
+			"items":       []map[string]interface{}{{"game_id": gameID, "quantity": 1, "gift": gift}},
+		})
+		if status != http.StatusBadRequest {
+			t.Errorf("Expected status code 400 for gift %v, got %d", gift, status)
+		}
+	}
+}
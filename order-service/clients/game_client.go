@@ -0,0 +1,149 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/money"
+)
+
+// ErrCatalogUnavailable is returned when game-service cannot be reached after all retries
+var ErrCatalogUnavailable = errors.New("game catalog is unavailable")
+
+// maxGamesPerRequest matches the batch limit of game-service
+const maxGamesPerRequest = 100
+
+// CatalogGame is the subset of a game-service game that order-service relies on
+type CatalogGame struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Price is decoded from the JSON number exactly, never through float64
+	Price       money.Amount `json:"price"`
+	Purchasable bool         `json:"purchasable"`
+}
+
+// GameClient looks up games in game-service. Requests time out after
+// GAME_SERVICE_TIMEOUT (default 3s) and are retried with exponential backoff
+// up to GAME_SERVICE_RETRIES times (default 2) on network errors and 5xx responses.
+type GameClient struct {
+	baseURL    string
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+}
+
+// NewGameClient creates a new game-service client
+func NewGameClient() *GameClient {
+	baseURL := os.Getenv("GAME_SERVICE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:30080"
+	}
+
+	timeout := 3 * time.Second
+	if value := os.Getenv("GAME_SERVICE_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			timeout = parsed
+		}
+	}
+
+	retries := 2
+	if value := os.Getenv("GAME_SERVICE_RETRIES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			retries = parsed
+		}
+	}
+
+	return &GameClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+		retries:    retries,
+		backoff:    200 * time.Millisecond,
+	}
+}
+
+// GetGames looks up the given game IDs and returns the games found keyed by ID
+// together with the IDs that do not exist in the catalog
+func (c *GameClient) GetGames(ids []int) (map[int]CatalogGame, []int, error) {
+	games := make(map[int]CatalogGame, len(ids))
+	var missing []int
+
+	for start := 0; start < len(ids); start += maxGamesPerRequest {
+		end := start + maxGamesPerRequest
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		batch, batchMissing, err := c.getBatch(ids[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, game := range batch {
+			games[game.ID] = game
+		}
+		missing = append(missing, batchMissing...)
+	}
+
+	return games, missing, nil
+}
+
+// getBatch fetches up to maxGamesPerRequest games, retrying transient failures
+func (c *GameClient) getBatch(ids []int) ([]CatalogGame, []int, error) {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(id)
+	}
+	url := fmt.Sprintf("%s/api/v1/games?ids=%s", c.baseURL, strings.Join(parts, ","))
+
+	var lastErr error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(c.backoff << (attempt - 1))
+		}
+
+		games, missing, retry, err := c.fetch(url)
+		if err == nil {
+			return games, missing, nil
+		}
+		if !retry {
+			return nil, nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, nil, fmt.Errorf("%w: %v", ErrCatalogUnavailable, lastErr)
+}
+
+// fetch performs a single batch request and reports whether a failure is worth retrying
+func (c *GameClient) fetch(url string) ([]CatalogGame, []int, bool, error) {
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, nil, true, fmt.Errorf("failed to reach game-service: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, nil, true, fmt.Errorf("game-service returned %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, false, fmt.Errorf("game-service rejected the lookup with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data struct {
+			Games      []CatalogGame `json:"games"`
+			MissingIDs []int         `json:"missing_ids"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, nil, true, fmt.Errorf("failed to decode game-service response: %v", err)
+	}
+
+	return response.Data.Games, response.Data.MissingIDs, false, nil
+}
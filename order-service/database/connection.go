@@ -93,11 +93,289 @@ func createTables() error {
 			quantity INTEGER NOT NULL DEFAULT 1,
 			subtotal DECIMAL(10,2) NOT NULL
 		)`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS catalog_price DECIMAL(10,2)`,
+		`CREATE TABLE IF NOT EXISTS order_status_history (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			from_status VARCHAR(50),
+			to_status VARCHAR(50) NOT NULL,
+			actor VARCHAR(255) NOT NULL,
+			reason TEXT NOT NULL DEFAULT '',
+			changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS carts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			customer_id VARCHAR(255),
+			guest_token VARCHAR(255) UNIQUE,
+			status VARCHAR(50) NOT NULL DEFAULT 'active',
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			CHECK (customer_id IS NOT NULL OR guest_token IS NOT NULL)
+		)`,
+		`CREATE TABLE IF NOT EXISTS cart_items (
+			cart_id UUID NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+			game_id INTEGER NOT NULL,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (cart_id, game_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS payment_attempts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			provider VARCHAR(50) NOT NULL,
+			provider_payment_id VARCHAR(255) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL,
+			captured_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+			refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0,
+			currency VARCHAR(3) NOT NULL,
+			payment_method VARCHAR(255),
+			failure_reason TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (provider, provider_payment_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS payment_webhook_events (
+			provider VARCHAR(50) NOT NULL,
+			event_id VARCHAR(255) NOT NULL,
+			event_type VARCHAR(100) NOT NULL,
+			received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (provider, event_id)
+		)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS refunds (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			status VARCHAR(50) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			reason TEXT NOT NULL,
+			requested_by VARCHAR(255) NOT NULL,
+			reviewed_by VARCHAR(255),
+			review_note TEXT,
+			payment_attempt_id UUID REFERENCES payment_attempts(id) ON DELETE SET NULL,
+			failure_reason TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			completed_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS refund_items (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			refund_id UUID NOT NULL REFERENCES refunds(id) ON DELETE CASCADE,
+			order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			quantity INTEGER NOT NULL CHECK (quantity > 0),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0)
+		)`,
+		`CREATE TABLE IF NOT EXISTS idempotency_keys (
+			key VARCHAR(255) PRIMARY KEY,
+			request_hash CHAR(64) NOT NULL,
+			order_id UUID NOT NULL,
+			response JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS promotions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			code VARCHAR(100) UNIQUE,
+			type VARCHAR(50) NOT NULL,
+			value DECIMAL(10,2) NOT NULL DEFAULT 0 CHECK (value >= 0),
+			buy_quantity INTEGER NOT NULL DEFAULT 0,
+			get_quantity INTEGER NOT NULL DEFAULT 0,
+			min_spend DECIMAL(10,2) NOT NULL DEFAULT 0,
+			game_ids INTEGER[] NOT NULL DEFAULT '{}',
+			categories TEXT[] NOT NULL DEFAULT '{}',
+			starts_at TIMESTAMP,
+			ends_at TIMESTAMP,
+			usage_limit INTEGER,
+			per_customer_limit INTEGER,
+			usage_count INTEGER NOT NULL DEFAULT 0,
+			stackable BOOLEAN NOT NULL DEFAULT FALSE,
+			priority INTEGER NOT NULL DEFAULT 0,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS discount_total DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS discount DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS order_discounts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			promotion_id UUID NOT NULL REFERENCES promotions(id),
+			code VARCHAR(100),
+			description VARCHAR(255) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			UNIQUE (order_id, promotion_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS order_item_discounts (
+			order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			promotion_id UUID NOT NULL REFERENCES promotions(id),
+			amount DECIMAL(10,2) NOT NULL CHECK (amount > 0),
+			PRIMARY KEY (order_item_id, promotion_id)
+		)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS tax_total DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS country VARCHAR(2) NOT NULL DEFAULT ''`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS region VARCHAR(10) NOT NULL DEFAULT ''`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS tax DECIMAL(10,2) NOT NULL DEFAULT 0`,
+		`ALTER TABLE order_items ADD COLUMN IF NOT EXISTS category VARCHAR(100) NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS order_tax_lines (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			order_item_id UUID NOT NULL REFERENCES order_items(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			country VARCHAR(2) NOT NULL,
+			region VARCHAR(10) NOT NULL DEFAULT '',
+			rate DECIMAL(7,4) NOT NULL,
+			taxable_amount DECIMAL(10,2) NOT NULL,
+			amount DECIMAL(10,2) NOT NULL
+		)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'USD'`,
+		`CREATE TABLE IF NOT EXISTS invoice_sequences (
+			year INTEGER PRIMARY KEY,
+			last_number INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS invoices (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL UNIQUE,
+			number VARCHAR(32) NOT NULL UNIQUE,
+			year INTEGER NOT NULL,
+			sequence INTEGER NOT NULL,
+			issued_at TIMESTAMP NOT NULL,
+			rendered_at TIMESTAMP,
+			html TEXT,
+			pdf BYTEA,
+			UNIQUE (year, sequence)
+		)`,
+		`CREATE TABLE IF NOT EXISTS license_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			game_id INTEGER NOT NULL,
+			license_key VARCHAR(255) NOT NULL UNIQUE,
+			order_item_id UUID REFERENCES order_items(id) ON DELETE SET NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			claimed_at TIMESTAMP,
+			revealed_at TIMESTAMP,
+			revoked_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS fulfillments (
+			order_id UUID PRIMARY KEY REFERENCES orders(id) ON DELETE CASCADE,
+			status VARCHAR(50) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			fulfilled_at TIMESTAMP
+		)`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS customer_email VARCHAR(255) NOT NULL DEFAULT ''`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS locale VARCHAR(10) NOT NULL DEFAULT 'en'`,
+		`CREATE TABLE IF NOT EXISTS notifications (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			event VARCHAR(50) NOT NULL,
+			reference VARCHAR(255) NOT NULL DEFAULT '',
+			recipient VARCHAR(255) NOT NULL,
+			locale VARCHAR(10) NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			sent_at TIMESTAMP,
+			UNIQUE (order_id, event, reference)
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			url TEXT NOT NULL,
+			event_types TEXT[] NOT NULL,
+			description VARCHAR(255) NOT NULL DEFAULT '',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			secret VARCHAR(255) NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			subscription_id UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+			event_id UUID NOT NULL,
+			event_type VARCHAR(100) NOT NULL,
+			payload TEXT NOT NULL,
+			status VARCHAR(50) NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			last_response_status INTEGER,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			delivered_at TIMESTAMP,
+			UNIQUE (subscription_id, event_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_delivery_attempts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+			url TEXT NOT NULL,
+			attempted_at TIMESTAMP NOT NULL,
+			response_status INTEGER,
+			error TEXT,
+			duration_ms BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS outbox_events (
+			id UUID PRIMARY KEY,
+			sequence BIGSERIAL UNIQUE,
+			aggregate_type VARCHAR(50) NOT NULL,
+			aggregate_id VARCHAR(255) NOT NULL,
+			event_type VARCHAR(100) NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			published_at TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS gifts (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+			order_item_id UUID NOT NULL UNIQUE REFERENCES order_items(id) ON DELETE CASCADE,
+			code VARCHAR(32) NOT NULL UNIQUE,
+			recipient_email VARCHAR(255) NOT NULL DEFAULT '',
+			recipient_customer_id VARCHAR(255) NOT NULL DEFAULT '',
+			message TEXT NOT NULL DEFAULT '',
+			status VARCHAR(50) NOT NULL,
+			redeemed_by VARCHAR(255),
+			redeemed_at TIMESTAMP,
+			revoked_quantity INTEGER NOT NULL DEFAULT 0,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders(customer_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_orders_order_date ON orders(order_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_order_date_id ON orders(order_date, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_total_price_id ON orders(total_price, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_orders_pending ON orders(order_date) WHERE status = 'pending'`,
 		`CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_order_items_game_id ON order_items(game_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id, changed_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_payment_attempts_order_id ON payment_attempts(order_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_refund_items_refund_id ON refund_items(refund_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_discounts_promotion_id ON order_discounts(promotion_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_order_tax_lines_order_id ON order_tax_lines(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_license_keys_available ON license_keys(game_id, created_at) WHERE claimed_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_license_keys_order_item_id ON license_keys(order_item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_fulfillments_due ON fulfillments(next_attempt_at) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending'`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_delivery_attempts_delivery_id ON webhook_delivery_attempts(delivery_id, attempted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_unpublished ON outbox_events(aggregate_type, aggregate_id, sequence) WHERE published_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_events_published_at ON outbox_events(published_at) WHERE published_at IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_gifts_order_id ON gifts(order_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_gifts_redeemed_by ON gifts(redeemed_by, redeemed_at) WHERE status = 'redeemed'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_carts_active_customer ON carts(customer_id) WHERE status = 'active' AND customer_id IS NOT NULL`,
 	}
 
 	for _, query := range queries {
@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher publishes every message to KAFKA_TOPIC (default orders) on
+// the brokers in KAFKA_BROKERS, a comma separated list of host:port. Messages
+// are keyed by their Key, so the events of one order land on one partition
+// and keep their order. A message counts as published once all in-sync
+// replicas have it.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewKafkaPublisher creates a Kafka publisher configured from the environment.
+// The topic has to exist; it is not created on the fly.
+func NewKafkaPublisher() (*KafkaPublisher, error) {
+	var brokers []string
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("KAFKA_BROKERS is required for the kafka event publisher")
+	}
+
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        envOrDefault("KAFKA_TOPIC", "orders"),
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Messages are written one at a time; don't wait for a batch to fill
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}, nil
+}
+
+// Name identifies the Kafka publisher
+func (p *KafkaPublisher) Name() string {
+	return "kafka"
+}
+
+// Publish writes a message with its ID and type as headers
+func (p *KafkaPublisher) Publish(ctx context.Context, message *Message) error {
+	err := p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(message.Key),
+		Value: message.Payload,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(message.ID)},
+			{Key: "event-type", Value: []byte(message.Type)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to publish to Kafka: %v", err)
+	}
+	return nil
+}
+
+// Close flushes and closes the Kafka writer
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryCapacity is how many messages MemoryPublisher keeps
+const memoryCapacity = 1000
+
+// MemoryPublisher stands in for a broker in development and tests. It keeps
+// the latest messages in memory, oldest first, and can be told to fail.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+// NewMemoryPublisher creates an empty memory publisher
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+// Name identifies the memory publisher
+func (p *MemoryPublisher) Name() string {
+	return "memory"
+}
+
+// Publish stores a copy of a message, or returns the error set by Fail
+func (p *MemoryPublisher) Publish(ctx context.Context, message *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	stored := *message
+	stored.Payload = append([]byte(nil), message.Payload...)
+	p.messages = append(p.messages, stored)
+	if len(p.messages) > memoryCapacity {
+		p.messages = append([]Message(nil), p.messages[len(p.messages)-memoryCapacity:]...)
+	}
+	return nil
+}
+
+// Close does nothing; the messages stay readable
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
+
+// Messages returns the published messages, oldest first
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.messages...)
+}
+
+// Fail makes every following Publish return err, until called with nil
+func (p *MemoryPublisher) Fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NATSPublisher publishes every message to the subject
+// <NATS_SUBJECT_PREFIX>.<type>, such as orders.OrderCreated, on the server at
+// NATS_URL (default nats://127.0.0.1:4222). With NATS_JETSTREAM=true it
+// publishes through JetStream and waits for the stream to store the message;
+// the Nats-Msg-Id header lets the stream drop messages published twice.
+// Otherwise a message counts as published once the server received it.
+type NATSPublisher struct {
+	conn          *nats.Conn
+	jetStream     nats.JetStreamContext
+	subjectPrefix string
+}
+
+// NewNATSPublisher connects to the NATS server configured in the environment
+func NewNATSPublisher() (*NATSPublisher, error) {
+	// Without a reconnect buffer publishing fails while disconnected instead
+	// of queueing messages that would be lost with the process
+	conn, err := nats.Connect(envOrDefault("NATS_URL", nats.DefaultURL),
+		nats.Name("order-service"),
+		nats.MaxReconnects(-1),
+		nats.ReconnectBufSize(-1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %v", err)
+	}
+
+	publisher := &NATSPublisher{
+		conn:          conn,
+		subjectPrefix: strings.TrimSuffix(envOrDefault("NATS_SUBJECT_PREFIX", "orders"), "."),
+	}
+	if strings.EqualFold(envOrDefault("NATS_JETSTREAM", "false"), "true") {
+		publisher.jetStream, err = conn.JetStream()
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to open NATS JetStream: %v", err)
+		}
+	}
+
+	return publisher, nil
+}
+
+// Name identifies the NATS publisher
+func (p *NATSPublisher) Name() string {
+	return "nats"
+}
+
+// Publish sends a message with its ID, type and key as headers
+func (p *NATSPublisher) Publish(ctx context.Context, message *Message) error {
+	msg := nats.NewMsg(p.subjectPrefix + "." + message.Type)
+	msg.Data = message.Payload
+	msg.Header.Set(nats.MsgIdHdr, message.ID)
+	msg.Header.Set("Event-Type", message.Type)
+	msg.Header.Set("Event-Key", message.Key)
+
+	if p.jetStream != nil {
+		if _, err := p.jetStream.PublishMsg(msg, nats.Context(ctx)); err != nil {
+			return fmt.Errorf("failed to publish to NATS JetStream: %v", err)
+		}
+		return nil
+	}
+
+	if err := p.conn.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to publish to NATS: %v", err)
+	}
+	if err := p.conn.FlushWithContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush NATS connection: %v", err)
+	}
+	return nil
+}
+
+// Close drains the connection to the NATS server
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,57 @@
+// Package events publishes the order events recorded in the outbox to a
+// message broker. The relay only sees Publisher, so brokers are
+// interchangeable: NATS, Kafka, or memory for development and tests.
+package events
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Message is an event to publish. ID identifies it across retries, so brokers
+// and consumers can drop duplicates. Key groups the events that have to stay
+// in order, such as those of one order; they are always published in order.
+type Message struct {
+	ID      string
+	Type    string
+	Key     string
+	Payload []byte
+}
+
+// Publisher is implemented by every broker adapter. Publish returns once the
+// broker accepted the message; an error means it may not have been published
+// and should be retried.
+type Publisher interface {
+	// Name identifies the publisher in logs
+	Name() string
+	// Publish hands a message to the broker
+	Publish(ctx context.Context, message *Message) error
+	// Close releases the connection to the broker
+	Close() error
+}
+
+// NewPublisher creates the publisher selected by EVENT_PUBLISHER: "memory"
+// (the default) keeps the latest messages in memory, "nats" and "kafka"
+// publish them to a broker.
+func NewPublisher() (Publisher, error) {
+	name := strings.ToLower(os.Getenv("EVENT_PUBLISHER"))
+	switch name {
+	case "", "memory":
+		return NewMemoryPublisher(), nil
+	case "nats":
+		return NewNATSPublisher()
+	case "kafka":
+		return NewKafkaPublisher()
+	default:
+		return nil, fmt.Errorf("unknown event publisher: %s", name)
+	}
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
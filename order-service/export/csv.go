@@ -0,0 +1,46 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"order-service/models"
+)
+
+// csvWriter writes a header line with the column names and one line per row
+type csvWriter struct {
+	writer   *csv.Writer
+	location *time.Location
+	record   []string
+}
+
+func newCSVWriter(w io.Writer, columns []models.ExportColumn, location *time.Location) (*csvWriter, error) {
+	writer := &csvWriter{
+		writer:   csv.NewWriter(w),
+		location: location,
+		record:   make([]string, len(columns)),
+	}
+
+	for i, column := range columns {
+		writer.record[i] = column.Name
+	}
+	if err := writer.writer.Write(writer.record); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *csvWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		w.record[i] = formatValue(value, w.location)
+	}
+	// csv.Writer buffers, so write errors surface a few rows late or on Close
+	return w.writer.Write(w.record)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,59 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"order-service/models"
+)
+
+// ndjsonWriter writes one JSON object per line, with the keys in column
+// order. Amounts are numbers, times RFC 3339 strings and NULL is null.
+type ndjsonWriter struct {
+	writer   *bufio.Writer
+	keys     [][]byte
+	location *time.Location
+}
+
+func newNDJSONWriter(w io.Writer, columns []models.ExportColumn, location *time.Location) *ndjsonWriter {
+	writer := &ndjsonWriter{
+		writer:   bufio.NewWriter(w),
+		keys:     make([][]byte, len(columns)),
+		location: location,
+	}
+	for i, column := range columns {
+		writer.keys[i], _ = json.Marshal(column.Name)
+	}
+	return writer
+}
+
+func (w *ndjsonWriter) WriteRow(values []interface{}) error {
+	w.writer.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			w.writer.WriteByte(',')
+		}
+		w.writer.Write(w.keys[i])
+		w.writer.WriteByte(':')
+
+		if t, ok := value.(time.Time); ok {
+			value = t.In(w.location).Format(time.RFC3339)
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		w.writer.Write(encoded)
+	}
+	w.writer.WriteString("}\n")
+
+	// bufio.Writer keeps the first write error and returns it from here on
+	_, err := w.writer.Write(nil)
+	return err
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.writer.Flush()
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize bounds how many rows are buffered before they are
+// written out as a row group
+const parquetRowGroupSize = 50000
+
+// parquetWriter writes a Snappy compressed Parquet file with an optional
+// column per export column. Amounts are DECIMAL(18,2) and times UTC
+// timestamps in milliseconds. Parquet orders the columns of a file by name.
+type parquetWriter struct {
+	writer *parquet.Writer
+	// indexes maps the position of a value to its column in the file
+	indexes []int
+	row     parquet.Row
+}
+
+func newParquetWriter(w io.Writer, columns []models.ExportColumn) (*parquetWriter, error) {
+	group := make(parquet.Group, len(columns))
+	for _, column := range columns {
+		group[column.Name] = parquet.Optional(parquetNode(column.Kind))
+	}
+	schema := parquet.NewSchema("order_export", group)
+
+	config, err := parquet.NewWriterConfig(schema,
+		parquet.Compression(&parquet.Snappy),
+		parquet.MaxRowsPerRowGroup(parquetRowGroupSize),
+		parquet.CreatedBy("order-service", "", ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Parquet writer: %v", err)
+	}
+
+	writer := &parquetWriter{
+		writer:  parquet.NewWriter(w, config),
+		indexes: make([]int, len(columns)),
+		row:     make(parquet.Row, len(columns)),
+	}
+	for i, column := range columns {
+		leaf, ok := schema.Lookup(column.Name)
+		if !ok {
+			return nil, fmt.Errorf("column %s missing from Parquet schema", column.Name)
+		}
+		writer.indexes[i] = leaf.ColumnIndex
+	}
+
+	return writer, nil
+}
+
+// parquetNode is the Parquet type of a column kind
+func parquetNode(kind string) parquet.Node {
+	switch kind {
+	case models.ExportKindInteger:
+		return parquet.Int(64)
+	case models.ExportKindAmount:
+		return parquet.Decimal(2, 18, parquet.Int64Type)
+	case models.ExportKindBoolean:
+		return parquet.Leaf(parquet.BooleanType)
+	case models.ExportKindTimestamp:
+		return parquet.Timestamp(parquet.Millisecond)
+	default:
+		return parquet.String()
+	}
+}
+
+func (w *parquetWriter) WriteRow(values []interface{}) error {
+	for i, value := range values {
+		index := w.indexes[i]
+
+		var v parquet.Value
+		switch value := value.(type) {
+		case string:
+			v = parquet.ByteArrayValue([]byte(value))
+		case int64:
+			v = parquet.Int64Value(value)
+		case money.Amount:
+			v = parquet.Int64Value(value.Minor())
+		case bool:
+			v = parquet.BooleanValue(value)
+		case time.Time:
+			v = parquet.Int64Value(value.UnixMilli())
+		default:
+			w.row[index] = parquet.NullValue().Level(0, 0, index)
+			continue
+		}
+		w.row[index] = v.Level(0, 1, index)
+	}
+
+	_, err := w.writer.WriteRows([]parquet.Row{w.row})
+	return err
+}
+
+func (w *parquetWriter) Close() error {
+	return w.writer.Close()
+}
@@ -0,0 +1,71 @@
+// Package export writes order exports as CSV, NDJSON or Parquet. Rows are
+// written as they come, so an export never has to fit in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+)
+
+// Writer writes the rows of an export. Values are strings, int64s, money
+// amounts, bools or times in the order of the columns, and nil for NULL.
+// Close finishes the output; it does not close the underlying writer.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewWriter creates a writer of the given format. Times are written in
+// location, except in Parquet files, which store them as UTC instants.
+func NewWriter(format string, w io.Writer, columns []models.ExportColumn, location *time.Location) (Writer, error) {
+	switch format {
+	case models.ExportFormatCSV:
+		return newCSVWriter(w, columns, location)
+	case models.ExportFormatNDJSON:
+		return newNDJSONWriter(w, columns, location), nil
+	case models.ExportFormatParquet:
+		return newParquetWriter(w, columns)
+	default:
+		return nil, fmt.Errorf("unknown export format %s", format)
+	}
+}
+
+// ContentType is the media type of an export format
+func ContentType(format string) string {
+	switch format {
+	case models.ExportFormatCSV:
+		return "text/csv; charset=utf-8"
+	case models.ExportFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// formatValue formats a value as text, with times in RFC 3339 in location
+// and amounts with two decimals. NULL is the empty string.
+func formatValue(value interface{}, location *time.Location) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int64:
+		return fmt.Sprint(value)
+	case money.Amount:
+		return value.String()
+	case bool:
+		if value {
+			return "true"
+		}
+		return "false"
+	case time.Time:
+		return value.In(location).Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
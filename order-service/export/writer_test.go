@@ -0,0 +1,96 @@
+package export
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+var testColumns = []models.ExportColumn{
+	{Name: "id", Kind: models.ExportKindString},
+	{Name: "order_date", Kind: models.ExportKindTimestamp},
+	{Name: "total_price", Kind: models.ExportKindAmount},
+	{Name: "catalog_price", Kind: models.ExportKindAmount},
+	{Name: "quantity", Kind: models.ExportKindInteger},
+	{Name: "prices_include_tax", Kind: models.ExportKindBoolean},
+}
+
+var testRows = [][]interface{}{
+	{"order-1", time.Date(2026, 1, 31, 23, 30, 0, 0, time.UTC), money.FromMinor(5990), nil, int64(2), true},
+	{`say "hi", ok`, time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC), money.FromMinor(-5), money.FromMinor(100), int64(1), false},
+}
+
+func writeTestExport(t *testing.T, format string) []byte {
+	t.Helper()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Timezone database not available: %v", err)
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf, testColumns, berlin)
+	if err != nil {
+		t.Fatalf("Failed to create %s writer: %v", format, err)
+	}
+	for _, row := range testRows {
+		if err := writer.WriteRow(row); err != nil {
+			t.Fatalf("Failed to write row: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close %s writer: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVWriter(t *testing.T) {
+	expected := "id,order_date,total_price,catalog_price,quantity,prices_include_tax\n" +
+		"order-1,2026-02-01T00:30:00+01:00,59.90,,2,true\n" +
+		`"say ""hi"", ok",2026-02-01T13:00:00+01:00,-0.05,1.00,1,false` + "\n"
+
+	if got := string(writeTestExport(t, models.ExportFormatCSV)); got != expected {
+		t.Errorf("Expected CSV\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	expected := `{"id":"order-1","order_date":"2026-02-01T00:30:00+01:00","total_price":59.9,"catalog_price":null,"quantity":2,"prices_include_tax":true}` + "\n" +
+		`{"id":"say \"hi\", ok","order_date":"2026-02-01T13:00:00+01:00","total_price":-0.05,"catalog_price":1,"quantity":1,"prices_include_tax":false}` + "\n"
+
+	if got := string(writeTestExport(t, models.ExportFormatNDJSON)); got != expected {
+		t.Errorf("Expected NDJSON\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	data := writeTestExport(t, models.ExportFormatParquet)
+
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to open Parquet file: %v", err)
+	}
+	if file.NumRows() != int64(len(testRows)) {
+		t.Fatalf("Expected %d rows, got %d", len(testRows), file.NumRows())
+	}
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+
+	first := map[string]interface{}{}
+	if err := reader.Read(&first); err != nil {
+		t.Fatalf("Failed to read row: %v", err)
+	}
+	if first["id"] != "order-1" || first["total_price"] != int64(5990) || first["catalog_price"] != nil ||
+		first["quantity"] != int64(2) || first["prices_include_tax"] != true {
+		t.Errorf("Unexpected first row %v", first)
+	}
+	if first["order_date"] != testRows[0][1].(time.Time).UnixMilli() {
+		t.Errorf("Expected the order date as UTC milliseconds, got %v", first["order_date"])
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"order-service/models"
+	"order-service/service"
+)
+
+// runExport implements the export subcommand, which writes the same exports
+// as GET /api/v1/orders/export to a file or standard output:
+//
+//	order-service export -from 2026-01-01 -to 2026-03-31 -format parquet -output q1.parquet
+func runExport(args []string) error {
+	var request models.OrderExportRequest
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.StringVar(&request.From, "from", "", "first day (YYYY-MM-DD) or time (RFC 3339) of the export")
+	flags.StringVar(&request.To, "to", "", "last day (YYYY-MM-DD) or end time (RFC 3339, exclusive) of the export")
+	flags.StringVar(&request.Format, "format", models.ExportFormatCSV, "csv, ndjson or parquet")
+	flags.StringVar(&request.Rows, "rows", models.ExportRowsOrders, "orders for a row per order, items for a row per order item")
+	flags.StringVar(&request.Columns, "columns", "", "comma separated columns to export (default all)")
+	flags.StringVar(&request.Timezone, "timezone", "UTC", "IANA timezone of the dates and the exported times")
+	output := flags.String("output", "-", "file to write, or - for standard output")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	orderService := service.NewOrderService()
+	orderExport, err := orderService.NewOrderExport(&request)
+	if err != nil {
+		return err
+	}
+
+	// Ctrl-C cancels the query instead of leaving it running
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if *output == "-" {
+		return orderService.WriteOrderExport(ctx, orderExport, os.Stdout)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", *output, err)
+	}
+	err = orderService.WriteOrderExport(ctx, orderExport, file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write %s: %v", *output, closeErr)
+	}
+	if err != nil {
+		// Don't leave half an export behind
+		os.Remove(*output)
+		return err
+	}
+
+	log.Printf("Exported %s to %s", orderExport.Rows, *output)
+	return nil
+}
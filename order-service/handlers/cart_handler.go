@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"order-service/clients"
+	"order-service/models"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	customerIDHeader = "X-Customer-ID"
+	cartTokenHeader  = "X-Cart-Token"
+)
+
+type CartHandler struct {
+	cartService *service.CartService
+}
+
+// NewCartHandler creates a new instance of CartHandler
+func NewCartHandler() *CartHandler {
+	return &CartHandler{
+		cartService: service.NewCartService(),
+	}
+}
+
+// GetCart handles GET /cart
+func (h *CartHandler) GetCart(c *gin.Context) {
+	cart, err := h.cartService.GetCart(cartIdentity(c))
+	if err != nil {
+		respondCartError(c, "Failed to get cart", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"cart": cart,
+	})
+}
+
+// AddCartItem handles POST /cart/items
+func (h *CartHandler) AddCartItem(c *gin.Context) {
+	var request models.AddCartItemRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	cart, err := h.cartService.AddItem(cartIdentity(c), &request)
+	if err != nil {
+		respondCartError(c, "Failed to add item to cart", err)
+		return
+	}
+
+	// Guests keep using the token returned here, which may be a new one
+	if cart.GuestToken != nil {
+		c.Header(cartTokenHeader, *cart.GuestToken)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Item added to cart",
+		"cart":    cart,
+	})
+}
+
+// UpdateCartItem handles PUT /cart/items/:game_id
+func (h *CartHandler) UpdateCartItem(c *gin.Context) {
+	gameID, err := strconv.Atoi(c.Param("game_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid game ID",
+		})
+		return
+	}
+
+	var request models.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	cart, err := h.cartService.UpdateItem(cartIdentity(c), gameID, &request)
+	if err != nil {
+		respondCartError(c, "Failed to update cart item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cart item updated",
+		"cart":    cart,
+	})
+}
+
+// RemoveCartItem handles DELETE /cart/items/:game_id
+func (h *CartHandler) RemoveCartItem(c *gin.Context) {
+	gameID, err := strconv.Atoi(c.Param("game_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid game ID",
+		})
+		return
+	}
+
+	cart, err := h.cartService.RemoveItem(cartIdentity(c), gameID)
+	if err != nil {
+		respondCartError(c, "Failed to remove cart item", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cart item removed",
+		"cart":    cart,
+	})
+}
+
+// MergeCart handles POST /cart/merge
+func (h *CartHandler) MergeCart(c *gin.Context) {
+	cart, err := h.cartService.MergeGuestCart(cartIdentity(c))
+	if err != nil {
+		respondCartError(c, "Failed to merge cart", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Guest cart merged successfully",
+		"cart":    cart,
+	})
+}
+
+// Checkout handles POST /cart/checkout
+func (h *CartHandler) Checkout(c *gin.Context) {
+	var request models.CheckoutCartRequest
+	if !bindOptionalJSON(c, &request) {
+		return
+	}
+
+	order, err := h.cartService.Checkout(cartIdentity(c), &request)
+	if err != nil {
+		respondCartError(c, "Failed to check out cart", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Order created successfully",
+		"order":   order,
+	})
+}
+
+// cartIdentity reads the customer ID and guest cart token from the request headers
+func cartIdentity(c *gin.Context) service.CartIdentity {
+	return service.CartIdentity{
+		CustomerID: c.GetHeader(customerIDHeader),
+		GuestToken: c.GetHeader(cartTokenHeader),
+	}
+}
+
+// respondCartError maps cart errors to HTTP status codes
+func respondCartError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, repository.ErrCartNotFound), errors.Is(err, repository.ErrCartItemNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, repository.ErrCartChanged), errors.Is(err, repository.ErrPromotionUnavailable):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrPromotionNotApplicable):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, clients.ErrCatalogUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"order-service/models"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FulfillmentHandler struct {
+	fulfillmentService *service.FulfillmentService
+}
+
+// NewFulfillmentHandler creates a new instance of FulfillmentHandler
+func NewFulfillmentHandler() *FulfillmentHandler {
+	return &FulfillmentHandler{
+		fulfillmentService: service.NewFulfillmentService(),
+	}
+}
+
+// GetOrderLicenseKeys handles GET /orders/:id/license-keys. Keys are only
+// revealed to the customer who placed the order, named by X-Customer-ID.
+func (h *FulfillmentHandler) GetOrderLicenseKeys(c *gin.Context) {
+	customerID := c.GetHeader(customerIDHeader)
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Missing customer",
+			"details": customerIDHeader + " header is required",
+		})
+		return
+	}
+
+	response, err := h.fulfillmentService.RevealLicenseKeys(c.Param("id"), customerID)
+	if err != nil {
+		respondFulfillmentError(c, "Failed to get license keys", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ImportLicenseKeys handles POST /license-keys
+func (h *FulfillmentHandler) ImportLicenseKeys(c *gin.Context) {
+	var request models.ImportLicenseKeysRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	added, err := h.fulfillmentService.ImportLicenseKeys(&request)
+	if err != nil {
+		respondFulfillmentError(c, "Failed to import license keys", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "License keys imported successfully",
+		"game_id": request.GameID,
+		"added":   added,
+		"skipped": len(request.Keys) - added,
+	})
+}
+
+// GetLicenseKeyStock handles GET /license-keys/stock
+func (h *FulfillmentHandler) GetLicenseKeyStock(c *gin.Context) {
+	stock, err := h.fulfillmentService.GetLicenseKeyStock()
+	if err != nil {
+		respondFulfillmentError(c, "Failed to get license key stock", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stock": stock,
+	})
+}
+
+// respondFulfillmentError maps fulfillment errors to HTTP status codes
+func respondFulfillmentError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case err.Error() == "order not found":
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidLicenseKeys):
+		status = http.StatusBadRequest
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"order-service/models"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GiftHandler struct {
+	giftService *service.GiftService
+}
+
+// NewGiftHandler creates a new instance of GiftHandler
+func NewGiftHandler() *GiftHandler {
+	return &GiftHandler{
+		giftService: service.NewGiftService(),
+	}
+}
+
+// GetOrderGifts handles GET /orders/:id/gifts. Gift codes are only shown to
+// the customer who placed the order, named by X-Customer-ID.
+func (h *GiftHandler) GetOrderGifts(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+
+	orderID := c.Param("id")
+	gifts, err := h.giftService.GetOrderGifts(orderID, customerID)
+	if err != nil {
+		respondGiftError(c, "Failed to get gifts", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": orderID,
+		"gifts":    gifts,
+	})
+}
+
+// RedeemGift handles POST /gifts/redeem for the customer named by X-Customer-ID
+func (h *GiftHandler) RedeemGift(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+
+	var request models.RedeemGiftRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	gift, err := h.giftService.RedeemGift(request.Code, customerID)
+	if err != nil {
+		respondGiftError(c, "Failed to redeem gift", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Gift redeemed successfully",
+		"gift":    gift,
+	})
+}
+
+// GetRedeemedGifts handles GET /gifts, the gifts redeemed by the customer named by X-Customer-ID
+func (h *GiftHandler) GetRedeemedGifts(c *gin.Context) {
+	customerID, ok := requireCustomerID(c)
+	if !ok {
+		return
+	}
+
+	gifts, err := h.giftService.GetRedeemedGifts(customerID)
+	if err != nil {
+		respondGiftError(c, "Failed to get gifts", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"customer_id": customerID,
+		"gifts":       gifts,
+	})
+}
+
+// requireCustomerID reads X-Customer-ID and answers 400 when it is missing
+func requireCustomerID(c *gin.Context) (string, bool) {
+	customerID := c.GetHeader(customerIDHeader)
+	if customerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Missing customer",
+			"details": customerIDHeader + " header is required",
+		})
+		return "", false
+	}
+	return customerID, true
+}
+
+// respondGiftError maps gift errors to HTTP status codes
+func respondGiftError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case err.Error() == "order not found", errors.Is(err, repository.ErrGiftNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidGift):
+		status = http.StatusBadRequest
+	case errors.Is(err, service.ErrGiftForAnotherCustomer):
+		status = http.StatusForbidden
+	case errors.Is(err, service.ErrGiftNotRedeemable):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InvoiceHandler struct {
+	invoiceService *service.InvoiceService
+}
+
+// NewInvoiceHandler creates a new instance of InvoiceHandler
+func NewInvoiceHandler() *InvoiceHandler {
+	return &InvoiceHandler{
+		invoiceService: service.NewInvoiceService(),
+	}
+}
+
+// GetOrderInvoice handles GET /orders/:id/invoice. The invoice is served as
+// HTML unless ?format=pdf is given or the client only accepts application/pdf.
+func (h *InvoiceHandler) GetOrderInvoice(c *gin.Context) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" {
+		format = "html"
+		if accept := c.GetHeader("Accept"); strings.Contains(accept, "application/pdf") && !strings.Contains(accept, "text/html") {
+			format = "pdf"
+		}
+	}
+	if format != "html" && format != "pdf" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format",
+			"details": "format must be html or pdf",
+		})
+		return
+	}
+
+	invoice, err := h.invoiceService.GetInvoice(c.Param("id"))
+	if err != nil {
+		respondInvoiceError(c, "Failed to get invoice", err)
+		return
+	}
+
+	c.Header("X-Invoice-Number", invoice.Number)
+	if format == "pdf" {
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s.pdf"`, invoice.Number))
+		c.Data(http.StatusOK, "application/pdf", invoice.PDF)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", invoice.HTML)
+}
+
+// respondInvoiceError maps invoice errors to HTTP status codes
+func respondInvoiceError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	details := err.Error()
+	switch {
+	case errors.Is(err, repository.ErrInvoiceNotFound):
+		status = http.StatusNotFound
+		details = "the order does not exist or has not been confirmed yet"
+	case err.Error() == "order not found":
+		status = http.StatusNotFound
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": details,
+	})
+}
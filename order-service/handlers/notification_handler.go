@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	notificationService *service.NotificationService
+}
+
+// NewNotificationHandler creates a new instance of NotificationHandler
+func NewNotificationHandler() *NotificationHandler {
+	return &NotificationHandler{
+		notificationService: service.NewNotificationService(),
+	}
+}
+
+// GetOrderNotifications handles GET /orders/:id/notifications
+func (h *NotificationHandler) GetOrderNotifications(c *gin.Context) {
+	id := c.Param("id")
+
+	notifications, err := h.notificationService.GetOrderNotifications(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Order not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get order notifications",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id":      id,
+		"notifications": notifications,
+	})
+}
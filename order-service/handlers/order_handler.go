@@ -1,15 +1,30 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"order-service/clients"
+	"order-service/export"
 	"order-service/models"
+	"order-service/repository"
 	"order-service/service"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// idempotencyKeyHeader makes POST /orders safe to retry
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks responses replayed from an earlier request with the same key
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
 type OrderHandler struct {
 	orderService *service.OrderService
 }
@@ -33,8 +48,46 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	order, err := h.orderService.CreateOrder(&request)
+	var order *models.Order
+	var err error
+	if key := c.GetHeader(idempotencyKeyHeader); key != "" {
+		var replayed bool
+		order, replayed, err = h.orderService.CreateOrderWithIdempotencyKey(key, &request)
+		if replayed && err == nil {
+			c.Header(idempotentReplayedHeader, "true")
+		}
+	} else {
+		order, err = h.orderService.CreateOrder(&request)
+	}
 	if err != nil {
+		if errors.Is(err, service.ErrIdempotencyKeyReused) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Idempotency key reused",
+				"details": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, clients.ErrCatalogUnavailable) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"error":   "Failed to create order",
+				"details": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, service.ErrPromotionNotApplicable) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Coupon cannot be applied",
+				"details": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, repository.ErrPromotionUnavailable) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Failed to create order",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Failed to create order",
 			"details": err.Error(),
@@ -91,39 +144,33 @@ func (h *OrderHandler) GetOrdersByCustomerID(c *gin.Context) {
 	})
 }
 
-// GetAllOrders handles GET /orders
+// GetAllOrders handles GET /orders. Orders can be filtered by status,
+// customer_id, from/to order date, min_total/max_total and game_id, sorted
+// with sort and order, and paged with page_size and cursor.
 func (h *OrderHandler) GetAllOrders(c *gin.Context) {
-	// Parse pagination parameters
-	page := 1
-	pageSize := 10
-
-	if pageParam := c.Query("page"); pageParam != "" {
-		if p, err := strconv.Atoi(pageParam); err == nil && p > 0 {
-			page = p
-		}
-	}
-
-	if sizeParam := c.Query("page_size"); sizeParam != "" {
-		if s, err := strconv.Atoi(sizeParam); err == nil && s > 0 && s <= 100 {
-			pageSize = s
-		}
+	var request models.OrderSearchRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
 	}
 
-	response, err := h.orderService.GetAllOrders(page, pageSize)
+	response, err := h.orderService.GetAllOrders(&request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidOrderSearch) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"error":   "Failed to get orders",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"orders":    response.Orders,
-		"total":     response.Total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	c.JSON(http.StatusOK, response)
 }
 
 // UpdateOrderStatus handles PUT /orders/:id/status
@@ -139,7 +186,7 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	err := h.orderService.UpdateOrderStatus(id, &request)
+	change, err := h.orderService.UpdateOrderStatus(id, &request)
 	if err != nil {
 		if err.Error() == "order not found" {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -147,6 +194,13 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 			})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidStatusTransition) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Invalid status transition",
+				"details": err.Error(),
+			})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Failed to update order status",
 			"details": err.Error(),
@@ -156,6 +210,32 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Order status updated successfully",
+		"change":  change,
+	})
+}
+
+// GetOrderStatusHistory handles GET /orders/:id/history
+func (h *OrderHandler) GetOrderStatusHistory(c *gin.Context) {
+	id := c.Param("id")
+
+	history, err := h.orderService.GetOrderStatusHistory(id)
+	if err != nil {
+		if err.Error() == "order not found" {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Order not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get order history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": id,
+		"history":  history,
 	})
 }
 
@@ -183,11 +263,26 @@ func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	})
 }
 
-// GetOrderStatistics handles GET /orders/stats
+// GetOrderStatistics handles GET /orders/stats. The from/to range,
+// granularity (hour, day, week or month) and number of top games and
+// categories are optional query parameters.
 func (h *OrderHandler) GetOrderStatistics(c *gin.Context) {
-	stats, err := h.orderService.GetOrderStatistics()
+	var request models.OrderStatisticsRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	stats, err := h.orderService.GetOrderStatistics(&request)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidStatistics) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"error":   "Failed to get order statistics",
 			"details": err.Error(),
 		})
@@ -199,6 +294,90 @@ func (h *OrderHandler) GetOrderStatistics(c *gin.Context) {
 	})
 }
 
+// ExportOrders handles GET /orders/export. It streams the orders placed in
+// the from/to range as CSV, NDJSON or Parquet, with a row per order or per
+// item, the selected columns and times in the given timezone.
+func (h *OrderHandler) ExportOrders(c *gin.Context) {
+	var request models.OrderExportRequest
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	orderExport, err := h.orderService.NewOrderExport(&request)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidExport) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   "Failed to export orders",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Type", export.ContentType(orderExport.Format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(orderExport)))
+	c.Status(http.StatusOK)
+
+	if err := h.orderService.WriteOrderExport(c.Request.Context(), orderExport, c.Writer); err != nil {
+		log.Printf("Order export failed: %v", err)
+		// The status was sent with the first rows. Dropping the connection
+		// before the end of the body is the only way left to tell the client
+		// that the export is incomplete.
+		if conn, _, err := c.Writer.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+// exportFilename names an export after its rows and range, such as orders-2026-01-01-2026-01-31.csv
+func exportFilename(orderExport *models.OrderExport) string {
+	parts := []string{orderExport.Rows}
+	if orderExport.From != nil {
+		parts = append(parts, orderExport.From.In(orderExport.Location).Format("2006-01-02"))
+	}
+	if orderExport.To != nil {
+		// The range ends before To; name it after its last day
+		parts = append(parts, orderExport.To.In(orderExport.Location).Add(-time.Nanosecond).Format("2006-01-02"))
+	}
+	return strings.Join(parts, "-") + "." + orderExport.Format
+}
+
+// GetGameSales handles GET /orders/stats/game-sales
+func (h *OrderHandler) GetGameSales(c *gin.Context) {
+	hours := 24
+	if hoursParam := c.Query("hours"); hoursParam != "" {
+		parsed, err := strconv.Atoi(hoursParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid hours parameter",
+				"details": err.Error(),
+			})
+			return
+		}
+		hours = parsed
+	}
+
+	sales, err := h.orderService.GetGameSales(hours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to get game sales",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"game_sales": sales,
+		"hours":      hours,
+	})
+}
+
 // HealthCheck handles GET /health
 func (h *OrderHandler) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
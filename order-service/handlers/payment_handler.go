@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"order-service/models"
+	"order-service/payments"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookBodySize bounds the size of payment webhook payloads
+const maxWebhookBodySize = 1 << 20
+
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+// NewPaymentHandler creates a new instance of PaymentHandler
+func NewPaymentHandler() *PaymentHandler {
+	return &PaymentHandler{
+		paymentService: service.NewPaymentService(),
+	}
+}
+
+// CreatePayment handles POST /orders/:id/payments
+func (h *PaymentHandler) CreatePayment(c *gin.Context) {
+	payment, clientSecret, err := h.paymentService.CreatePayment(c.Param("id"))
+	if err != nil {
+		respondPaymentError(c, "Failed to create payment", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":       "Payment created successfully",
+		"payment":       payment,
+		"client_secret": clientSecret,
+	})
+}
+
+// GetOrderPayments handles GET /orders/:id/payments
+func (h *PaymentHandler) GetOrderPayments(c *gin.Context) {
+	orderID := c.Param("id")
+
+	attempts, err := h.paymentService.GetOrderPayments(orderID)
+	if err != nil {
+		respondPaymentError(c, "Failed to get payments", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": orderID,
+		"payments": attempts,
+	})
+}
+
+// GetPayment handles GET /payments/:id
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	payment, err := h.paymentService.GetPayment(c.Param("id"))
+	if err != nil {
+		respondPaymentError(c, "Failed to get payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"payment": payment,
+	})
+}
+
+// AuthorizePayment handles POST /payments/:id/authorize
+func (h *PaymentHandler) AuthorizePayment(c *gin.Context) {
+	var request models.AuthorizePaymentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	payment, err := h.paymentService.AuthorizePayment(c.Param("id"), &request)
+	if err != nil {
+		if errors.Is(err, service.ErrPaymentDeclined) {
+			c.JSON(http.StatusPaymentRequired, gin.H{
+				"error":   "Payment declined",
+				"details": err.Error(),
+				"payment": payment,
+			})
+			return
+		}
+		respondPaymentError(c, "Failed to authorize payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment authorized successfully",
+		"payment": payment,
+	})
+}
+
+// CapturePayment handles POST /payments/:id/capture
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	var request models.PaymentAmountRequest
+	if !bindOptionalJSON(c, &request) {
+		return
+	}
+
+	payment, err := h.paymentService.CapturePayment(c.Param("id"), &request)
+	if err != nil {
+		respondPaymentError(c, "Failed to capture payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment captured successfully",
+		"payment": payment,
+	})
+}
+
+// VoidPayment handles POST /payments/:id/void
+func (h *PaymentHandler) VoidPayment(c *gin.Context) {
+	payment, err := h.paymentService.VoidPayment(c.Param("id"))
+	if err != nil {
+		respondPaymentError(c, "Failed to void payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment voided successfully",
+		"payment": payment,
+	})
+}
+
+// RefundPayment handles POST /payments/:id/refund
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	var request models.PaymentAmountRequest
+	if !bindOptionalJSON(c, &request) {
+		return
+	}
+
+	payment, err := h.paymentService.RefundPayment(c.Param("id"), &request)
+	if err != nil {
+		respondPaymentError(c, "Failed to refund payment", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Payment refunded successfully",
+		"payment": payment,
+	})
+}
+
+// HandleWebhook handles POST /payments/webhook
+func (h *PaymentHandler) HandleWebhook(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.paymentService.HandleWebhook(c.Request.Header, body); err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Invalid webhook signature",
+				"details": err.Error(),
+			})
+			return
+		}
+		if errors.Is(err, repository.ErrPaymentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Payment not found",
+			})
+			return
+		}
+		log.Printf("Failed to process payment webhook: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to process webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"received": true,
+	})
+}
+
+// bindOptionalJSON binds a JSON body if one was sent
+func bindOptionalJSON(c *gin.Context, request interface{}) bool {
+	if c.Request.ContentLength == 0 {
+		return true
+	}
+	if err := c.ShouldBindJSON(request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// respondPaymentError maps payment errors to HTTP status codes
+func respondPaymentError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, repository.ErrPaymentNotFound), err.Error() == "order not found":
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidPaymentState):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrPaymentDeclined):
+		status = http.StatusPaymentRequired
+	case errors.Is(err, payments.ErrProviderUnavailable):
+		status = http.StatusBadGateway
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
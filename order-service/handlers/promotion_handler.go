@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"order-service/clients"
+	"order-service/models"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionHandler struct {
+	promotionService *service.PromotionService
+}
+
+// NewPromotionHandler creates a new instance of PromotionHandler
+func NewPromotionHandler() *PromotionHandler {
+	return &PromotionHandler{
+		promotionService: service.NewPromotionService(),
+	}
+}
+
+// CreatePromotion handles POST /promotions
+func (h *PromotionHandler) CreatePromotion(c *gin.Context) {
+	var request models.PromotionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	promotion, err := h.promotionService.CreatePromotion(&request)
+	if err != nil {
+		respondPromotionError(c, "Failed to create promotion", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "Promotion created successfully",
+		"promotion": promotion,
+	})
+}
+
+// GetPromotions handles GET /promotions
+func (h *PromotionHandler) GetPromotions(c *gin.Context) {
+	promotions, err := h.promotionService.GetPromotions()
+	if err != nil {
+		respondPromotionError(c, "Failed to get promotions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promotions": promotions,
+		"count":      len(promotions),
+	})
+}
+
+// GetPromotion handles GET /promotions/:id
+func (h *PromotionHandler) GetPromotion(c *gin.Context) {
+	promotion, err := h.promotionService.GetPromotion(c.Param("id"))
+	if err != nil {
+		respondPromotionError(c, "Failed to get promotion", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"promotion": promotion,
+	})
+}
+
+// UpdatePromotion handles PUT /promotions/:id
+func (h *PromotionHandler) UpdatePromotion(c *gin.Context) {
+	var request models.PromotionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	promotion, err := h.promotionService.UpdatePromotion(c.Param("id"), &request)
+	if err != nil {
+		respondPromotionError(c, "Failed to update promotion", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Promotion updated successfully",
+		"promotion": promotion,
+	})
+}
+
+// PreviewPromotions handles POST /promotions/preview
+func (h *PromotionHandler) PreviewPromotions(c *gin.Context) {
+	var request models.PromotionPreviewRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	preview, err := h.promotionService.Preview(&request)
+	if err != nil {
+		respondPromotionError(c, "Failed to preview promotions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"preview": preview,
+	})
+}
+
+// respondPromotionError maps promotion errors to HTTP status codes
+func respondPromotionError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, repository.ErrPromotionNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, repository.ErrPromotionCodeTaken):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrPromotionNotApplicable):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, clients.ErrCatalogUnavailable):
+		status = http.StatusServiceUnavailable
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"order-service/models"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundHandler struct {
+	refundService *service.RefundService
+}
+
+// NewRefundHandler creates a new instance of RefundHandler
+func NewRefundHandler() *RefundHandler {
+	return &RefundHandler{
+		refundService: service.NewRefundService(),
+	}
+}
+
+// RequestRefund handles POST /orders/:id/refunds
+func (h *RefundHandler) RequestRefund(c *gin.Context) {
+	var request models.CreateRefundRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	refund, err := h.refundService.RequestRefund(c.Param("id"), &request)
+	if err != nil {
+		respondRefundError(c, "Failed to request refund", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Refund requested successfully",
+		"refund":  refund,
+	})
+}
+
+// GetOrderRefunds handles GET /orders/:id/refunds
+func (h *RefundHandler) GetOrderRefunds(c *gin.Context) {
+	orderID := c.Param("id")
+
+	refunds, err := h.refundService.GetOrderRefunds(orderID)
+	if err != nil {
+		respondRefundError(c, "Failed to get refunds", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"order_id": orderID,
+		"refunds":  refunds,
+	})
+}
+
+// GetRefund handles GET /refunds/:id
+func (h *RefundHandler) GetRefund(c *gin.Context) {
+	refund, err := h.refundService.GetRefund(c.Param("id"))
+	if err != nil {
+		respondRefundError(c, "Failed to get refund", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"refund": refund,
+	})
+}
+
+// ApproveRefund handles POST /refunds/:id/approve
+func (h *RefundHandler) ApproveRefund(c *gin.Context) {
+	var request models.ReviewRefundRequest
+	if !bindOptionalJSON(c, &request) {
+		return
+	}
+
+	refund, err := h.refundService.ApproveRefund(c.Param("id"), &request)
+	if err != nil {
+		if errors.Is(err, service.ErrRefundFailed) {
+			c.JSON(http.StatusBadGateway, gin.H{
+				"error":   "Refund failed",
+				"details": err.Error(),
+				"refund":  refund,
+			})
+			return
+		}
+		respondRefundError(c, "Failed to approve refund", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund approved successfully",
+		"refund":  refund,
+	})
+}
+
+// RejectRefund handles POST /refunds/:id/reject
+func (h *RefundHandler) RejectRefund(c *gin.Context) {
+	var request models.ReviewRefundRequest
+	if !bindOptionalJSON(c, &request) {
+		return
+	}
+
+	refund, err := h.refundService.RejectRefund(c.Param("id"), &request)
+	if err != nil {
+		respondRefundError(c, "Failed to reject refund", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Refund rejected",
+		"refund":  refund,
+	})
+}
+
+// respondRefundError maps refund errors to HTTP status codes
+func respondRefundError(c *gin.Context, message string, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, repository.ErrRefundNotFound), err.Error() == "order not found":
+		status = http.StatusNotFound
+	case errors.Is(err, repository.ErrRefundStatusConflict):
+		status = http.StatusConflict
+	case errors.Is(err, service.ErrInvalidRefund):
+		status = http.StatusUnprocessableEntity
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"order-service/models"
+	"order-service/repository"
+	"order-service/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: service.NewWebhookService(),
+	}
+}
+
+// CreateSubscription handles POST /webhooks
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var request models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	subscription, err := h.webhookService.CreateSubscription(&request)
+	if err != nil {
+		respondWebhookError(c, "Failed to create webhook subscription", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Webhook subscription created successfully",
+		"subscription": subscription,
+	})
+}
+
+// GetSubscriptions handles GET /webhooks
+func (h *WebhookHandler) GetSubscriptions(c *gin.Context) {
+	subscriptions, err := h.webhookService.GetSubscriptions()
+	if err != nil {
+		respondWebhookError(c, "Failed to get webhook subscriptions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subscriptions,
+		"count":         len(subscriptions),
+	})
+}
+
+// GetSubscription handles GET /webhooks/:id
+func (h *WebhookHandler) GetSubscription(c *gin.Context) {
+	subscription, err := h.webhookService.GetSubscription(c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, "Failed to get webhook subscription", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscription": subscription,
+	})
+}
+
+// UpdateSubscription handles PUT /webhooks/:id
+func (h *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	var request models.WebhookSubscriptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	subscription, err := h.webhookService.UpdateSubscription(c.Param("id"), &request)
+	if err != nil {
+		respondWebhookError(c, "Failed to update webhook subscription", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Webhook subscription updated successfully",
+		"subscription": subscription,
+	})
+}
+
+// DeleteSubscription handles DELETE /webhooks/:id
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	if err := h.webhookService.DeleteSubscription(c.Param("id")); err != nil {
+		respondWebhookError(c, "Failed to delete webhook subscription", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Webhook subscription deleted successfully",
+	})
+}
+
+// GetDeliveries handles GET /webhooks/:id/deliveries?status=dead&limit=50
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id := c.Param("id")
+
+	deliveries, err := h.webhookService.GetDeliveries(id, c.Query("status"), c.Query("limit"))
+	if err != nil {
+		respondWebhookError(c, "Failed to get webhook deliveries", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscription_id": id,
+		"deliveries":      deliveries,
+		"count":           len(deliveries),
+	})
+}
+
+// GetDelivery handles GET /webhook-deliveries/:id
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.webhookService.GetDelivery(c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, "Failed to get webhook delivery", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"delivery": delivery,
+	})
+}
+
+// Redeliver handles POST /webhook-deliveries/:id/redeliver
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.webhookService.Redeliver(c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, "Failed to redeliver webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Webhook delivery queued",
+		"delivery": delivery,
+	})
+}
+
+// respondWebhookError maps webhook errors to HTTP status codes
+func respondWebhookError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrInvalidWebhookSubscription):
+		status = http.StatusBadRequest
+	case errors.Is(err, repository.ErrWebhookSubscriptionNotFound), errors.Is(err, repository.ErrWebhookDeliveryNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, repository.ErrWebhookSubscriptionInactive):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
@@ -0,0 +1,129 @@
+// Package invoices renders order invoices as HTML and PDF documents
+package invoices
+
+import (
+	"os"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+)
+
+// Seller identifies the business issuing invoices
+type Seller struct {
+	Name    string
+	Address string
+	TaxID   string
+}
+
+// SellerFromEnv reads the seller details from INVOICE_SELLER_NAME,
+// INVOICE_SELLER_ADDRESS and INVOICE_SELLER_TAX_ID
+func SellerFromEnv() Seller {
+	seller := Seller{
+		Name:    os.Getenv("INVOICE_SELLER_NAME"),
+		Address: os.Getenv("INVOICE_SELLER_ADDRESS"),
+		TaxID:   os.Getenv("INVOICE_SELLER_TAX_ID"),
+	}
+	if seller.Name == "" {
+		seller.Name = "LUGX Gaming"
+	}
+	return seller
+}
+
+// Document holds everything printed on an invoice
+type Document struct {
+	Seller     Seller
+	Number     string
+	IssuedAt   time.Time
+	OrderID    string
+	OrderDate  time.Time
+	CustomerID string
+	Currency   string
+	// Country and Region are the jurisdiction the order was taxed for
+	Country          string
+	Region           string
+	PricesIncludeTax bool
+	Lines            []Line
+	Discounts        []Discount
+	Taxes            []Tax
+	Subtotal         money.Amount
+	DiscountTotal    money.Amount
+	TaxTotal         money.Amount
+	Total            money.Amount
+}
+
+// Line is an order item on an invoice. Amount is what the line costs after
+// discounts, before any tax added on top.
+type Line struct {
+	Description string
+	Quantity    int
+	UnitPrice   money.Amount
+	Discount    money.Amount
+	Tax         money.Amount
+	Amount      money.Amount
+}
+
+// Discount is a promotion applied to the order
+type Discount struct {
+	Description string
+	Code        string
+	Amount      money.Amount
+}
+
+// Tax is the tax of one rule over the whole order
+type Tax struct {
+	Name    string
+	Rate    float64
+	Taxable money.Amount
+	Amount  money.Amount
+}
+
+// NewDocument builds the document of an invoice from its order
+func NewDocument(seller Seller, invoice *models.Invoice, order *models.Order) *Document {
+	document := &Document{
+		Seller:           seller,
+		Number:           invoice.Number,
+		IssuedAt:         invoice.IssuedAt,
+		OrderID:          order.ID,
+		OrderDate:        order.OrderDate,
+		CustomerID:       order.CustomerID,
+		Currency:         order.Currency,
+		Country:          order.Country,
+		Region:           order.Region,
+		PricesIncludeTax: order.PricesIncludeTax,
+		DiscountTotal:    order.DiscountTotal,
+		TaxTotal:         order.TaxTotal,
+		Total:            order.TotalPrice,
+	}
+
+	for _, item := range order.Items {
+		document.Lines = append(document.Lines, Line{
+			Description: item.GameName,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.Price,
+			Discount:    item.Discount,
+			Tax:         item.Tax,
+			Amount:      item.Subtotal - item.Discount,
+		})
+		document.Subtotal += item.Subtotal
+	}
+
+	for _, discount := range order.Discounts {
+		line := Discount{Description: discount.Description, Amount: discount.Amount}
+		if discount.Code != nil {
+			line.Code = *discount.Code
+		}
+		document.Discounts = append(document.Discounts, line)
+	}
+
+	for _, taxLine := range order.TaxLines {
+		document.Taxes = append(document.Taxes, Tax{
+			Name:    taxLine.Name,
+			Rate:    taxLine.Rate,
+			Taxable: taxLine.TaxableAmount,
+			Amount:  taxLine.Amount,
+		})
+	}
+
+	return document
+}
@@ -0,0 +1,36 @@
+package invoices
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"strconv"
+	"time"
+)
+
+//go:embed invoice.html
+var htmlTemplate string
+
+var invoiceTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"date": formatDate,
+	"rate": formatRate,
+}).Parse(htmlTemplate))
+
+// RenderHTML renders an invoice as a standalone HTML page
+func RenderHTML(document *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := invoiceTemplate.Execute(&buf, document); err != nil {
+		return nil, fmt.Errorf("failed to render invoice %s: %v", document.Number, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// formatRate prints a tax rate with as many decimals as it has, such as 19% or 9.975%
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
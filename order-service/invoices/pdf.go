@@ -0,0 +1,286 @@
+package invoices
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Page layout in points, on A4 paper
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	pageMargin = 50
+	lineHeight = 15
+	fontSize   = 10
+)
+
+// Right edges of the numeric columns of the line table
+const (
+	columnQuantity  = 290
+	columnUnitPrice = 350
+	columnDiscount  = 410
+	columnTax       = 460
+	columnAmount    = pageWidth - pageMargin
+)
+
+// columnTotalsLabel is where the labels of the totals start
+const columnTotalsLabel = 320
+
+// Fonts of the page resources: the standard Helvetica faces every PDF reader has
+const (
+	fontRegular = "F1"
+	fontBold    = "F2"
+)
+
+// RenderPDF renders an invoice as a PDF document
+func RenderPDF(document *Document) ([]byte, error) {
+	w := &pdfWriter{}
+	w.newPage()
+
+	w.text(pageMargin, fontBold, 20, "Invoice "+document.Number)
+	w.advance(2)
+
+	w.text(pageMargin, fontBold, fontSize, document.Seller.Name)
+	w.advance(1)
+	if document.Seller.Address != "" {
+		w.text(pageMargin, fontRegular, fontSize, document.Seller.Address)
+		w.advance(1)
+	}
+	if document.Seller.TaxID != "" {
+		w.text(pageMargin, fontRegular, fontSize, "Tax ID: "+document.Seller.TaxID)
+		w.advance(1)
+	}
+	w.advance(1)
+
+	details := []string{
+		"Invoice date: " + formatDate(document.IssuedAt),
+		"Order: " + document.OrderID,
+		"Order date: " + formatDate(document.OrderDate),
+		"Customer: " + document.CustomerID,
+	}
+	if document.Country != "" {
+		country := "Country: " + document.Country
+		if document.Region != "" {
+			country += " (" + document.Region + ")"
+		}
+		details = append(details, country)
+	}
+	for _, detail := range details {
+		w.text(pageMargin, fontRegular, fontSize, detail)
+		w.advance(1)
+	}
+	w.advance(1)
+
+	tableHeader := func() {
+		w.text(pageMargin, fontBold, fontSize, "Item")
+		w.textRight(columnQuantity, fontBold, fontSize, "Qty")
+		w.textRight(columnUnitPrice, fontBold, fontSize, "Unit price")
+		w.textRight(columnDiscount, fontBold, fontSize, "Discount")
+		w.textRight(columnTax, fontBold, fontSize, "Tax")
+		w.textRight(columnAmount, fontBold, fontSize, "Amount ("+document.Currency+")")
+		w.advance(1)
+		w.rule()
+	}
+	tableHeader()
+
+	for _, line := range document.Lines {
+		if w.needsPage(1) {
+			w.newPage()
+			tableHeader()
+		}
+		description := fitText(line.Description, fontSize, columnQuantity-pageMargin-40)
+		w.text(pageMargin, fontRegular, fontSize, description)
+		w.textRight(columnQuantity, fontRegular, fontSize, strconv.Itoa(line.Quantity))
+		w.textRight(columnUnitPrice, fontRegular, fontSize, line.UnitPrice.String())
+		if line.Discount != 0 {
+			w.textRight(columnDiscount, fontRegular, fontSize, "-"+line.Discount.String())
+		}
+		w.textRight(columnTax, fontRegular, fontSize, line.Tax.String())
+		w.textRight(columnAmount, fontRegular, fontSize, line.Amount.String())
+		w.advance(1)
+	}
+	w.rule()
+	w.advance(1)
+
+	total := func(font, label, amount string) {
+		if w.needsPage(1) {
+			w.newPage()
+		}
+		w.text(columnTotalsLabel, font, fontSize, fitText(label, fontSize, columnAmount-columnTotalsLabel-70))
+		w.textRight(columnAmount, font, fontSize, amount)
+		w.advance(1)
+	}
+
+	total(fontRegular, "Subtotal", document.Subtotal.String())
+	for _, discount := range document.Discounts {
+		label := discount.Description
+		if discount.Code != "" {
+			label += " (" + discount.Code + ")"
+		}
+		total(fontRegular, label, "-"+discount.Amount.String())
+	}
+	if !document.PricesIncludeTax {
+		for _, tax := range document.Taxes {
+			total(fontRegular, fmt.Sprintf("%s %s on %s", tax.Name, formatRate(tax.Rate), tax.Taxable), tax.Amount.String())
+		}
+	}
+	total(fontBold, "Total ("+document.Currency+")", document.Total.String())
+	if document.PricesIncludeTax {
+		for _, tax := range document.Taxes {
+			total(fontRegular, fmt.Sprintf("Includes %s %s on %s", tax.Name, formatRate(tax.Rate), tax.Taxable), tax.Amount.String())
+		}
+	}
+
+	if len(document.Taxes) == 0 {
+		w.advance(1)
+		if w.needsPage(1) {
+			w.newPage()
+		}
+		w.text(pageMargin, fontRegular, fontSize, "No tax was charged on this order.")
+	}
+
+	return w.bytes(), nil
+}
+
+// pdfWriter lays out text top to bottom over as many pages as needed and
+// writes them as a PDF 1.4 file
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	// y is the baseline of the current line on the last page
+	y float64
+}
+
+func (w *pdfWriter) newPage() {
+	w.pages = append(w.pages, &bytes.Buffer{})
+	w.y = pageHeight - pageMargin
+}
+
+// needsPage reports whether the given number of lines no longer fits on the current page
+func (w *pdfWriter) needsPage(lines int) bool {
+	return w.y-float64(lines*lineHeight) < pageMargin
+}
+
+// advance moves down the given number of lines
+func (w *pdfWriter) advance(lines int) {
+	w.y -= float64(lines * lineHeight)
+}
+
+func (w *pdfWriter) text(x float64, font string, size float64, s string) {
+	fmt.Fprintf(w.pages[len(w.pages)-1], "BT /%s %s Tf %s %s Td (%s) Tj ET\n",
+		font, formatNumber(size), formatNumber(x), formatNumber(w.y), escapeText(s))
+}
+
+// textRight writes text that ends at the given x
+func (w *pdfWriter) textRight(right float64, font string, size float64, s string) {
+	w.text(right-textWidth(s, size), font, size, s)
+}
+
+// rule draws a thin line across the page between the previous line and the current one
+func (w *pdfWriter) rule() {
+	y := w.y + lineHeight - 4
+	fmt.Fprintf(w.pages[len(w.pages)-1], "0.5 w %d %s m %d %s l S\n",
+		pageMargin, formatNumber(y), pageWidth-pageMargin, formatNumber(y))
+}
+
+// bytes assembles the PDF file: the catalog, the page tree, both fonts, then
+// each page followed by its content stream, and the cross-reference table
+func (w *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	const firstPageObject = 5
+	kids := make([]string, len(w.pages))
+	for i := range w.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+2*i)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(w.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, page := range w.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, fontRegular, fontBold, firstPageObject+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// escapeText encodes text as the body of a PDF string in WinAnsiEncoding.
+// Characters the standard fonts cannot show are replaced with '?'.
+func escapeText(s string) string {
+	var buf strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			buf.WriteRune(r)
+		case r == '€':
+			buf.WriteString(`\200`)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&buf, `\%03o`, r)
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	return buf.String()
+}
+
+// fitText shortens text with an ellipsis until it fits in width
+func fitText(s string, size, width float64) string {
+	if textWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + "..."
+}
+
+// textWidth measures text set in Helvetica. Helvetica-Bold is slightly wider
+// for letters but not for digits, so amounts line up in either face.
+func textWidth(s string, size float64) float64 {
+	var units int
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			units += helveticaWidths[r-0x20]
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * size / 1000
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, in thousandths of the font size
+var helveticaWidths = [...]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
@@ -6,6 +6,7 @@ import (
 
 	"order-service/database"
 	"order-service/routes"
+	"order-service/service"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +23,29 @@ func main() {
 	}
 	defer database.CloseDB()
 
+	// "order-service export ..." writes an order export instead of serving the API
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := runExport(os.Args[2:]); err != nil {
+			log.Fatalf("Export failed: %v", err)
+		}
+		return
+	}
+
+	// Retry license key fulfillment of paid orders in the background
+	go service.NewFulfillmentService().Run()
+
+	// Cancel pending orders that were not paid in time
+	go service.NewOrderExpiryService().Run()
+
+	// Email customers about their orders
+	go service.NewNotificationService().Run()
+
+	// Deliver order events to webhook subscribers
+	go service.NewWebhookService().Run()
+
+	// Publish the order events recorded in the outbox
+	go service.NewOutboxRelay().Run()
+
 	// Setup routes
 	router := routes.SetupRoutes()
 
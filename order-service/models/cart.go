@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Cart statuses
+const (
+	CartStatusActive     = "active"
+	CartStatusMerged     = "merged"
+	CartStatusCheckedOut = "checked_out"
+)
+
+// Cart represents a shopping cart owned by a customer or by a guest token
+type Cart struct {
+	ID         string     `json:"id" db:"id"`
+	CustomerID *string    `json:"customer_id,omitempty" db:"customer_id"`
+	GuestToken *string    `json:"guest_token,omitempty" db:"guest_token"`
+	Status     string     `json:"status" db:"status"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	Items      []CartItem `json:"items"`
+}
+
+// CartItem represents a game and quantity in a cart. Prices are not stored;
+// carts are re-priced from the catalog every time they are read.
+type CartItem struct {
+	GameID    int       `json:"game_id" db:"game_id"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	AddedAt   time.Time `json:"added_at" db:"added_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AddCartItemRequest represents the request body for adding a game to a cart
+type AddCartItemRequest struct {
+	GameID   int `json:"game_id" binding:"required"`
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// UpdateCartItemRequest represents the request body for changing an item's quantity
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity" binding:"required,min=1"`
+}
+
+// CheckoutCartRequest represents the optional request body for checking out a cart
+type CheckoutCartRequest struct {
+	CouponCodes []string `json:"coupon_codes,omitempty"`
+	Country     string   `json:"country,omitempty" binding:"omitempty,len=2"`
+	Region      string   `json:"region,omitempty"`
+	// CustomerEmail and Locale are the same as when creating an order
+	CustomerEmail string `json:"customer_email,omitempty" binding:"omitempty,email,max=255"`
+	Locale        string `json:"locale,omitempty" binding:"max=35"`
+}
+
+// CartResponse represents a cart priced against the current catalog
+type CartResponse struct {
+	ID         string             `json:"id"`
+	CustomerID *string            `json:"customer_id,omitempty"`
+	GuestToken *string            `json:"guest_token,omitempty"`
+	Status     string             `json:"status"`
+	Items      []CartItemResponse `json:"items"`
+	ItemCount  int                `json:"item_count"`
+	TotalPrice money.Amount       `json:"total_price"`
+	PricedAt   time.Time          `json:"priced_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
+}
+
+// CartItemResponse represents a cart item with its current catalog price.
+// Available is false when the game was removed from the catalog or is no
+// longer purchasable; such items are excluded from the total and block checkout.
+type CartItemResponse struct {
+	GameID    int          `json:"game_id"`
+	GameName  string       `json:"game_name"`
+	UnitPrice money.Amount `json:"unit_price"`
+	Quantity  int          `json:"quantity"`
+	Subtotal  money.Amount `json:"subtotal"`
+	Available bool         `json:"available"`
+}
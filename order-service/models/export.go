@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// Export formats
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatParquet = "parquet"
+)
+
+// Export rows: one per order, or one per order item with the columns of its order
+const (
+	ExportRowsOrders = "orders"
+	ExportRowsItems  = "items"
+)
+
+// Kinds of export columns, which decide how their values are written
+const (
+	ExportKindString    = "string"
+	ExportKindInteger   = "integer"
+	ExportKindAmount    = "amount"
+	ExportKindBoolean   = "boolean"
+	ExportKindTimestamp = "timestamp"
+)
+
+// ExportColumn is a column an export can include
+type ExportColumn struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// OrderExportColumns are the columns of order rows, in their default order
+var OrderExportColumns = []ExportColumn{
+	{Name: "id", Kind: ExportKindString},
+	{Name: "customer_id", Kind: ExportKindString},
+	{Name: "customer_email", Kind: ExportKindString},
+	{Name: "status", Kind: ExportKindString},
+	{Name: "order_date", Kind: ExportKindTimestamp},
+	{Name: "created_at", Kind: ExportKindTimestamp},
+	{Name: "updated_at", Kind: ExportKindTimestamp},
+	{Name: "currency", Kind: ExportKindString},
+	{Name: "country", Kind: ExportKindString},
+	{Name: "region", Kind: ExportKindString},
+	{Name: "prices_include_tax", Kind: ExportKindBoolean},
+	{Name: "item_count", Kind: ExportKindInteger},
+	{Name: "discount_total", Kind: ExportKindAmount},
+	{Name: "tax_total", Kind: ExportKindAmount},
+	{Name: "total_price", Kind: ExportKindAmount},
+	{Name: "refunded_amount", Kind: ExportKindAmount},
+	{Name: "net_total", Kind: ExportKindAmount},
+}
+
+// OrderItemExportColumns are the columns of item rows, in their default order
+var OrderItemExportColumns = []ExportColumn{
+	{Name: "order_id", Kind: ExportKindString},
+	{Name: "order_date", Kind: ExportKindTimestamp},
+	{Name: "customer_id", Kind: ExportKindString},
+	{Name: "status", Kind: ExportKindString},
+	{Name: "currency", Kind: ExportKindString},
+	{Name: "country", Kind: ExportKindString},
+	{Name: "region", Kind: ExportKindString},
+	{Name: "prices_include_tax", Kind: ExportKindBoolean},
+	{Name: "item_id", Kind: ExportKindString},
+	{Name: "game_id", Kind: ExportKindInteger},
+	{Name: "game_name", Kind: ExportKindString},
+	{Name: "category", Kind: ExportKindString},
+	{Name: "catalog_price", Kind: ExportKindAmount},
+	{Name: "price", Kind: ExportKindAmount},
+	{Name: "quantity", Kind: ExportKindInteger},
+	{Name: "subtotal", Kind: ExportKindAmount},
+	{Name: "discount", Kind: ExportKindAmount},
+	{Name: "tax", Kind: ExportKindAmount},
+	{Name: "line_total", Kind: ExportKindAmount},
+}
+
+// OrderExportRequest represents the query parameters of an order export.
+// From and To are RFC 3339 times or YYYY-MM-DD dates in Timezone; To is
+// inclusive for dates. Columns is a comma separated list.
+type OrderExportRequest struct {
+	From     string `form:"from"`
+	To       string `form:"to"`
+	Format   string `form:"format"`
+	Rows     string `form:"rows"`
+	Columns  string `form:"columns"`
+	Timezone string `form:"timezone"`
+}
+
+// OrderExport is a validated export: the orders placed in [From, To), written
+// as Format with the given columns and times in Location
+type OrderExport struct {
+	From     *time.Time
+	To       *time.Time
+	Format   string
+	Rows     string
+	Columns  []ExportColumn
+	Location *time.Location
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+)
+
+// Fulfillment statuses. Confirmed orders are fulfilled by claiming their
+// license keys; attempts that fail stay pending and are retried. Orders that
+// are cancelled or delivered by hand before that are skipped.
+const (
+	FulfillmentStatusPending   = "pending"
+	FulfillmentStatusFulfilled = "fulfilled"
+	FulfillmentStatusSkipped   = "skipped"
+)
+
+// Fulfillment tracks the delivery of an order's license keys
+type Fulfillment struct {
+	OrderID       string     `json:"order_id" db:"order_id"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	FulfilledAt   *time.Time `json:"fulfilled_at,omitempty" db:"fulfilled_at"`
+}
+
+// OrderItemLicenseKeys lists the license keys issued for one order line
+type OrderItemLicenseKeys struct {
+	OrderItemID string   `json:"order_item_id"`
+	GameID      int      `json:"game_id"`
+	GameName    string   `json:"game_name"`
+	LicenseKeys []string `json:"license_keys"`
+}
+
+// OrderLicenseKeysResponse reveals the license keys of an order to its customer
+type OrderLicenseKeysResponse struct {
+	OrderID     string                 `json:"order_id"`
+	Fulfillment *Fulfillment           `json:"fulfillment"`
+	Items       []OrderItemLicenseKeys `json:"items"`
+}
+
+// ImportLicenseKeysRequest adds license keys of a game to the key store
+type ImportLicenseKeysRequest struct {
+	GameID int      `json:"game_id" binding:"required"`
+	Keys   []string `json:"keys" binding:"required,min=1,dive,required,max=255"`
+}
+
+// LicenseKeyStock is the number of unclaimed license keys of a game
+type LicenseKeyStock struct {
+	GameID    int `json:"game_id" db:"game_id"`
+	Available int `json:"available" db:"available"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Gift statuses. A gift is issued with its order and redeemed by its
+// recipient once the order is paid. Refunds revoke the units of the gifted
+// line they refund; once every unit is revoked, or the order is fully
+// refunded or cancelled, the gift is revoked, redeemed or not.
+const (
+	GiftStatusIssued   = "issued"
+	GiftStatusRedeemed = "redeemed"
+	GiftStatusRevoked  = "revoked"
+)
+
+// Gift is an order line bought for someone else. Its code is handed to the
+// recipient, who redeems it to take over the line's license keys.
+type Gift struct {
+	ID          string `json:"id" db:"id"`
+	OrderID     string `json:"order_id" db:"order_id"`
+	OrderItemID string `json:"order_item_id" db:"order_item_id"`
+	GameID      int    `json:"game_id,omitempty"`
+	GameName    string `json:"game_name,omitempty"`
+	Quantity    int    `json:"quantity,omitempty"`
+	// Code is only shown to the customer who placed the order
+	Code                string     `json:"code,omitempty" db:"code"`
+	RecipientEmail      string     `json:"recipient_email,omitempty" db:"recipient_email"`
+	RecipientCustomerID string     `json:"recipient_customer_id,omitempty" db:"recipient_customer_id"`
+	Message             string     `json:"message,omitempty" db:"message"`
+	Status              string     `json:"status" db:"status"`
+	RedeemedBy          *string    `json:"redeemed_by,omitempty" db:"redeemed_by"`
+	RedeemedAt          *time.Time `json:"redeemed_at,omitempty" db:"redeemed_at"`
+	// RevokedQuantity is how many units of the line were refunded since
+	RevokedQuantity int        `json:"revoked_quantity,omitempty" db:"revoked_quantity"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	// LicenseKeys are only shown to the customer who redeemed the gift, while it stays redeemed
+	LicenseKeys []string `json:"license_keys,omitempty"`
+}
+
+// GiftRequest marks an order line as a gift for a recipient, named by email,
+// customer ID or both
+type GiftRequest struct {
+	RecipientEmail      string `json:"recipient_email,omitempty" binding:"omitempty,email,max=255"`
+	RecipientCustomerID string `json:"recipient_customer_id,omitempty" binding:"max=255"`
+	Message             string `json:"message,omitempty" binding:"max=1000"`
+}
+
+// RedeemGiftRequest represents the request body for redeeming a gift code
+type RedeemGiftRequest struct {
+	Code string `json:"code" binding:"required"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// IdempotencyKey is the stored result of a POST /orders request sent with an
+// Idempotency-Key header. Response is the order as it was returned to the
+// first request, replayed verbatim to retries until ExpiresAt.
+type IdempotencyKey struct {
+	Key         string          `json:"key" db:"key"`
+	RequestHash string          `json:"request_hash" db:"request_hash"`
+	OrderID     string          `json:"order_id" db:"order_id"`
+	Response    json.RawMessage `json:"response" db:"response"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	ExpiresAt   time.Time       `json:"expires_at" db:"expires_at"`
+}
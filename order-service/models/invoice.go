@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// Invoice represents the invoice of a confirmed order. Numbers are assigned
+// per calendar year without gaps, in the order orders are confirmed. The
+// rendered documents are stored so the invoice never changes once issued.
+type Invoice struct {
+	ID      string `json:"id" db:"id"`
+	OrderID string `json:"order_id" db:"order_id"`
+	// Number is the year and sequence, such as INV-2024-000042
+	Number     string     `json:"number" db:"number"`
+	Year       int        `json:"year" db:"year"`
+	Sequence   int        `json:"sequence" db:"sequence"`
+	IssuedAt   time.Time  `json:"issued_at" db:"issued_at"`
+	RenderedAt *time.Time `json:"rendered_at,omitempty" db:"rendered_at"`
+	HTML       []byte     `json:"-" db:"html"`
+	PDF        []byte     `json:"-" db:"pdf"`
+}
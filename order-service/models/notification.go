@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Notification events, one email each. Orders placed without a customer
+// email get no notifications.
+const (
+	NotificationOrderPlaced    = "order_placed"
+	NotificationOrderPaid      = "order_paid"
+	NotificationOrderShipped   = "order_shipped"
+	NotificationOrderDelivered = "order_delivered"
+	NotificationOrderRefunded  = "order_refunded"
+)
+
+// Notification statuses. Notifications are queued pending and retried until
+// they are sent or run out of attempts and fail. Notifications that are
+// outdated by the time they are due, such as a shipping email for an order
+// that was delivered in the meantime, are skipped.
+const (
+	NotificationStatusPending = "pending"
+	NotificationStatusSent    = "sent"
+	NotificationStatusSkipped = "skipped"
+	NotificationStatusFailed  = "failed"
+)
+
+// Notification is an email about an order, queued in the transaction that
+// changed the order
+type Notification struct {
+	ID      string `json:"id" db:"id"`
+	OrderID string `json:"order_id" db:"order_id"`
+	Event   string `json:"event" db:"event"`
+	// Reference tells apart notifications an order can get more than once, such as the refund ID of order_refunded
+	Reference     string     `json:"reference,omitempty" db:"reference"`
+	Recipient     string     `json:"recipient" db:"recipient"`
+	Locale        string     `json:"locale" db:"locale"`
+	Status        string     `json:"status" db:"status"`
+	Attempts      int        `json:"attempts" db:"attempts"`
+	LastError     *string    `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty" db:"sent_at"`
+}
@@ -2,64 +2,162 @@ package models
 
 import (
 	"time"
+
+	"order-service/money"
+)
+
+// Order statuses
+const (
+	OrderStatusPending    = "pending"
+	OrderStatusConfirmed  = "confirmed"
+	OrderStatusProcessing = "processing"
+	OrderStatusShipped    = "shipped"
+	OrderStatusDelivered  = "delivered"
+	OrderStatusCancelled  = "cancelled"
 )
 
 // Order represents an order entity
 type Order struct {
-	ID          string      `json:"id" db:"id"`
-	CustomerID  string      `json:"customer_id" db:"customer_id" binding:"required"`
-	TotalPrice  float64     `json:"total_price" db:"total_price"`
-	Status      string      `json:"status" db:"status"`
-	OrderDate   time.Time   `json:"order_date" db:"order_date"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
-	Items       []OrderItem `json:"items,omitempty"`
+	ID         string       `json:"id" db:"id"`
+	CustomerID string       `json:"customer_id" db:"customer_id" binding:"required"`
+	TotalPrice money.Amount `json:"total_price" db:"total_price"`
+	// Currency is the ISO 4217 code of every amount of the order
+	Currency string `json:"currency" db:"currency"`
+	// DiscountTotal is already taken off TotalPrice
+	DiscountTotal money.Amount `json:"discount_total" db:"discount_total"`
+	// TaxTotal is included in TotalPrice; when PricesIncludeTax is false it was added on top of the item prices
+	TaxTotal         money.Amount `json:"tax_total" db:"tax_total"`
+	PricesIncludeTax bool         `json:"prices_include_tax" db:"prices_include_tax"`
+	// Country and Region select the tax rules of the order
+	Country string `json:"country,omitempty" db:"country"`
+	Region  string `json:"region,omitempty" db:"region"`
+	// RefundedAmount is the sum of completed refunds and NetTotal what remains of TotalPrice
+	RefundedAmount money.Amount `json:"refunded_amount" db:"refunded_amount"`
+	NetTotal       money.Amount `json:"net_total" db:"-"`
+	Status         string       `json:"status" db:"status"`
+	OrderDate      time.Time    `json:"order_date" db:"order_date"`
+	CreatedAt      time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at" db:"updated_at"`
+	Items          []OrderItem  `json:"items,omitempty"`
+	// Discounts lists the promotions applied to the order
+	Discounts []OrderDiscount `json:"discounts,omitempty"`
+	// TaxLines sums the taxes of the lines per rule
+	TaxLines []TaxLine `json:"tax_lines,omitempty"`
+	// CustomerEmail receives the order's notifications in Locale
+	CustomerEmail string `json:"customer_email,omitempty" db:"customer_email"`
+	Locale        string `json:"locale,omitempty" db:"locale"`
 }
 
 // OrderItem represents an item within an order
 type OrderItem struct {
-	ID       string  `json:"id" db:"id"`
-	OrderID  string  `json:"order_id" db:"order_id"`
-	GameID   int     `json:"game_id" db:"game_id" binding:"required"`
-	GameName string  `json:"game_name" db:"game_name"`
-	Price    float64 `json:"price" db:"price" binding:"required,min=0"`
-	Quantity int     `json:"quantity" db:"quantity" binding:"required,min=1"`
-	Subtotal float64 `json:"subtotal" db:"subtotal"`
+	ID       string       `json:"id" db:"id"`
+	OrderID  string       `json:"order_id" db:"order_id"`
+	GameID   int          `json:"game_id" db:"game_id" binding:"required"`
+	GameName string       `json:"game_name" db:"game_name"`
+	Price    money.Amount `json:"price" db:"price" binding:"required,min=0"`
+	Quantity int          `json:"quantity" db:"quantity" binding:"required,min=1"`
+	Subtotal money.Amount `json:"subtotal" db:"subtotal"`
+	// CatalogPrice is the game-service price snapshotted when the order was placed
+	CatalogPrice *money.Amount `json:"catalog_price,omitempty" db:"catalog_price"`
+	// Discount is taken off Subtotal; Discounts breaks it down per promotion
+	Discount  money.Amount        `json:"discount" db:"discount"`
+	Discounts []OrderItemDiscount `json:"discounts,omitempty"`
+	// Tax is levied on Subtotal less Discount; Taxes breaks it down per rule
+	Tax   money.Amount `json:"tax" db:"tax"`
+	Taxes []TaxLine    `json:"taxes,omitempty"`
+	// Category is the catalog category snapshotted when the order was placed
+	Category string `json:"category,omitempty" db:"category"`
+	// Gift is set when the line was bought for someone else
+	Gift *Gift `json:"gift,omitempty"`
 }
 
 // CreateOrderRequest represents the request body for creating an order
 type CreateOrderRequest struct {
 	CustomerID string                   `json:"customer_id" binding:"required"`
 	Items      []CreateOrderItemRequest `json:"items" binding:"required,min=1"`
+	// CouponCodes are promotion codes entered by the customer
+	CouponCodes []string `json:"coupon_codes,omitempty"`
+	// Country (ISO 3166-1 alpha-2) and Region select the tax rules; orders without a country are not taxed
+	Country string `json:"country,omitempty" binding:"omitempty,len=2"`
+	Region  string `json:"region,omitempty"`
+	// CustomerEmail receives emails about the order in Locale, such as "en" or "de-AT"; orders without one send none
+	CustomerEmail string `json:"customer_email,omitempty" binding:"omitempty,email,max=255"`
+	Locale        string `json:"locale,omitempty" binding:"max=35"`
 }
 
-// CreateOrderItemRequest represents an item in the order creation request
+// CreateOrderItemRequest represents an item in the order creation request.
+// GameName and Price are accepted for backward compatibility but ignored:
+// the name and price are always taken from the game catalog.
 type CreateOrderItemRequest struct {
-	GameID   int     `json:"game_id" binding:"required"`
-	GameName string  `json:"game_name" binding:"required"`
-	Price    float64 `json:"price" binding:"required,min=0"`
-	Quantity int     `json:"quantity" binding:"required,min=1"`
+	GameID   int          `json:"game_id" binding:"required"`
+	GameName string       `json:"game_name,omitempty"`
+	Price    money.Amount `json:"price,omitempty"`
+	Quantity int          `json:"quantity" binding:"required,min=1"`
+	// Gift marks the line as bought for someone else
+	Gift *GiftRequest `json:"gift,omitempty"`
 }
 
 // UpdateOrderStatusRequest represents the request body for updating order status
 type UpdateOrderStatusRequest struct {
 	Status string `json:"status" binding:"required,oneof=pending confirmed processing shipped delivered cancelled"`
+	Actor  string `json:"actor,omitempty"`  // Who made the change, defaults to "api"
+	Reason string `json:"reason,omitempty"` // Why the change was made
+}
+
+// OrderStatusChange represents one entry of an order's status history
+type OrderStatusChange struct {
+	ID         string    `json:"id" db:"id"`
+	OrderID    string    `json:"order_id" db:"order_id"`
+	FromStatus *string   `json:"from_status" db:"from_status"` // nil when the order was created
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	Actor      string    `json:"actor" db:"actor"`
+	Reason     string    `json:"reason" db:"reason"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
 }
 
 // OrderResponse represents the response structure for order queries
 type OrderResponse struct {
-	ID         string      `json:"id"`
-	CustomerID string      `json:"customer_id"`
-	TotalPrice float64     `json:"total_price"`
-	Status     string      `json:"status"`
-	OrderDate  time.Time   `json:"order_date"`
-	CreatedAt  time.Time   `json:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at"`
-	Items      []OrderItem `json:"items"`
+	ID               string       `json:"id"`
+	CustomerID       string       `json:"customer_id"`
+	TotalPrice       money.Amount `json:"total_price"`
+	Currency         string       `json:"currency"`
+	DiscountTotal    money.Amount `json:"discount_total"`
+	TaxTotal         money.Amount `json:"tax_total"`
+	PricesIncludeTax bool         `json:"prices_include_tax"`
+	Country          string       `json:"country,omitempty"`
+	Region           string       `json:"region,omitempty"`
+	RefundedAmount   money.Amount `json:"refunded_amount"`
+	NetTotal         money.Amount `json:"net_total"`
+	Status           string       `json:"status"`
+	OrderDate        time.Time    `json:"order_date"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	Items            []OrderItem  `json:"items"`
+	TaxLines         []TaxLine    `json:"tax_lines"`
+	// Discounts lists the promotions applied to the order
+	Discounts     []OrderDiscount `json:"discounts,omitempty"`
+	CustomerEmail string          `json:"customer_email,omitempty"`
+	Locale        string          `json:"locale,omitempty"`
 }
 
 // OrdersListResponse represents the response for listing orders
 type OrdersListResponse struct {
 	Orders []OrderResponse `json:"orders"`
-	Total  int             `json:"total"`
+	// Total counts every order matching the filters, not just this page. It
+	// is left out of pages fetched by cursor, which would otherwise count
+	// every match again on each page.
+	Total    *int `json:"total,omitempty"`
+	PageSize int  `json:"page_size"`
+	// Page is only set when paging by page number rather than cursor
+	Page int `json:"page,omitempty"`
+	// NextCursor fetches the following page; it is empty on the last one
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// GameSales represents the sales volume of a single game over a time window
+type GameSales struct {
+	GameID     int          `json:"game_id" db:"game_id"`
+	UnitsSold  int          `json:"units_sold" db:"units_sold"`
+	Revenue    money.Amount `json:"revenue" db:"revenue"`
+	OrderCount int          `json:"order_count" db:"order_count"`
 }
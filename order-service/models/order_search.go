@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Order sort keys. Orders with the same key are ordered by ID, so every
+// order has a stable place that a cursor can point at.
+const (
+	OrderSortOrderDate  = "order_date"
+	OrderSortTotalPrice = "total_price"
+)
+
+// OrderSearchRequest holds the query parameters of GET /orders
+type OrderSearchRequest struct {
+	// Status may be repeated or comma separated
+	Status     []string `form:"status"`
+	CustomerID string   `form:"customer_id"`
+	// From and To bound the order date as RFC 3339 times or YYYY-MM-DD dates;
+	// From is inclusive, To exclusive unless it is a date
+	From     string `form:"from"`
+	To       string `form:"to"`
+	MinTotal string `form:"min_total"`
+	MaxTotal string `form:"max_total"`
+	// GameID matches orders with at least one item of the game
+	GameID string `form:"game_id"`
+	Sort   string `form:"sort"`
+	Order  string `form:"order"`
+	// Cursor is the next_cursor of the previous page; Page is only used without it
+	Cursor   string `form:"cursor"`
+	Page     string `form:"page"`
+	PageSize string `form:"page_size"`
+}
+
+// OrderSearch filters, sorts and pages the orders read by the repository
+type OrderSearch struct {
+	Statuses   []string
+	CustomerID string
+	From       *time.Time
+	To         *time.Time
+	MinTotal   *money.Amount
+	MaxTotal   *money.Amount
+	GameID     int
+	Sort       string
+	Descending bool
+	// After continues a previous page; Offset skips orders instead
+	After  *OrderCursor
+	Offset int
+	Limit  int
+}
+
+// OrderCursor points at the last order of a page by its sort key and ID
+type OrderCursor struct {
+	Sort       string       `json:"s"`
+	Descending bool         `json:"d,omitempty"`
+	OrderDate  *time.Time   `json:"t,omitempty"`
+	TotalPrice money.Amount `json:"p,omitempty"`
+	ID         string       `json:"id"`
+}
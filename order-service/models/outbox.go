@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Order event types recorded in the outbox and published to the event stream
+const (
+	OrderEventCreated       = "OrderCreated"
+	OrderEventStatusChanged = "OrderStatusChanged"
+	OrderEventDeleted       = "OrderDeleted"
+)
+
+// OutboxAggregateOrder is the aggregate type of order events
+const OutboxAggregateOrder = "order"
+
+// OutboxEvent is a domain event written in the transaction of the change
+// that caused it and published to the broker afterwards. Sequence orders the
+// events; events of one aggregate are published in that order.
+type OutboxEvent struct {
+	ID            string `json:"id" db:"id"`
+	Sequence      int64  `json:"sequence" db:"sequence"`
+	AggregateType string `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   string `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string `json:"event_type" db:"event_type"`
+	// Payload is the event as published, byte for byte
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" db:"published_at"`
+}
+
+// OrderEvent is the payload of every order event. Data is the order for
+// OrderCreated, the OrderStatusChange for OrderStatusChanged and
+// OrderDeletedData for OrderDeleted.
+type OrderEvent struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OrderID    string          `json:"order_id"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Payment attempt statuses
+const (
+	PaymentStatusRequiresAuthorization = "requires_authorization"
+	PaymentStatusAuthorized            = "authorized"
+	PaymentStatusCaptured              = "captured"
+	PaymentStatusPartiallyRefunded     = "partially_refunded"
+	PaymentStatusRefunded              = "refunded"
+	PaymentStatusVoided                = "voided"
+	PaymentStatusFailed                = "failed"
+)
+
+// PaymentAttempt represents one attempt to pay for an order at a payment provider
+type PaymentAttempt struct {
+	ID                string       `json:"id" db:"id"`
+	OrderID           string       `json:"order_id" db:"order_id"`
+	Provider          string       `json:"provider" db:"provider"`
+	ProviderPaymentID string       `json:"provider_payment_id" db:"provider_payment_id"`
+	Status            string       `json:"status" db:"status"`
+	Amount            money.Amount `json:"amount" db:"amount"`
+	CapturedAmount    money.Amount `json:"captured_amount" db:"captured_amount"`
+	RefundedAmount    money.Amount `json:"refunded_amount" db:"refunded_amount"`
+	Currency          string       `json:"currency" db:"currency"`
+	PaymentMethod     *string      `json:"payment_method,omitempty" db:"payment_method"`
+	FailureReason     *string      `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt         time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at" db:"updated_at"`
+}
+
+// AuthorizePaymentRequest represents the request body for authorizing a payment
+type AuthorizePaymentRequest struct {
+	PaymentMethod string `json:"payment_method" binding:"required"`
+}
+
+// PaymentAmountRequest represents the request body for capturing or refunding
+// a payment. Without an amount the full remaining amount is used.
+type PaymentAmountRequest struct {
+	Amount *money.Amount `json:"amount,omitempty" binding:"omitempty,gt=0"`
+}
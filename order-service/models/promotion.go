@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Promotion types
+const (
+	PromotionTypePercentage = "percentage"
+	PromotionTypeFixed      = "fixed"
+	PromotionTypeBuyXGetY   = "buy_x_get_y"
+)
+
+// Promotion is a discount rule. Promotions with a code are coupons that
+// customers enter at checkout; promotions without a code apply automatically.
+type Promotion struct {
+	ID   string  `json:"id" db:"id"`
+	Name string  `json:"name" db:"name"`
+	Code *string `json:"code,omitempty" db:"code"`
+	Type string  `json:"type" db:"type"`
+	// Value is the percentage off for percentage promotions and the amount off
+	// for fixed ones, both with two decimals like the column they are stored in
+	Value money.Amount `json:"value" db:"value"`
+	// BuyQuantity and GetQuantity make every BuyQuantity+GetQuantity eligible
+	// units come with the GetQuantity cheapest of them free
+	BuyQuantity int `json:"buy_quantity,omitempty" db:"buy_quantity"`
+	GetQuantity int `json:"get_quantity,omitempty" db:"get_quantity"`
+	// MinSpend is compared with the undiscounted price of the eligible items
+	MinSpend money.Amount `json:"min_spend" db:"min_spend"`
+	// GameIDs and Categories restrict the promotion to matching games; empty means every game
+	GameIDs          []int      `json:"game_ids" db:"game_ids"`
+	Categories       []string   `json:"categories" db:"categories"`
+	StartsAt         *time.Time `json:"starts_at,omitempty" db:"starts_at"`
+	EndsAt           *time.Time `json:"ends_at,omitempty" db:"ends_at"`
+	UsageLimit       *int       `json:"usage_limit,omitempty" db:"usage_limit"`
+	PerCustomerLimit *int       `json:"per_customer_limit,omitempty" db:"per_customer_limit"`
+	UsageCount       int        `json:"usage_count" db:"usage_count"`
+	// Stackable promotions combine with each other; a promotion that is not
+	// stackable is always applied alone
+	Stackable bool      `json:"stackable" db:"stackable"`
+	Priority  int       `json:"priority" db:"priority"`
+	Active    bool      `json:"active" db:"active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// PromotionRequest represents the request body for creating or replacing a promotion
+type PromotionRequest struct {
+	Name             string       `json:"name" binding:"required"`
+	Code             *string      `json:"code,omitempty"`
+	Type             string       `json:"type" binding:"required,oneof=percentage fixed buy_x_get_y"`
+	Value            money.Amount `json:"value" binding:"min=0"`
+	BuyQuantity      int          `json:"buy_quantity,omitempty" binding:"min=0"`
+	GetQuantity      int          `json:"get_quantity,omitempty" binding:"min=0"`
+	MinSpend         money.Amount `json:"min_spend" binding:"min=0"`
+	GameIDs          []int        `json:"game_ids,omitempty"`
+	Categories       []string     `json:"categories,omitempty"`
+	StartsAt         *time.Time   `json:"starts_at,omitempty"`
+	EndsAt           *time.Time   `json:"ends_at,omitempty"`
+	UsageLimit       *int         `json:"usage_limit,omitempty" binding:"omitempty,min=1"`
+	PerCustomerLimit *int         `json:"per_customer_limit,omitempty" binding:"omitempty,min=1"`
+	Stackable        bool         `json:"stackable"`
+	Priority         int          `json:"priority"`
+	Active           *bool        `json:"active,omitempty"`
+}
+
+// OrderDiscount is a promotion applied to an order, with the total it took off
+type OrderDiscount struct {
+	ID          string       `json:"id" db:"id"`
+	OrderID     string       `json:"order_id" db:"order_id"`
+	PromotionID string       `json:"promotion_id" db:"promotion_id"`
+	Code        *string      `json:"code,omitempty" db:"code"`
+	Description string       `json:"description" db:"description"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+}
+
+// OrderItemDiscount is the part of a promotion's discount taken off one order line
+type OrderItemDiscount struct {
+	PromotionID string       `json:"promotion_id" db:"promotion_id"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+}
+
+// PromotionPreviewRequest represents the request body for pricing a basket without placing an order
+type PromotionPreviewRequest struct {
+	CustomerID  string                   `json:"customer_id"`
+	Items       []CreateOrderItemRequest `json:"items" binding:"required,min=1"`
+	CouponCodes []string                 `json:"coupon_codes,omitempty"`
+}
+
+// PromotionPreviewResponse is the price of a basket with promotions applied
+type PromotionPreviewResponse struct {
+	Items         []OrderItem     `json:"items"`
+	Discounts     []OrderDiscount `json:"discounts"`
+	Subtotal      money.Amount    `json:"subtotal"`
+	DiscountTotal money.Amount    `json:"discount_total"`
+	TotalPrice    money.Amount    `json:"total_price"`
+}
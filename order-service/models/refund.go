@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Refund statuses. Refunds are requested, then approved or rejected; approved
+// refunds are sent to the payment provider and end up completed or failed.
+// Failed refunds can be approved again.
+const (
+	RefundStatusRequested = "requested"
+	RefundStatusApproved  = "approved"
+	RefundStatusRejected  = "rejected"
+	RefundStatusCompleted = "completed"
+	RefundStatusFailed    = "failed"
+)
+
+// Refund represents money given back on an order, either for the order as a
+// whole or for specific line items
+type Refund struct {
+	ID               string       `json:"id" db:"id"`
+	OrderID          string       `json:"order_id" db:"order_id"`
+	Status           string       `json:"status" db:"status"`
+	Amount           money.Amount `json:"amount" db:"amount"`
+	Reason           string       `json:"reason" db:"reason"`
+	RequestedBy      string       `json:"requested_by" db:"requested_by"`
+	ReviewedBy       *string      `json:"reviewed_by,omitempty" db:"reviewed_by"`
+	ReviewNote       *string      `json:"review_note,omitempty" db:"review_note"`
+	PaymentAttemptID *string      `json:"payment_attempt_id,omitempty" db:"payment_attempt_id"`
+	FailureReason    *string      `json:"failure_reason,omitempty" db:"failure_reason"`
+	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at" db:"updated_at"`
+	CompletedAt      *time.Time   `json:"completed_at,omitempty" db:"completed_at"`
+	Items            []RefundItem `json:"items"`
+}
+
+// RefundItem represents the refunded part of an order line
+type RefundItem struct {
+	ID          string       `json:"id" db:"id"`
+	RefundID    string       `json:"refund_id" db:"refund_id"`
+	OrderItemID string       `json:"order_item_id" db:"order_item_id"`
+	GameID      int          `json:"game_id" db:"game_id"`
+	Quantity    int          `json:"quantity" db:"quantity"`
+	Amount      money.Amount `json:"amount" db:"amount"`
+}
+
+// RefundCommitments is what is already refunded, or pending approval, on an
+// order: the total amount and the quantity and amount per order item
+type RefundCommitments struct {
+	Amount         money.Amount
+	ItemQuantities map[string]int
+	ItemAmounts    map[string]money.Amount
+}
+
+// CreateRefundRequest represents the request body for requesting a refund.
+// Without items the refund applies to the order as a whole; without an amount
+// it covers everything that is still refundable.
+type CreateRefundRequest struct {
+	Amount      *money.Amount             `json:"amount,omitempty" binding:"omitempty,gt=0"`
+	Reason      string                    `json:"reason" binding:"required"`
+	RequestedBy string                    `json:"requested_by"`
+	Items       []CreateRefundItemRequest `json:"items,omitempty" binding:"omitempty,dive"`
+}
+
+// CreateRefundItemRequest selects an order line to refund. Quantity defaults
+// to every unit not refunded yet and Amount to the price of those units.
+type CreateRefundItemRequest struct {
+	OrderItemID string        `json:"order_item_id" binding:"required"`
+	Quantity    int           `json:"quantity,omitempty" binding:"omitempty,min=1"`
+	Amount      *money.Amount `json:"amount,omitempty" binding:"omitempty,gt=0"`
+}
+
+// ReviewRefundRequest represents the request body for approving or rejecting a refund
+type ReviewRefundRequest struct {
+	Actor string `json:"actor"`
+	Note  string `json:"note"`
+	// SettleOutsideProvider completes the refund of an order without payments, paid before payments
+	// were taken through the provider, on the reviewer's word that the money is paid back by other means
+	SettleOutsideProvider bool `json:"settle_outside_provider,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"order-service/money"
+)
+
+// Statistics granularities, the width of the time buckets. Buckets start at
+// UTC boundaries; weeks start on Monday.
+const (
+	StatisticsGranularityHour  = "hour"
+	StatisticsGranularityDay   = "day"
+	StatisticsGranularityWeek  = "week"
+	StatisticsGranularityMonth = "month"
+)
+
+// OrderStatisticsRequest holds the query parameters of GET /orders/stats
+type OrderStatisticsRequest struct {
+	// From and To bound the order date like in an order search
+	From        string `form:"from"`
+	To          string `form:"to"`
+	Granularity string `form:"granularity"`
+	// Top is how many games and categories to rank
+	Top string `form:"top"`
+}
+
+// OrderStatistics aggregates the orders placed in a date range. Revenue counts
+// delivered orders after discounts and before tax, like the revenue of top
+// games and categories; net revenue takes off their refunds, without the tax
+// those paid back. RefundedAmount is what refunds paid back, tax included.
+type OrderStatistics struct {
+	From              *time.Time     `json:"from,omitempty"`
+	To                *time.Time     `json:"to,omitempty"`
+	Granularity       string         `json:"granularity"`
+	TotalOrders       int            `json:"total_orders"`
+	DeliveredOrders   int            `json:"delivered_orders"`
+	TotalRevenue      money.Amount   `json:"total_revenue"`
+	NetRevenue        money.Amount   `json:"net_revenue"`
+	AverageOrderValue money.Amount   `json:"average_order_value"`
+	RefundedAmount    money.Amount   `json:"refunded_amount"`
+	RefundedOrders    int            `json:"refunded_orders"`
+	StatusCounts      map[string]int `json:"status_counts"`
+	// Buckets without orders are left out
+	Buckets       []OrderStatisticsBucket `json:"buckets"`
+	TopGames      []GameRevenue           `json:"top_games"`
+	TopCategories []CategoryRevenue       `json:"top_categories"`
+}
+
+// OrderStatisticsBucket aggregates the orders placed in one time bucket
+type OrderStatisticsBucket struct {
+	Start             time.Time      `json:"start"`
+	OrderCount        int            `json:"order_count"`
+	DeliveredOrders   int            `json:"delivered_orders"`
+	Revenue           money.Amount   `json:"revenue"`
+	NetRevenue        money.Amount   `json:"net_revenue"`
+	AverageOrderValue money.Amount   `json:"average_order_value"`
+	StatusCounts      map[string]int `json:"status_counts"`
+}
+
+// GameRevenue ranks a game by the revenue of its delivered order lines, after
+// discounts and before tax
+type GameRevenue struct {
+	GameID     int          `json:"game_id" db:"game_id"`
+	GameName   string       `json:"game_name" db:"game_name"`
+	UnitsSold  int          `json:"units_sold" db:"units_sold"`
+	Revenue    money.Amount `json:"revenue" db:"revenue"`
+	OrderCount int          `json:"order_count" db:"order_count"`
+}
+
+// CategoryRevenue ranks a game category like GameRevenue ranks a game
+type CategoryRevenue struct {
+	Category   string       `json:"category" db:"category"`
+	UnitsSold  int          `json:"units_sold" db:"units_sold"`
+	Revenue    money.Amount `json:"revenue" db:"revenue"`
+	OrderCount int          `json:"order_count" db:"order_count"`
+}
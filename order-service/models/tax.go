@@ -0,0 +1,16 @@
+package models
+
+import "order-service/money"
+
+// TaxLine is a tax levied under one rule, either on a single order line or,
+// on the order, summed over all of its lines
+type TaxLine struct {
+	Name    string `json:"name" db:"name"`
+	Country string `json:"country" db:"country"`
+	Region  string `json:"region,omitempty" db:"region"`
+	// Rate is a percentage
+	Rate float64 `json:"rate" db:"rate"`
+	// TaxableAmount is the net amount the tax is levied on
+	TaxableAmount money.Amount `json:"taxable_amount" db:"taxable_amount"`
+	Amount        money.Amount `json:"amount" db:"amount"`
+}
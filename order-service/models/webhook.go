@@ -0,0 +1,114 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"order-service/money"
+)
+
+// Webhook event types sent to subscribers. A subscription to "*" receives all of them.
+const (
+	WebhookEventOrderCreated       = "order.created"
+	WebhookEventOrderStatusChanged = "order.status_changed"
+	WebhookEventOrderRefunded      = "order.refunded"
+	WebhookEventOrderDeleted       = "order.deleted"
+	WebhookEventAll                = "*"
+)
+
+// Webhook delivery statuses. Deliveries are retried while pending and end up
+// delivered, or dead once they run out of attempts; dead and delivered
+// deliveries can be redelivered by hand.
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusDead      = "dead"
+)
+
+// WebhookSubscription sends the events of the given types to a partner's URL.
+// Secret signs the deliveries; it is only shown when it is set.
+type WebhookSubscription struct {
+	ID          string    `json:"id" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	EventTypes  []string  `json:"event_types" db:"event_types"`
+	Description string    `json:"description" db:"description"`
+	Active      bool      `json:"active" db:"active"`
+	Secret      string    `json:"secret,omitempty" db:"secret"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// WebhookSubscriptionRequest creates or replaces a webhook subscription.
+// Without a secret one is generated on creation, and kept on update.
+type WebhookSubscriptionRequest struct {
+	URL         string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes  []string `json:"event_types" binding:"required,min=1,dive,required"`
+	Description string   `json:"description,omitempty" binding:"max=255"`
+	Active      *bool    `json:"active,omitempty"`
+	Secret      string   `json:"secret,omitempty" binding:"omitempty,min=16,max=255"`
+}
+
+// WebhookEvent is the body of every delivery
+type WebhookEvent struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// The data of order.created is the order and the data of order.status_changed
+// the OrderStatusChange.
+
+// OrderRefundedData is the data of an order.refunded event
+type OrderRefundedData struct {
+	OrderID  string       `json:"order_id"`
+	RefundID string       `json:"refund_id"`
+	Amount   money.Amount `json:"amount"`
+	// RefundedAmount is what has been refunded on the order so far, this refund included
+	RefundedAmount money.Amount `json:"refunded_amount"`
+	Currency       string       `json:"currency"`
+}
+
+// OrderDeletedData is the data of an order.deleted event
+type OrderDeletedData struct {
+	OrderID string `json:"order_id"`
+}
+
+// WebhookDelivery is one event sent to one subscription
+type WebhookDelivery struct {
+	ID             string `json:"id" db:"id"`
+	SubscriptionID string `json:"subscription_id" db:"subscription_id"`
+	EventID        string `json:"event_id" db:"event_id"`
+	EventType      string `json:"event_type" db:"event_type"`
+	// Payload is the WebhookEvent sent as the request body, byte for byte
+	Payload            json.RawMessage `json:"payload" db:"payload"`
+	Status             string          `json:"status" db:"status"`
+	Attempts           int             `json:"attempts" db:"attempts"`
+	LastError          *string         `json:"last_error,omitempty" db:"last_error"`
+	LastResponseStatus *int            `json:"last_response_status,omitempty" db:"last_response_status"`
+	NextAttemptAt      time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt          time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt          time.Time       `json:"updated_at" db:"updated_at"`
+	DeliveredAt        *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	// AttemptLog is only loaded for a single delivery
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt logs one request of a delivery and its outcome
+type WebhookDeliveryAttempt struct {
+	ID          string    `json:"id" db:"id"`
+	DeliveryID  string    `json:"delivery_id" db:"delivery_id"`
+	URL         string    `json:"url" db:"url"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+	// ResponseStatus is nil when no response was received
+	ResponseStatus *int    `json:"response_status,omitempty" db:"response_status"`
+	Error          *string `json:"error,omitempty" db:"error"`
+	DurationMS     int64   `json:"duration_ms" db:"duration_ms"`
+}
+
+// DueWebhookDelivery is a delivery leased for an attempt, with where to send it
+type DueWebhookDelivery struct {
+	WebhookDelivery
+	URL    string
+	Secret string
+}
@@ -0,0 +1,193 @@
+// Package money represents amounts exactly, as integer minor units (cents)
+// of a currency, so that sums and products never drift the way float64 does.
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MinorUnits is the number of minor units in one major unit. Every amount
+// the service handles, and every DECIMAL(10,2) column it stores them in,
+// has two decimal places.
+const MinorUnits = 100
+
+// Amount is a money amount in minor units. It is written to JSON as a plain
+// decimal number (59.99), like the float64 amounts it replaces, and to
+// Postgres as an exact decimal string.
+type Amount int64
+
+// DefaultCurrency is the ISO 4217 code orders are priced in, read from
+// CURRENCY and, for older deployments, PAYMENT_CURRENCY. It defaults to USD.
+func DefaultCurrency() string {
+	for _, name := range []string{"CURRENCY", "PAYMENT_CURRENCY"} {
+		if value := strings.ToUpper(strings.TrimSpace(os.Getenv(name))); value != "" {
+			return value
+		}
+	}
+	return "USD"
+}
+
+// FromMinor returns the amount of the given number of minor units
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromFloat converts a float to the nearest amount. It is only meant for
+// drivers that hand DECIMAL columns over as float64.
+func FromFloat(value float64) Amount {
+	return Amount(math.Round(value * MinorUnits))
+}
+
+// Parse reads a decimal amount such as "59.99", "-3" or "1e2" exactly.
+// Digits beyond the minor unit are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	value.Mul(value, big.NewRat(MinorUnits, 1))
+
+	// Round half away from zero
+	num := new(big.Int).Abs(value.Num())
+	den := value.Denom()
+	quotient, remainder := new(big.Int).QuoRem(num, den, new(big.Int))
+	if remainder.Mul(remainder, big.NewInt(2)).Cmp(den) >= 0 {
+		quotient.Add(quotient, big.NewInt(1))
+	}
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+
+	minor := quotient.Int64()
+	if value.Sign() < 0 {
+		minor = -minor
+	}
+	return Amount(minor), nil
+}
+
+// Minor returns the amount in minor units
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// Float returns the amount as a float, for display and for callers that still need one
+func (a Amount) Float() float64 {
+	return float64(a) / MinorUnits
+}
+
+// Mul multiplies the amount by a quantity
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// MulRat multiplies the amount by numerator/denominator, rounding half away
+// from zero. Denominator must be positive.
+func (a Amount) MulRat(numerator, denominator int64) Amount {
+	return Amount(divideRounded(int64(a)*numerator, denominator))
+}
+
+// Percent returns rate percent of the amount, rounded half away from zero.
+// Rates are honoured to four decimal places, such as 9.975.
+func (a Amount) Percent(rate float64) Amount {
+	const precision = 10000
+	return a.MulRat(int64(math.Round(rate*precision)), 100*precision)
+}
+
+// Min returns the smaller of two amounts
+func Min(a, b Amount) Amount {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Sum adds up amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, amount := range amounts {
+		total += amount
+	}
+	return total
+}
+
+// String formats the amount with two decimals, such as "59.90"
+func (a Amount) String() string {
+	sign := ""
+	minor := int64(a)
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/MinorUnits, minor%MinorUnits)
+}
+
+// MarshalJSON writes the amount as a JSON number without trailing zeros,
+// exactly as encoding/json writes the equivalent float64
+func (a Amount) MarshalJSON() ([]byte, error) {
+	s := a.String()
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = amount
+	return nil
+}
+
+// Scan reads a DECIMAL column without going through float64
+func (a *Amount) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case []byte:
+		amount, err := Parse(string(value))
+		if err != nil {
+			return err
+		}
+		*a = amount
+	case string:
+		amount, err := Parse(value)
+		if err != nil {
+			return err
+		}
+		*a = amount
+	case int64:
+		*a = Amount(value * MinorUnits)
+	case float64:
+		*a = FromFloat(value)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+// Value writes the amount as an exact decimal string
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
+
+// divideRounded divides integers, rounding half away from zero. Denominator must be positive.
+func divideRounded(numerator, denominator int64) int64 {
+	if numerator < 0 {
+		return -divideRounded(-numerator, denominator)
+	}
+	return (2*numerator + denominator) / (2 * denominator)
+}
@@ -0,0 +1,119 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Amount
+	}{
+		{"59.99", 5999},
+		{" 59.9 ", 5990},
+		{"-3", -300},
+		{"1e2", 10000},
+		{"0", 0},
+		{"0.004", 0},
+		{"0.005", 1},
+		{"1.125", 113},
+		{"-1.125", -113},
+		{"-0.004", 0},
+		{"19.994999", 1999},
+		{"1/3", 33},
+	}
+
+	for _, test := range tests {
+		amount, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", test.input, err)
+			continue
+		}
+		if amount != test.expected {
+			t.Errorf("Parse(%q) = %d minor units, expected %d", test.input, amount, test.expected)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.2.3", "1e30"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", input)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	// Amounts are written exactly as the float64 values they replace were
+	for _, value := range []float64{59.99, 59.9, 60, 0, -0.05, -12.5, 1234567.89} {
+		expected, err := json.Marshal(value)
+		if err != nil {
+			t.Fatalf("Failed to marshal %v: %v", value, err)
+		}
+		got, err := json.Marshal(FromFloat(value))
+		if err != nil {
+			t.Fatalf("Failed to marshal amount %v: %v", value, err)
+		}
+		if string(got) != string(expected) {
+			t.Errorf("Expected %v to marshal as %s, got %s", value, expected, got)
+		}
+	}
+
+	var decoded struct {
+		Number Amount `json:"number"`
+		String Amount `json:"string"`
+		Null   Amount `json:"null"`
+	}
+	err := json.Unmarshal([]byte(`{"number": 59.99, "string": "0.10", "null": null}`), &decoded)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal amounts: %v", err)
+	}
+	if decoded.Number != 5999 || decoded.String != 10 || decoded.Null != 0 {
+		t.Errorf("Expected 5999, 10 and 0 minor units, got %d, %d and %d", decoded.Number, decoded.String, decoded.Null)
+	}
+}
+
+func TestMulRat(t *testing.T) {
+	tests := []struct {
+		amount      Amount
+		numerator   int64
+		denominator int64
+		expected    Amount
+	}{
+		{1000, 1, 3, 333},
+		{1000, 2, 3, 667},
+		{5, 1, 2, 3},
+		{-5, 1, 2, -3},
+		{-1000, 2, 3, -667},
+		{1999, 0, 7, 0},
+		{1999, 7, 7, 1999},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.MulRat(test.numerator, test.denominator); got != test.expected {
+			t.Errorf("%d.MulRat(%d, %d) = %d, expected %d", test.amount, test.numerator, test.denominator, got, test.expected)
+		}
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount   Amount
+		rate     float64
+		expected Amount
+	}{
+		{10000, 19, 1900},
+		{10000, 9.975, 998},
+		{1999, 5, 100},
+		{1000, 7.25, 73},
+		{1000, 12.5, 125},
+		{-1000, 7.25, -73},
+		{5999, 0, 0},
+		{5999, 100, 5999},
+	}
+
+	for _, test := range tests {
+		if got := test.amount.Percent(test.rate); got != test.expected {
+			t.Errorf("%d.Percent(%v) = %d, expected %d", test.amount, test.rate, got, test.expected)
+		}
+	}
+}
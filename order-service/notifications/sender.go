@@ -0,0 +1,96 @@
+// Package notifications renders the emails sent to customers about their
+// orders and delivers them over SMTP or to a local directory
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultFrom = "LUGX Gaming <no-reply@lugx-gaming.local>"
+
+// Message is a rendered email to one recipient. ID identifies it across
+// retries; it becomes the Message-ID so mail clients can spot duplicates.
+type Message struct {
+	ID      string
+	To      string
+	Subject string
+	HTML    string
+}
+
+// Sender is implemented by every way of delivering email. An error means the
+// message may not have been delivered and should be retried.
+type Sender interface {
+	// Name identifies the sender in logs
+	Name() string
+	// Send delivers a message
+	Send(message *Message) error
+}
+
+// NewSender creates the sender selected by EMAIL_SENDER: "sink" (the default)
+// writes messages to disk, "smtp" sends them to a mail server. Messages are
+// sent from EMAIL_FROM.
+func NewSender() (Sender, error) {
+	from, err := mail.ParseAddress(envOrDefault("EMAIL_FROM", defaultFrom))
+	if err != nil {
+		return nil, fmt.Errorf("invalid EMAIL_FROM: %v", err)
+	}
+
+	name := strings.ToLower(os.Getenv("EMAIL_SENDER"))
+	switch name {
+	case "", "sink":
+		return NewSinkSender(from), nil
+	case "smtp":
+		return NewSMTPSender(from)
+	default:
+		return nil, fmt.Errorf("unknown email sender: %s", name)
+	}
+}
+
+// buildMessage encodes a message as an RFC 5322 email with a quoted-printable HTML body
+func buildMessage(from *mail.Address, message *Message, at time.Time) ([]byte, error) {
+	domain := "localhost"
+	if i := strings.LastIndex(from.Address, "@"); i >= 0 {
+		domain = from.Address[i+1:]
+	}
+	to := mail.Address{Address: message.To}
+
+	var buf bytes.Buffer
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", to.String()},
+		{"Subject", mime.QEncoding.Encode("utf-8", message.Subject)},
+		{"Date", at.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + message.ID + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/html; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+
+	body := quotedprintable.NewWriter(&buf)
+	if _, err := body.Write([]byte(message.HTML)); err != nil {
+		return nil, fmt.Errorf("failed to encode message body: %v", err)
+	}
+	if err := body.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode message body: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,55 @@
+package notifications
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SinkSender stands in for a mail server in development and tests. Every
+// message is written to EMAIL_SINK_DIR (default order-service-mail in the
+// temporary directory) as <message ID>.eml, exactly as it would be sent over
+// SMTP, so it can be opened in any mail client.
+type SinkSender struct {
+	dir  string
+	from *mail.Address
+}
+
+// NewSinkSender creates a sink sender configured from the environment
+func NewSinkSender(from *mail.Address) *SinkSender {
+	return &SinkSender{
+		dir:  envOrDefault("EMAIL_SINK_DIR", filepath.Join(os.TempDir(), "order-service-mail")),
+		from: from,
+	}
+}
+
+// Name identifies the sink sender
+func (s *SinkSender) Name() string {
+	return "sink"
+}
+
+// Send writes a message to the sink directory. A retried message replaces the
+// file of its earlier attempt.
+func (s *SinkSender) Send(message *Message) error {
+	data, err := buildMessage(s.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail sink directory: %v", err)
+	}
+
+	// Write to a temporary file first so readers never see half a message
+	path := filepath.Join(s.dir, message.ID+".eml")
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package notifications
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+// smtpTimeout bounds a whole conversation with the mail server
+const smtpTimeout = 30 * time.Second
+
+// SMTPSender sends messages to the mail server at SMTP_HOST and SMTP_PORT
+// (default 587). The connection is upgraded with STARTTLS when the server
+// offers it, and authenticated with SMTP_USERNAME and SMTP_PASSWORD when a
+// username is set.
+type SMTPSender struct {
+	host string
+	addr string
+	auth smtp.Auth
+	from *mail.Address
+}
+
+// NewSMTPSender creates an SMTP sender configured from the environment
+func NewSMTPSender(from *mail.Address) (*SMTPSender, error) {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil, fmt.Errorf("SMTP_HOST is required to send email over SMTP")
+	}
+
+	sender := &SMTPSender{
+		host: host,
+		addr: net.JoinHostPort(host, envOrDefault("SMTP_PORT", "587")),
+		from: from,
+	}
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		sender.auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+	return sender, nil
+}
+
+// Name identifies the SMTP sender
+func (s *SMTPSender) Name() string {
+	return "smtp"
+}
+
+// Send delivers a message to the mail server
+func (s *SMTPSender) Send(message *Message) error {
+	data, err := buildMessage(s.from, message, time.Now())
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("tcp", s.addr, smtpTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to mail server: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, s.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet mail server: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: s.host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %v", err)
+		}
+	}
+	if s.auth != nil {
+		if err := client.Auth(s.auth); err != nil {
+			return fmt.Errorf("failed to authenticate with mail server: %v", err)
+		}
+	}
+
+	if err := client.Mail(s.from.Address); err != nil {
+		return fmt.Errorf("mail server rejected sender: %v", err)
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return fmt.Errorf("mail server rejected recipient: %v", err)
+	}
+	body, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("mail server rejected message: %v", err)
+	}
+	if _, err := body.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %v", err)
+	}
+	if err := body.Close(); err != nil {
+		return fmt.Errorf("mail server rejected message: %v", err)
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,170 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path"
+	"strings"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+)
+
+// DefaultLocale is used for locales without a catalog, and for keys a catalog lacks
+const DefaultLocale = "en"
+
+// Events lists every notification event that has a template
+var Events = []string{
+	models.NotificationOrderPlaced,
+	models.NotificationOrderPaid,
+	models.NotificationOrderShipped,
+	models.NotificationOrderDelivered,
+	models.NotificationOrderRefunded,
+}
+
+//go:embed templates/*.html locales/*.json
+var files embed.FS
+
+// Every event is rendered by layout.html around the "content" template of
+// <event>.html. Text is looked up in the catalog of the recipient's locale
+// with the t function: {{t "key" args...}} formats the catalog entry with
+// the args like fmt.Sprintf.
+var (
+	templates = parseTemplates()
+	catalogs  = loadCatalogs()
+)
+
+// Data is what the templates print
+type Data struct {
+	Order *models.Order
+	// Refund is only set for order_refunded
+	Refund *models.Refund
+}
+
+// view is the template data of one rendering
+type view struct {
+	*Data
+	Locale  string
+	Subject string
+}
+
+// SupportedLocale maps a locale such as "de-AT" or "de_AT" to the catalog
+// used for it, DefaultLocale when there is none
+func SupportedLocale(locale string) string {
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	if _, ok := catalogs[language]; ok {
+		return language
+	}
+	return DefaultLocale
+}
+
+// Render renders the subject and HTML body of an event's email in a locale
+func Render(event, locale string, data *Data) (string, string, error) {
+	tmpl, ok := templates[event]
+	if !ok {
+		return "", "", fmt.Errorf("no template for notification event %s", event)
+	}
+	locale = SupportedLocale(locale)
+
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to prepare %s template: %v", event, err)
+	}
+	tmpl.Funcs(localeFuncs(locale))
+
+	subject := translate(locale, event+".subject", orderReference(data.Order.ID))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, &view{Data: data, Locale: locale, Subject: subject}); err != nil {
+		return "", "", fmt.Errorf("failed to render %s email: %v", event, err)
+	}
+
+	return subject, buf.String(), nil
+}
+
+// localeFuncs are the template functions that depend on the locale
+func localeFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"t": func(key string, args ...interface{}) string {
+			return translate(locale, key, args...)
+		},
+		"date": func(t time.Time) string {
+			return t.Format(translate(locale, "date_format"))
+		},
+	}
+}
+
+// translate formats the catalog entry of a key, falling back to the default locale
+func translate(locale, key string, args ...interface{}) string {
+	text, ok := catalogs[locale][key]
+	if !ok {
+		text = catalogs[DefaultLocale][key]
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// orderReference shortens an order ID to what customers are shown, like 3F2A9C1B
+func orderReference(id string) string {
+	if len(id) > 8 {
+		id = id[:8]
+	}
+	return strings.ToUpper(id)
+}
+
+func formatMoney(amount money.Amount, currency string) string {
+	return amount.String() + " " + currency
+}
+
+func parseTemplates() map[string]*template.Template {
+	funcs := localeFuncs(DefaultLocale)
+	funcs["money"] = formatMoney
+	funcs["ref"] = orderReference
+
+	parsed := make(map[string]*template.Template, len(Events))
+	for _, event := range Events {
+		parsed[event] = template.Must(template.New("layout.html").Funcs(funcs).
+			ParseFS(files, "templates/layout.html", "templates/"+event+".html"))
+	}
+	return parsed
+}
+
+// loadCatalogs reads the catalog of every locale. Every catalog must have
+// every key of the default one, so a missing translation fails at startup
+// rather than in an email.
+func loadCatalogs() map[string]map[string]string {
+	paths, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]string, len(paths))
+	for _, entry := range paths {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("notifications: invalid catalog %s: %v", entry.Name(), err))
+		}
+		loaded[strings.TrimSuffix(entry.Name(), ".json")] = catalog
+	}
+
+	for locale, catalog := range loaded {
+		for key := range loaded[DefaultLocale] {
+			if _, ok := catalog[key]; !ok {
+				panic(fmt.Sprintf("notifications: catalog %s lacks %s", locale, key))
+			}
+		}
+	}
+	return loaded
+}
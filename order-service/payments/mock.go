@@ -0,0 +1,198 @@
+package payments
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/money"
+
+	"github.com/google/uuid"
+)
+
+// Payment methods understood by the mock provider. Any other payment method
+// is authorized successfully.
+const (
+	MockMethodDeclined          = "pm_card_declined"
+	MockMethodInsufficientFunds = "pm_card_insufficient_funds"
+)
+
+// MockProvider is a fully local payment gateway for development and tests.
+// It keeps no state: every operation succeeds except authorizations with one
+// of the declining test payment methods. Like a real gateway it reports each
+// outcome asynchronously with a signed webhook, sent to PAYMENT_WEBHOOK_URL
+// (default this service's own webhook endpoint) and signed with
+// PAYMENT_WEBHOOK_SECRET.
+type MockProvider struct {
+	webhookURL    string
+	webhookSecret string
+	httpClient    *http.Client
+	retries       int
+}
+
+// NewMockProvider creates a mock provider configured from the environment.
+// Without PAYMENT_WEBHOOK_SECRET the webhooks are signed with a random secret
+// known only to this process, which works as long as they are sent back to
+// it; a PAYMENT_WEBHOOK_URL elsewhere therefore requires the secret.
+func NewMockProvider() (*MockProvider, error) {
+	webhookURL := os.Getenv("PAYMENT_WEBHOOK_URL")
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if webhookURL != "" && secret == "" {
+		return nil, fmt.Errorf("PAYMENT_WEBHOOK_SECRET is required when PAYMENT_WEBHOOK_URL is set")
+	}
+	if webhookURL == "" {
+		port := os.Getenv("PORT")
+		if port == "" {
+			port = "8081"
+		}
+		webhookURL = "http://localhost:" + port + "/api/v1/payments/webhook"
+	}
+	if secret == "" {
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %v", err)
+		}
+		secret = hex.EncodeToString(random)
+		log.Printf("PAYMENT_WEBHOOK_SECRET is not set, signing mock payment webhooks with a random secret")
+	}
+
+	return &MockProvider{
+		webhookURL:    webhookURL,
+		webhookSecret: secret,
+		httpClient:    &http.Client{Timeout: 5 * time.Second},
+		retries:       3,
+	}, nil
+}
+
+// Name identifies the mock provider
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// CreateIntent prepares a mock payment intent
+func (p *MockProvider) CreateIntent(request IntentRequest) (*Intent, error) {
+	id := "pi_mock_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	return &Intent{
+		ID:           id,
+		ClientSecret: id + "_secret_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:16],
+	}, nil
+}
+
+// Authorize declines the test payment methods and authorizes everything else
+func (p *MockProvider) Authorize(intentID, paymentMethod string, amount money.Amount) (*Result, error) {
+	result := &Result{Succeeded: true, Amount: amount}
+	switch paymentMethod {
+	case MockMethodDeclined:
+		result = &Result{Succeeded: false, FailureReason: "card_declined"}
+	case MockMethodInsufficientFunds:
+		result = &Result{Succeeded: false, FailureReason: "insufficient_funds"}
+	}
+
+	eventType := EventPaymentAuthorized
+	if !result.Succeeded {
+		eventType = EventPaymentFailed
+	}
+	p.emit(eventType, intentID, result)
+
+	return result, nil
+}
+
+// Capture always succeeds
+func (p *MockProvider) Capture(intentID string, amount money.Amount) (*Result, error) {
+	result := &Result{Succeeded: true, Amount: amount}
+	p.emit(EventPaymentCaptured, intentID, result)
+	return result, nil
+}
+
+// Void always succeeds
+func (p *MockProvider) Void(intentID string) (*Result, error) {
+	result := &Result{Succeeded: true}
+	p.emit(EventPaymentVoided, intentID, result)
+	return result, nil
+}
+
+// Refund always succeeds
+func (p *MockProvider) Refund(intentID string, amount money.Amount) (*Result, error) {
+	result := &Result{Succeeded: true, Amount: amount}
+	p.emit(EventPaymentRefunded, intentID, result)
+	return result, nil
+}
+
+// ParseWebhook verifies the HMAC signature of a mock webhook and decodes it
+func (p *MockProvider) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	if err := VerifySignature(p.webhookSecret, header.Get(SignatureHeader), body, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %v", err)
+	}
+	if event.ID == "" || event.Type == "" || event.PaymentID == "" {
+		return nil, fmt.Errorf("invalid webhook payload: id, type and payment_id are required")
+	}
+
+	return &event, nil
+}
+
+// emit delivers a signed webhook for an outcome in the background, retrying
+// with a short backoff like a real gateway would
+func (p *MockProvider) emit(eventType, intentID string, result *Result) {
+	event := WebhookEvent{
+		ID:            "evt_mock_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Type:          eventType,
+		PaymentID:     intentID,
+		Amount:        result.Amount,
+		FailureReason: result.FailureReason,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	go func() {
+		body, err := json.Marshal(event)
+		if err != nil {
+			log.Printf("mock payment provider: failed to encode webhook %s: %v", event.ID, err)
+			return
+		}
+
+		backoff := 500 * time.Millisecond
+		for attempt := 0; ; attempt++ {
+			err = p.deliver(body)
+			if err == nil {
+				return
+			}
+			if attempt >= p.retries {
+				log.Printf("mock payment provider: giving up on webhook %s: %v", event.ID, err)
+				return
+			}
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}()
+}
+
+func (p *MockProvider) deliver(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, p.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(p.webhookSecret, body, time.Now()))
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package payments
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"order-service/money"
+)
+
+var (
+	// ErrProviderUnavailable is returned when the payment provider cannot be reached
+	ErrProviderUnavailable = errors.New("payment provider is unavailable")
+	// ErrInvalidSignature is returned when a webhook is not signed by the provider
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// Webhook event types sent by payment providers
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentFailed     = "payment.failed"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentVoided     = "payment.voided"
+	EventPaymentRefunded   = "payment.refunded"
+)
+
+// IntentRequest describes the payment to prepare for an order
+type IntentRequest struct {
+	OrderID  string
+	Amount   money.Amount
+	Currency string
+}
+
+// Intent is a payment prepared at the provider, waiting for authorization.
+// ClientSecret lets the storefront complete the payment with the provider.
+type Intent struct {
+	ID           string
+	ClientSecret string
+}
+
+// Result is the outcome of an operation on a payment intent. Declined
+// operations are not errors: Succeeded is false and FailureReason says why.
+type Result struct {
+	Succeeded     bool
+	Amount        money.Amount
+	FailureReason string
+}
+
+// WebhookEvent is a payment outcome reported by the provider
+type WebhookEvent struct {
+	ID            string       `json:"id"`
+	Type          string       `json:"type"`
+	PaymentID     string       `json:"payment_id"`
+	Amount        money.Amount `json:"amount"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// PaymentProvider is implemented by every payment gateway. Amounts are in
+// the currency of the intent. Errors are reserved for requests the provider
+// could not process; declines are reported through Result.
+type PaymentProvider interface {
+	// Name identifies the provider in stored payment attempts
+	Name() string
+	// CreateIntent prepares a payment for an order
+	CreateIntent(request IntentRequest) (*Intent, error)
+	// Authorize reserves the amount on the given payment method
+	Authorize(intentID, paymentMethod string, amount money.Amount) (*Result, error)
+	// Capture collects up to the authorized amount
+	Capture(intentID string, amount money.Amount) (*Result, error)
+	// Void releases an authorization that was not captured
+	Void(intentID string) (*Result, error)
+	// Refund returns part or all of a captured amount
+	Refund(intentID string, amount money.Amount) (*Result, error)
+	// ParseWebhook verifies the signature of a webhook request and decodes its event
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+// NewProvider creates the provider selected by PAYMENT_PROVIDER (default "mock")
+func NewProvider() (PaymentProvider, error) {
+	name := strings.ToLower(os.Getenv("PAYMENT_PROVIDER"))
+	switch name {
+	case "", "mock":
+		provider, err := NewMockProvider()
+		if err != nil {
+			return nil, err
+		}
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+}
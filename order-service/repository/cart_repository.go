@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+)
+
+var (
+	// ErrCartNotFound is returned when no active cart matches the given identity
+	ErrCartNotFound = errors.New("cart not found")
+	// ErrCartItemNotFound is returned when a game is not in the cart
+	ErrCartItemNotFound = errors.New("cart item not found")
+	// ErrCartChanged is returned when a cart was modified or checked out
+	// while a checkout was being prepared
+	ErrCartChanged = errors.New("cart was modified during checkout")
+)
+
+// MaxCartItemQuantity caps the quantity of a single game in a cart
+const MaxCartItemQuantity = 99
+
+type CartRepository struct {
+	db *sql.DB
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// NewCartRepository creates a new instance of CartRepository
+func NewCartRepository() *CartRepository {
+	return &CartRepository{
+		db: database.DB,
+	}
+}
+
+// GetOrCreateCustomerCart returns the active cart of a customer, creating an empty one if needed
+func (r *CartRepository) GetOrCreateCustomerCart(customerID string) (*models.Cart, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	cartID, err := getOrCreateCustomerCartTx(tx, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return r.GetCartByID(cartID)
+}
+
+// getOrCreateCustomerCartTx returns the ID of the customer's active cart and
+// locks it for the rest of the transaction
+func getOrCreateCustomerCartTx(tx *sql.Tx, customerID string) (string, error) {
+	// The partial unique index guarantees a single active cart per customer
+	_, err := tx.Exec(`INSERT INTO carts (customer_id, status) VALUES ($1, $2)
+			  ON CONFLICT (customer_id) WHERE status = 'active' AND customer_id IS NOT NULL DO NOTHING`,
+		customerID, models.CartStatusActive)
+	if err != nil {
+		return "", fmt.Errorf("failed to create cart: %v", err)
+	}
+
+	var cartID string
+	err = tx.QueryRow(`SELECT id FROM carts WHERE customer_id = $1 AND status = $2 FOR UPDATE`,
+		customerID, models.CartStatusActive).Scan(&cartID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get cart: %v", err)
+	}
+
+	return cartID, nil
+}
+
+// CreateGuestCart creates an empty cart identified by a guest token
+func (r *CartRepository) CreateGuestCart(guestToken string) (*models.Cart, error) {
+	var cartID string
+	err := r.db.QueryRow(`INSERT INTO carts (guest_token, status) VALUES ($1, $2) RETURNING id`,
+		guestToken, models.CartStatusActive).Scan(&cartID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cart: %v", err)
+	}
+
+	return r.GetCartByID(cartID)
+}
+
+// GetActiveGuestCart retrieves the active cart for a guest token
+func (r *CartRepository) GetActiveGuestCart(guestToken string) (*models.Cart, error) {
+	var cartID string
+	err := r.db.QueryRow(`SELECT id FROM carts WHERE guest_token = $1 AND customer_id IS NULL AND status = $2`,
+		guestToken, models.CartStatusActive).Scan(&cartID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCartNotFound
+		}
+		return nil, fmt.Errorf("failed to get cart: %v", err)
+	}
+
+	return r.GetCartByID(cartID)
+}
+
+// GetCartByID retrieves a cart with its items
+func (r *CartRepository) GetCartByID(id string) (*models.Cart, error) {
+	cart := &models.Cart{}
+
+	query := `SELECT id, customer_id, guest_token, status, created_at, updated_at
+			  FROM carts WHERE id = $1`
+
+	err := r.db.QueryRow(query, id).Scan(
+		&cart.ID, &cart.CustomerID, &cart.GuestToken, &cart.Status,
+		&cart.CreatedAt, &cart.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCartNotFound
+		}
+		return nil, fmt.Errorf("failed to get cart: %v", err)
+	}
+
+	items, err := getCartItems(r.db, id)
+	if err != nil {
+		return nil, err
+	}
+	cart.Items = items
+
+	return cart, nil
+}
+
+// AddItem adds a game to an active cart, increasing the quantity if it is already there
+func (r *CartRepository) AddItem(cartID string, gameID, quantity int) error {
+	return r.withActiveCart(cartID, func(tx *sql.Tx, now time.Time) error {
+		_, err := tx.Exec(`INSERT INTO cart_items (cart_id, game_id, quantity, added_at, updated_at)
+				  VALUES ($1, $2, LEAST($3, $4::integer), $5, $5)
+				  ON CONFLICT (cart_id, game_id) DO UPDATE
+				  SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $4::integer), updated_at = EXCLUDED.updated_at`,
+			cartID, gameID, quantity, MaxCartItemQuantity, now)
+		if err != nil {
+			return fmt.Errorf("failed to add cart item: %v", err)
+		}
+		return nil
+	})
+}
+
+// SetItemQuantity replaces the quantity of a game already in an active cart
+func (r *CartRepository) SetItemQuantity(cartID string, gameID, quantity int) error {
+	return r.withActiveCart(cartID, func(tx *sql.Tx, now time.Time) error {
+		result, err := tx.Exec(`UPDATE cart_items SET quantity = $1, updated_at = $2 WHERE cart_id = $3 AND game_id = $4`,
+			quantity, now, cartID, gameID)
+		if err != nil {
+			return fmt.Errorf("failed to update cart item: %v", err)
+		}
+		return requireAffected(result, ErrCartItemNotFound)
+	})
+}
+
+// RemoveItem removes a game from an active cart
+func (r *CartRepository) RemoveItem(cartID string, gameID int) error {
+	return r.withActiveCart(cartID, func(tx *sql.Tx, now time.Time) error {
+		result, err := tx.Exec(`DELETE FROM cart_items WHERE cart_id = $1 AND game_id = $2`, cartID, gameID)
+		if err != nil {
+			return fmt.Errorf("failed to remove cart item: %v", err)
+		}
+		return requireAffected(result, ErrCartItemNotFound)
+	})
+}
+
+// MergeGuestCart moves the items of a guest cart into the customer's active
+// cart, summing quantities of games present in both, and marks the guest cart
+// as merged. It returns the ID of the customer cart.
+func (r *CartRepository) MergeGuestCart(guestToken, customerID string) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var guestCartID string
+	err = tx.QueryRow(`SELECT id FROM carts WHERE guest_token = $1 AND customer_id IS NULL AND status = $2 FOR UPDATE`,
+		guestToken, models.CartStatusActive).Scan(&guestCartID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrCartNotFound
+		}
+		return "", fmt.Errorf("failed to get guest cart: %v", err)
+	}
+
+	customerCartID, err := getOrCreateCustomerCartTx(tx, customerID)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`INSERT INTO cart_items (cart_id, game_id, quantity, added_at, updated_at)
+			  SELECT $1, game_id, quantity, added_at, $3 FROM cart_items WHERE cart_id = $2
+			  ON CONFLICT (cart_id, game_id) DO UPDATE
+			  SET quantity = LEAST(cart_items.quantity + EXCLUDED.quantity, $4::integer), updated_at = EXCLUDED.updated_at`,
+		customerCartID, guestCartID, now, MaxCartItemQuantity)
+	if err != nil {
+		return "", fmt.Errorf("failed to merge cart items: %v", err)
+	}
+
+	if err := setCartStatusTx(tx, guestCartID, models.CartStatusMerged, now); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`UPDATE carts SET updated_at = $1 WHERE id = $2`, now, customerCartID); err != nil {
+		return "", fmt.Errorf("failed to update cart: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return customerCartID, nil
+}
+
+// CheckoutCart creates the order and marks the cart as checked out in a
+// single transaction. The order must have been built from expected; if the
+// cart no longer holds exactly those items, or is not active anymore,
+// ErrCartChanged is returned and nothing is written.
+func (r *CartRepository) CheckoutCart(cartID string, expected []models.CartItem, order *models.Order) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockActiveCartTx(tx, cartID); err != nil {
+		if errors.Is(err, ErrCartNotFound) {
+			return ErrCartChanged
+		}
+		return err
+	}
+
+	items, err := getCartItems(tx, cartID)
+	if err != nil {
+		return err
+	}
+	if !sameCartItems(items, expected) {
+		return ErrCartChanged
+	}
+
+	if err := createOrderTx(tx, order); err != nil {
+		return err
+	}
+
+	if err := setCartStatusTx(tx, cartID, models.CartStatusCheckedOut, order.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withActiveCart runs fn in a transaction holding the lock on an active cart,
+// so item changes cannot interleave with a merge or checkout
+func (r *CartRepository) withActiveCart(cartID string, fn func(tx *sql.Tx, now time.Time) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockActiveCartTx(tx, cartID); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := fn(tx, now); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE carts SET updated_at = $1 WHERE id = $2`, now, cartID); err != nil {
+		return fmt.Errorf("failed to update cart: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// lockActiveCartTx locks a cart row and fails with ErrCartNotFound unless it is active
+func lockActiveCartTx(tx *sql.Tx, cartID string) error {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM carts WHERE id = $1 FOR UPDATE`, cartID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrCartNotFound
+		}
+		return fmt.Errorf("failed to lock cart: %v", err)
+	}
+	if status != models.CartStatusActive {
+		return ErrCartNotFound
+	}
+	return nil
+}
+
+// setCartStatusTx changes the status of a cart within an existing transaction
+func setCartStatusTx(tx *sql.Tx, cartID, status string, at time.Time) error {
+	_, err := tx.Exec(`UPDATE carts SET status = $1, updated_at = $2 WHERE id = $3`, status, at, cartID)
+	if err != nil {
+		return fmt.Errorf("failed to update cart status: %v", err)
+	}
+	return nil
+}
+
+// getCartItems retrieves the items of a cart in the order they were added
+func getCartItems(q queryer, cartID string) ([]models.CartItem, error) {
+	rows, err := q.Query(`SELECT game_id, quantity, added_at, updated_at
+			  FROM cart_items WHERE cart_id = $1 ORDER BY added_at, game_id`, cartID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cart items: %v", err)
+	}
+	defer rows.Close()
+
+	items := []models.CartItem{}
+	for rows.Next() {
+		var item models.CartItem
+		if err := rows.Scan(&item.GameID, &item.Quantity, &item.AddedAt, &item.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan cart item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// sameCartItems reports whether two item lists hold the same games and quantities
+func sameCartItems(a, b []models.CartItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	quantities := make(map[int]int, len(a))
+	for _, item := range a {
+		quantities[item.GameID] = item.Quantity
+	}
+	for _, item := range b {
+		if q, ok := quantities[item.GameID]; !ok || q != item.Quantity {
+			return false
+		}
+	}
+	return true
+}
+
+// requireAffected returns notFound when a statement changed no rows
+func requireAffected(result sql.Result, notFound error) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %v", err)
+	}
+	if rowsAffected == 0 {
+		return notFound
+	}
+	return nil
+}
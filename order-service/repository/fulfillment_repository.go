@@ -0,0 +1,406 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrFulfillmentNotFound is returned when an order was never queued for fulfillment
+	ErrFulfillmentNotFound = errors.New("fulfillment not found")
+	// ErrNotEnoughLicenseKeys is returned when the key store cannot cover an order line
+	ErrNotEnoughLicenseKeys = errors.New("not enough license keys")
+	// ErrOrderNotPaid is returned when the captured payments of an order do not cover its total
+	ErrOrderNotPaid = errors.New("order not paid")
+)
+
+const fulfillmentColumns = `order_id, status, attempts, last_error, next_attempt_at, created_at, updated_at, fulfilled_at`
+
+type FulfillmentRepository struct {
+	db *sql.DB
+}
+
+// NewFulfillmentRepository creates a new instance of FulfillmentRepository
+func NewFulfillmentRepository() *FulfillmentRepository {
+	return &FulfillmentRepository{
+		db: database.DB,
+	}
+}
+
+// ImportLicenseKeys adds license keys of a game to the key store and returns
+// how many were new; keys it already holds are ignored. Pending fulfillments
+// are made due so they are retried against the new stock.
+func (r *FulfillmentRepository) ImportLicenseKeys(gameID int, keys []string, at time.Time) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO license_keys (game_id, license_key, created_at)
+			  SELECT $1, key, $3 FROM unnest($2::text[]) AS key
+			  ON CONFLICT (license_key) DO NOTHING`, gameID, pq.Array(keys), at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to import license keys: %v", err)
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	_, err = tx.Exec(`UPDATE fulfillments SET next_attempt_at = $2, updated_at = $2
+			  WHERE status = $1 AND next_attempt_at > $2`, models.FulfillmentStatusPending, at)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reschedule fulfillments: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit license keys: %v", err)
+	}
+
+	return int(added), nil
+}
+
+// GetLicenseKeyStock counts the unclaimed license keys of every game in the key store
+func (r *FulfillmentRepository) GetLicenseKeyStock() ([]models.LicenseKeyStock, error) {
+	rows, err := r.db.Query(`SELECT game_id, COUNT(*) FILTER (WHERE claimed_at IS NULL)
+			  FROM license_keys GROUP BY game_id ORDER BY game_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query license key stock: %v", err)
+	}
+	defer rows.Close()
+
+	stock := []models.LicenseKeyStock{}
+	for rows.Next() {
+		var entry models.LicenseKeyStock
+		if err := rows.Scan(&entry.GameID, &entry.Available); err != nil {
+			return nil, fmt.Errorf("failed to scan license key stock: %v", err)
+		}
+		stock = append(stock, entry)
+	}
+
+	return stock, nil
+}
+
+// GetFulfillment retrieves the fulfillment of an order
+func (r *FulfillmentRepository) GetFulfillment(orderID string) (*models.Fulfillment, error) {
+	row := r.db.QueryRow(`SELECT `+fulfillmentColumns+` FROM fulfillments WHERE order_id = $1`, orderID)
+	return scanFulfillment(row)
+}
+
+// LeaseDueFulfillments picks up to limit pending fulfillments that are due and
+// pushes their next attempt back to leaseUntil, so other replicas skip them
+// while this one works on them. If this replica stops, they become due again
+// once the lease runs out.
+func (r *FulfillmentRepository) LeaseDueFulfillments(at, leaseUntil time.Time, limit int) ([]models.Fulfillment, error) {
+	rows, err := r.db.Query(`UPDATE fulfillments SET next_attempt_at = $3, updated_at = $2
+			  WHERE order_id IN (
+				  SELECT order_id FROM fulfillments
+				  WHERE status = $1 AND next_attempt_at <= $2
+				  ORDER BY next_attempt_at LIMIT $4
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING `+fulfillmentColumns, models.FulfillmentStatusPending, at, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease fulfillments: %v", err)
+	}
+	defer rows.Close()
+
+	var fulfillments []models.Fulfillment
+	for rows.Next() {
+		fulfillment, err := scanFulfillment(rows)
+		if err != nil {
+			return nil, err
+		}
+		fulfillments = append(fulfillments, *fulfillment)
+	}
+
+	return fulfillments, nil
+}
+
+// QueueFulfillmentIfPaid queues a confirmed order for fulfillment once its
+// captured payments cover its total, for captures that come after the order
+// was confirmed, including orders moved on by hand since, and queues the
+// order paid email. Orders that are not paid, or were cancelled, are left
+// alone.
+func (r *FulfillmentRepository) QueueFulfillmentIfPaid(orderID string, at time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("failed to get order status: %v", err)
+	}
+	switch status {
+	case models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusDelivered:
+	default:
+		return nil
+	}
+
+	paid, err := orderPaidTx(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if !paid {
+		return nil
+	}
+
+	if err := enqueueFulfillmentTx(tx, orderID, at); err != nil {
+		return err
+	}
+	if err := enqueueNotificationTx(tx, orderID, models.NotificationOrderPaid, "", at); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fulfillment: %v", err)
+	}
+
+	return nil
+}
+
+// FulfillOrder claims a license key for every unit of an order's items and
+// moves the order through the statuses returned by steps, in one transaction.
+// steps is given the order's locked current status; an error from it,
+// ErrOrderNotPaid when the order's captured payments no longer cover its
+// total, or ErrNotEnoughLicenseKeys for any line, leaves everything
+// unchanged. Fulfillments that are no longer pending are left alone.
+func (r *FulfillmentRepository) FulfillOrder(orderID, actor string, steps func(from string) ([]string, error)) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT status FROM fulfillments WHERE order_id = $1 FOR UPDATE`, orderID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrFulfillmentNotFound
+		}
+		return fmt.Errorf("failed to lock fulfillment: %v", err)
+	}
+	if status != models.FulfillmentStatusPending {
+		return nil
+	}
+
+	var current string
+	err = tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&current)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("order not found")
+		}
+		return fmt.Errorf("failed to get order status: %v", err)
+	}
+
+	path, err := steps(current)
+	if err != nil {
+		return err
+	}
+
+	paid, err := orderPaidTx(tx, orderID)
+	if err != nil {
+		return err
+	}
+	if !paid {
+		return ErrOrderNotPaid
+	}
+
+	items, err := queryOrderItems(tx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get order items: %v", err)
+	}
+
+	// Gifted units refunded before the order was fulfilled get no key
+	revoked := make(map[string]int)
+	rows, err := tx.Query(`SELECT order_item_id, revoked_quantity FROM gifts WHERE order_id = $1 AND revoked_quantity > 0`, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to get revoked gifts: %v", err)
+	}
+	for rows.Next() {
+		var orderItemID string
+		var quantity int
+		if err := rows.Scan(&orderItemID, &quantity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan revoked gift: %v", err)
+		}
+		revoked[orderItemID] = quantity
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read revoked gifts: %v", err)
+	}
+
+	now := time.Now()
+	for _, item := range items {
+		quantity := item.Quantity - revoked[item.ID]
+		if quantity <= 0 {
+			continue
+		}
+
+		// Oldest keys first; keys locked by a concurrent claim are skipped rather than waited for
+		result, err := tx.Exec(`UPDATE license_keys SET order_item_id = $1, claimed_at = $2
+				  WHERE id IN (
+					  SELECT id FROM license_keys
+					  WHERE game_id = $3 AND claimed_at IS NULL
+					  ORDER BY created_at, id LIMIT $4
+					  FOR UPDATE SKIP LOCKED
+				  )`, item.ID, now, item.GameID, quantity)
+		if err != nil {
+			return fmt.Errorf("failed to claim license keys: %v", err)
+		}
+		claimed, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %v", err)
+		}
+		if int(claimed) < quantity {
+			return fmt.Errorf("%w: game %d needs %d, %d available", ErrNotEnoughLicenseKeys, item.GameID, quantity, claimed)
+		}
+	}
+
+	for _, step := range path {
+		_, err := updateOrderStatusTx(tx, orderID, step, actor, "license keys issued",
+			func(string) error { return nil })
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE fulfillments SET status = $2, attempts = attempts + 1, last_error = NULL,
+			  fulfilled_at = $3, updated_at = $3 WHERE order_id = $1`,
+		orderID, models.FulfillmentStatusFulfilled, now)
+	if err != nil {
+		return fmt.Errorf("failed to update fulfillment: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit fulfillment: %v", err)
+	}
+
+	return nil
+}
+
+// RecordFulfillmentFailure counts a failed attempt and schedules the next one
+func (r *FulfillmentRepository) RecordFulfillmentFailure(orderID, reason string, nextAttemptAt, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE fulfillments SET attempts = attempts + 1, last_error = $3,
+			  next_attempt_at = $4, updated_at = $5 WHERE order_id = $1 AND status = $2`,
+		orderID, models.FulfillmentStatusPending, reason, nextAttemptAt, at)
+	if err != nil {
+		return fmt.Errorf("failed to record fulfillment failure: %v", err)
+	}
+	return nil
+}
+
+// SkipFulfillment stops retrying the fulfillment of an order that no longer needs it
+func (r *FulfillmentRepository) SkipFulfillment(orderID, reason string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE fulfillments SET status = $3, last_error = $4, updated_at = $5
+			  WHERE order_id = $1 AND status = $2`,
+		orderID, models.FulfillmentStatusPending, models.FulfillmentStatusSkipped, reason, at)
+	if err != nil {
+		return fmt.Errorf("failed to skip fulfillment: %v", err)
+	}
+	return nil
+}
+
+// RevealLicenseKeys retrieves the license keys issued for an order, per line,
+// and records when each key was first revealed. Gifted lines are left out:
+// their keys go to the recipient who redeems the gift.
+func (r *FulfillmentRepository) RevealLicenseKeys(orderID string, at time.Time) ([]models.OrderItemLicenseKeys, error) {
+	_, err := r.db.Exec(`UPDATE license_keys SET revealed_at = $2
+			  WHERE revealed_at IS NULL AND order_item_id IN (
+				  SELECT id FROM order_items oi
+				  WHERE order_id = $1 AND NOT EXISTS (SELECT 1 FROM gifts g WHERE g.order_item_id = oi.id)
+			  )`,
+		orderID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record license key reveal: %v", err)
+	}
+
+	rows, err := r.db.Query(`SELECT oi.id, oi.game_id, oi.game_name, lk.license_key
+			  FROM order_items oi JOIN license_keys lk ON lk.order_item_id = oi.id
+			  WHERE oi.order_id = $1 AND NOT EXISTS (SELECT 1 FROM gifts g WHERE g.order_item_id = oi.id)
+			  ORDER BY oi.id, lk.claimed_at, lk.license_key`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query license keys: %v", err)
+	}
+	defer rows.Close()
+
+	items := []models.OrderItemLicenseKeys{}
+	for rows.Next() {
+		var item models.OrderItemLicenseKeys
+		var key string
+		if err := rows.Scan(&item.OrderItemID, &item.GameID, &item.GameName, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan license key: %v", err)
+		}
+		if len(items) == 0 || items[len(items)-1].OrderItemID != item.OrderItemID {
+			items = append(items, item)
+		}
+		last := &items[len(items)-1]
+		last.LicenseKeys = append(last.LicenseKeys, key)
+	}
+
+	return items, nil
+}
+
+// enqueueFulfillmentTx queues an order for fulfillment within the transaction
+// that confirms it or records its payment. A fulfillment that was skipped,
+// because the order was not paid yet, is queued again.
+func enqueueFulfillmentTx(tx *sql.Tx, orderID string, at time.Time) error {
+	_, err := tx.Exec(`INSERT INTO fulfillments (order_id, status, next_attempt_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $3, $3)
+			  ON CONFLICT (order_id) DO UPDATE SET status = $2, last_error = NULL, next_attempt_at = $3, updated_at = $3
+			  WHERE fulfillments.status = $4`,
+		orderID, models.FulfillmentStatusPending, at, models.FulfillmentStatusSkipped)
+	if err != nil {
+		return fmt.Errorf("failed to queue fulfillment: %v", err)
+	}
+	return nil
+}
+
+// orderPaidTx reports whether the captured payments of an order cover its
+// total. Free orders are paid without any payment.
+func orderPaidTx(tx *sql.Tx, orderID string) (bool, error) {
+	var paid bool
+	err := tx.QueryRow(`SELECT o.total_price <= COALESCE((
+				  SELECT SUM(p.captured_amount) FROM payment_attempts p
+				  WHERE p.order_id = o.id AND p.status IN ($2, $3)
+			  ), 0)
+			  FROM orders o WHERE o.id = $1`,
+		orderID, models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded).Scan(&paid)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, fmt.Errorf("order not found")
+		}
+		return false, fmt.Errorf("failed to check order payments: %v", err)
+	}
+	return paid, nil
+}
+
+func scanFulfillment(row rowScanner) (*models.Fulfillment, error) {
+	fulfillment := &models.Fulfillment{}
+	err := row.Scan(
+		&fulfillment.OrderID, &fulfillment.Status, &fulfillment.Attempts, &fulfillment.LastError,
+		&fulfillment.NextAttemptAt, &fulfillment.CreatedAt, &fulfillment.UpdatedAt, &fulfillment.FulfilledAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrFulfillmentNotFound
+		}
+		return nil, fmt.Errorf("failed to scan fulfillment: %v", err)
+	}
+	return fulfillment, nil
+}
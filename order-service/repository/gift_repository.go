@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ErrGiftNotFound is returned when no gift has the given code
+var ErrGiftNotFound = errors.New("gift not found")
+
+// giftCodeAlphabet leaves out 0, 1, I and O, which are easily mistaken for each other
+const giftCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+const giftColumns = `g.id, g.order_id, g.order_item_id, oi.game_id, oi.game_name, oi.quantity, g.code, g.recipient_email,
+			  g.recipient_customer_id, g.message, g.status, g.redeemed_by, g.redeemed_at, g.revoked_quantity, g.revoked_at,
+			  g.created_at, g.updated_at`
+
+type GiftRepository struct {
+	db *sql.DB
+}
+
+// NewGiftRepository creates a new instance of GiftRepository
+func NewGiftRepository() *GiftRepository {
+	return &GiftRepository{
+		db: database.DB,
+	}
+}
+
+// GetOrderGifts retrieves the gifts of an order with their codes, in line order
+func (r *GiftRepository) GetOrderGifts(orderID string) ([]models.Gift, error) {
+	rows, err := r.db.Query(`SELECT `+giftColumns+`
+			  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+			  WHERE g.order_id = $1 ORDER BY g.order_item_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gifts: %v", err)
+	}
+	defer rows.Close()
+
+	return scanGifts(rows)
+}
+
+// GetRedeemedGifts retrieves the gifts a customer redeemed, newest first.
+// Gifts that are still redeemed come with their license keys, and the
+// reveal of those keys is recorded like RevealLicenseKeys does.
+func (r *GiftRepository) GetRedeemedGifts(customerID string, at time.Time) ([]models.Gift, error) {
+	_, err := r.db.Exec(`UPDATE license_keys SET revealed_at = $3
+			  WHERE revealed_at IS NULL AND revoked_at IS NULL AND order_item_id IN (
+				  SELECT order_item_id FROM gifts WHERE redeemed_by = $1 AND status = $2
+			  )`, customerID, models.GiftStatusRedeemed, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record license key reveal: %v", err)
+	}
+
+	rows, err := r.db.Query(`SELECT `+giftColumns+`
+			  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+			  WHERE g.redeemed_by = $1 ORDER BY g.redeemed_at DESC, g.id`, customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gifts: %v", err)
+	}
+	defer rows.Close()
+
+	gifts, err := scanGifts(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range gifts {
+		gifts[i].Code = ""
+		if gifts[i].Status == models.GiftStatusRedeemed {
+			if gifts[i].LicenseKeys, err = queryGiftLicenseKeys(r.db, gifts[i].OrderItemID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return gifts, nil
+}
+
+// RedeemGift hands the gift with a code over to a customer. The order and
+// the gift are locked while check decides, from the gift and the order's
+// status, whether the customer may redeem it, so a code cannot be redeemed
+// twice. The redeemed gift comes with the license keys issued so far, which
+// are recorded as revealed.
+func (r *GiftRepository) RedeemGift(code, customerID string, at time.Time, check func(gift *models.Gift, orderStatus string) error) (*models.Gift, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The order is locked before the gift, in the order refunds and
+	// cancellations lock them when they revoke its gifts
+	var orderID, orderStatus string
+	err = tx.QueryRow(`SELECT o.id, o.status FROM gifts g JOIN orders o ON o.id = g.order_id
+			  WHERE g.code = $1 FOR SHARE OF o`, code).Scan(&orderID, &orderStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrGiftNotFound
+		}
+		return nil, fmt.Errorf("failed to lock order: %v", err)
+	}
+
+	rows, err := tx.Query(`SELECT `+giftColumns+`
+			  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+			  WHERE g.code = $1 AND g.order_id = $2 FOR UPDATE OF g`, code, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock gift: %v", err)
+	}
+	gifts, err := scanGifts(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(gifts) == 0 {
+		return nil, ErrGiftNotFound
+	}
+	gift := &gifts[0]
+
+	if err := check(gift, orderStatus); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`UPDATE gifts SET status = $2, redeemed_by = $3, redeemed_at = $4, updated_at = $4 WHERE id = $1`,
+		gift.ID, models.GiftStatusRedeemed, customerID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem gift: %v", err)
+	}
+	gift.Status = models.GiftStatusRedeemed
+	gift.RedeemedBy = &customerID
+	gift.RedeemedAt = &at
+	gift.UpdatedAt = at
+
+	_, err = tx.Exec(`UPDATE license_keys SET revealed_at = $2
+			  WHERE order_item_id = $1 AND revealed_at IS NULL AND revoked_at IS NULL`, gift.OrderItemID, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record license key reveal: %v", err)
+	}
+	if gift.LicenseKeys, err = queryGiftLicenseKeys(tx, gift.OrderItemID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit gift redemption: %v", err)
+	}
+
+	gift.Code = ""
+	return gift, nil
+}
+
+// insertGiftTx issues a gift with a new code for an order line within the
+// transaction that creates the order. The code is left off item.Gift, since
+// order responses are not limited to the customer who placed the order.
+func insertGiftTx(tx *sql.Tx, item *models.OrderItem, at time.Time) error {
+	gift := item.Gift
+	gift.ID = uuid.New().String()
+	gift.OrderID = item.OrderID
+	gift.OrderItemID = item.ID
+	gift.Status = models.GiftStatusIssued
+	gift.CreatedAt = at
+	gift.UpdatedAt = at
+
+	code, err := newGiftCode()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`INSERT INTO gifts (id, order_id, order_item_id, code, recipient_email, recipient_customer_id, message,
+			  status, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)`,
+		gift.ID, gift.OrderID, gift.OrderItemID, code, gift.RecipientEmail, gift.RecipientCustomerID, gift.Message,
+		gift.Status, at)
+	if err != nil {
+		return fmt.Errorf("failed to insert gift: %v", err)
+	}
+	return nil
+}
+
+// revokeGiftsTx revokes gifted units of an order within the transaction that
+// completes a refund or cancels the order, which holds the order's lock. A
+// refund of lines revokes as many units of each gifted line as it refunds; a
+// refund by amount revokes every gift once the order is fully refunded. An
+// empty refundID revokes every gift of the order, as cancelling it does. A
+// gift is revoked once all of its units are.
+func revokeGiftsTx(tx *sql.Tx, orderID, refundID string, at time.Time) error {
+	query := `SELECT g.id, g.order_item_id, oi.quantity, g.revoked_quantity, oi.quantity - g.revoked_quantity
+			  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+			  WHERE g.order_id = $1 AND g.status <> $2`
+	args := []interface{}{orderID, models.GiftStatusRevoked}
+	if refundID != "" {
+		var byLine, fullyRefunded bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM refund_items WHERE refund_id = $2), refunded_amount >= total_price
+				  FROM orders WHERE id = $1`, orderID, refundID).Scan(&byLine, &fullyRefunded)
+		if err != nil {
+			return fmt.Errorf("failed to get refunded lines: %v", err)
+		}
+		if byLine {
+			query = `SELECT g.id, g.order_item_id, oi.quantity, g.revoked_quantity,
+					  LEAST(oi.quantity - g.revoked_quantity, (
+						  SELECT SUM(ri.quantity) FROM refund_items ri WHERE ri.refund_id = $3 AND ri.order_item_id = g.order_item_id
+					  ))
+					  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+					  WHERE g.order_id = $1 AND g.status <> $2
+					  AND g.order_item_id IN (SELECT order_item_id FROM refund_items WHERE refund_id = $3)`
+			args = append(args, refundID)
+		} else if !fullyRefunded {
+			return nil
+		}
+	}
+
+	type revocation struct {
+		giftID, orderItemID         string
+		quantity, revoked, revoking int
+	}
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query gifts to revoke: %v", err)
+	}
+	var revocations []revocation
+	for rows.Next() {
+		var r revocation
+		if err := rows.Scan(&r.giftID, &r.orderItemID, &r.quantity, &r.revoked, &r.revoking); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan gift to revoke: %v", err)
+		}
+		revocations = append(revocations, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read gifts to revoke: %v", err)
+	}
+
+	for _, r := range revocations {
+		if r.revoking <= 0 {
+			continue
+		}
+
+		revoked := r.revoked + r.revoking
+		_, err := tx.Exec(`UPDATE gifts SET revoked_quantity = $2,
+				  status = CASE WHEN $2 >= $3 THEN $4 ELSE status END, revoked_at = $5, updated_at = $5
+				  WHERE id = $1`, r.giftID, revoked, r.quantity, models.GiftStatusRevoked, at)
+		if err != nil {
+			return fmt.Errorf("failed to revoke gift: %v", err)
+		}
+
+		if err := revokeLicenseKeysTx(tx, r.orderItemID, r.revoking, at); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revokeLicenseKeysTx takes back the license keys of count revoked units of
+// an order line. Keys nobody has seen yet go back to the key store; keys
+// already revealed are marked revoked, so they are neither shown nor issued
+// again and can be disabled with the publisher.
+func revokeLicenseKeysTx(tx *sql.Tx, orderItemID string, count int, at time.Time) error {
+	result, err := tx.Exec(`UPDATE license_keys SET order_item_id = NULL, claimed_at = NULL
+			  WHERE id IN (
+				  SELECT id FROM license_keys
+				  WHERE order_item_id = $1 AND revealed_at IS NULL AND revoked_at IS NULL
+				  ORDER BY claimed_at DESC, id LIMIT $2
+			  )`, orderItemID, count)
+	if err != nil {
+		return fmt.Errorf("failed to release license keys: %v", err)
+	}
+	released, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if int(released) >= count {
+		return nil
+	}
+
+	_, err = tx.Exec(`UPDATE license_keys SET revoked_at = $3
+			  WHERE id IN (
+				  SELECT id FROM license_keys
+				  WHERE order_item_id = $1 AND revoked_at IS NULL
+				  ORDER BY revealed_at DESC, id LIMIT $2
+			  )`, orderItemID, count-int(released), at)
+	if err != nil {
+		return fmt.Errorf("failed to revoke license keys: %v", err)
+	}
+	return nil
+}
+
+// loadOrderGifts attaches the gifts of an order to its items, without their codes
+func loadOrderGifts(q queryer, order *models.Order) error {
+	return loadOrdersGifts(q, []*models.Order{order})
+}
+
+// loadOrdersGifts attaches the gifts of several orders to their items in one
+// query, without their codes
+func loadOrdersGifts(q queryer, orders []*models.Order) error {
+	ids := make([]string, len(orders))
+	type itemRef struct{ order, item int }
+	itemIndex := make(map[string]itemRef)
+	for i, order := range orders {
+		ids[i] = order.ID
+		for j, item := range order.Items {
+			itemIndex[item.ID] = itemRef{i, j}
+		}
+	}
+
+	rows, err := q.Query(`SELECT `+giftColumns+`
+			  FROM gifts g JOIN order_items oi ON oi.id = g.order_item_id
+			  WHERE g.order_id = ANY($1::uuid[])`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query gifts: %v", err)
+	}
+	defer rows.Close()
+
+	gifts, err := scanGifts(rows)
+	if err != nil {
+		return err
+	}
+
+	for i := range gifts {
+		gifts[i].Code = ""
+		if ref, ok := itemIndex[gifts[i].OrderItemID]; ok {
+			orders[ref.order].Items[ref.item].Gift = &gifts[i]
+		}
+	}
+
+	return nil
+}
+
+// queryGiftLicenseKeys retrieves the license keys issued for a gifted order line that were not revoked
+func queryGiftLicenseKeys(q queryer, orderItemID string) ([]string, error) {
+	rows, err := q.Query(`SELECT license_key FROM license_keys WHERE order_item_id = $1 AND revoked_at IS NULL
+			  ORDER BY claimed_at, license_key`, orderItemID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query license keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan license key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// newGiftCode generates a random code of four groups of four characters,
+// such as "K7QD-M2XP-9HTA-RW4C". 80 random bits make guessing hopeless, and
+// the unique index on gifts.code turns the unlikely collision into an error.
+func newGiftCode() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate gift code: %v", err)
+	}
+
+	var code strings.Builder
+	for i, b := range random {
+		if i > 0 && i%4 == 0 {
+			code.WriteByte('-')
+		}
+		code.WriteByte(giftCodeAlphabet[int(b)%len(giftCodeAlphabet)])
+	}
+	return code.String(), nil
+}
+
+func scanGifts(rows *sql.Rows) ([]models.Gift, error) {
+	gifts := []models.Gift{}
+	for rows.Next() {
+		var gift models.Gift
+		err := rows.Scan(
+			&gift.ID, &gift.OrderID, &gift.OrderItemID, &gift.GameID, &gift.GameName, &gift.Quantity, &gift.Code,
+			&gift.RecipientEmail, &gift.RecipientCustomerID, &gift.Message, &gift.Status, &gift.RedeemedBy,
+			&gift.RedeemedAt, &gift.RevokedQuantity, &gift.RevokedAt, &gift.CreatedAt, &gift.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan gift: %v", err)
+		}
+		gifts = append(gifts, gift)
+	}
+
+	return gifts, rows.Err()
+}
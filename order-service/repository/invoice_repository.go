@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvoiceNotFound is returned when an order has no invoice, because it does
+// not exist or has not been confirmed yet
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+type InvoiceRepository struct {
+	db *sql.DB
+}
+
+// NewInvoiceRepository creates a new instance of InvoiceRepository
+func NewInvoiceRepository() *InvoiceRepository {
+	return &InvoiceRepository{
+		db: database.DB,
+	}
+}
+
+// GetInvoiceByOrderID retrieves the invoice of an order with its documents, if rendered
+func (r *InvoiceRepository) GetInvoiceByOrderID(orderID string) (*models.Invoice, error) {
+	invoice := &models.Invoice{}
+	err := r.db.QueryRow(`SELECT id, order_id, number, year, sequence, issued_at, rendered_at, html, pdf
+			  FROM invoices WHERE order_id = $1`, orderID).Scan(
+		&invoice.ID, &invoice.OrderID, &invoice.Number, &invoice.Year, &invoice.Sequence,
+		&invoice.IssuedAt, &invoice.RenderedAt, &invoice.HTML, &invoice.PDF,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, fmt.Errorf("failed to get invoice: %v", err)
+	}
+
+	return invoice, nil
+}
+
+// StoreInvoiceDocuments stores the rendered documents of an invoice. Documents
+// are only ever stored once; if they were stored concurrently, the invoice is
+// left as the first writer stored it.
+func (r *InvoiceRepository) StoreInvoiceDocuments(id string, html, pdf []byte, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE invoices SET html = $1, pdf = $2, rendered_at = $3
+			  WHERE id = $4 AND rendered_at IS NULL`, string(html), pdf, at, id)
+	if err != nil {
+		return fmt.Errorf("failed to store invoice documents: %v", err)
+	}
+	return nil
+}
+
+// issueInvoiceTx assigns the next invoice number of the year to an order
+// within the transaction that confirms it. The year's counter row stays locked
+// until the transaction ends and a rolled back transaction gives its number
+// back, so numbers have no gaps. Orders that already have an invoice keep it.
+func issueInvoiceTx(tx *sql.Tx, orderID string, at time.Time) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM invoices WHERE order_id = $1)`, orderID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check invoice: %v", err)
+	}
+	if exists {
+		return nil
+	}
+
+	invoice := &models.Invoice{
+		ID:       uuid.New().String(),
+		OrderID:  orderID,
+		Year:     at.Year(),
+		IssuedAt: at,
+	}
+
+	err = tx.QueryRow(`INSERT INTO invoice_sequences (year, last_number) VALUES ($1, 1)
+			  ON CONFLICT (year) DO UPDATE SET last_number = invoice_sequences.last_number + 1
+			  RETURNING last_number`, invoice.Year).Scan(&invoice.Sequence)
+	if err != nil {
+		return fmt.Errorf("failed to assign invoice number: %v", err)
+	}
+	invoice.Number = fmt.Sprintf("INV-%d-%06d", invoice.Year, invoice.Sequence)
+
+	_, err = tx.Exec(`INSERT INTO invoices (id, order_id, number, year, sequence, issued_at)
+			  VALUES ($1, $2, $3, $4, $5, $6)`,
+		invoice.ID, invoice.OrderID, invoice.Number, invoice.Year, invoice.Sequence, invoice.IssuedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create invoice: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+)
+
+const notificationColumns = `id, order_id, event, reference, recipient, locale, status, attempts, last_error,
+			  next_attempt_at, created_at, updated_at, sent_at`
+
+type NotificationRepository struct {
+	db *sql.DB
+}
+
+// NewNotificationRepository creates a new instance of NotificationRepository
+func NewNotificationRepository() *NotificationRepository {
+	return &NotificationRepository{
+		db: database.DB,
+	}
+}
+
+// GetOrderNotifications retrieves the notifications of an order, oldest first
+func (r *NotificationRepository) GetOrderNotifications(orderID string) ([]models.Notification, error) {
+	rows, err := r.db.Query(`SELECT `+notificationColumns+` FROM notifications
+			  WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %v", err)
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *notification)
+	}
+
+	return notifications, nil
+}
+
+// LeaseDueNotifications picks up to limit pending notifications that are due
+// and pushes their next attempt back to leaseUntil, like LeaseDueFulfillments
+func (r *NotificationRepository) LeaseDueNotifications(at, leaseUntil time.Time, limit int) ([]models.Notification, error) {
+	rows, err := r.db.Query(`UPDATE notifications SET next_attempt_at = $3, updated_at = $2
+			  WHERE id IN (
+				  SELECT id FROM notifications
+				  WHERE status = $1 AND next_attempt_at <= $2
+				  ORDER BY next_attempt_at LIMIT $4
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING `+notificationColumns, models.NotificationStatusPending, at, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease notifications: %v", err)
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, *notification)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationSent records that a pending notification was handed to the mail server
+func (r *NotificationRepository) MarkNotificationSent(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE notifications SET status = $3, attempts = attempts + 1, last_error = NULL,
+			  sent_at = $4, updated_at = $4 WHERE id = $1 AND status = $2`,
+		id, models.NotificationStatusPending, models.NotificationStatusSent, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark notification sent: %v", err)
+	}
+	return nil
+}
+
+// RecordNotificationFailure counts a failed attempt and schedules the next one
+func (r *NotificationRepository) RecordNotificationFailure(id, reason string, nextAttemptAt, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE notifications SET attempts = attempts + 1, last_error = $3,
+			  next_attempt_at = $4, updated_at = $5 WHERE id = $1 AND status = $2`,
+		id, models.NotificationStatusPending, reason, nextAttemptAt, at)
+	if err != nil {
+		return fmt.Errorf("failed to record notification failure: %v", err)
+	}
+	return nil
+}
+
+// FailNotification counts a last failed attempt and stops retrying a pending notification
+func (r *NotificationRepository) FailNotification(id, reason string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE notifications SET status = $3, attempts = attempts + 1, last_error = $4, updated_at = $5
+			  WHERE id = $1 AND status = $2`,
+		id, models.NotificationStatusPending, models.NotificationStatusFailed, reason, at)
+	if err != nil {
+		return fmt.Errorf("failed to fail notification: %v", err)
+	}
+	return nil
+}
+
+// SkipNotification stops retrying a pending notification that is no longer worth sending
+func (r *NotificationRepository) SkipNotification(id, reason string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE notifications SET status = $3, last_error = $4, updated_at = $5
+			  WHERE id = $1 AND status = $2`,
+		id, models.NotificationStatusPending, models.NotificationStatusSkipped, reason, at)
+	if err != nil {
+		return fmt.Errorf("failed to skip notification: %v", err)
+	}
+	return nil
+}
+
+// enqueueNotificationTx queues an email about an order within the transaction
+// that changed it. Orders without a customer email are not notified, and an
+// event is only queued once per order and reference.
+func enqueueNotificationTx(tx *sql.Tx, orderID, event, reference string, at time.Time) error {
+	_, err := tx.Exec(`INSERT INTO notifications (order_id, event, reference, recipient, locale, status,
+				  next_attempt_at, created_at, updated_at)
+			  SELECT id, $2, $3, customer_email, locale, $4, $5, $5, $5
+			  FROM orders WHERE id = $1 AND customer_email <> ''
+			  ON CONFLICT (order_id, event, reference) DO NOTHING`,
+		orderID, event, reference, models.NotificationStatusPending, at)
+	if err != nil {
+		return fmt.Errorf("failed to queue notification: %v", err)
+	}
+	return nil
+}
+
+func scanNotification(row rowScanner) (*models.Notification, error) {
+	notification := &models.Notification{}
+	err := row.Scan(
+		&notification.ID, &notification.OrderID, &notification.Event, &notification.Reference,
+		&notification.Recipient, &notification.Locale, &notification.Status, &notification.Attempts,
+		&notification.LastError, &notification.NextAttemptAt, &notification.CreatedAt,
+		&notification.UpdatedAt, &notification.SentAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan notification: %v", err)
+	}
+	return notification, nil
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"order-service/models"
+	"order-service/money"
+)
+
+// orderExportExpressions select the export columns of order rows
+var orderExportExpressions = map[string]string{
+	"id":                 "o.id",
+	"customer_id":        "o.customer_id",
+	"customer_email":     "o.customer_email",
+	"status":             "o.status",
+	"order_date":         "o.order_date",
+	"created_at":         "o.created_at",
+	"updated_at":         "o.updated_at",
+	"currency":           "o.currency",
+	"country":            "o.country",
+	"region":             "o.region",
+	"prices_include_tax": "o.prices_include_tax",
+	"item_count":         "(SELECT COALESCE(SUM(oi.quantity), 0) FROM order_items oi WHERE oi.order_id = o.id)",
+	"discount_total":     "o.discount_total",
+	"tax_total":          "o.tax_total",
+	"total_price":        "o.total_price",
+	"refunded_amount":    "o.refunded_amount",
+	"net_total":          "o.total_price - o.refunded_amount",
+}
+
+// orderItemExportExpressions select the export columns of item rows
+var orderItemExportExpressions = map[string]string{
+	"order_id":           "o.id",
+	"order_date":         "o.order_date",
+	"customer_id":        "o.customer_id",
+	"status":             "o.status",
+	"currency":           "o.currency",
+	"country":            "o.country",
+	"region":             "o.region",
+	"prices_include_tax": "o.prices_include_tax",
+	"item_id":            "oi.id",
+	"game_id":            "oi.game_id",
+	"game_name":          "oi.game_name",
+	"category":           "oi.category",
+	"catalog_price":      "oi.catalog_price",
+	"price":              "oi.price",
+	"quantity":           "oi.quantity",
+	"subtotal":           "oi.subtotal",
+	"discount":           "oi.discount",
+	"tax":                "oi.tax",
+	"line_total":         "oi.subtotal - oi.discount + CASE WHEN o.prices_include_tax THEN 0 ELSE oi.tax END",
+}
+
+// ExportOrders streams the rows of an export to write, one at a time and
+// oldest order first, straight from the database cursor, so exports of any
+// size run in constant memory. Values are strings, int64s, money amounts,
+// bools or times in the order of export.Columns, and nil for NULL. The rows
+// come from a single query and are consistent with each other. If write
+// fails, the query is cancelled and the error returned.
+func (r *OrderRepository) ExportOrders(ctx context.Context, export *models.OrderExport, write func(values []interface{}) error) error {
+	expressions, from, order := orderExportExpressions, `orders o`, `o.order_date, o.id`
+	if export.Rows == models.ExportRowsItems {
+		expressions = orderItemExportExpressions
+		from, order = `order_items oi JOIN orders o ON o.id = oi.order_id`, `o.order_date, o.id, oi.id`
+	}
+
+	selected := make([]string, len(export.Columns))
+	for i, column := range export.Columns {
+		expression, ok := expressions[column.Name]
+		if !ok {
+			return fmt.Errorf("unknown export column %s", column.Name)
+		}
+		selected[i] = expression
+	}
+	where, args := orderDateRange("o.order_date", export.From, export.To, nil)
+
+	// Cancelling before the rows are closed stops the query instead of
+	// reading the rest of its result
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+strings.Join(selected, ", ")+` FROM `+from+
+		` WHERE `+where+` ORDER BY `+order, args...)
+	if err != nil {
+		return fmt.Errorf("failed to export orders: %v", err)
+	}
+	defer func() {
+		cancel()
+		rows.Close()
+	}()
+
+	targets := make([]interface{}, len(export.Columns))
+	for i, column := range export.Columns {
+		targets[i] = newExportTarget(column.Kind)
+	}
+	values := make([]interface{}, len(export.Columns))
+
+	for rows.Next() {
+		if err := rows.Scan(targets...); err != nil {
+			return fmt.Errorf("failed to scan exported order: %v", err)
+		}
+		for i, target := range targets {
+			values[i] = exportValue(target)
+		}
+		if err := write(values); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to export orders: %v", err)
+	}
+
+	return nil
+}
+
+// newExportTarget returns a scan destination for a column of the given kind
+func newExportTarget(kind string) interface{} {
+	switch kind {
+	case models.ExportKindInteger:
+		return new(sql.NullInt64)
+	case models.ExportKindAmount:
+		return new(*money.Amount)
+	case models.ExportKindBoolean:
+		return new(sql.NullBool)
+	case models.ExportKindTimestamp:
+		return new(sql.NullTime)
+	default:
+		return new(sql.NullString)
+	}
+}
+
+// exportValue unwraps a scanned export value, with nil for NULL
+func exportValue(target interface{}) interface{} {
+	switch target := target.(type) {
+	case *sql.NullInt64:
+		if target.Valid {
+			return target.Int64
+		}
+	case **money.Amount:
+		if *target != nil {
+			return **target
+		}
+	case *sql.NullBool:
+		if target.Valid {
+			return target.Bool
+		}
+	case *sql.NullTime:
+		if target.Valid {
+			return target.Time
+		}
+	case *sql.NullString:
+		if target.Valid {
+			return target.String
+		}
+	}
+	return nil
+}
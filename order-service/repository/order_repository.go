@@ -2,13 +2,17 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"order-service/database"
 	"order-service/models"
+	"order-service/money"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type OrderRepository struct {
@@ -30,59 +34,195 @@ func (r *OrderRepository) CreateOrder(order *models.Order) error {
 	}
 	defer tx.Rollback()
 
+	if err := createOrderTx(tx, order); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateOrderWithIdempotencyKey creates an order and stores it under an
+// idempotency key in the same transaction. If another request already stored
+// a result under the key, nothing is created and that result is returned with
+// created set to false. A concurrent request with the same key blocks on the
+// key's primary key until the first one commits, so only one order is created.
+func (r *OrderRepository) CreateOrderWithIdempotencyKey(key *models.IdempotencyKey, order *models.Order) (*models.IdempotencyKey, bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Expired keys may be reused
+	_, err = tx.Exec(`DELETE FROM idempotency_keys WHERE expires_at < $1`, key.CreatedAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to delete expired idempotency keys: %v", err)
+	}
+
+	if err := createOrderTx(tx, order); err != nil {
+		return nil, false, err
+	}
+
+	key.OrderID = order.ID
+	key.Response, err = json.Marshal(order)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode order: %v", err)
+	}
+
+	result, err := tx.Exec(`INSERT INTO idempotency_keys (key, request_hash, order_id, response, created_at, expires_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (key) DO NOTHING`,
+		key.Key, key.RequestHash, key.OrderID, []byte(key.Response), key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to store idempotency key: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		// Another request won; discard this order and return its result
+		tx.Rollback()
+		existing, err := r.GetIdempotencyKey(key.Key, key.CreatedAt)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil {
+			return nil, false, fmt.Errorf("idempotency key %s disappeared", key.Key)
+		}
+		return existing, false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit order: %v", err)
+	}
+
+	return key, true, nil
+}
+
+// GetIdempotencyKey retrieves a stored result that has not expired at the given time, or nil
+func (r *OrderRepository) GetIdempotencyKey(key string, at time.Time) (*models.IdempotencyKey, error) {
+	record := &models.IdempotencyKey{}
+	var response []byte
+	err := r.db.QueryRow(`SELECT key, request_hash, order_id, response, created_at, expires_at
+			  FROM idempotency_keys WHERE key = $1 AND expires_at >= $2`, key, at).Scan(
+		&record.Key, &record.RequestHash, &record.OrderID, &response, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %v", err)
+	}
+	record.Response = response
+
+	return record, nil
+}
+
+// createOrderTx inserts an order, its items and the initial history entry within an existing transaction,
+// and records its OrderCreated event in the outbox
+func createOrderTx(tx *sql.Tx, order *models.Order) error {
 	// Generate UUID for the order
 	order.ID = uuid.New().String()
-	order.Status = "pending"
+	order.Status = models.OrderStatusPending
 	order.OrderDate = time.Now()
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 
-	// Calculate total price
-	var totalPrice float64
+	// Calculate total price; line discounts and taxes are already set by the service
+	var totalPrice, discountTotal, taxTotal money.Amount
 	for i := range order.Items {
-		order.Items[i].Subtotal = order.Items[i].Price * float64(order.Items[i].Quantity)
-		totalPrice += order.Items[i].Subtotal
+		order.Items[i].Subtotal = order.Items[i].Price.Mul(order.Items[i].Quantity)
+		totalPrice += order.Items[i].Subtotal - order.Items[i].Discount
+		discountTotal += order.Items[i].Discount
+		taxTotal += order.Items[i].Tax
+	}
+	if !order.PricesIncludeTax {
+		totalPrice += taxTotal
 	}
 	order.TotalPrice = totalPrice
+	order.DiscountTotal = discountTotal
+	order.TaxTotal = taxTotal
+	order.NetTotal = order.TotalPrice
+	if order.Currency == "" {
+		order.Currency = money.DefaultCurrency()
+	}
 
 	// Insert order
-	query := `INSERT INTO orders (id, customer_id, total_price, status, order_date, created_at, updated_at) 
-			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	query := `INSERT INTO orders (id, customer_id, total_price, currency, discount_total, tax_total, prices_include_tax, country, region, 
+			  customer_email, locale, status, order_date, created_at, updated_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)`
 	
-	_, err = tx.Exec(query, order.ID, order.CustomerID, order.TotalPrice, order.Status, 
+	_, err := tx.Exec(query, order.ID, order.CustomerID, order.TotalPrice, order.Currency, order.DiscountTotal, order.TaxTotal,
+					order.PricesIncludeTax, order.Country, order.Region, order.CustomerEmail, order.Locale, order.Status, 
 					order.OrderDate, order.CreatedAt, order.UpdatedAt)
 	if err != nil {
 		return fmt.Errorf("failed to insert order: %v", err)
 	}
 
 	// Insert order items
-	itemQuery := `INSERT INTO order_items (id, order_id, game_id, game_name, price, quantity, subtotal) 
-				  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	itemQuery := `INSERT INTO order_items (id, order_id, game_id, game_name, category, price, quantity, subtotal, catalog_price, discount, tax) 
+				  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 	
 	for i := range order.Items {
 		order.Items[i].ID = uuid.New().String()
 		order.Items[i].OrderID = order.ID
 		
 		_, err = tx.Exec(itemQuery, order.Items[i].ID, order.Items[i].OrderID, 
-						order.Items[i].GameID, order.Items[i].GameName, 
-						order.Items[i].Price, order.Items[i].Quantity, order.Items[i].Subtotal)
+						order.Items[i].GameID, order.Items[i].GameName, order.Items[i].Category,
+						order.Items[i].Price, order.Items[i].Quantity, order.Items[i].Subtotal,
+						order.Items[i].CatalogPrice, order.Items[i].Discount, order.Items[i].Tax)
 		if err != nil {
 			return fmt.Errorf("failed to insert order item: %v", err)
 		}
+
+		if order.Items[i].Gift != nil {
+			if err := insertGiftTx(tx, &order.Items[i], order.CreatedAt); err != nil {
+				return err
+			}
+		}
+
+		for _, line := range order.Items[i].Taxes {
+			_, err = tx.Exec(`INSERT INTO order_tax_lines (order_id, order_item_id, name, country, region, rate, taxable_amount, amount)
+					  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				order.ID, order.Items[i].ID, line.Name, line.Country, line.Region, line.Rate, line.TaxableAmount, line.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to insert order tax line: %v", err)
+			}
+		}
 	}
+	order.TaxLines = summarizeTaxLines(order.Items)
 
-	return tx.Commit()
+	if err := redeemPromotionsTx(tx, order); err != nil {
+		return err
+	}
+
+	if err := enqueueNotificationTx(tx, order.ID, models.NotificationOrderPlaced, "", order.CreatedAt); err != nil {
+		return err
+	}
+	if err := enqueueWebhookEventTx(tx, models.WebhookEventOrderCreated, order, order.CreatedAt); err != nil {
+		return err
+	}
+	if err := insertOrderEventTx(tx, models.OrderEventCreated, order.ID, order, order.CreatedAt); err != nil {
+		return err
+	}
+
+	// The history starts with the creation of the order
+	_, err = insertStatusChange(tx, order.ID, nil, order.Status, order.CustomerID, "order created", order.CreatedAt)
+	return err
 }
 
 // GetOrderByID retrieves an order by its ID
 func (r *OrderRepository) GetOrderByID(id string) (*models.Order, error) {
 	order := &models.Order{}
 	
-	query := `SELECT id, customer_id, total_price, status, order_date, created_at, updated_at 
+	query := `SELECT id, customer_id, total_price, currency, discount_total, tax_total, prices_include_tax, country, region, 
+			  refunded_amount, customer_email, locale, status, order_date, created_at, updated_at 
 			  FROM orders WHERE id = $1`
 	
 	err := r.db.QueryRow(query, id).Scan(
-		&order.ID, &order.CustomerID, &order.TotalPrice, &order.Status,
+		&order.ID, &order.CustomerID, &order.TotalPrice, &order.Currency, &order.DiscountTotal, &order.TaxTotal,
+			&order.PricesIncludeTax, &order.Country, &order.Region, &order.RefundedAmount, &order.CustomerEmail, &order.Locale, &order.Status,
 		&order.OrderDate, &order.CreatedAt, &order.UpdatedAt,
 	)
 	
@@ -92,6 +232,7 @@ func (r *OrderRepository) GetOrderByID(id string) (*models.Order, error) {
 		}
 		return nil, fmt.Errorf("failed to get order: %v", err)
 	}
+	order.NetTotal = netTotal(order.TotalPrice, order.RefundedAmount)
 
 	// Get order items
 	items, err := r.getOrderItems(id)
@@ -100,12 +241,25 @@ func (r *OrderRepository) GetOrderByID(id string) (*models.Order, error) {
 	}
 	order.Items = items
 
+	if err := loadOrderTaxes(r.db, order); err != nil {
+		return nil, err
+	}
+
+	if err := loadOrderDiscounts(r.db, order); err != nil {
+		return nil, err
+	}
+
+	if err := loadOrderGifts(r.db, order); err != nil {
+		return nil, err
+	}
+
 	return order, nil
 }
 
 // GetOrdersByCustomerID retrieves all orders for a specific customer
 func (r *OrderRepository) GetOrdersByCustomerID(customerID string) ([]models.Order, error) {
-	query := `SELECT id, customer_id, total_price, status, order_date, created_at, updated_at 
+	query := `SELECT id, customer_id, total_price, currency, discount_total, tax_total, prices_include_tax, country, region, 
+			  refunded_amount, customer_email, locale, status, order_date, created_at, updated_at 
 			  FROM orders WHERE customer_id = $1 ORDER BY order_date DESC`
 	
 	rows, err := r.db.Query(query, customerID)
@@ -118,43 +272,108 @@ func (r *OrderRepository) GetOrdersByCustomerID(customerID string) ([]models.Ord
 	for rows.Next() {
 		var order models.Order
 		err := rows.Scan(
-			&order.ID, &order.CustomerID, &order.TotalPrice, &order.Status,
+			&order.ID, &order.CustomerID, &order.TotalPrice, &order.Currency, &order.DiscountTotal, &order.TaxTotal,
+			&order.PricesIncludeTax, &order.Country, &order.Region, &order.RefundedAmount, &order.CustomerEmail, &order.Locale, &order.Status,
 			&order.OrderDate, &order.CreatedAt, &order.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %v", err)
 		}
-
-		// Get items for this order
-		items, err := r.getOrderItems(order.ID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get order items for order %s: %v", order.ID, err)
-		}
-		order.Items = items
-
+		order.NetTotal = netTotal(order.TotalPrice, order.RefundedAmount)
 		orders = append(orders, order)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orders: %v", err)
+	}
+
+	if err := loadOrderItems(r.db, orders); err != nil {
+		return nil, err
+	}
 
 	return orders, nil
 }
 
-// GetAllOrders retrieves all orders with pagination
-func (r *OrderRepository) GetAllOrders(limit, offset int) ([]models.Order, int, error) {
-	// Get total count
-	var total int
-	countQuery := `SELECT COUNT(*) FROM orders`
-	err := r.db.QueryRow(countQuery).Scan(&total)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get orders count: %v", err)
+// GetAllOrders retrieves the orders matching a search, one page at a time.
+// Pages continue after search.After by comparing the sort key and ID, which
+// the (key, id) indexes serve without reading the skipped orders, so deep
+// pages cost the same as the first. How many orders match in total is only
+// counted for the first page, since counting reads every match; it is nil
+// for pages after a cursor.
+func (r *OrderRepository) GetAllOrders(search *models.OrderSearch) ([]models.Order, *int, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
 	}
 
-	// Get orders with pagination
-	query := `SELECT id, customer_id, total_price, status, order_date, created_at, updated_at 
-			  FROM orders ORDER BY order_date DESC LIMIT $1 OFFSET $2`
-	
-	rows, err := r.db.Query(query, limit, offset)
+	if len(search.Statuses) > 0 {
+		conditions = append(conditions, "status = ANY("+arg(pq.Array(search.Statuses))+")")
+	}
+	if search.CustomerID != "" {
+		conditions = append(conditions, "customer_id = "+arg(search.CustomerID))
+	}
+	if search.From != nil {
+		conditions = append(conditions, "order_date >= "+arg(*search.From))
+	}
+	if search.To != nil {
+		conditions = append(conditions, "order_date < "+arg(*search.To))
+	}
+	if search.MinTotal != nil {
+		conditions = append(conditions, "total_price >= "+arg(*search.MinTotal))
+	}
+	if search.MaxTotal != nil {
+		conditions = append(conditions, "total_price <= "+arg(*search.MaxTotal))
+	}
+	if search.GameID != 0 {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM order_items oi WHERE oi.order_id = orders.id AND oi.game_id = "+arg(search.GameID)+")")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total *int
+	if search.After == nil {
+		var count int
+		err := r.db.QueryRow(`SELECT COUNT(*) FROM orders`+where, args...).Scan(&count)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get orders count: %v", err)
+		}
+		total = &count
+	}
+
+	column := models.OrderSortOrderDate
+	if search.Sort == models.OrderSortTotalPrice {
+		column = models.OrderSortTotalPrice
+	}
+	direction, comparison := "ASC", ">"
+	if search.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if after := search.After; after != nil {
+		var key interface{} = *after.OrderDate
+		if column == models.OrderSortTotalPrice {
+			key = after.TotalPrice
+		}
+		cursor := fmt.Sprintf("(%s, id) %s (%s, %s::uuid)", column, comparison, arg(key), arg(after.ID))
+		if where == "" {
+			where = " WHERE " + cursor
+		} else {
+			where += " AND " + cursor
+		}
+	}
+
+	query := `SELECT id, customer_id, total_price, currency, discount_total, tax_total, prices_include_tax, country, region,
+			  refunded_amount, customer_email, locale, status, order_date, created_at, updated_at
+			  FROM orders` + where + fmt.Sprintf(` ORDER BY %s %s, id %s LIMIT %s OFFSET %s`,
+		column, direction, direction, arg(search.Limit), arg(search.Offset))
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to query orders: %v", err)
+		return nil, nil, fmt.Errorf("failed to query orders: %v", err)
 	}
 	defer rows.Close()
 
@@ -162,53 +381,267 @@ func (r *OrderRepository) GetAllOrders(limit, offset int) ([]models.Order, int,
 	for rows.Next() {
 		var order models.Order
 		err := rows.Scan(
-			&order.ID, &order.CustomerID, &order.TotalPrice, &order.Status,
+			&order.ID, &order.CustomerID, &order.TotalPrice, &order.Currency, &order.DiscountTotal, &order.TaxTotal,
+			&order.PricesIncludeTax, &order.Country, &order.Region, &order.RefundedAmount, &order.CustomerEmail, &order.Locale, &order.Status,
 			&order.OrderDate, &order.CreatedAt, &order.UpdatedAt,
 		)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to scan order: %v", err)
+			return nil, nil, fmt.Errorf("failed to scan order: %v", err)
 		}
+		order.NetTotal = netTotal(order.TotalPrice, order.RefundedAmount)
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read orders: %v", err)
+	}
 
-		// Get items for this order
-		items, err := r.getOrderItems(order.ID)
+	if err := loadOrderItems(r.db, orders); err != nil {
+		return nil, nil, err
+	}
+
+	return orders, total, nil
+}
+
+// UpdateOrderStatus moves an order to a new status and records the change in
+// order_status_history. The current status is locked for the duration of the
+// transaction and passed to validate, which can reject the transition.
+func (r *OrderRepository) UpdateOrderStatus(id, status, actor, reason string, validate func(from string) error) (*models.OrderStatusChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	change, err := updateOrderStatusTx(tx, id, status, actor, reason, validate)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit status change: %v", err)
+	}
+
+	return change, nil
+}
+
+// ExpirePendingOrders cancels up to limit pending orders placed before the
+// given time, oldest first, recording actor and reason in their history.
+// Orders with a payment that went through are left for its webhook to
+// confirm; payments are authorized under a lock on their order, so the
+// check and the authorization cannot interleave. Orders being changed
+// elsewhere, including by another replica running the same sweep or a
+// payment being authorized, are skipped rather than waited for.
+func (r *OrderRepository) ExpirePendingOrders(placedBefore time.Time, limit int, actor, reason string) ([]string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT o.id FROM orders o
+			  WHERE o.status = $1 AND o.order_date < $2
+			  AND NOT EXISTS (SELECT 1 FROM payment_attempts pa WHERE pa.order_id = o.id AND pa.status = ANY($3))
+			  ORDER BY o.order_date LIMIT $4
+			  FOR UPDATE OF o SKIP LOCKED`,
+		models.OrderStatusPending, placedBefore,
+		pq.Array([]string{models.PaymentStatusAuthorized, models.PaymentStatusCaptured}), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired orders: %v", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expired order: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read expired orders: %v", err)
+	}
+
+	expired := make([]string, 0, len(ids))
+	for _, id := range ids {
+		// Payments lock their order before they are authorized, so one that
+		// went through between the query and the row lock is visible now
+		var paid bool
+		err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM payment_attempts WHERE order_id = $1 AND status = ANY($2))`,
+			id, pq.Array([]string{models.PaymentStatusAuthorized, models.PaymentStatusCaptured})).Scan(&paid)
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to get order items for order %s: %v", order.ID, err)
+			return nil, fmt.Errorf("failed to check payments of expired order: %v", err)
+		}
+		if paid {
+			continue
 		}
-		order.Items = items
 
-		orders = append(orders, order)
+		// The row lock taken above keeps the order pending until the commit
+		_, err = updateOrderStatusTx(tx, id, models.OrderStatusCancelled, actor, reason,
+			func(string) error { return nil })
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, id)
 	}
 
-	return orders, total, nil
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit expired orders: %v", err)
+	}
+
+	return expired, nil
 }
 
-// UpdateOrderStatus updates the status of an order
-func (r *OrderRepository) UpdateOrderStatus(id string, status string) error {
-	query := `UPDATE orders SET status = $1, updated_at = $2 WHERE id = $3`
-	
-	result, err := r.db.Exec(query, status, time.Now(), id)
+// updateOrderStatusTx performs UpdateOrderStatus within an existing transaction.
+// Confirming an order issues its invoice number and, once captured payments
+// cover its total, queues its fulfillment in the same transaction; cancelling it releases its coupon uses and closes its
+// unfinished payment attempts. Confirmed, shipped and delivered orders queue
+// an email to the customer, and every change is sent to webhook subscribers
+// and recorded in the outbox.
+func updateOrderStatusTx(tx *sql.Tx, id, status, actor, reason string, validate func(from string) error) (*models.OrderStatusChange, error) {
+	var current string
+	err := tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, id).Scan(&current)
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %v", err)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, fmt.Errorf("failed to get order status: %v", err)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if err := validate(current); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`UPDATE orders SET status = $1, updated_at = $2 WHERE id = $3`, status, now, id)
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %v", err)
+		return nil, fmt.Errorf("failed to update order status: %v", err)
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("order not found")
+	switch status {
+	case models.OrderStatusConfirmed:
+		if err := issueInvoiceTx(tx, id, now); err != nil {
+			return nil, err
+		}
+		paid, err := orderPaidTx(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if paid {
+			if err := enqueueFulfillmentTx(tx, id, now); err != nil {
+				return nil, err
+			}
+			if err := enqueueNotificationTx(tx, id, models.NotificationOrderPaid, "", now); err != nil {
+				return nil, err
+			}
+		}
+	case models.OrderStatusShipped:
+		if err := enqueueNotificationTx(tx, id, models.NotificationOrderShipped, "", now); err != nil {
+			return nil, err
+		}
+	case models.OrderStatusDelivered:
+		if err := enqueueNotificationTx(tx, id, models.NotificationOrderDelivered, "", now); err != nil {
+			return nil, err
+		}
+	case models.OrderStatusCancelled:
+		if err := releasePromotionsTx(tx, id, now); err != nil {
+			return nil, err
+		}
+		if err := failOpenPaymentAttemptsTx(tx, id, "order cancelled", now); err != nil {
+			return nil, err
+		}
+		if err := revokeGiftsTx(tx, id, "", now); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	change, err := insertStatusChange(tx, id, &current, status, actor, reason, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := enqueueWebhookEventTx(tx, models.WebhookEventOrderStatusChanged, change, now); err != nil {
+		return nil, err
+	}
+	if err := insertOrderEventTx(tx, models.OrderEventStatusChanged, id, change, now); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// insertStatusChange appends an entry to an order's status history
+func insertStatusChange(tx *sql.Tx, orderID string, from *string, to, actor, reason string, at time.Time) (*models.OrderStatusChange, error) {
+	change := &models.OrderStatusChange{
+		ID:         uuid.New().String(),
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+		ChangedAt:  at,
+	}
+
+	query := `INSERT INTO order_status_history (id, order_id, from_status, to_status, actor, reason, changed_at) 
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := tx.Exec(query, change.ID, change.OrderID, change.FromStatus, change.ToStatus,
+					change.Actor, change.Reason, change.ChangedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record status change: %v", err)
+	}
+
+	return change, nil
 }
 
-// DeleteOrder deletes an order and its items
+// GetOrderStatusHistory retrieves the status changes of an order, oldest first
+func (r *OrderRepository) GetOrderStatusHistory(orderID string) ([]models.OrderStatusChange, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM orders WHERE id = $1)`, orderID).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check order: %v", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	query := `SELECT id, order_id, from_status, to_status, actor, reason, changed_at 
+			  FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id`
+
+	rows, err := r.db.Query(query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query status history: %v", err)
+	}
+	defer rows.Close()
+
+	history := []models.OrderStatusChange{}
+	for rows.Next() {
+		var change models.OrderStatusChange
+		err := rows.Scan(
+			&change.ID, &change.OrderID, &change.FromStatus, &change.ToStatus,
+			&change.Actor, &change.Reason, &change.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %v", err)
+		}
+		history = append(history, change)
+	}
+
+	return history, nil
+}
+
+// DeleteOrder deletes an order and its items and tells webhook subscribers
+// and the event stream
 func (r *OrderRepository) DeleteOrder(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
 	// The order_items will be deleted automatically due to CASCADE
-	query := `DELETE FROM orders WHERE id = $1`
-	
-	result, err := r.db.Exec(query, id)
+	result, err := tx.Exec(`DELETE FROM orders WHERE id = $1`, id)
 	if err != nil {
 		return fmt.Errorf("failed to delete order: %v", err)
 	}
@@ -222,15 +655,218 @@ func (r *OrderRepository) DeleteOrder(id string) error {
 		return fmt.Errorf("order not found")
 	}
 
+	now := time.Now()
+	deleted := models.OrderDeletedData{OrderID: id}
+	if err := enqueueWebhookEventTx(tx, models.WebhookEventOrderDeleted, deleted, now); err != nil {
+		return err
+	}
+	if err := insertOrderEventTx(tx, models.OrderEventDeleted, id, deleted, now); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit order deletion: %v", err)
+	}
+
 	return nil
 }
 
+// netLineRevenue is the revenue of an order line after discounts and before
+// tax. Lines of orders with tax-inclusive prices have the tax in their
+// subtotal; on other orders it was added on top.
+const netLineRevenue = `oi.subtotal - oi.discount - CASE WHEN o.prices_include_tax THEN oi.tax ELSE 0 END`
+
+// orderRevenue is the revenue of an order after discounts and before tax, the
+// sum of netLineRevenue over its lines
+const orderRevenue = `total_price - tax_total`
+
+// refundedRevenue is the part of an order's refunds that paid back revenue
+// rather than tax. Refunds include tax, so they are split in proportion to
+// the order's total.
+const refundedRevenue = `ROUND(refunded_amount * (total_price - tax_total) / NULLIF(total_price, 0), 2)`
+
+// GetOrderStatistics aggregates the orders placed in [from, to) into time
+// buckets truncated to granularity, and ranks the top games and categories by
+// the revenue of their delivered lines. Revenue is counted after discounts
+// and before tax throughout, so the top lines add up to the total revenue
+// when nothing is left out. Either bound may be nil. Everything is
+// summed by the database, so the result covers every order in the range.
+func (r *OrderRepository) GetOrderStatistics(from, to *time.Time, granularity string, top int) (*models.OrderStatistics, error) {
+	stats := &models.OrderStatistics{
+		From:          from,
+		To:            to,
+		Granularity:   granularity,
+		StatusCounts:  map[string]int{},
+		Buckets:       []models.OrderStatisticsBucket{},
+		TopGames:      []models.GameRevenue{},
+		TopCategories: []models.CategoryRevenue{},
+	}
+
+	where, args := orderDateRange("order_date", from, to, []interface{}{granularity})
+	rows, err := r.db.Query(`SELECT date_trunc($1, order_date) AS bucket, status, COUNT(*),
+			  COALESCE(SUM(`+orderRevenue+`), 0), COALESCE(SUM(refunded_amount), 0),
+			  COALESCE(SUM(`+refundedRevenue+`), 0), COUNT(*) FILTER (WHERE refunded_amount > 0)
+			  FROM orders WHERE `+where+`
+			  GROUP BY bucket, status ORDER BY bucket, status`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order statistics: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var start time.Time
+		var status string
+		var count, refundedOrders int
+		var revenue, refunded, refundedBeforeTax money.Amount
+		if err := rows.Scan(&start, &status, &count, &revenue, &refunded, &refundedBeforeTax, &refundedOrders); err != nil {
+			return nil, fmt.Errorf("failed to scan order statistics: %v", err)
+		}
+
+		if len(stats.Buckets) == 0 || !stats.Buckets[len(stats.Buckets)-1].Start.Equal(start) {
+			stats.Buckets = append(stats.Buckets, models.OrderStatisticsBucket{Start: start, StatusCounts: map[string]int{}})
+		}
+		bucket := &stats.Buckets[len(stats.Buckets)-1]
+		bucket.OrderCount += count
+		bucket.StatusCounts[status] = count
+		if status == models.OrderStatusDelivered {
+			bucket.DeliveredOrders = count
+			bucket.Revenue = revenue
+			bucket.NetRevenue = netTotal(revenue, refundedBeforeTax)
+		}
+
+		stats.TotalOrders += count
+		stats.StatusCounts[status] += count
+		stats.RefundedAmount += refunded
+		stats.RefundedOrders += refundedOrders
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read order statistics: %v", err)
+	}
+
+	for i := range stats.Buckets {
+		bucket := &stats.Buckets[i]
+		bucket.AverageOrderValue = averageOrderValue(bucket.Revenue, bucket.DeliveredOrders)
+		stats.DeliveredOrders += bucket.DeliveredOrders
+		stats.TotalRevenue += bucket.Revenue
+		stats.NetRevenue += bucket.NetRevenue
+	}
+	stats.AverageOrderValue = averageOrderValue(stats.TotalRevenue, stats.DeliveredOrders)
+
+	where, args = orderDateRange("o.order_date", from, to, []interface{}{models.OrderStatusDelivered, top})
+	gameRows, err := r.db.Query(`SELECT oi.game_id, MAX(oi.game_name), SUM(oi.quantity),
+			  SUM(`+netLineRevenue+`) AS revenue, COUNT(DISTINCT o.id)
+			  FROM order_items oi JOIN orders o ON o.id = oi.order_id
+			  WHERE o.status = $1 AND `+where+`
+			  GROUP BY oi.game_id ORDER BY revenue DESC, oi.game_id LIMIT $2`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top games: %v", err)
+	}
+	defer gameRows.Close()
+
+	for gameRows.Next() {
+		var game models.GameRevenue
+		if err := gameRows.Scan(&game.GameID, &game.GameName, &game.UnitsSold, &game.Revenue, &game.OrderCount); err != nil {
+			return nil, fmt.Errorf("failed to scan top game: %v", err)
+		}
+		stats.TopGames = append(stats.TopGames, game)
+	}
+	if err := gameRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read top games: %v", err)
+	}
+
+	// Lines ordered before categories were recorded have none and are left out
+	categoryRows, err := r.db.Query(`SELECT oi.category, SUM(oi.quantity),
+			  SUM(`+netLineRevenue+`) AS revenue, COUNT(DISTINCT o.id)
+			  FROM order_items oi JOIN orders o ON o.id = oi.order_id
+			  WHERE o.status = $1 AND oi.category <> '' AND `+where+`
+			  GROUP BY oi.category ORDER BY revenue DESC, oi.category LIMIT $2`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query top categories: %v", err)
+	}
+	defer categoryRows.Close()
+
+	for categoryRows.Next() {
+		var category models.CategoryRevenue
+		if err := categoryRows.Scan(&category.Category, &category.UnitsSold, &category.Revenue, &category.OrderCount); err != nil {
+			return nil, fmt.Errorf("failed to scan top category: %v", err)
+		}
+		stats.TopCategories = append(stats.TopCategories, category)
+	}
+	if err := categoryRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read top categories: %v", err)
+	}
+
+	return stats, nil
+}
+
+// orderDateRange builds a condition restricting column to [from, to), with
+// its parameters numbered after args
+func orderDateRange(column string, from, to *time.Time, args []interface{}) (string, []interface{}) {
+	conditions := []string{"TRUE"}
+	if from != nil {
+		args = append(args, *from)
+		conditions = append(conditions, fmt.Sprintf("%s >= $%d", column, len(args)))
+	}
+	if to != nil {
+		args = append(args, *to)
+		conditions = append(conditions, fmt.Sprintf("%s < $%d", column, len(args)))
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// averageOrderValue divides revenue by the number of orders, rounding to the minor unit
+func averageOrderValue(revenue money.Amount, orders int) money.Amount {
+	if orders == 0 {
+		return 0
+	}
+	return revenue.MulRat(1, int64(orders))
+}
+
+// GetGameSalesSince aggregates units sold and revenue per game for orders placed
+// since the given time that were paid for, leaving out pending and cancelled orders
+func (r *OrderRepository) GetGameSalesSince(since time.Time) ([]models.GameSales, error) {
+	query := `SELECT oi.game_id, SUM(oi.quantity), SUM(oi.subtotal - oi.discount), COUNT(DISTINCT o.id)
+			  FROM order_items oi
+			  JOIN orders o ON o.id = oi.order_id
+			  WHERE o.order_date >= $1 AND o.status = ANY($2)
+			  GROUP BY oi.game_id
+			  ORDER BY SUM(oi.quantity) DESC`
+
+	paid := []string{models.OrderStatusConfirmed, models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusDelivered}
+	rows, err := r.db.Query(query, since, pq.Array(paid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query game sales: %v", err)
+	}
+	defer rows.Close()
+
+	sales := []models.GameSales{}
+	for rows.Next() {
+		var sale models.GameSales
+		err := rows.Scan(&sale.GameID, &sale.UnitsSold, &sale.Revenue, &sale.OrderCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan game sales: %v", err)
+		}
+		sales = append(sales, sale)
+	}
+
+	return sales, nil
+}
+
+// netTotal is the revenue left on an order after refunds
+func netTotal(totalPrice, refundedAmount money.Amount) money.Amount {
+	return totalPrice - refundedAmount
+}
+
 // getOrderItems retrieves all items for a specific order
 func (r *OrderRepository) getOrderItems(orderID string) ([]models.OrderItem, error) {
-	query := `SELECT id, order_id, game_id, game_name, price, quantity, subtotal 
-			  FROM order_items WHERE order_id = $1 ORDER BY id`
-	
-	rows, err := r.db.Query(query, orderID)
+	return queryOrderItems(r.db, orderID)
+}
+
+// queryOrderItems retrieves the items of an order through a database or transaction
+func queryOrderItems(q queryer, orderID string) ([]models.OrderItem, error) {
+	query := `SELECT ` + orderItemColumns + ` FROM order_items WHERE order_id = $1 ORDER BY id`
+
+	rows, err := q.Query(query, orderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query order items: %v", err)
 	}
@@ -238,16 +874,137 @@ func (r *OrderRepository) getOrderItems(orderID string) ([]models.OrderItem, err
 
 	var items []models.OrderItem
 	for rows.Next() {
-		var item models.OrderItem
-		err := rows.Scan(
-			&item.ID, &item.OrderID, &item.GameID, &item.GameName,
-			&item.Price, &item.Quantity, &item.Subtotal,
-		)
+		item, err := scanOrderItem(rows)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan order item: %v", err)
+			return nil, err
 		}
 		items = append(items, item)
 	}
 
-	return items, nil
+	return items, rows.Err()
+}
+
+// loadOrderItems attaches the items, tax lines, discounts and gifts of a page
+// of orders with one query each, however many orders the page holds
+func loadOrderItems(q queryer, orders []models.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(orders))
+	page := make([]*models.Order, len(orders))
+	orderIndex := make(map[string]int, len(orders))
+	for i := range orders {
+		ids[i] = orders[i].ID
+		page[i] = &orders[i]
+		orderIndex[orders[i].ID] = i
+	}
+
+	rows, err := q.Query(`SELECT `+orderItemColumns+` FROM order_items
+			  WHERE order_id = ANY($1::uuid[]) ORDER BY order_id, id`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query order items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanOrderItem(rows)
+		if err != nil {
+			return err
+		}
+		if i, ok := orderIndex[item.OrderID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read order items: %v", err)
+	}
+
+	if err := loadOrdersTaxes(q, page); err != nil {
+		return err
+	}
+	if err := loadOrdersDiscounts(q, page); err != nil {
+		return err
+	}
+	return loadOrdersGifts(q, page)
+}
+
+// loadOrderTaxes attaches the tax lines to the items of an order and sums them on the order
+func loadOrderTaxes(q queryer, order *models.Order) error {
+	return loadOrdersTaxes(q, []*models.Order{order})
+}
+
+// loadOrdersTaxes attaches the tax lines to the items of several orders in one query
+func loadOrdersTaxes(q queryer, orders []*models.Order) error {
+	ids := make([]string, len(orders))
+	type itemRef struct{ order, item int }
+	itemIndex := make(map[string]itemRef)
+	for i, order := range orders {
+		ids[i] = order.ID
+		for j, item := range order.Items {
+			itemIndex[item.ID] = itemRef{i, j}
+		}
+	}
+
+	rows, err := q.Query(`SELECT order_item_id, name, country, region, rate, taxable_amount, amount
+			  FROM order_tax_lines WHERE order_id = ANY($1::uuid[]) ORDER BY order_item_id, name`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query order tax lines: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID string
+		var line models.TaxLine
+		err := rows.Scan(&itemID, &line.Name, &line.Country, &line.Region, &line.Rate, &line.TaxableAmount, &line.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to scan order tax line: %v", err)
+		}
+		if ref, ok := itemIndex[itemID]; ok {
+			item := &orders[ref.order].Items[ref.item]
+			item.Taxes = append(item.Taxes, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, order := range orders {
+		order.TaxLines = summarizeTaxLines(order.Items)
+	}
+	return nil
+}
+
+const orderItemColumns = `id, order_id, game_id, game_name, category, price, quantity, subtotal, catalog_price, discount, tax`
+
+func scanOrderItem(row rowScanner) (models.OrderItem, error) {
+	var item models.OrderItem
+	err := row.Scan(
+		&item.ID, &item.OrderID, &item.GameID, &item.GameName, &item.Category,
+		&item.Price, &item.Quantity, &item.Subtotal, &item.CatalogPrice, &item.Discount, &item.Tax,
+	)
+	if err != nil {
+		return item, fmt.Errorf("failed to scan order item: %v", err)
+	}
+	return item, nil
+}
+
+// summarizeTaxLines sums the tax lines of order items per rule, in the order the rules first appear
+func summarizeTaxLines(items []models.OrderItem) []models.TaxLine {
+	var summary []models.TaxLine
+	index := make(map[models.TaxLine]int)
+	for _, item := range items {
+		for _, line := range item.Taxes {
+			key := models.TaxLine{Name: line.Name, Country: line.Country, Region: line.Region, Rate: line.Rate}
+			i, ok := index[key]
+			if !ok {
+				i = len(summary)
+				index[key] = i
+				summary = append(summary, key)
+			}
+			summary[i].TaxableAmount += line.TaxableAmount
+			summary[i].Amount += line.Amount
+		}
+	}
+	return summary
 }
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+)
+
+// The benchmarks need the PostgreSQL database configured by the DB_*
+// environment variables and are skipped without one:
+//
+//	go test ./repository -run '^$' -bench OrderPage -benchtime 20x
+//
+// BenchmarkOrderPageLoading compares loading the items and tax lines of a page
+// one order at a time, as listings used to, with loading them in bulk.
+
+const benchmarkOrderCount = 1000
+
+var benchmarkPageSizes = []int{10, 100, 1000}
+
+func BenchmarkOrderPageLoading(b *testing.B) {
+	repo, customerID := seedBenchmarkOrders(b)
+
+	for _, size := range benchmarkPageSizes {
+		page, _, err := repo.GetAllOrders(&models.OrderSearch{CustomerID: customerID, Sort: models.OrderSortOrderDate, Limit: size})
+		if err != nil {
+			b.Fatalf("Failed to get orders: %v", err)
+		}
+
+		b.Run(fmt.Sprintf("per-order/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := loadOrderItemsPerOrder(repo, withoutItems(page)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("bulk/%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := loadOrderItems(repo.db, withoutItems(page)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetAllOrdersPage measures a whole listing page, count included
+func BenchmarkGetAllOrdersPage(b *testing.B) {
+	repo, customerID := seedBenchmarkOrders(b)
+
+	for _, size := range benchmarkPageSizes {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, _, err := repo.GetAllOrders(&models.OrderSearch{CustomerID: customerID, Sort: models.OrderSortOrderDate, Limit: size})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// loadOrderItemsPerOrder loads the same as loadOrderItems one order at a
+// time, with four queries per order
+func loadOrderItemsPerOrder(r *OrderRepository, orders []models.Order) error {
+	for i := range orders {
+		items, err := r.getOrderItems(orders[i].ID)
+		if err != nil {
+			return err
+		}
+		orders[i].Items = items
+
+		if err := loadOrderTaxes(r.db, &orders[i]); err != nil {
+			return err
+		}
+		if err := loadOrderDiscounts(r.db, &orders[i]); err != nil {
+			return err
+		}
+		if err := loadOrderGifts(r.db, &orders[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func withoutItems(orders []models.Order) []models.Order {
+	stripped := make([]models.Order, len(orders))
+	for i, order := range orders {
+		order.Items = nil
+		order.TaxLines = nil
+		order.Discounts = nil
+		stripped[i] = order
+	}
+	return stripped
+}
+
+// seedBenchmarkOrders inserts benchmarkOrderCount orders of three taxed items
+// for a customer of their own, and deletes them when the benchmark ends
+func seedBenchmarkOrders(b *testing.B) (*OrderRepository, string) {
+	b.Helper()
+
+	if database.DB == nil || database.DB.Ping() != nil {
+		if err := database.InitDB(); err != nil {
+			b.Skipf("Database not available: %v", err)
+		}
+	}
+
+	customerID := fmt.Sprintf("benchmark_%d", time.Now().UnixNano())
+	seed := []string{
+		`INSERT INTO orders (customer_id, total_price, tax_total, country, status, order_date)
+		 SELECT $1, 35.70, 5.70, 'DE', 'pending', NOW() - g * INTERVAL '1 second'
+		 FROM generate_series(1, ` + fmt.Sprint(benchmarkOrderCount) + `) AS g`,
+		`INSERT INTO order_items (order_id, game_id, game_name, price, quantity, subtotal, tax)
+		 SELECT o.id, g, 'Benchmark Game', 10.00, 1, 10.00, 1.90
+		 FROM orders o, generate_series(1, 3) AS g WHERE o.customer_id = $1`,
+		`INSERT INTO order_tax_lines (order_id, order_item_id, name, country, rate, taxable_amount, amount)
+		 SELECT o.id, oi.id, 'VAT', 'DE', 19, 10.00, 1.90
+		 FROM orders o JOIN order_items oi ON oi.order_id = o.id WHERE o.customer_id = $1`,
+	}
+	for _, query := range seed {
+		if _, err := database.DB.Exec(query, customerID); err != nil {
+			b.Fatalf("Failed to seed benchmark orders: %v", err)
+		}
+	}
+	b.Cleanup(func() {
+		database.DB.Exec(`DELETE FROM orders WHERE customer_id = $1`, customerID)
+	})
+
+	return NewOrderRepository(), customerID
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const outboxEventColumns = `id, sequence, aggregate_type, aggregate_id, event_type, payload, attempts, last_error,
+			  next_attempt_at, created_at, published_at`
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+// NewOutboxRepository creates a new instance of OutboxRepository
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{
+		db: database.DB,
+	}
+}
+
+// LeaseDueEvents picks up to limit unpublished events that are due and holds
+// them until leaseUntil, so only one replica publishes each. Only the oldest
+// unpublished event of each aggregate is picked, which keeps the events of an
+// order in order even while an earlier one waits for its retry. The events
+// are returned in sequence order.
+func (r *OutboxRepository) LeaseDueEvents(at, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	rows, err := r.db.Query(`UPDATE outbox_events SET next_attempt_at = $2
+			  WHERE id IN (
+				  SELECT e.id FROM outbox_events e
+				  WHERE e.published_at IS NULL AND e.next_attempt_at <= $1
+				  AND NOT EXISTS (
+					  SELECT 1 FROM outbox_events p
+					  WHERE p.aggregate_type = e.aggregate_type AND p.aggregate_id = e.aggregate_id
+					  AND p.published_at IS NULL AND p.sequence < e.sequence
+				  )
+				  ORDER BY e.sequence LIMIT $3
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING `+outboxEventColumns, at, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease outbox events: %v", err)
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to lease outbox events: %v", err)
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Sequence < events[j].Sequence
+	})
+	return events, nil
+}
+
+// MarkEventPublished records that the broker accepted an event
+func (r *OutboxRepository) MarkEventPublished(id string, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE outbox_events SET attempts = attempts + 1, last_error = NULL, published_at = $2
+			  WHERE id = $1 AND published_at IS NULL`, id, at)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox event published: %v", err)
+	}
+	return nil
+}
+
+// RecordEventFailure counts a failed attempt to publish an event and schedules the next one
+func (r *OutboxRepository) RecordEventFailure(id, reason string, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(`UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3
+			  WHERE id = $1 AND published_at IS NULL`, id, reason, nextAttemptAt)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox event failure: %v", err)
+	}
+	return nil
+}
+
+// ReleaseEvents hands leased events back without an attempt, due at the given time
+func (r *OutboxRepository) ReleaseEvents(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := r.db.Exec(`UPDATE outbox_events SET next_attempt_at = $2
+			  WHERE id = ANY($1::uuid[]) AND published_at IS NULL`, pq.Array(ids), at)
+	if err != nil {
+		return fmt.Errorf("failed to release outbox events: %v", err)
+	}
+	return nil
+}
+
+// DeletePublishedEvents removes events published before the given time and
+// returns how many were removed
+func (r *OutboxRepository) DeletePublishedEvents(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox_events WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// insertOrderEventTx records an order event in the outbox within the
+// transaction of the change, so the event is published if and only if the
+// change is committed
+func insertOrderEventTx(tx *sql.Tx, eventType, orderID string, data interface{}, at time.Time) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode order event: %v", err)
+	}
+	event := models.OrderEvent{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		OrderID:    orderID,
+		OccurredAt: at.UTC(),
+		Data:       body,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode order event: %v", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO outbox_events (id, aggregate_type, aggregate_id, event_type, payload, next_attempt_at, created_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $6)`,
+		event.ID, models.OutboxAggregateOrder, orderID, eventType, string(payload), at)
+	if err != nil {
+		return fmt.Errorf("failed to record order event: %v", err)
+	}
+	return nil
+}
+
+func scanOutboxEvent(row rowScanner) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	var payload []byte
+	err := row.Scan(&event.ID, &event.Sequence, &event.AggregateType, &event.AggregateID, &event.EventType, &payload,
+		&event.Attempts, &event.LastError, &event.NextAttemptAt, &event.CreatedAt, &event.PublishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan outbox event: %v", err)
+	}
+	event.Payload = payload
+	return &event, nil
+}
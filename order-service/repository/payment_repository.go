@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrPaymentNotFound is returned when a payment attempt does not exist
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrDuplicateWebhookEvent is returned when a provider event was already recorded
+	ErrDuplicateWebhookEvent = errors.New("duplicate webhook event")
+)
+
+const paymentAttemptColumns = `id, order_id, provider, provider_payment_id, status, amount, captured_amount,
+			  refunded_amount, currency, payment_method, failure_reason, created_at, updated_at`
+
+type PaymentRepository struct {
+	db *sql.DB
+}
+
+// NewPaymentRepository creates a new instance of PaymentRepository
+func NewPaymentRepository() *PaymentRepository {
+	return &PaymentRepository{
+		db: database.DB,
+	}
+}
+
+// CreateAttempt stores a new payment attempt
+func (r *PaymentRepository) CreateAttempt(attempt *models.PaymentAttempt) error {
+	attempt.ID = uuid.New().String()
+	attempt.CreatedAt = time.Now()
+	attempt.UpdatedAt = attempt.CreatedAt
+
+	query := `INSERT INTO payment_attempts (id, order_id, provider, provider_payment_id, status, amount, currency, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := r.db.Exec(query, attempt.ID, attempt.OrderID, attempt.Provider, attempt.ProviderPaymentID,
+		attempt.Status, attempt.Amount, attempt.Currency, attempt.CreatedAt, attempt.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert payment attempt: %v", err)
+	}
+
+	return nil
+}
+
+// GetAttemptByID retrieves a payment attempt
+func (r *PaymentRepository) GetAttemptByID(id string) (*models.PaymentAttempt, error) {
+	row := r.db.QueryRow(`SELECT `+paymentAttemptColumns+` FROM payment_attempts WHERE id = $1`, id)
+	return scanPaymentAttempt(row)
+}
+
+// GetAttemptsByOrderID retrieves the payment attempts of an order, oldest first
+func (r *PaymentRepository) GetAttemptsByOrderID(orderID string) ([]models.PaymentAttempt, error) {
+	rows, err := r.db.Query(`SELECT `+paymentAttemptColumns+` FROM payment_attempts
+			  WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment attempts: %v", err)
+	}
+	defer rows.Close()
+
+	attempts := []models.PaymentAttempt{}
+	for rows.Next() {
+		attempt, err := scanPaymentAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, *attempt)
+	}
+
+	return attempts, rows.Err()
+}
+
+// WithLockedAttempt locks a payment attempt, passes it to fn and saves the
+// changes fn makes to its status, amounts, payment method and failure reason.
+// Operations on the same attempt are serialized, including the provider
+// calls made inside fn. If fn fails nothing is saved.
+func (r *PaymentRepository) WithLockedAttempt(id string, fn func(attempt *models.PaymentAttempt) error) (*models.PaymentAttempt, error) {
+	return r.withLockedAttempt(nil, `SELECT `+paymentAttemptColumns+` FROM payment_attempts WHERE id = $1 FOR UPDATE`, fn, id)
+}
+
+// WithLockedOrderAttempt is WithLockedAttempt that also passes fn the status
+// of the attempt's order. The order is locked against status changes first,
+// in the same transaction, so it cannot be cancelled or expired while fn runs.
+func (r *PaymentRepository) WithLockedOrderAttempt(id string, fn func(orderStatus string, attempt *models.PaymentAttempt) error) (*models.PaymentAttempt, error) {
+	var orderStatus string
+	lockOrder := func(tx *sql.Tx) error {
+		err := tx.QueryRow(`SELECT o.status FROM payment_attempts pa
+				  JOIN orders o ON o.id = pa.order_id
+				  WHERE pa.id = $1 FOR SHARE OF o`, id).Scan(&orderStatus)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return ErrPaymentNotFound
+			}
+			return fmt.Errorf("failed to lock order of payment attempt: %v", err)
+		}
+		return nil
+	}
+
+	return r.withLockedAttempt(lockOrder, `SELECT `+paymentAttemptColumns+` FROM payment_attempts WHERE id = $1 FOR UPDATE`,
+		func(attempt *models.PaymentAttempt) error {
+			return fn(orderStatus, attempt)
+		}, id)
+}
+
+// WithLockedAttemptForEvent is WithLockedAttempt for the attempt a provider
+// event is about, identified by its provider payment ID. The event is
+// recorded first in the same transaction, so concurrent deliveries of an
+// event wait for each other and all but one get ErrDuplicateWebhookEvent.
+// If fn fails the event is not recorded and can be delivered again.
+func (r *PaymentRepository) WithLockedAttemptForEvent(provider, eventID, eventType, providerPaymentID string, fn func(attempt *models.PaymentAttempt) error) (*models.PaymentAttempt, error) {
+	record := func(tx *sql.Tx) error {
+		result, err := tx.Exec(`INSERT INTO payment_webhook_events (provider, event_id, event_type) VALUES ($1, $2, $3)
+				  ON CONFLICT (provider, event_id) DO NOTHING`, provider, eventID, eventType)
+		if err != nil {
+			return fmt.Errorf("failed to record webhook event: %v", err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to get rows affected: %v", err)
+		}
+		if inserted == 0 {
+			return ErrDuplicateWebhookEvent
+		}
+		return nil
+	}
+
+	return r.withLockedAttempt(record, `SELECT `+paymentAttemptColumns+` FROM payment_attempts
+			  WHERE provider = $1 AND provider_payment_id = $2 FOR UPDATE`, fn, provider, providerPaymentID)
+}
+
+// withLockedAttempt runs before, if given, ahead of locking the attempt in the same transaction
+func (r *PaymentRepository) withLockedAttempt(before func(tx *sql.Tx) error, query string, fn func(attempt *models.PaymentAttempt) error, args ...interface{}) (*models.PaymentAttempt, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if before != nil {
+		if err := before(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	attempt, err := scanPaymentAttempt(tx.QueryRow(query, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(attempt); err != nil {
+		return nil, err
+	}
+
+	attempt.UpdatedAt = time.Now()
+	_, err = tx.Exec(`UPDATE payment_attempts
+			  SET status = $1, captured_amount = $2, refunded_amount = $3, payment_method = $4, failure_reason = $5, updated_at = $6
+			  WHERE id = $7`,
+		attempt.Status, attempt.CapturedAmount, attempt.RefundedAmount, attempt.PaymentMethod,
+		attempt.FailureReason, attempt.UpdatedAt, attempt.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment attempt: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment attempt: %v", err)
+	}
+
+	return attempt, nil
+}
+
+// ForgetWebhookEvent removes the record of a provider event, so a redelivery
+// is applied again after processing it failed part way
+func (r *PaymentRepository) ForgetWebhookEvent(provider, eventID string) error {
+	_, err := r.db.Exec(`DELETE FROM payment_webhook_events WHERE provider = $1 AND event_id = $2`, provider, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to forget webhook event: %v", err)
+	}
+	return nil
+}
+
+// failOpenPaymentAttemptsTx fails the attempts of an order that were never
+// authorized, so they cannot be completed once the order is cancelled
+func failOpenPaymentAttemptsTx(tx *sql.Tx, orderID, reason string, at time.Time) error {
+	_, err := tx.Exec(`UPDATE payment_attempts SET status = $3, failure_reason = $4, updated_at = $5
+			  WHERE order_id = $1 AND status = $2`,
+		orderID, models.PaymentStatusRequiresAuthorization, models.PaymentStatusFailed, reason, at)
+	if err != nil {
+		return fmt.Errorf("failed to close payment attempts: %v", err)
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPaymentAttempt(row rowScanner) (*models.PaymentAttempt, error) {
+	attempt := &models.PaymentAttempt{}
+	err := row.Scan(
+		&attempt.ID, &attempt.OrderID, &attempt.Provider, &attempt.ProviderPaymentID, &attempt.Status,
+		&attempt.Amount, &attempt.CapturedAmount, &attempt.RefundedAmount, &attempt.Currency,
+		&attempt.PaymentMethod, &attempt.FailureReason, &attempt.CreatedAt, &attempt.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("failed to scan payment attempt: %v", err)
+	}
+	return attempt, nil
+}
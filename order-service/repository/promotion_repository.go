@@ -0,0 +1,355 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrPromotionNotFound is returned when a promotion does not exist
+	ErrPromotionNotFound = errors.New("promotion not found")
+	// ErrPromotionCodeTaken is returned when another promotion already uses a coupon code
+	ErrPromotionCodeTaken = errors.New("promotion code already in use")
+	// ErrPromotionUnavailable is returned when a promotion ran out of uses or
+	// was deactivated between pricing an order and storing it
+	ErrPromotionUnavailable = errors.New("promotion no longer available")
+)
+
+// uniqueViolation is the Postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+const promotionColumns = `id, name, code, type, value, buy_quantity, get_quantity, min_spend, game_ids, categories,
+			  starts_at, ends_at, usage_limit, per_customer_limit, usage_count, stackable, priority, active,
+			  created_at, updated_at`
+
+type PromotionRepository struct {
+	db *sql.DB
+}
+
+// NewPromotionRepository creates a new instance of PromotionRepository
+func NewPromotionRepository() *PromotionRepository {
+	return &PromotionRepository{
+		db: database.DB,
+	}
+}
+
+// CreatePromotion stores a new promotion
+func (r *PromotionRepository) CreatePromotion(promotion *models.Promotion) error {
+	promotion.ID = uuid.New().String()
+	promotion.UsageCount = 0
+	promotion.CreatedAt = time.Now()
+	promotion.UpdatedAt = promotion.CreatedAt
+
+	_, err := r.db.Exec(`INSERT INTO promotions (id, name, code, type, value, buy_quantity, get_quantity, min_spend,
+			  game_ids, categories, starts_at, ends_at, usage_limit, per_customer_limit, stackable, priority, active,
+			  created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		promotion.ID, promotion.Name, promotion.Code, promotion.Type, promotion.Value,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.MinSpend,
+		pq.Array(toInt64s(promotion.GameIDs)), pq.Array(promotion.Categories),
+		promotion.StartsAt, promotion.EndsAt, promotion.UsageLimit, promotion.PerCustomerLimit,
+		promotion.Stackable, promotion.Priority, promotion.Active, promotion.CreatedAt, promotion.UpdatedAt)
+	if err != nil {
+		return promotionWriteError("create", err)
+	}
+
+	return nil
+}
+
+// UpdatePromotion replaces the rules of a promotion. The usage count is kept.
+func (r *PromotionRepository) UpdatePromotion(promotion *models.Promotion) error {
+	promotion.UpdatedAt = time.Now()
+
+	err := r.db.QueryRow(`UPDATE promotions SET name = $2, code = $3, type = $4, value = $5, buy_quantity = $6,
+			  get_quantity = $7, min_spend = $8, game_ids = $9, categories = $10, starts_at = $11, ends_at = $12,
+			  usage_limit = $13, per_customer_limit = $14, stackable = $15, priority = $16, active = $17, updated_at = $18
+			  WHERE id = $1
+			  RETURNING usage_count, created_at`,
+		promotion.ID, promotion.Name, promotion.Code, promotion.Type, promotion.Value,
+		promotion.BuyQuantity, promotion.GetQuantity, promotion.MinSpend,
+		pq.Array(toInt64s(promotion.GameIDs)), pq.Array(promotion.Categories),
+		promotion.StartsAt, promotion.EndsAt, promotion.UsageLimit, promotion.PerCustomerLimit,
+		promotion.Stackable, promotion.Priority, promotion.Active, promotion.UpdatedAt).
+		Scan(&promotion.UsageCount, &promotion.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrPromotionNotFound
+		}
+		return promotionWriteError("update", err)
+	}
+
+	return nil
+}
+
+// GetPromotionByID retrieves a promotion
+func (r *PromotionRepository) GetPromotionByID(id string) (*models.Promotion, error) {
+	return scanPromotion(r.db.QueryRow(`SELECT `+promotionColumns+` FROM promotions WHERE id = $1`, id))
+}
+
+// GetPromotions retrieves every promotion, highest priority first
+func (r *PromotionRepository) GetPromotions() ([]models.Promotion, error) {
+	return r.queryPromotions(`SELECT ` + promotionColumns + ` FROM promotions ORDER BY priority DESC, created_at, id`)
+}
+
+// GetPromotionsByCodes retrieves the promotions with the given coupon codes, whatever their state
+func (r *PromotionRepository) GetPromotionsByCodes(codes []string) ([]models.Promotion, error) {
+	return r.queryPromotions(`SELECT `+promotionColumns+` FROM promotions WHERE code = ANY($1)`, pq.Array(codes))
+}
+
+// GetAutomaticPromotions retrieves the promotions without a code that are
+// active at the given time and have uses left
+func (r *PromotionRepository) GetAutomaticPromotions(at time.Time) ([]models.Promotion, error) {
+	return r.queryPromotions(`SELECT `+promotionColumns+` FROM promotions
+			  WHERE code IS NULL AND active
+			  AND (starts_at IS NULL OR starts_at <= $1) AND (ends_at IS NULL OR ends_at > $1)
+			  AND (usage_limit IS NULL OR usage_count < usage_limit)
+			  ORDER BY priority DESC, created_at, id`, at)
+}
+
+// CountCustomerRedemptions counts, per promotion, the orders of a customer that used it.
+// Cancelled orders do not count.
+func (r *PromotionRepository) CountCustomerRedemptions(customerID string, promotionIDs []string) (map[string]int, error) {
+	counts := make(map[string]int, len(promotionIDs))
+	if customerID == "" || len(promotionIDs) == 0 {
+		return counts, nil
+	}
+
+	rows, err := r.db.Query(`SELECT d.promotion_id, COUNT(*)
+			  FROM order_discounts d
+			  JOIN orders o ON o.id = d.order_id
+			  WHERE o.customer_id = $1 AND d.promotion_id = ANY($2) AND o.status <> $3
+			  GROUP BY d.promotion_id`, customerID, pq.Array(promotionIDs), models.OrderStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count promotion redemptions: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan promotion redemptions: %v", err)
+		}
+		counts[id] = count
+	}
+
+	return counts, rows.Err()
+}
+
+func (r *PromotionRepository) queryPromotions(query string, args ...interface{}) ([]models.Promotion, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query promotions: %v", err)
+	}
+	defer rows.Close()
+
+	promotions := []models.Promotion{}
+	for rows.Next() {
+		promotion, err := scanPromotion(rows)
+		if err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, *promotion)
+	}
+
+	return promotions, rows.Err()
+}
+
+// redeemPromotionsTx records the discounts of a new order and counts them
+// against the promotions' usage limits. The usage count is incremented only
+// while it is below the global limit, and the row lock taken by that update
+// serializes concurrent orders using the same promotion, so the per-customer
+// count read afterwards cannot be raced either.
+func redeemPromotionsTx(tx *sql.Tx, order *models.Order) error {
+	for i := range order.Discounts {
+		discount := &order.Discounts[i]
+
+		var perCustomerLimit sql.NullInt64
+		err := tx.QueryRow(`UPDATE promotions SET usage_count = usage_count + 1
+				  WHERE id = $1 AND active AND (usage_limit IS NULL OR usage_count < usage_limit)
+				  RETURNING per_customer_limit`, discount.PromotionID).Scan(&perCustomerLimit)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("%w: %s has been used up or deactivated", ErrPromotionUnavailable, discount.Description)
+			}
+			return fmt.Errorf("failed to redeem promotion: %v", err)
+		}
+
+		if perCustomerLimit.Valid {
+			var used int64
+			err := tx.QueryRow(`SELECT COUNT(*) FROM order_discounts d
+					  JOIN orders o ON o.id = d.order_id
+					  WHERE d.promotion_id = $1 AND o.customer_id = $2 AND o.status <> $3`,
+				discount.PromotionID, order.CustomerID, models.OrderStatusCancelled).Scan(&used)
+			if err != nil {
+				return fmt.Errorf("failed to count promotion redemptions: %v", err)
+			}
+			if used >= perCustomerLimit.Int64 {
+				return fmt.Errorf("%w: %s can be used %d time(s) per customer", ErrPromotionUnavailable, discount.Description, perCustomerLimit.Int64)
+			}
+		}
+
+		discount.ID = uuid.New().String()
+		discount.OrderID = order.ID
+		_, err = tx.Exec(`INSERT INTO order_discounts (id, order_id, promotion_id, code, description, amount)
+				  VALUES ($1, $2, $3, $4, $5, $6)`,
+			discount.ID, discount.OrderID, discount.PromotionID, discount.Code, discount.Description, discount.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to insert order discount: %v", err)
+		}
+	}
+
+	for _, item := range order.Items {
+		for _, discount := range item.Discounts {
+			_, err := tx.Exec(`INSERT INTO order_item_discounts (order_item_id, promotion_id, amount) VALUES ($1, $2, $3)`,
+				item.ID, discount.PromotionID, discount.Amount)
+			if err != nil {
+				return fmt.Errorf("failed to insert order item discount: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// releasePromotionsTx gives the uses of a cancelled order back to its
+// promotions. Its discounts stay recorded, but cancelled orders no longer
+// count against per-customer limits either.
+func releasePromotionsTx(tx *sql.Tx, orderID string, at time.Time) error {
+	_, err := tx.Exec(`UPDATE promotions p SET usage_count = GREATEST(p.usage_count - d.uses, 0), updated_at = $2
+			  FROM (SELECT promotion_id, COUNT(*) AS uses FROM order_discounts
+				    WHERE order_id = $1 GROUP BY promotion_id) d
+			  WHERE p.id = d.promotion_id`, orderID, at)
+	if err != nil {
+		return fmt.Errorf("failed to release promotions: %v", err)
+	}
+	return nil
+}
+
+// loadOrderDiscounts attaches the discount breakdown to an order and its items
+func loadOrderDiscounts(q queryer, order *models.Order) error {
+	return loadOrdersDiscounts(q, []*models.Order{order})
+}
+
+// loadOrdersDiscounts attaches the discount breakdown to several orders and
+// their items with one query for each level
+func loadOrdersDiscounts(q queryer, orders []*models.Order) error {
+	ids := make([]string, len(orders))
+	orderIndex := make(map[string]int, len(orders))
+	for i, order := range orders {
+		ids[i] = order.ID
+		orderIndex[order.ID] = i
+	}
+
+	rows, err := q.Query(`SELECT id, order_id, promotion_id, code, description, amount
+			  FROM order_discounts WHERE order_id = ANY($1::uuid[]) ORDER BY order_id, amount DESC, id`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query order discounts: %v", err)
+	}
+	defer rows.Close()
+
+	discounted := 0
+	for rows.Next() {
+		var discount models.OrderDiscount
+		err := rows.Scan(&discount.ID, &discount.OrderID, &discount.PromotionID, &discount.Code,
+			&discount.Description, &discount.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to scan order discount: %v", err)
+		}
+		if i, ok := orderIndex[discount.OrderID]; ok {
+			orders[i].Discounts = append(orders[i].Discounts, discount)
+			discounted++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if discounted == 0 {
+		return nil
+	}
+
+	itemRows, err := q.Query(`SELECT d.order_item_id, d.promotion_id, d.amount
+			  FROM order_item_discounts d
+			  JOIN order_items oi ON oi.id = d.order_item_id
+			  WHERE oi.order_id = ANY($1::uuid[]) ORDER BY d.order_item_id, d.amount DESC`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to query order item discounts: %v", err)
+	}
+	defer itemRows.Close()
+
+	type itemRef struct{ order, item int }
+	itemIndex := make(map[string]itemRef)
+	for i, order := range orders {
+		for j, item := range order.Items {
+			itemIndex[item.ID] = itemRef{i, j}
+		}
+	}
+	for itemRows.Next() {
+		var itemID string
+		var discount models.OrderItemDiscount
+		if err := itemRows.Scan(&itemID, &discount.PromotionID, &discount.Amount); err != nil {
+			return fmt.Errorf("failed to scan order item discount: %v", err)
+		}
+		if ref, ok := itemIndex[itemID]; ok {
+			item := &orders[ref.order].Items[ref.item]
+			item.Discounts = append(item.Discounts, discount)
+		}
+	}
+
+	return itemRows.Err()
+}
+
+// promotionWriteError reports a duplicate coupon code as ErrPromotionCodeTaken
+func promotionWriteError(action string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return ErrPromotionCodeTaken
+	}
+	return fmt.Errorf("failed to %s promotion: %v", action, err)
+}
+
+func scanPromotion(row rowScanner) (*models.Promotion, error) {
+	promotion := &models.Promotion{}
+	var gameIDs pq.Int64Array
+	var categories pq.StringArray
+	err := row.Scan(
+		&promotion.ID, &promotion.Name, &promotion.Code, &promotion.Type, &promotion.Value,
+		&promotion.BuyQuantity, &promotion.GetQuantity, &promotion.MinSpend, &gameIDs, &categories,
+		&promotion.StartsAt, &promotion.EndsAt, &promotion.UsageLimit, &promotion.PerCustomerLimit,
+		&promotion.UsageCount, &promotion.Stackable, &promotion.Priority, &promotion.Active,
+		&promotion.CreatedAt, &promotion.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrPromotionNotFound
+		}
+		return nil, fmt.Errorf("failed to scan promotion: %v", err)
+	}
+
+	promotion.GameIDs = make([]int, len(gameIDs))
+	for i, id := range gameIDs {
+		promotion.GameIDs[i] = int(id)
+	}
+	promotion.Categories = []string(categories)
+	if promotion.Categories == nil {
+		promotion.Categories = []string{}
+	}
+
+	return promotion, nil
+}
+
+func toInt64s(values []int) []int64 {
+	converted := make([]int64, len(values))
+	for i, value := range values {
+		converted[i] = int64(value)
+	}
+	return converted
+}
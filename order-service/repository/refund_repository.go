@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+	"order-service/money"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrRefundNotFound is returned when a refund does not exist
+	ErrRefundNotFound = errors.New("refund not found")
+	// ErrRefundStatusConflict is returned when a refund is not in a status that allows the change
+	ErrRefundStatusConflict = errors.New("refund status conflict")
+)
+
+// committedRefundStatuses are the refund statuses that hold on to part of an
+// order's refundable amount; only rejected refunds release it
+var committedRefundStatuses = []string{
+	models.RefundStatusRequested,
+	models.RefundStatusApproved,
+	models.RefundStatusCompleted,
+	models.RefundStatusFailed,
+}
+
+const refundColumns = `id, order_id, status, amount, reason, requested_by, reviewed_by, review_note,
+			  payment_attempt_id, failure_reason, created_at, updated_at, completed_at`
+
+type RefundRepository struct {
+	db *sql.DB
+}
+
+// NewRefundRepository creates a new instance of RefundRepository
+func NewRefundRepository() *RefundRepository {
+	return &RefundRepository{
+		db: database.DB,
+	}
+}
+
+// CreateRefund stores a refund request for an order. The order is locked
+// while build turns it, together with what is already committed to other
+// refunds, into the refund to store, so concurrent requests cannot refund
+// the same money twice.
+func (r *RefundRepository) CreateRefund(orderID string, build func(order *models.Order, committed *models.RefundCommitments) (*models.Refund, error)) (*models.Refund, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	order := &models.Order{ID: orderID}
+	err = tx.QueryRow(`SELECT customer_id, total_price, prices_include_tax, refunded_amount, status FROM orders WHERE id = $1 FOR UPDATE`, orderID).
+		Scan(&order.CustomerID, &order.TotalPrice, &order.PricesIncludeTax, &order.RefundedAmount, &order.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, fmt.Errorf("failed to lock order: %v", err)
+	}
+
+	order.Items, err = queryOrderItems(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	committed, err := getRefundCommitments(tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	refund, err := build(order, committed)
+	if err != nil {
+		return nil, err
+	}
+
+	refund.ID = uuid.New().String()
+	refund.OrderID = orderID
+	refund.Status = models.RefundStatusRequested
+	refund.CreatedAt = time.Now()
+	refund.UpdatedAt = refund.CreatedAt
+
+	_, err = tx.Exec(`INSERT INTO refunds (id, order_id, status, amount, reason, requested_by, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		refund.ID, refund.OrderID, refund.Status, refund.Amount, refund.Reason, refund.RequestedBy,
+		refund.CreatedAt, refund.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert refund: %v", err)
+	}
+
+	for i := range refund.Items {
+		refund.Items[i].ID = uuid.New().String()
+		refund.Items[i].RefundID = refund.ID
+
+		_, err = tx.Exec(`INSERT INTO refund_items (id, refund_id, order_item_id, quantity, amount) VALUES ($1, $2, $3, $4, $5)`,
+			refund.Items[i].ID, refund.ID, refund.Items[i].OrderItemID, refund.Items[i].Quantity, refund.Items[i].Amount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert refund item: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %v", err)
+	}
+
+	return refund, nil
+}
+
+// GetRefundByID retrieves a refund with its items
+func (r *RefundRepository) GetRefundByID(id string) (*models.Refund, error) {
+	refund, err := scanRefund(r.db.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.getRefundItems([]string{refund.ID})
+	if err != nil {
+		return nil, err
+	}
+	refund.Items = items[refund.ID]
+
+	return refund, nil
+}
+
+// GetRefundsByOrderID retrieves the refunds of an order with their items, oldest first
+func (r *RefundRepository) GetRefundsByOrderID(orderID string) ([]models.Refund, error) {
+	rows, err := r.db.Query(`SELECT `+refundColumns+` FROM refunds WHERE order_id = $1 ORDER BY created_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refunds: %v", err)
+	}
+	defer rows.Close()
+
+	refunds := []models.Refund{}
+	var ids []string
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+		ids = append(ids, refund.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read refunds: %v", err)
+	}
+
+	items, err := r.getRefundItems(ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range refunds {
+		refunds[i].Items = items[refunds[i].ID]
+	}
+
+	return refunds, nil
+}
+
+// ReviewRefund moves a refund that is in one of the from statuses to a new
+// status and records who reviewed it
+func (r *RefundRepository) ReviewRefund(id string, from []string, to, actor, note string) (*models.Refund, error) {
+	result, err := r.db.Exec(`UPDATE refunds SET status = $1, reviewed_by = $2, review_note = $3, failure_reason = NULL, updated_at = $4
+			  WHERE id = $5 AND status = ANY($6)`,
+		to, actor, note, time.Now(), id, pq.Array(from))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update refund: %v", err)
+	}
+
+	if err := r.requireRefundUpdated(result, id); err != nil {
+		return nil, err
+	}
+
+	return r.GetRefundByID(id)
+}
+
+// CompleteRefund marks an approved refund as completed, links it to the
+// payment attempt it was paid back through, if any, adds its amount to the
+// order's refunded amount, revokes the gifts it covers and queues an email
+// to the customer and an order.refunded event to webhook subscribers
+func (r *RefundRepository) CompleteRefund(id string, paymentAttemptID *string) (*models.Refund, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	var orderID string
+	var amount money.Amount
+	err = tx.QueryRow(`UPDATE refunds SET status = $1, payment_attempt_id = $2, completed_at = $3, updated_at = $3
+			  WHERE id = $4 AND status = $5 RETURNING order_id, amount`,
+		models.RefundStatusCompleted, paymentAttemptID, now, id, models.RefundStatusApproved).Scan(&orderID, &amount)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: refund %s is not approved", ErrRefundStatusConflict, id)
+		}
+		return nil, fmt.Errorf("failed to complete refund: %v", err)
+	}
+
+	event := models.OrderRefundedData{OrderID: orderID, RefundID: id, Amount: amount}
+	err = tx.QueryRow(`UPDATE orders SET refunded_amount = refunded_amount + $1, updated_at = $2 WHERE id = $3
+			  RETURNING refunded_amount, currency`,
+		amount, now, orderID).Scan(&event.RefundedAmount, &event.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update order refunded amount: %v", err)
+	}
+
+	if err := revokeGiftsTx(tx, orderID, id, now); err != nil {
+		return nil, err
+	}
+	if err := enqueueNotificationTx(tx, orderID, models.NotificationOrderRefunded, id, now); err != nil {
+		return nil, err
+	}
+	if err := enqueueWebhookEventTx(tx, models.WebhookEventOrderRefunded, event, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %v", err)
+	}
+
+	return r.GetRefundByID(id)
+}
+
+// FailRefund marks an approved refund as failed with the reason given by the payment provider
+func (r *RefundRepository) FailRefund(id, reason string) (*models.Refund, error) {
+	result, err := r.db.Exec(`UPDATE refunds SET status = $1, failure_reason = $2, updated_at = $3 WHERE id = $4 AND status = $5`,
+		models.RefundStatusFailed, reason, time.Now(), id, models.RefundStatusApproved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update refund: %v", err)
+	}
+
+	if err := r.requireRefundUpdated(result, id); err != nil {
+		return nil, err
+	}
+
+	return r.GetRefundByID(id)
+}
+
+// requireRefundUpdated tells a missing refund apart from one in the wrong status
+func (r *RefundRepository) requireRefundUpdated(result sql.Result, id string) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var status string
+	err = r.db.QueryRow(`SELECT status FROM refunds WHERE id = $1`, id).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrRefundNotFound
+		}
+		return fmt.Errorf("failed to get refund status: %v", err)
+	}
+	return fmt.Errorf("%w: refund is %s", ErrRefundStatusConflict, status)
+}
+
+// getRefundItems retrieves the items of the given refunds keyed by refund ID
+func (r *RefundRepository) getRefundItems(refundIDs []string) (map[string][]models.RefundItem, error) {
+	items := make(map[string][]models.RefundItem, len(refundIDs))
+	for _, id := range refundIDs {
+		items[id] = []models.RefundItem{}
+	}
+	if len(refundIDs) == 0 {
+		return items, nil
+	}
+
+	rows, err := r.db.Query(`SELECT ri.id, ri.refund_id, ri.order_item_id, oi.game_id, ri.quantity, ri.amount
+			  FROM refund_items ri
+			  JOIN order_items oi ON oi.id = ri.order_item_id
+			  WHERE ri.refund_id = ANY($1)
+			  ORDER BY ri.refund_id, oi.id`, pq.Array(refundIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query refund items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.RefundItem
+		if err := rows.Scan(&item.ID, &item.RefundID, &item.OrderItemID, &item.GameID, &item.Quantity, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan refund item: %v", err)
+		}
+		items[item.RefundID] = append(items[item.RefundID], item)
+	}
+
+	return items, rows.Err()
+}
+
+// getRefundCommitments sums what other refunds already hold on an order
+func getRefundCommitments(tx *sql.Tx, orderID string) (*models.RefundCommitments, error) {
+	committed := &models.RefundCommitments{
+		ItemQuantities: make(map[string]int),
+		ItemAmounts:    make(map[string]money.Amount),
+	}
+
+	err := tx.QueryRow(`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE order_id = $1 AND status = ANY($2)`,
+		orderID, pq.Array(committedRefundStatuses)).Scan(&committed.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum refunds: %v", err)
+	}
+
+	rows, err := tx.Query(`SELECT ri.order_item_id, SUM(ri.quantity), SUM(ri.amount)
+			  FROM refund_items ri
+			  JOIN refunds rf ON rf.id = ri.refund_id
+			  WHERE rf.order_id = $1 AND rf.status = ANY($2)
+			  GROUP BY ri.order_item_id`, orderID, pq.Array(committedRefundStatuses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum refund items: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemID string
+		var quantity int
+		var amount money.Amount
+		if err := rows.Scan(&itemID, &quantity, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan refund item totals: %v", err)
+		}
+		committed.ItemQuantities[itemID] = quantity
+		committed.ItemAmounts[itemID] = amount
+	}
+
+	return committed, rows.Err()
+}
+
+func scanRefund(row rowScanner) (*models.Refund, error) {
+	refund := &models.Refund{}
+	err := row.Scan(
+		&refund.ID, &refund.OrderID, &refund.Status, &refund.Amount, &refund.Reason, &refund.RequestedBy,
+		&refund.ReviewedBy, &refund.ReviewNote, &refund.PaymentAttemptID, &refund.FailureReason,
+		&refund.CreatedAt, &refund.UpdatedAt, &refund.CompletedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrRefundNotFound
+		}
+		return nil, fmt.Errorf("failed to scan refund: %v", err)
+	}
+	return refund, nil
+}
@@ -0,0 +1,341 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"order-service/database"
+	"order-service/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrWebhookSubscriptionNotFound is returned when a webhook subscription does not exist
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	// ErrWebhookDeliveryNotFound is returned when a webhook delivery does not exist
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrWebhookSubscriptionInactive is returned when redelivering to a deactivated subscription
+	ErrWebhookSubscriptionInactive = errors.New("webhook subscription is inactive")
+)
+
+const webhookSubscriptionColumns = `id, url, event_types, description, active, secret, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, last_error,
+			  last_response_status, next_attempt_at, created_at, updated_at, delivered_at`
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+// NewWebhookRepository creates a new instance of WebhookRepository
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{
+		db: database.DB,
+	}
+}
+
+// CreateSubscription stores a new webhook subscription
+func (r *WebhookRepository) CreateSubscription(subscription *models.WebhookSubscription) error {
+	subscription.ID = uuid.New().String()
+	subscription.CreatedAt = time.Now()
+	subscription.UpdatedAt = subscription.CreatedAt
+
+	_, err := r.db.Exec(`INSERT INTO webhook_subscriptions (id, url, event_types, description, active, secret, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		subscription.ID, subscription.URL, pq.Array(subscription.EventTypes), subscription.Description,
+		subscription.Active, subscription.Secret, subscription.CreatedAt, subscription.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %v", err)
+	}
+	return nil
+}
+
+// UpdateSubscription replaces a webhook subscription. An empty secret keeps
+// the current one. Deliveries already queued keep their payload but go to
+// the new URL.
+func (r *WebhookRepository) UpdateSubscription(subscription *models.WebhookSubscription) error {
+	subscription.UpdatedAt = time.Now()
+
+	err := r.db.QueryRow(`UPDATE webhook_subscriptions SET url = $2, event_types = $3, description = $4, active = $5,
+			  secret = COALESCE(NULLIF($6, ''), secret), updated_at = $7
+			  WHERE id = $1
+			  RETURNING created_at`,
+		subscription.ID, subscription.URL, pq.Array(subscription.EventTypes), subscription.Description,
+		subscription.Active, subscription.Secret, subscription.UpdatedAt).Scan(&subscription.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWebhookSubscriptionNotFound
+		}
+		return fmt.Errorf("failed to update webhook subscription: %v", err)
+	}
+	return nil
+}
+
+// GetSubscription retrieves a webhook subscription
+func (r *WebhookRepository) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	row := r.db.QueryRow(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id)
+	return scanWebhookSubscription(row)
+}
+
+// GetSubscriptions retrieves every webhook subscription, oldest first
+func (r *WebhookRepository) GetSubscriptions() ([]models.WebhookSubscription, error) {
+	rows, err := r.db.Query(`SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY created_at, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	subscriptions := []models.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// DeleteSubscription deletes a webhook subscription along with its deliveries
+func (r *WebhookRepository) DeleteSubscription(id string) error {
+	result, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrWebhookSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves the latest deliveries of a subscription, newest
+// first, optionally only those in one status
+func (r *WebhookRepository) GetDeliveries(subscriptionID, status string, limit int) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries
+			  WHERE subscription_id = $1 AND ($2 = '' OR status = $2)
+			  ORDER BY created_at DESC, id DESC LIMIT $3`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetDelivery retrieves a webhook delivery with the log of its attempts, oldest first
+func (r *WebhookRepository) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = $1`, id))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT id, delivery_id, url, attempted_at, response_status, error, duration_ms
+			  FROM webhook_delivery_attempts WHERE delivery_id = $1 ORDER BY attempted_at, id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook delivery attempts: %v", err)
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []models.WebhookDeliveryAttempt{}
+	for rows.Next() {
+		var attempt models.WebhookDeliveryAttempt
+		err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.URL, &attempt.AttemptedAt, &attempt.ResponseStatus,
+			&attempt.Error, &attempt.DurationMS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery attempt: %v", err)
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+
+	return delivery, rows.Err()
+}
+
+// LeaseDueDeliveries picks up to limit pending deliveries of active
+// subscriptions that are due and pushes their next attempt back to
+// leaseUntil, like LeaseDueFulfillments. Each comes with the URL and secret
+// of its subscription.
+func (r *WebhookRepository) LeaseDueDeliveries(at, leaseUntil time.Time, limit int) ([]models.DueWebhookDelivery, error) {
+	rows, err := r.db.Query(`UPDATE webhook_deliveries d SET next_attempt_at = $3, updated_at = $2
+			  FROM webhook_subscriptions s
+			  WHERE s.id = d.subscription_id AND d.id IN (
+				  SELECT d2.id FROM webhook_deliveries d2
+				  JOIN webhook_subscriptions s2 ON s2.id = d2.subscription_id
+				  WHERE d2.status = $1 AND d2.next_attempt_at <= $2 AND s2.active
+				  ORDER BY d2.next_attempt_at LIMIT $4
+				  FOR UPDATE OF d2 SKIP LOCKED
+			  )
+			  RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.last_error,
+			  d.last_response_status, d.next_attempt_at, d.created_at, d.updated_at, d.delivered_at, s.url, s.secret`,
+		models.WebhookDeliveryStatusPending, at, leaseUntil, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease webhook deliveries: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.DueWebhookDelivery
+	for rows.Next() {
+		var due models.DueWebhookDelivery
+		if err := scanWebhookDeliveryInto(rows, &due.WebhookDelivery, &due.URL, &due.Secret); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, due)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordDeliveryAttempt logs an attempt of a pending delivery and moves the
+// delivery to status: pending again to be retried at nextAttemptAt, delivered
+// or dead
+func (r *WebhookRepository) RecordDeliveryAttempt(attempt *models.WebhookDeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	attempt.ID = uuid.New().String()
+	_, err = tx.Exec(`INSERT INTO webhook_delivery_attempts (id, delivery_id, url, attempted_at, response_status, error, duration_ms)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		attempt.ID, attempt.DeliveryID, attempt.URL, attempt.AttemptedAt, attempt.ResponseStatus,
+		attempt.Error, attempt.DurationMS)
+	if err != nil {
+		return fmt.Errorf("failed to log webhook delivery attempt: %v", err)
+	}
+
+	now := time.Now()
+	var deliveredAt *time.Time
+	if status == models.WebhookDeliveryStatusDelivered {
+		deliveredAt = &now
+	}
+	_, err = tx.Exec(`UPDATE webhook_deliveries SET status = $3, attempts = attempts + 1, last_error = $4,
+			  last_response_status = $5, next_attempt_at = $6, delivered_at = $7, updated_at = $8
+			  WHERE id = $1 AND status = $2`,
+		attempt.DeliveryID, models.WebhookDeliveryStatusPending, status, attempt.Error, attempt.ResponseStatus,
+		nextAttemptAt, deliveredAt, now)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook delivery attempt: %v", err)
+	}
+	return nil
+}
+
+// Redeliver makes a delivery pending and due again with a fresh set of
+// attempts, whatever its status. Its payload is sent unchanged.
+func (r *WebhookRepository) Redeliver(id string, at time.Time) (*models.WebhookDelivery, error) {
+	row := r.db.QueryRow(`UPDATE webhook_deliveries d SET status = $2, attempts = 0, next_attempt_at = $3, updated_at = $3
+			  FROM webhook_subscriptions s
+			  WHERE d.id = $1 AND s.id = d.subscription_id AND s.active
+			  RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.last_error,
+			  d.last_response_status, d.next_attempt_at, d.created_at, d.updated_at, d.delivered_at`,
+		id, models.WebhookDeliveryStatusPending, at)
+	delivery, err := scanWebhookDelivery(row)
+	if err != ErrWebhookDeliveryNotFound {
+		return delivery, err
+	}
+
+	// Tell a missing delivery apart from one of an inactive subscription
+	var exists bool
+	err = r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %v", err)
+	}
+	if exists {
+		return nil, ErrWebhookSubscriptionInactive
+	}
+	return nil, ErrWebhookDeliveryNotFound
+}
+
+// enqueueWebhookEventTx queues an event for every active subscription to its
+// type, within the transaction that caused it, so partners hear about every
+// committed change and nothing else
+func enqueueWebhookEventTx(tx *sql.Tx, eventType string, data interface{}, at time.Time) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %v", err)
+	}
+	event := models.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: at.UTC(),
+		Data:      body,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook event: %v", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status,
+				  next_attempt_at, created_at, updated_at)
+			  SELECT id, $1::uuid, $2::text, $3::text, $4::text, $5::timestamp, $5::timestamp, $5::timestamp
+			  FROM webhook_subscriptions
+			  WHERE active AND ($2::text = ANY(event_types) OR $6::text = ANY(event_types))`,
+		event.ID, event.Type, string(payload), models.WebhookDeliveryStatusPending, at, models.WebhookEventAll)
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %v", err)
+	}
+	return nil
+}
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	subscription := &models.WebhookSubscription{}
+	err := row.Scan(&subscription.ID, &subscription.URL, pq.Array(&subscription.EventTypes), &subscription.Description,
+		&subscription.Active, &subscription.Secret, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to scan webhook subscription: %v", err)
+	}
+	return subscription, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	if err := scanWebhookDeliveryInto(row, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// scanWebhookDeliveryInto scans the delivery columns followed by any extra columns
+func scanWebhookDeliveryInto(row rowScanner, delivery *models.WebhookDelivery, extra ...interface{}) error {
+	var payload []byte
+	dest := append([]interface{}{
+		&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.LastError, &delivery.LastResponseStatus, &delivery.NextAttemptAt,
+		&delivery.CreatedAt, &delivery.UpdatedAt, &delivery.DeliveredAt,
+	}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrWebhookDeliveryNotFound
+		}
+		return fmt.Errorf("failed to scan webhook delivery: %v", err)
+	}
+	delivery.Payload = payload
+	return nil
+}
@@ -14,7 +14,8 @@ func SetupRoutes() *gin.Engine {
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Customer-ID, X-Cart-Token, Idempotency-Key")
+		c.Header("Access-Control-Expose-Headers", "X-Cart-Token, Idempotent-Replayed, X-Invoice-Number, Content-Disposition")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -26,6 +27,15 @@ func SetupRoutes() *gin.Engine {
 
 	// Initialize handlers
 	orderHandler := handlers.NewOrderHandler()
+	cartHandler := handlers.NewCartHandler()
+	paymentHandler := handlers.NewPaymentHandler()
+	refundHandler := handlers.NewRefundHandler()
+	promotionHandler := handlers.NewPromotionHandler()
+	invoiceHandler := handlers.NewInvoiceHandler()
+	fulfillmentHandler := handlers.NewFulfillmentHandler()
+	notificationHandler := handlers.NewNotificationHandler()
+	webhookHandler := handlers.NewWebhookHandler()
+	giftHandler := handlers.NewGiftHandler()
 
 	// Health check endpoint
 	router.GET("/health", orderHandler.HealthCheck)
@@ -39,11 +49,92 @@ func SetupRoutes() *gin.Engine {
 			orders.POST("", orderHandler.CreateOrder)                                 // Create new order
 			orders.GET("", orderHandler.GetAllOrders)                               // Get all orders with pagination
 			orders.GET("/stats", orderHandler.GetOrderStatistics)                   // Get order statistics
+			orders.GET("/stats/game-sales", orderHandler.GetGameSales)              // Get units sold per game
+			orders.GET("/export", orderHandler.ExportOrders)                        // Stream orders or items as CSV, NDJSON or Parquet
 			orders.GET("/:id", orderHandler.GetOrderByID)                          // Get specific order
 			orders.PUT("/:id/status", orderHandler.UpdateOrderStatus)              // Update order status
+			orders.GET("/:id/history", orderHandler.GetOrderStatusHistory)         // Get order status history
+			orders.GET("/:id/invoice", invoiceHandler.GetOrderInvoice)             // Get the invoice of a confirmed order as HTML or PDF
+			orders.GET("/:id/license-keys", fulfillmentHandler.GetOrderLicenseKeys) // Reveal license keys to the ordering customer
+			orders.GET("/:id/gifts", giftHandler.GetOrderGifts)                    // Show gift codes to the ordering customer
+			orders.GET("/:id/notifications", notificationHandler.GetOrderNotifications) // Get the emails sent about an order
+			orders.POST("/:id/payments", paymentHandler.CreatePayment)             // Start a payment for an order
+			orders.GET("/:id/payments", paymentHandler.GetOrderPayments)           // Get payment attempts of an order
+			orders.POST("/:id/refunds", refundHandler.RequestRefund)               // Request a refund for an order or its items
+			orders.GET("/:id/refunds", refundHandler.GetOrderRefunds)              // Get refunds of an order
 			orders.DELETE("/:id", orderHandler.DeleteOrder)                        // Delete order
 			orders.GET("/customer/:customer_id", orderHandler.GetOrdersByCustomerID) // Get orders by customer
 		}
+
+		// Payment routes
+		payments := v1.Group("/payments")
+		{
+			payments.POST("/webhook", paymentHandler.HandleWebhook)          // Signed payment provider webhook
+			payments.GET("/:id", paymentHandler.GetPayment)                  // Get a payment attempt
+			payments.POST("/:id/authorize", paymentHandler.AuthorizePayment) // Authorize with a payment method
+			payments.POST("/:id/capture", paymentHandler.CapturePayment)     // Capture an authorized payment
+			payments.POST("/:id/void", paymentHandler.VoidPayment)           // Release an authorization
+			payments.POST("/:id/refund", paymentHandler.RefundPayment)       // Refund a captured payment
+		}
+
+		// Refund routes
+		refunds := v1.Group("/refunds")
+		{
+			refunds.GET("/:id", refundHandler.GetRefund)              // Get a refund
+			refunds.POST("/:id/approve", refundHandler.ApproveRefund) // Approve and pay back a refund
+			refunds.POST("/:id/reject", refundHandler.RejectRefund)   // Reject a refund
+		}
+
+		// Promotion routes
+		promotions := v1.Group("/promotions")
+		{
+			promotions.POST("", promotionHandler.CreatePromotion)           // Create a coupon or automatic promotion
+			promotions.GET("", promotionHandler.GetPromotions)              // List promotions
+			promotions.POST("/preview", promotionHandler.PreviewPromotions) // Price a basket with promotions applied
+			promotions.GET("/:id", promotionHandler.GetPromotion)           // Get a promotion
+			promotions.PUT("/:id", promotionHandler.UpdatePromotion)        // Replace the rules of a promotion
+		}
+
+		// License key store routes
+		licenseKeys := v1.Group("/license-keys")
+		{
+			licenseKeys.POST("", fulfillmentHandler.ImportLicenseKeys)        // Add license keys of a game
+			licenseKeys.GET("/stock", fulfillmentHandler.GetLicenseKeyStock) // Count unclaimed keys per game
+		}
+
+		// Gift routes, for the customer named by the X-Customer-ID header
+		gifts := v1.Group("/gifts")
+		{
+			gifts.GET("", giftHandler.GetRedeemedGifts)  // List redeemed gifts with their license keys
+			gifts.POST("/redeem", giftHandler.RedeemGift) // Redeem a gift code
+		}
+
+		// Outbound webhook routes
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("", webhookHandler.CreateSubscription)          // Subscribe a URL to order events
+			webhooks.GET("", webhookHandler.GetSubscriptions)             // List subscriptions
+			webhooks.GET("/:id", webhookHandler.GetSubscription)          // Get a subscription
+			webhooks.PUT("/:id", webhookHandler.UpdateSubscription)       // Replace a subscription
+			webhooks.DELETE("/:id", webhookHandler.DeleteSubscription)    // Delete a subscription and its deliveries
+			webhooks.GET("/:id/deliveries", webhookHandler.GetDeliveries) // Get the delivery log of a subscription
+		}
+		webhookDeliveries := v1.Group("/webhook-deliveries")
+		{
+			webhookDeliveries.GET("/:id", webhookHandler.GetDelivery)          // Get a delivery and its attempts
+			webhookDeliveries.POST("/:id/redeliver", webhookHandler.Redeliver) // Send a delivery again
+		}
+
+		// Cart routes, identified by the X-Customer-ID or X-Cart-Token header
+		cart := v1.Group("/cart")
+		{
+			cart.GET("", cartHandler.GetCart)                          // Get the priced cart
+			cart.POST("/items", cartHandler.AddCartItem)               // Add a game to the cart
+			cart.PUT("/items/:game_id", cartHandler.UpdateCartItem)    // Change the quantity of a game
+			cart.DELETE("/items/:game_id", cartHandler.RemoveCartItem) // Remove a game from the cart
+			cart.POST("/merge", cartHandler.MergeCart)                 // Merge a guest cart into the customer cart
+			cart.POST("/checkout", cartHandler.Checkout)               // Convert the cart into an order
+		}
 	}
 
 	return router
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/clients"
+	"order-service/models"
+	"order-service/notifications"
+	"order-service/repository"
+	"order-service/tax"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrCartIdentityRequired is returned when a request carries neither a customer ID nor a cart token
+	ErrCartIdentityRequired = errors.New("X-Customer-ID or X-Cart-Token header is required")
+	// ErrCartEmpty is returned when checking out a cart without items
+	ErrCartEmpty = errors.New("cart is empty")
+)
+
+// CartIdentity identifies the cart a request operates on. A customer cart
+// takes precedence over a guest cart when both are given.
+type CartIdentity struct {
+	CustomerID string
+	GuestToken string
+}
+
+type CartService struct {
+	cartRepo         *repository.CartRepository
+	gameClient       *clients.GameClient
+	promotionService *PromotionService
+	taxTable         *tax.Table
+}
+
+// NewCartService creates a new instance of CartService
+func NewCartService() *CartService {
+	taxTable, err := tax.NewTable()
+	if err != nil {
+		log.Fatalf("Failed to load tax rules: %v", err)
+	}
+
+	return &CartService{
+		cartRepo:         repository.NewCartRepository(),
+		gameClient:       clients.NewGameClient(),
+		promotionService: NewPromotionService(),
+		taxTable:         taxTable,
+	}
+}
+
+// GetCart returns the current cart priced against the catalog
+func (s *CartService) GetCart(identity CartIdentity) (*models.CartResponse, error) {
+	cart, err := s.findCart(identity)
+	if err != nil {
+		return nil, err
+	}
+	return s.priceCart(cart)
+}
+
+// AddItem adds a game to the cart. A guest cart with a new token is created
+// when the request carries no identity, or a token that is no longer active.
+func (s *CartService) AddItem(identity CartIdentity, request *models.AddCartItemRequest) (*models.CartResponse, error) {
+	if err := validateCartQuantity(request.Quantity); err != nil {
+		return nil, err
+	}
+
+	// Only games that could be ordered right now may be added
+	if _, err := lookupPurchasableGames(s.gameClient, []int{request.GameID}); err != nil {
+		return nil, err
+	}
+
+	cart, err := s.findCart(identity)
+	if errors.Is(err, ErrCartIdentityRequired) || (errors.Is(err, repository.ErrCartNotFound) && identity.CustomerID == "") {
+		cart, err = s.cartRepo.CreateGuestCart(uuid.New().String())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.AddItem(cart.ID, request.GameID, request.Quantity); err != nil {
+		return nil, err
+	}
+
+	return s.reloadCart(cart.ID)
+}
+
+// UpdateItem sets the quantity of a game already in the cart
+func (s *CartService) UpdateItem(identity CartIdentity, gameID int, request *models.UpdateCartItemRequest) (*models.CartResponse, error) {
+	if err := validateCartQuantity(request.Quantity); err != nil {
+		return nil, err
+	}
+
+	cart, err := s.findCart(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.SetItemQuantity(cart.ID, gameID, request.Quantity); err != nil {
+		return nil, err
+	}
+
+	return s.reloadCart(cart.ID)
+}
+
+// RemoveItem removes a game from the cart
+func (s *CartService) RemoveItem(identity CartIdentity, gameID int) (*models.CartResponse, error) {
+	cart, err := s.findCart(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.RemoveItem(cart.ID, gameID); err != nil {
+		return nil, err
+	}
+
+	return s.reloadCart(cart.ID)
+}
+
+// MergeGuestCart merges a guest cart into the customer's cart, typically right after login
+func (s *CartService) MergeGuestCart(identity CartIdentity) (*models.CartResponse, error) {
+	if identity.CustomerID == "" || identity.GuestToken == "" {
+		return nil, fmt.Errorf("both X-Customer-ID and X-Cart-Token headers are required")
+	}
+
+	cartID, err := s.cartRepo.MergeGuestCart(identity.GuestToken, identity.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.reloadCart(cartID)
+}
+
+// Checkout converts the customer's cart into a pending order. Items are
+// priced from the catalog, discounted by promotions and the given coupons and
+// taxed for the given country and region, and the order is only written if the cart did not change in the meantime.
+func (s *CartService) Checkout(identity CartIdentity, request *models.CheckoutCartRequest) (*models.Order, error) {
+	if identity.CustomerID == "" {
+		return nil, fmt.Errorf("X-Customer-ID header is required to check out")
+	}
+
+	cart, err := s.cartRepo.GetOrCreateCustomerCart(identity.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(cart.Items) == 0 {
+		return nil, ErrCartEmpty
+	}
+
+	gameIDs := make([]int, len(cart.Items))
+	for i, item := range cart.Items {
+		gameIDs[i] = item.GameID
+	}
+
+	catalog, err := lookupPurchasableGames(s.gameClient, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &models.Order{
+		CustomerID:    identity.CustomerID,
+		CustomerEmail: request.CustomerEmail,
+		Locale:        notifications.SupportedLocale(request.Locale),
+		Items:         make([]models.OrderItem, len(cart.Items)),
+	}
+	for i, item := range cart.Items {
+		order.Items[i] = catalogOrderItem(catalog[item.GameID], item.Quantity)
+	}
+
+	if err := s.promotionService.applyPromotions(order, catalog, request.CouponCodes); err != nil {
+		return nil, err
+	}
+
+	if err := applyTax(s.taxTable, order, request.Country, request.Region); err != nil {
+		return nil, err
+	}
+
+	if err := s.cartRepo.CheckoutCart(cart.ID, cart.Items, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// findCart resolves the active cart for an identity. Customers always have a
+// cart; guest tokens must refer to an active guest cart.
+func (s *CartService) findCart(identity CartIdentity) (*models.Cart, error) {
+	switch {
+	case identity.CustomerID != "":
+		return s.cartRepo.GetOrCreateCustomerCart(identity.CustomerID)
+	case identity.GuestToken != "":
+		return s.cartRepo.GetActiveGuestCart(identity.GuestToken)
+	default:
+		return nil, ErrCartIdentityRequired
+	}
+}
+
+// reloadCart reads a cart back after a change and prices it
+func (s *CartService) reloadCart(cartID string) (*models.CartResponse, error) {
+	cart, err := s.cartRepo.GetCartByID(cartID)
+	if err != nil {
+		return nil, err
+	}
+	return s.priceCart(cart)
+}
+
+// priceCart prices every item at the current catalog price. Games that were
+// removed or are no longer purchasable are kept but marked unavailable.
+func (s *CartService) priceCart(cart *models.Cart) (*models.CartResponse, error) {
+	response := &models.CartResponse{
+		ID:         cart.ID,
+		CustomerID: cart.CustomerID,
+		GuestToken: cart.GuestToken,
+		Status:     cart.Status,
+		Items:      make([]models.CartItemResponse, len(cart.Items)),
+		PricedAt:   time.Now(),
+		UpdatedAt:  cart.UpdatedAt,
+	}
+	if len(cart.Items) == 0 {
+		return response, nil
+	}
+
+	gameIDs := make([]int, len(cart.Items))
+	for i, item := range cart.Items {
+		gameIDs[i] = item.GameID
+	}
+
+	games, _, err := s.gameClient.GetGames(gameIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to price cart: %w", err)
+	}
+
+	for i, item := range cart.Items {
+		line := models.CartItemResponse{
+			GameID:   item.GameID,
+			Quantity: item.Quantity,
+		}
+		if game, ok := games[item.GameID]; ok {
+			line.GameName = game.Name
+			line.UnitPrice = game.Price
+			line.Available = game.Purchasable
+		}
+		if line.Available {
+			line.Subtotal = line.UnitPrice.Mul(item.Quantity)
+			response.TotalPrice += line.Subtotal
+			response.ItemCount += item.Quantity
+		}
+		response.Items[i] = line
+	}
+
+	return response, nil
+}
+
+// validateCartQuantity checks a requested quantity against the per-item limit
+func validateCartQuantity(quantity int) error {
+	if quantity <= 0 || quantity > repository.MaxCartItemQuantity {
+		return fmt.Errorf("quantity must be between 1 and %d", repository.MaxCartItemQuantity)
+	}
+	return nil
+}
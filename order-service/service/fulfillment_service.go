@@ -0,0 +1,236 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/models"
+	"order-service/repository"
+)
+
+// ErrFulfillmentNotNeeded is returned when an order is no longer waiting for its license keys
+var ErrFulfillmentNotNeeded = errors.New("fulfillment not needed")
+
+// ErrInvalidLicenseKeys is returned when imported license keys are malformed
+var ErrInvalidLicenseKeys = errors.New("invalid license keys")
+
+const (
+	// fulfillmentActor is recorded in the status history of orders moved by fulfillment
+	fulfillmentActor = "fulfillment"
+	// fulfillmentBatchSize is how many due fulfillments one poll works through
+	fulfillmentBatchSize = 50
+	// fulfillmentLease is how long a replica has to finish a fulfillment it picked up
+	fulfillmentLease = 5 * time.Minute
+	// maxFulfillmentRetryDelay caps the exponential backoff between attempts
+	maxFulfillmentRetryDelay = time.Hour
+)
+
+type FulfillmentService struct {
+	fulfillmentRepo *repository.FulfillmentRepository
+	orderRepo       *repository.OrderRepository
+	pollInterval    time.Duration
+	retryDelay      time.Duration
+}
+
+// NewFulfillmentService creates a new instance of FulfillmentService. Due
+// fulfillments are polled every FULFILLMENT_POLL_INTERVAL (default 15s), and
+// failed attempts are retried after FULFILLMENT_RETRY_DELAY (default 30s),
+// doubling with every failure up to an hour.
+func NewFulfillmentService() *FulfillmentService {
+	return &FulfillmentService{
+		fulfillmentRepo: repository.NewFulfillmentRepository(),
+		orderRepo:       repository.NewOrderRepository(),
+		pollInterval:    durationFromEnv("FULFILLMENT_POLL_INTERVAL", 15*time.Second),
+		retryDelay:      durationFromEnv("FULFILLMENT_RETRY_DELAY", 30*time.Second),
+	}
+}
+
+// Run works through due fulfillments every poll interval. It never returns
+// and is meant to run in its own goroutine on every replica.
+func (s *FulfillmentService) Run() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.ProcessDue()
+	}
+}
+
+// ProcessDue attempts every fulfillment that is due
+func (s *FulfillmentService) ProcessDue() {
+	now := time.Now()
+	due, err := s.fulfillmentRepo.LeaseDueFulfillments(now, now.Add(fulfillmentLease), fulfillmentBatchSize)
+	if err != nil {
+		log.Printf("Failed to pick up due fulfillments: %v", err)
+		return
+	}
+
+	for _, fulfillment := range due {
+		s.attempt(fulfillment.OrderID, fulfillment.Attempts)
+	}
+}
+
+// fulfill attempts the fulfillment of a newly paid order right away, so
+// customers usually get their keys without waiting for the next poll. Orders
+// that were not queued, because they are not paid yet, are left alone.
+func (s *FulfillmentService) fulfill(orderID string) {
+	fulfillment, err := s.fulfillmentRepo.GetFulfillment(orderID)
+	if err != nil {
+		if !errors.Is(err, repository.ErrFulfillmentNotFound) {
+			log.Printf("Failed to get fulfillment of order %s: %v", orderID, err)
+		}
+		return
+	}
+	if fulfillment.Status == models.FulfillmentStatusPending {
+		s.attempt(orderID, fulfillment.Attempts)
+	}
+}
+
+// fulfillCaptured queues a confirmed order whose payment was just captured,
+// if that paid it in full, and attempts its fulfillment right away
+func (s *FulfillmentService) fulfillCaptured(orderID string) {
+	if err := s.fulfillmentRepo.QueueFulfillmentIfPaid(orderID, time.Now()); err != nil {
+		log.Printf("Failed to queue fulfillment of order %s: %v", orderID, err)
+		return
+	}
+	s.fulfill(orderID)
+}
+
+// attempt claims the license keys of an order and delivers it. Orders that
+// were cancelled in the meantime, or whose payment is no longer captured,
+// are skipped; a later capture queues them again. Other failures
+// are retried with exponential backoff.
+func (s *FulfillmentService) attempt(orderID string, attempts int) {
+	err := s.fulfillmentRepo.FulfillOrder(orderID, fulfillmentActor, fulfillmentSteps)
+	if err == nil {
+		return
+	}
+
+	now := time.Now()
+	if errors.Is(err, ErrFulfillmentNotNeeded) || errors.Is(err, repository.ErrOrderNotPaid) || err.Error() == "order not found" {
+		if err := s.fulfillmentRepo.SkipFulfillment(orderID, err.Error(), now); err != nil {
+			log.Printf("Failed to skip fulfillment of order %s: %v", orderID, err)
+		}
+		return
+	}
+
+	delay := backoff(s.retryDelay, attempts, maxFulfillmentRetryDelay)
+	log.Printf("Fulfillment of order %s failed, retrying in %s: %v", orderID, delay, err)
+	if err := s.fulfillmentRepo.RecordFulfillmentFailure(orderID, err.Error(), now.Add(delay), now); err != nil {
+		log.Printf("Failed to record fulfillment failure of order %s: %v", orderID, err)
+	}
+}
+
+// fulfillmentSteps returns the statuses a paid order moves through to be
+// delivered. Fulfillment follows the regular transitions, so the history
+// shows each step. Orders delivered by hand before their keys were issued
+// still get them, without a status change.
+func fulfillmentSteps(from string) ([]string, error) {
+	switch from {
+	case models.OrderStatusConfirmed:
+		return []string{models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusDelivered}, nil
+	case models.OrderStatusProcessing:
+		return []string{models.OrderStatusShipped, models.OrderStatusDelivered}, nil
+	case models.OrderStatusShipped:
+		return []string{models.OrderStatusDelivered}, nil
+	case models.OrderStatusDelivered:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%w: order is %s", ErrFulfillmentNotNeeded, from)
+	}
+}
+
+// ImportLicenseKeys adds license keys of a game to the key store and retries
+// pending fulfillments in the background. It returns how many keys were new.
+func (s *FulfillmentService) ImportLicenseKeys(request *models.ImportLicenseKeysRequest) (int, error) {
+	if request.GameID <= 0 {
+		return 0, fmt.Errorf("%w: game_id must be positive", ErrInvalidLicenseKeys)
+	}
+
+	keys := make([]string, 0, len(request.Keys))
+	for _, key := range request.Keys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return 0, fmt.Errorf("%w: keys cannot be blank", ErrInvalidLicenseKeys)
+		}
+		keys = append(keys, key)
+	}
+
+	added, err := s.fulfillmentRepo.ImportLicenseKeys(request.GameID, keys, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	go s.ProcessDue()
+
+	return added, nil
+}
+
+// GetLicenseKeyStock counts the unclaimed license keys per game
+func (s *FulfillmentService) GetLicenseKeyStock() ([]models.LicenseKeyStock, error) {
+	return s.fulfillmentRepo.GetLicenseKeyStock()
+}
+
+// RevealLicenseKeys returns the license keys of an order to the customer who
+// placed it, along with the state of its fulfillment. Other customers get
+// "order not found" so order IDs cannot be probed.
+func (s *FulfillmentService) RevealLicenseKeys(orderID, customerID string) (*models.OrderLicenseKeysResponse, error) {
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if customerID == "" || order.CustomerID != customerID {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	response := &models.OrderLicenseKeysResponse{OrderID: order.ID}
+	response.Fulfillment, err = s.fulfillmentRepo.GetFulfillment(order.ID)
+	if err != nil && !errors.Is(err, repository.ErrFulfillmentNotFound) {
+		return nil, err
+	}
+
+	response.Items, err = s.fulfillmentRepo.RevealLicenseKeys(order.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// durationFromEnv reads a positive duration such as "30s" from an environment variable
+func durationFromEnv(name string, fallback time.Duration) time.Duration {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// intFromEnv reads a positive integer from an environment variable
+func intFromEnv(name string, fallback int) int {
+	if value := os.Getenv(name); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+// backoff is the delay before retrying after the given number of failed
+// attempts: base after the first, doubling with each further one up to max
+func backoff(base time.Duration, attempts int, max time.Duration) time.Duration {
+	delay := base
+	for i := 0; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"order-service/models"
+)
+
+func TestFulfillmentSteps(t *testing.T) {
+	for from, expected := range map[string][]string{
+		models.OrderStatusConfirmed:  {models.OrderStatusProcessing, models.OrderStatusShipped, models.OrderStatusDelivered},
+		models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusDelivered},
+		models.OrderStatusShipped:    {models.OrderStatusDelivered},
+		models.OrderStatusDelivered:  nil,
+	} {
+		steps, err := fulfillmentSteps(from)
+		if err != nil || !reflect.DeepEqual(steps, expected) {
+			t.Errorf("fulfillmentSteps(%s) = %v, %v, expected %v", from, steps, err, expected)
+		}
+	}
+
+	for _, from := range []string{models.OrderStatusPending, models.OrderStatusCancelled} {
+		if _, err := fulfillmentSteps(from); !errors.Is(err, ErrFulfillmentNotNeeded) {
+			t.Errorf("Expected ErrFulfillmentNotNeeded for %s, got %v", from, err)
+		}
+	}
+}
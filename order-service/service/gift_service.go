@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"order-service/models"
+	"order-service/repository"
+)
+
+var (
+	// ErrInvalidGift is returned when the recipient or message of a gift is malformed
+	ErrInvalidGift = errors.New("invalid gift")
+	// ErrGiftNotRedeemable is returned when a gift was redeemed or revoked, or its order is not paid
+	ErrGiftNotRedeemable = errors.New("gift cannot be redeemed")
+	// ErrGiftForAnotherCustomer is returned when a gift for a named customer is redeemed by someone else
+	ErrGiftForAnotherCustomer = errors.New("gift is for another customer")
+)
+
+const (
+	// maxGiftMessageLength bounds the message to the recipient
+	maxGiftMessageLength = 1000
+	// giftCodeGroupLength is the length of the groups of a gift code, which are joined by hyphens
+	giftCodeGroupLength = 4
+)
+
+// paidOrderStatuses are the statuses in which an order has been paid for, so its gifts can be redeemed
+var paidOrderStatuses = map[string]bool{
+	models.OrderStatusConfirmed:  true,
+	models.OrderStatusProcessing: true,
+	models.OrderStatusShipped:    true,
+	models.OrderStatusDelivered:  true,
+}
+
+type GiftService struct {
+	giftRepo  *repository.GiftRepository
+	orderRepo *repository.OrderRepository
+}
+
+// NewGiftService creates a new instance of GiftService
+func NewGiftService() *GiftService {
+	return &GiftService{
+		giftRepo:  repository.NewGiftRepository(),
+		orderRepo: repository.NewOrderRepository(),
+	}
+}
+
+// GetOrderGifts returns the gifts of an order, with their codes, to the
+// customer who placed it. Other customers get "order not found" so order IDs
+// cannot be probed.
+func (s *GiftService) GetOrderGifts(orderID, customerID string) ([]models.Gift, error) {
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if customerID == "" || order.CustomerID != customerID {
+		return nil, fmt.Errorf("order not found")
+	}
+
+	return s.giftRepo.GetOrderGifts(order.ID)
+}
+
+// RedeemGift transfers a gifted order line, and the license keys issued for
+// it, to the customer redeeming its code. A code can be redeemed once, and
+// only after the order is paid; gifts for a named customer can only be
+// redeemed by that customer, gifts for an email address by whoever holds
+// the code.
+func (s *GiftService) RedeemGift(code, customerID string) (*models.Gift, error) {
+	code = normalizeGiftCode(code)
+	if code == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrInvalidGift)
+	}
+
+	return s.giftRepo.RedeemGift(code, customerID, time.Now(), func(gift *models.Gift, orderStatus string) error {
+		switch {
+		case gift.Status == models.GiftStatusRedeemed:
+			return fmt.Errorf("%w: gift was already redeemed", ErrGiftNotRedeemable)
+		case gift.Status == models.GiftStatusRevoked:
+			return fmt.Errorf("%w: gift was revoked", ErrGiftNotRedeemable)
+		case !paidOrderStatuses[orderStatus]:
+			return fmt.Errorf("%w: order is %s", ErrGiftNotRedeemable, orderStatus)
+		case gift.RecipientCustomerID != "" && gift.RecipientCustomerID != customerID:
+			return ErrGiftForAnotherCustomer
+		}
+		return nil
+	})
+}
+
+// GetRedeemedGifts returns the gifts a customer redeemed, with the license
+// keys of those that were not revoked since
+func (s *GiftService) GetRedeemedGifts(customerID string) ([]models.Gift, error) {
+	return s.giftRepo.GetRedeemedGifts(customerID, time.Now())
+}
+
+// buildGift validates the gift of an order line. The recipient is named by
+// email, customer ID or both, and cannot be the customer placing the order.
+func buildGift(request *models.GiftRequest, customerID string) (*models.Gift, error) {
+	gift := &models.Gift{
+		RecipientEmail:      strings.TrimSpace(request.RecipientEmail),
+		RecipientCustomerID: strings.TrimSpace(request.RecipientCustomerID),
+		Message:             strings.TrimSpace(request.Message),
+	}
+
+	if gift.RecipientEmail == "" && gift.RecipientCustomerID == "" {
+		return nil, fmt.Errorf("%w: recipient_email or recipient_customer_id is required", ErrInvalidGift)
+	}
+	if gift.RecipientEmail != "" {
+		address, err := mail.ParseAddress(gift.RecipientEmail)
+		if err != nil || address.Address != gift.RecipientEmail || len(gift.RecipientEmail) > 255 {
+			return nil, fmt.Errorf("%w: recipient_email is not a valid email address", ErrInvalidGift)
+		}
+	}
+	if len(gift.RecipientCustomerID) > 255 {
+		return nil, fmt.Errorf("%w: recipient_customer_id must be at most 255 characters", ErrInvalidGift)
+	}
+	if gift.RecipientCustomerID == customerID {
+		return nil, fmt.Errorf("%w: an order cannot be gifted to the customer placing it", ErrInvalidGift)
+	}
+	if len(gift.Message) > maxGiftMessageLength {
+		return nil, fmt.Errorf("%w: message must be at most %d characters", ErrInvalidGift, maxGiftMessageLength)
+	}
+
+	return gift, nil
+}
+
+// normalizeGiftCode turns a code as typed by a customer, in any case and
+// with or without spaces and hyphens, into the form it was issued in, such
+// as "K7QD-M2XP-9HTA-RW4C"
+func normalizeGiftCode(code string) string {
+	var characters []rune
+	for _, r := range strings.ToUpper(code) {
+		if r != '-' && r != ' ' && r != '\t' {
+			characters = append(characters, r)
+		}
+	}
+
+	var normalized strings.Builder
+	for i, r := range characters {
+		if i > 0 && i%giftCodeGroupLength == 0 {
+			normalized.WriteByte('-')
+		}
+		normalized.WriteRune(r)
+	}
+	return normalized.String()
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"order-service/models"
+)
+
+func TestNormalizeGiftCode(t *testing.T) {
+	for input, expected := range map[string]string{
+		"K7QD-M2XP-9HTA-RW4C":  "K7QD-M2XP-9HTA-RW4C",
+		"k7qd m2xp 9hta rw4c":  "K7QD-M2XP-9HTA-RW4C",
+		" k7qdm2xp-9htarw4c\t": "K7QD-M2XP-9HTA-RW4C",
+		"K7Q-DM2-XP9-HTA-RW4C": "K7QD-M2XP-9HTA-RW4C",
+		"":                     "",
+		" - ":                  "",
+	} {
+		if got := normalizeGiftCode(input); got != expected {
+			t.Errorf("normalizeGiftCode(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestBuildGift(t *testing.T) {
+	gift, err := buildGift(&models.GiftRequest{
+		RecipientEmail: " friend@example.com ",
+		Message:        " Happy birthday! ",
+	}, "customer-1")
+	if err != nil {
+		t.Fatalf("Expected a valid gift, got %v", err)
+	}
+	if gift.RecipientEmail != "friend@example.com" || gift.Message != "Happy birthday!" {
+		t.Errorf("Expected the recipient and message to be trimmed, got %+v", gift)
+	}
+
+	invalid := []*models.GiftRequest{
+		{},
+		{Message: "no recipient"},
+		{RecipientEmail: "not an email"},
+		{RecipientEmail: "Friend <friend@example.com>"},
+		{RecipientCustomerID: "customer-1"},
+		{RecipientCustomerID: "customer-2", Message: strings.Repeat("x", maxGiftMessageLength+1)},
+	}
+	for _, request := range invalid {
+		if _, err := buildGift(request, "customer-1"); !errors.Is(err, ErrInvalidGift) {
+			t.Errorf("Expected ErrInvalidGift for %+v, got %v", request, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"order-service/invoices"
+	"order-service/models"
+	"order-service/repository"
+)
+
+type InvoiceService struct {
+	invoiceRepo *repository.InvoiceRepository
+	orderRepo   *repository.OrderRepository
+	seller      invoices.Seller
+}
+
+// NewInvoiceService creates a new instance of InvoiceService issuing invoices
+// for the seller configured with INVOICE_SELLER_*
+func NewInvoiceService() *InvoiceService {
+	return &InvoiceService{
+		invoiceRepo: repository.NewInvoiceRepository(),
+		orderRepo:   repository.NewOrderRepository(),
+		seller:      invoices.SellerFromEnv(),
+	}
+}
+
+// GetInvoice retrieves the invoice of an order with its HTML and PDF
+// documents. Invoice numbers are issued when orders are confirmed; the
+// documents are rendered and stored right after, or on first request if that
+// failed, and never change afterwards.
+func (s *InvoiceService) GetInvoice(orderID string) (*models.Invoice, error) {
+	invoice, err := s.invoiceRepo.GetInvoiceByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if invoice.RenderedAt != nil {
+		return invoice, nil
+	}
+
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	document := invoices.NewDocument(s.seller, invoice, order)
+	html, err := invoices.RenderHTML(document)
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := invoices.RenderPDF(document)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.invoiceRepo.StoreInvoiceDocuments(invoice.ID, html, pdf, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Read back what was stored, in case a concurrent request stored it first
+	return s.invoiceRepo.GetInvoiceByOrderID(orderID)
+}
+
+// renderInvoice stores the documents of a newly confirmed order's invoice, so
+// they show the order as it was when confirmed. A failure is only logged: the
+// confirmation stands and the documents are rendered on first request.
+func (s *InvoiceService) renderInvoice(orderID string) {
+	if _, err := s.GetInvoice(orderID); err != nil {
+		log.Printf("Failed to render invoice of order %s: %v", orderID, err)
+	}
+}
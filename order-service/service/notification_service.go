@@ -0,0 +1,164 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"order-service/models"
+	"order-service/notifications"
+	"order-service/repository"
+)
+
+// errNotificationOutdated is returned when a notification no longer matches its order
+var errNotificationOutdated = errors.New("notification outdated")
+
+const (
+	// notificationBatchSize is how many due notifications one poll works through
+	notificationBatchSize = 50
+	// notificationLease is how long a replica has to send a notification it picked up
+	notificationLease = 5 * time.Minute
+	// maxNotificationRetryDelay caps the exponential backoff between attempts
+	maxNotificationRetryDelay = time.Hour
+)
+
+// NotificationService emails customers about their orders. Order changes
+// queue notifications in the transaction that makes them, and the service
+// renders and sends them in the background, retrying failed attempts.
+type NotificationService struct {
+	notificationRepo *repository.NotificationRepository
+	orderRepo        *repository.OrderRepository
+	refundRepo       *repository.RefundRepository
+	sender           notifications.Sender
+	pollInterval     time.Duration
+	retryDelay       time.Duration
+	maxAttempts      int
+}
+
+// NewNotificationService creates a new instance of NotificationService using
+// the sender selected by EMAIL_SENDER. Due notifications are polled every
+// EMAIL_POLL_INTERVAL (default 5s); failed attempts are retried after
+// EMAIL_RETRY_DELAY (default 30s), doubling with every failure up to an hour,
+// until EMAIL_MAX_ATTEMPTS (default 8) attempts have failed.
+func NewNotificationService() *NotificationService {
+	sender, err := notifications.NewSender()
+	if err != nil {
+		log.Fatalf("Failed to initialize email sender: %v", err)
+	}
+
+	return &NotificationService{
+		notificationRepo: repository.NewNotificationRepository(),
+		orderRepo:        repository.NewOrderRepository(),
+		refundRepo:       repository.NewRefundRepository(),
+		sender:           sender,
+		pollInterval:     durationFromEnv("EMAIL_POLL_INTERVAL", 5*time.Second),
+		retryDelay:       durationFromEnv("EMAIL_RETRY_DELAY", 30*time.Second),
+		maxAttempts:      intFromEnv("EMAIL_MAX_ATTEMPTS", 8),
+	}
+}
+
+// Run sends due notifications every poll interval. It never returns and is
+// meant to run in its own goroutine on every replica.
+func (s *NotificationService) Run() {
+	log.Printf("Sending order emails through the %s sender", s.sender.Name())
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.ProcessDue()
+	}
+}
+
+// ProcessDue attempts every notification that is due
+func (s *NotificationService) ProcessDue() {
+	now := time.Now()
+	due, err := s.notificationRepo.LeaseDueNotifications(now, now.Add(notificationLease), notificationBatchSize)
+	if err != nil {
+		log.Printf("Failed to pick up due notifications: %v", err)
+		return
+	}
+
+	for i := range due {
+		s.attempt(&due[i])
+	}
+}
+
+// GetOrderNotifications retrieves the emails queued for an order and their delivery state
+func (s *NotificationService) GetOrderNotifications(orderID string) ([]models.Notification, error) {
+	if _, err := s.orderRepo.GetOrderByID(orderID); err != nil {
+		return nil, err
+	}
+	return s.notificationRepo.GetOrderNotifications(orderID)
+}
+
+// attempt renders and sends a notification. Notifications that are outdated
+// or whose order is gone are skipped; other failures are retried with
+// exponential backoff until the attempts run out.
+func (s *NotificationService) attempt(notification *models.Notification) {
+	err := s.send(notification)
+	now := time.Now()
+	if err == nil {
+		if err := s.notificationRepo.MarkNotificationSent(notification.ID, now); err != nil {
+			log.Printf("Failed to mark notification %s sent: %v", notification.ID, err)
+		}
+		return
+	}
+
+	if errors.Is(err, errNotificationOutdated) || err.Error() == "order not found" {
+		if err := s.notificationRepo.SkipNotification(notification.ID, err.Error(), now); err != nil {
+			log.Printf("Failed to skip notification %s: %v", notification.ID, err)
+		}
+		return
+	}
+
+	if notification.Attempts+1 >= s.maxAttempts {
+		log.Printf("Giving up on %s email for order %s after %d attempts: %v",
+			notification.Event, notification.OrderID, notification.Attempts+1, err)
+		if err := s.notificationRepo.FailNotification(notification.ID, err.Error(), now); err != nil {
+			log.Printf("Failed to record notification failure of %s: %v", notification.ID, err)
+		}
+		return
+	}
+
+	delay := backoff(s.retryDelay, notification.Attempts, maxNotificationRetryDelay)
+	log.Printf("Sending %s email for order %s failed, retrying in %s: %v", notification.Event, notification.OrderID, delay, err)
+	if err := s.notificationRepo.RecordNotificationFailure(notification.ID, err.Error(), now.Add(delay), now); err != nil {
+		log.Printf("Failed to record notification failure of %s: %v", notification.ID, err)
+	}
+}
+
+// send renders a notification from the current state of its order and hands it to the sender
+func (s *NotificationService) send(notification *models.Notification) error {
+	order, err := s.orderRepo.GetOrderByID(notification.OrderID)
+	if err != nil {
+		return err
+	}
+
+	data := &notifications.Data{Order: order}
+	switch notification.Event {
+	case models.NotificationOrderShipped:
+		// Orders are often shipped and delivered at once; the delivery email says it all
+		if order.Status == models.OrderStatusDelivered {
+			return fmt.Errorf("%w: order was delivered before the shipping email went out", errNotificationOutdated)
+		}
+	case models.NotificationOrderRefunded:
+		data.Refund, err = s.refundRepo.GetRefundByID(notification.Reference)
+		if err != nil {
+			return err
+		}
+	}
+
+	subject, html, err := notifications.Render(notification.Event, notification.Locale, data)
+	if err != nil {
+		return err
+	}
+
+	return s.sender.Send(&notifications.Message{
+		ID:      notification.ID,
+		To:      notification.Recipient,
+		Subject: subject,
+		HTML:    html,
+	})
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"order-service/repository"
+)
+
+const (
+	// orderExpiryActor is recorded in the status history of expired orders
+	orderExpiryActor = "expiry"
+	// orderExpiryBatchSize is how many orders one transaction cancels
+	orderExpiryBatchSize = 100
+)
+
+// OrderExpiryService cancels pending orders that were not paid in time, which
+// gives their coupon uses back and closes their payment attempts
+type OrderExpiryService struct {
+	orderRepo *repository.OrderRepository
+	ttl       time.Duration
+	interval  time.Duration
+}
+
+// NewOrderExpiryService creates a new instance of OrderExpiryService. Orders
+// still pending ORDER_PENDING_TTL (default 1h) after they were placed are
+// cancelled by a sweep every ORDER_EXPIRY_INTERVAL (default 1m).
+func NewOrderExpiryService() *OrderExpiryService {
+	return &OrderExpiryService{
+		orderRepo: repository.NewOrderRepository(),
+		ttl:       durationFromEnv("ORDER_PENDING_TTL", time.Hour),
+		interval:  durationFromEnv("ORDER_EXPIRY_INTERVAL", time.Minute),
+	}
+}
+
+// Run sweeps expired orders every interval. It never returns and is meant to
+// run in its own goroutine; replicas running it at the same time skip the
+// orders another one is cancelling.
+func (s *OrderExpiryService) Run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.ExpireStale()
+	}
+}
+
+// ExpireStale cancels every pending order older than the TTL, a batch at a time
+func (s *OrderExpiryService) ExpireStale() {
+	reason := "not paid within " + s.ttl.String()
+	for {
+		expired, err := s.orderRepo.ExpirePendingOrders(time.Now().Add(-s.ttl), orderExpiryBatchSize, orderExpiryActor, reason)
+		if err != nil {
+			log.Printf("Failed to expire pending orders: %v", err)
+			return
+		}
+		if len(expired) > 0 {
+			log.Printf("Cancelled %d pending orders not paid within %s", len(expired), s.ttl)
+		}
+		if len(expired) < orderExpiryBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"order-service/export"
+	"order-service/models"
+)
+
+// ErrInvalidExport is returned when the range, format, rows, columns or timezone of an export are malformed
+var ErrInvalidExport = errors.New("invalid export")
+
+// NewOrderExport validates an export request. The export defaults to CSV
+// with a row per order, every column and times in UTC. Dates of the range
+// are days in the export's timezone.
+func (s *OrderService) NewOrderExport(request *models.OrderExportRequest) (*models.OrderExport, error) {
+	orderExport := &models.OrderExport{
+		Format:   strings.ToLower(strings.TrimSpace(request.Format)),
+		Rows:     strings.ToLower(strings.TrimSpace(request.Rows)),
+		Location: time.UTC,
+	}
+
+	switch orderExport.Format {
+	case "":
+		orderExport.Format = models.ExportFormatCSV
+	case models.ExportFormatCSV, models.ExportFormatNDJSON, models.ExportFormatParquet:
+	default:
+		return nil, fmt.Errorf("%w: format must be csv, ndjson or parquet", ErrInvalidExport)
+	}
+
+	available := models.OrderExportColumns
+	switch orderExport.Rows {
+	case "":
+		orderExport.Rows = models.ExportRowsOrders
+	case models.ExportRowsOrders:
+	case models.ExportRowsItems:
+		available = models.OrderItemExportColumns
+	default:
+		return nil, fmt.Errorf("%w: rows must be orders or items", ErrInvalidExport)
+	}
+
+	if timezone := strings.TrimSpace(request.Timezone); timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %s", ErrInvalidExport, timezone)
+		}
+		orderExport.Location = location
+	}
+
+	var err error
+	if orderExport.From, err = parseExportBound("from", request.From, false, orderExport.Location); err != nil {
+		return nil, err
+	}
+	if orderExport.To, err = parseExportBound("to", request.To, true, orderExport.Location); err != nil {
+		return nil, err
+	}
+	if orderExport.From != nil && orderExport.To != nil && !orderExport.From.Before(*orderExport.To) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidExport)
+	}
+
+	if orderExport.Columns, err = selectExportColumns(available, request.Columns); err != nil {
+		return nil, err
+	}
+
+	return orderExport, nil
+}
+
+// WriteOrderExport streams an export to w. An error may leave w with part of the export.
+func (s *OrderService) WriteOrderExport(ctx context.Context, orderExport *models.OrderExport, w io.Writer) error {
+	writer, err := export.NewWriter(orderExport.Format, w, orderExport.Columns, orderExport.Location)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orderRepo.ExportOrders(ctx, orderExport, writer.WriteRow); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// parseExportBound parses a bound of the export range. Order dates are stored
+// in UTC, so the bound is too.
+func parseExportBound(name, value string, upper bool, location *time.Location) (*time.Time, error) {
+	parsed, err := parseDateBoundIn(value, upper, location)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %v", ErrInvalidExport, name, err)
+	}
+	if parsed != nil {
+		utc := parsed.UTC()
+		parsed = &utc
+	}
+	return parsed, nil
+}
+
+// selectExportColumns picks the columns named in a comma separated list, in
+// its order, or all of them when the list is empty
+func selectExportColumns(available []models.ExportColumn, list string) ([]models.ExportColumn, error) {
+	if strings.TrimSpace(list) == "" {
+		return available, nil
+	}
+
+	byName := make(map[string]models.ExportColumn, len(available))
+	for _, column := range available {
+		byName[column.Name] = column
+	}
+
+	var columns []models.ExportColumn
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %s", ErrInvalidExport, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: column %s is listed twice", ErrInvalidExport, name)
+		}
+		seen[name] = true
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: no columns selected", ErrInvalidExport)
+	}
+
+	return columns, nil
+}
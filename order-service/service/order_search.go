@@ -0,0 +1,191 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-service/models"
+	"order-service/money"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidOrderSearch is returned when the filters, sort or cursor of an order search are malformed
+var ErrInvalidOrderSearch = errors.New("invalid order search")
+
+const (
+	defaultOrderPageSize = 10
+	maxOrderPageSize     = 100
+)
+
+// newOrderSearch validates the query parameters of GET /orders. Page and
+// page_size keep their lenient parsing: invalid values fall back to the defaults.
+func newOrderSearch(request *models.OrderSearchRequest) (*models.OrderSearch, int, error) {
+	search := &models.OrderSearch{
+		CustomerID: strings.TrimSpace(request.CustomerID),
+		Sort:       models.OrderSortOrderDate,
+		Descending: true,
+		Limit:      defaultOrderPageSize,
+	}
+
+	for _, value := range request.Status {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.TrimSpace(status)
+			if status == "" {
+				continue
+			}
+			if _, ok := orderStatusTransitions[status]; !ok {
+				return nil, 0, fmt.Errorf("%w: unknown status %s", ErrInvalidOrderSearch, status)
+			}
+			search.Statuses = append(search.Statuses, status)
+		}
+	}
+
+	var err error
+	if search.From, err = parseOrderSearchTime("from", request.From, false); err != nil {
+		return nil, 0, err
+	}
+	if search.To, err = parseOrderSearchTime("to", request.To, true); err != nil {
+		return nil, 0, err
+	}
+	if search.From != nil && search.To != nil && !search.From.Before(*search.To) {
+		return nil, 0, fmt.Errorf("%w: from must be before to", ErrInvalidOrderSearch)
+	}
+
+	if search.MinTotal, err = parseOrderSearchAmount("min_total", request.MinTotal); err != nil {
+		return nil, 0, err
+	}
+	if search.MaxTotal, err = parseOrderSearchAmount("max_total", request.MaxTotal); err != nil {
+		return nil, 0, err
+	}
+	if search.MinTotal != nil && search.MaxTotal != nil && *search.MinTotal > *search.MaxTotal {
+		return nil, 0, fmt.Errorf("%w: min_total cannot exceed max_total", ErrInvalidOrderSearch)
+	}
+
+	if request.GameID != "" {
+		search.GameID, err = strconv.Atoi(request.GameID)
+		if err != nil || search.GameID <= 0 {
+			return nil, 0, fmt.Errorf("%w: game_id must be a positive integer", ErrInvalidOrderSearch)
+		}
+	}
+
+	switch request.Sort {
+	case "", models.OrderSortOrderDate:
+	case models.OrderSortTotalPrice:
+		search.Sort = models.OrderSortTotalPrice
+	default:
+		return nil, 0, fmt.Errorf("%w: sort must be %s or %s", ErrInvalidOrderSearch, models.OrderSortOrderDate, models.OrderSortTotalPrice)
+	}
+	switch strings.ToLower(request.Order) {
+	case "", "desc":
+	case "asc":
+		search.Descending = false
+	default:
+		return nil, 0, fmt.Errorf("%w: order must be asc or desc", ErrInvalidOrderSearch)
+	}
+
+	if size, err := strconv.Atoi(request.PageSize); err == nil && size > 0 && size <= maxOrderPageSize {
+		search.Limit = size
+	}
+
+	page := 1
+	if request.Cursor != "" {
+		search.After, err = decodeOrderCursor(request.Cursor)
+		if err != nil {
+			return nil, 0, err
+		}
+		if search.After.Sort != search.Sort || search.After.Descending != search.Descending {
+			return nil, 0, fmt.Errorf("%w: cursor belongs to a different sort", ErrInvalidOrderSearch)
+		}
+		page = 0
+	} else if p, err := strconv.Atoi(request.Page); err == nil && p > 0 {
+		page = p
+		search.Offset = (page - 1) * search.Limit
+	}
+
+	return search, page, nil
+}
+
+// parseOrderSearchTime parses the from or to parameter of an order search
+func parseOrderSearchTime(name, value string, upper bool) (*time.Time, error) {
+	parsed, err := parseDateBound(value, upper)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %v", ErrInvalidOrderSearch, name, err)
+	}
+	return parsed, nil
+}
+
+// parseDateBound parses the bound of a date range given as an RFC 3339 time
+// or a YYYY-MM-DD date, which as an exclusive upper bound includes the whole
+// day. An empty value is no bound.
+func parseDateBound(value string, upper bool) (*time.Time, error) {
+	return parseDateBoundIn(value, upper, time.UTC)
+}
+
+// parseDateBoundIn is parseDateBound with dates starting at midnight in location
+func parseDateBoundIn(value string, upper bool, location *time.Location) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return &parsed, nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02", value, location)
+	if err != nil {
+		return nil, errors.New("must be an RFC 3339 time or a YYYY-MM-DD date")
+	}
+	if upper {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return &parsed, nil
+}
+
+func parseOrderSearchAmount(name, value string) (*money.Amount, error) {
+	if value == "" {
+		return nil, nil
+	}
+	amount, err := money.Parse(value)
+	if err != nil || amount < 0 {
+		return nil, fmt.Errorf("%w: %s must be a non-negative amount", ErrInvalidOrderSearch, name)
+	}
+	return &amount, nil
+}
+
+// encodeOrderCursor points a cursor at the last order of a page. Cursors are
+// opaque to clients; they are base64url encoded JSON.
+func encodeOrderCursor(search *models.OrderSearch, order *models.OrderResponse) string {
+	cursor := models.OrderCursor{
+		Sort:       search.Sort,
+		Descending: search.Descending,
+		ID:         order.ID,
+	}
+	if search.Sort == models.OrderSortTotalPrice {
+		cursor.TotalPrice = order.TotalPrice
+	} else {
+		orderDate := order.OrderDate
+		cursor.OrderDate = &orderDate
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeOrderCursor(value string) (*models.OrderCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidOrderSearch)
+	}
+	var cursor models.OrderCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidOrderSearch)
+	}
+	if _, err := uuid.Parse(cursor.ID); err != nil || (cursor.Sort == models.OrderSortOrderDate && cursor.OrderDate == nil) {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidOrderSearch)
+	}
+	return &cursor, nil
+}
@@ -1,25 +1,166 @@
 package service
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"order-service/clients"
 	"order-service/models"
+	"order-service/notifications"
 	"order-service/repository"
+	"order-service/tax"
+)
+
+// ErrIdempotencyKeyReused is returned when an idempotency key is sent again with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key reused with a different request")
+
+// ErrInvalidStatistics is returned when the range, granularity or limit of order statistics is malformed
+var ErrInvalidStatistics = errors.New("invalid statistics request")
+
+const (
+	// maxIdempotencyKeyLength matches the idempotency_keys.key column
+	maxIdempotencyKeyLength = 255
+	// defaultStatisticsTop and maxStatisticsTop bound how many games and categories statistics rank
+	defaultStatisticsTop = 10
+	maxStatisticsTop     = 100
 )
 
 type OrderService struct {
-	orderRepo *repository.OrderRepository
+	orderRepo          *repository.OrderRepository
+	gameClient         *clients.GameClient
+	promotionService   *PromotionService
+	invoiceService     *InvoiceService
+	fulfillmentService *FulfillmentService
+	taxTable           *tax.Table
+	idempotencyTTL     time.Duration
 }
 
-// NewOrderService creates a new instance of OrderService
+// NewOrderService creates a new instance of OrderService. Idempotency keys are
+// kept for IDEMPOTENCY_KEY_TTL (default 24h).
 func NewOrderService() *OrderService {
+	idempotencyTTL := 24 * time.Hour
+	if value := os.Getenv("IDEMPOTENCY_KEY_TTL"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			idempotencyTTL = parsed
+		}
+	}
+
+	taxTable, err := tax.NewTable()
+	if err != nil {
+		log.Fatalf("Failed to load tax rules: %v", err)
+	}
+
 	return &OrderService{
-		orderRepo: repository.NewOrderRepository(),
+		orderRepo:          repository.NewOrderRepository(),
+		gameClient:         clients.NewGameClient(),
+		promotionService:   NewPromotionService(),
+		invoiceService:     NewInvoiceService(),
+		fulfillmentService: NewFulfillmentService(),
+		taxTable:           taxTable,
+		idempotencyTTL:     idempotencyTTL,
 	}
 }
 
 // CreateOrder creates a new order
 func (s *OrderService) CreateOrder(request *models.CreateOrderRequest) (*models.Order, error) {
+	order, err := s.buildOrder(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create order in repository
+	err = s.orderRepo.CreateOrder(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	return order, nil
+}
+
+// CreateOrderWithIdempotencyKey creates an order at most once per key. A retry
+// with the same key and an identical request gets the order created by the
+// first request, with replayed set to true; the same key with a different
+// request fails with ErrIdempotencyKeyReused. Only successful results are
+// stored, so a request that failed can be retried with the same key.
+func (s *OrderService) CreateOrderWithIdempotencyKey(key string, request *models.CreateOrderRequest) (*models.Order, bool, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, false, fmt.Errorf("idempotency key must be at most %d characters", maxIdempotencyKeyLength)
+	}
+
+	requestHash, err := hashRequest(request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Replay without touching the catalog when the key is already known
+	now := time.Now()
+	existing, err := s.orderRepo.GetIdempotencyKey(key, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		order, err := replayIdempotencyKey(existing, requestHash)
+		return order, true, err
+	}
+
+	order, err := s.buildOrder(request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	record, created, err := s.orderRepo.CreateOrderWithIdempotencyKey(&models.IdempotencyKey{
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.idempotencyTTL),
+	}, order)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create order: %w", err)
+	}
+	if !created {
+		// A concurrent request with the same key created the order first
+		order, err := replayIdempotencyKey(record, requestHash)
+		return order, true, err
+	}
+
+	return order, false, nil
+}
+
+// replayIdempotencyKey returns the order stored under a key if it was created by the same request
+func replayIdempotencyKey(record *models.IdempotencyKey, requestHash string) (*models.Order, error) {
+	if record.RequestHash != requestHash {
+		return nil, fmt.Errorf("%w: key %s was used for a different request", ErrIdempotencyKeyReused, record.Key)
+	}
+
+	var order models.Order
+	if err := json.Unmarshal(record.Response, &order); err != nil {
+		return nil, fmt.Errorf("failed to decode stored order: %v", err)
+	}
+	return &order, nil
+}
+
+// hashRequest fingerprints a request by its normalized JSON encoding, so
+// whitespace and field order do not matter
+func hashRequest(request interface{}) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %v", err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// buildOrder validates a create request, prices its items from the catalog
+// and applies promotions and taxes
+func (s *OrderService) buildOrder(request *models.CreateOrderRequest) (*models.Order, error) {
 	// Validate request
 	if len(request.Items) == 0 {
 		return nil, fmt.Errorf("order must contain at least one item")
@@ -27,33 +168,99 @@ func (s *OrderService) CreateOrder(request *models.CreateOrderRequest) (*models.
 
 	// Convert request to order model
 	order := &models.Order{
-		CustomerID: request.CustomerID,
-		Items:      make([]models.OrderItem, len(request.Items)),
+		CustomerID:    request.CustomerID,
+		CustomerEmail: request.CustomerEmail,
+		Locale:        notifications.SupportedLocale(request.Locale),
+		Items:         make([]models.OrderItem, len(request.Items)),
 	}
 
+	gameIDs := make([]int, 0, len(request.Items))
+	gifts := make([]*models.Gift, len(request.Items))
 	for i, item := range request.Items {
 		if item.Quantity <= 0 {
-			return nil, fmt.Errorf("quantity must be greater than 0 for game %s", item.GameName)
+			return nil, fmt.Errorf("quantity must be greater than 0 for game %d", item.GameID)
 		}
-		if item.Price < 0 {
-			return nil, fmt.Errorf("price cannot be negative for game %s", item.GameName)
+		if item.Gift != nil {
+			gift, err := buildGift(item.Gift, request.CustomerID)
+			if err != nil {
+				return nil, err
+			}
+			gifts[i] = gift
 		}
+		gameIDs = append(gameIDs, item.GameID)
+	}
+
+	// Names and prices come from the catalog, never from the client
+	catalog, err := lookupPurchasableGames(s.gameClient, gameIDs)
+	if err != nil {
+		return nil, err
+	}
 
-		order.Items[i] = models.OrderItem{
-			GameID:   item.GameID,
-			GameName: item.GameName,
-			Price:    item.Price,
-			Quantity: item.Quantity,
+	for i, item := range request.Items {
+		order.Items[i] = catalogOrderItem(catalog[item.GameID], item.Quantity)
+		order.Items[i].Gift = gifts[i]
+	}
+
+	if err := s.promotionService.applyPromotions(order, catalog, request.CouponCodes); err != nil {
+		return nil, err
+	}
+
+	if err := applyTax(s.taxTable, order, request.Country, request.Region); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// catalogOrderItem builds an order item priced at the current catalog price
+func catalogOrderItem(game clients.CatalogGame, quantity int) models.OrderItem {
+	catalogPrice := game.Price
+	return models.OrderItem{
+		GameID:       game.ID,
+		GameName:     game.Name,
+		Category:     game.Category,
+		Price:        catalogPrice,
+		Quantity:     quantity,
+		CatalogPrice: &catalogPrice,
+	}
+}
+
+// lookupPurchasableGames resolves game IDs against the catalog and rejects
+// unknown games and games that are not for sale
+func lookupPurchasableGames(gameClient *clients.GameClient, gameIDs []int) (map[int]clients.CatalogGame, error) {
+	seen := make(map[int]bool, len(gameIDs))
+	uniqueIDs := make([]int, 0, len(gameIDs))
+	for _, id := range gameIDs {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
 		}
 	}
 
-	// Create order in repository
-	err := s.orderRepo.CreateOrder(order)
+	games, missing, err := gameClient.GetGames(uniqueIDs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create order: %v", err)
+		return nil, fmt.Errorf("failed to verify games: %w", err)
 	}
 
-	return order, nil
+	if len(missing) > 0 {
+		ids := make([]string, len(missing))
+		for i, id := range missing {
+			ids[i] = strconv.Itoa(id)
+		}
+		return nil, fmt.Errorf("unknown game IDs: %s", strings.Join(ids, ", "))
+	}
+
+	for _, id := range uniqueIDs {
+		game, ok := games[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown game IDs: %d", id)
+		}
+		if !game.Purchasable {
+			return nil, fmt.Errorf("game %d (%s) is not available for purchase", game.ID, game.Name)
+		}
+	}
+
+	return games, nil
 }
 
 // GetOrderByID retrieves an order by its ID
@@ -84,68 +291,104 @@ func (s *OrderService) GetOrdersByCustomerID(customerID string) ([]models.Order,
 	return orders, nil
 }
 
-// GetAllOrders retrieves all orders with pagination
-func (s *OrderService) GetAllOrders(page, pageSize int) (*models.OrdersListResponse, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
+// GetAllOrders searches orders by status, customer, order date, total and
+// game. Pages follow each other by cursor; page numbers still work but get
+// slower the deeper they go.
+func (s *OrderService) GetAllOrders(request *models.OrderSearchRequest) (*models.OrdersListResponse, error) {
+	search, page, err := newOrderSearch(request)
+	if err != nil {
+		return nil, err
 	}
 
-	offset := (page - 1) * pageSize
-	orders, total, err := s.orderRepo.GetAllOrders(pageSize, offset)
+	// One extra order tells whether there is a next page
+	pageSize := search.Limit
+	search.Limit++
+	orders, total, err := s.orderRepo.GetAllOrders(search)
 	if err != nil {
 		return nil, err
 	}
+	hasMore := len(orders) > pageSize
+	if hasMore {
+		orders = orders[:pageSize]
+	}
 
 	// Convert to response format
 	orderResponses := make([]models.OrderResponse, len(orders))
 	for i, order := range orders {
 		orderResponses[i] = models.OrderResponse{
-			ID:         order.ID,
-			CustomerID: order.CustomerID,
-			TotalPrice: order.TotalPrice,
-			Status:     order.Status,
-			OrderDate:  order.OrderDate,
-			CreatedAt:  order.CreatedAt,
-			UpdatedAt:  order.UpdatedAt,
-			Items:      order.Items,
+			ID:               order.ID,
+			CustomerID:       order.CustomerID,
+			TotalPrice:       order.TotalPrice,
+			Currency:         order.Currency,
+			DiscountTotal:    order.DiscountTotal,
+			TaxTotal:         order.TaxTotal,
+			PricesIncludeTax: order.PricesIncludeTax,
+			Country:          order.Country,
+			Region:           order.Region,
+			RefundedAmount:   order.RefundedAmount,
+			NetTotal:         order.NetTotal,
+			Status:           order.Status,
+			OrderDate:        order.OrderDate,
+			CreatedAt:        order.CreatedAt,
+			UpdatedAt:        order.UpdatedAt,
+			Items:            order.Items,
+			TaxLines:         order.TaxLines,
+			Discounts:        order.Discounts,
+			CustomerEmail:    order.CustomerEmail,
+			Locale:           order.Locale,
 		}
 	}
 
-	return &models.OrdersListResponse{
-		Orders: orderResponses,
-		Total:  total,
-	}, nil
+	response := &models.OrdersListResponse{
+		Orders:   orderResponses,
+		Total:    total,
+		PageSize: pageSize,
+		Page:     page,
+	}
+	if hasMore {
+		response.NextCursor = encodeOrderCursor(search, &orderResponses[len(orderResponses)-1])
+	}
+
+	return response, nil
 }
 
-// UpdateOrderStatus updates the status of an order
-func (s *OrderService) UpdateOrderStatus(id string, request *models.UpdateOrderStatusRequest) error {
+// UpdateOrderStatus moves an order to a new status, enforcing the allowed
+// transitions, and records who made the change and why
+func (s *OrderService) UpdateOrderStatus(id string, request *models.UpdateOrderStatusRequest) (*models.OrderStatusChange, error) {
 	if id == "" {
-		return fmt.Errorf("order ID is required")
+		return nil, fmt.Errorf("order ID is required")
 	}
 
-	// Validate status
-	validStatuses := map[string]bool{
-		"pending":    true,
-		"confirmed":  true,
-		"processing": true,
-		"shipped":    true,
-		"delivered":  true,
-		"cancelled":  true,
+	if _, ok := orderStatusTransitions[request.Status]; !ok {
+		return nil, fmt.Errorf("invalid status: %s", request.Status)
 	}
 
-	if !validStatuses[request.Status] {
-		return fmt.Errorf("invalid status: %s", request.Status)
+	actor := request.Actor
+	if actor == "" {
+		actor = "api"
 	}
 
-	err := s.orderRepo.UpdateOrderStatus(id, request.Status)
+	change, err := s.orderRepo.UpdateOrderStatus(id, request.Status, actor, request.Reason,
+		statusTransitionValidator(request.Status))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if change.ToStatus == models.OrderStatusConfirmed {
+		s.invoiceService.renderInvoice(id)
+		s.fulfillmentService.fulfill(id)
+	}
+
+	return change, nil
+}
+
+// GetOrderStatusHistory retrieves every status change of an order, oldest first
+func (s *OrderService) GetOrderStatusHistory(id string) ([]models.OrderStatusChange, error) {
+	if id == "" {
+		return nil, fmt.Errorf("order ID is required")
+	}
+
+	return s.orderRepo.GetOrderStatusHistory(id)
 }
 
 // DeleteOrder deletes an order
@@ -162,29 +405,54 @@ func (s *OrderService) DeleteOrder(id string) error {
 	return nil
 }
 
-// GetOrderStatistics provides basic order statistics
-func (s *OrderService) GetOrderStatistics() (map[string]interface{}, error) {
-	// This could be expanded to provide more detailed statistics
-	orders, total, err := s.orderRepo.GetAllOrders(1000, 0) // Get recent orders for stats
+// GetOrderStatistics aggregates the orders placed in a date range per time
+// bucket, and ranks the games and categories that brought in the most revenue.
+// Without a range every order is counted.
+func (s *OrderService) GetOrderStatistics(request *models.OrderStatisticsRequest) (*models.OrderStatistics, error) {
+	from, err := parseDateBound(request.From, false)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get orders for statistics: %v", err)
+		return nil, fmt.Errorf("%w: from %v", ErrInvalidStatistics, err)
+	}
+	to, err := parseDateBound(request.To, true)
+	if err != nil {
+		return nil, fmt.Errorf("%w: to %v", ErrInvalidStatistics, err)
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidStatistics)
 	}
 
-	statusCounts := make(map[string]int)
-	var totalRevenue float64
+	granularity := strings.ToLower(request.Granularity)
+	switch granularity {
+	case "":
+		granularity = models.StatisticsGranularityDay
+	case models.StatisticsGranularityHour, models.StatisticsGranularityDay,
+		models.StatisticsGranularityWeek, models.StatisticsGranularityMonth:
+	default:
+		return nil, fmt.Errorf("%w: granularity must be hour, day, week or month", ErrInvalidStatistics)
+	}
 
-	for _, order := range orders {
-		statusCounts[order.Status]++
-		if order.Status == "delivered" {
-			totalRevenue += order.TotalPrice
+	top := defaultStatisticsTop
+	if request.Top != "" {
+		top, err = strconv.Atoi(request.Top)
+		if err != nil || top < 1 || top > maxStatisticsTop {
+			return nil, fmt.Errorf("%w: top must be between 1 and %d", ErrInvalidStatistics, maxStatisticsTop)
 		}
 	}
 
-	stats := map[string]interface{}{
-		"total_orders":   total,
-		"total_revenue":  totalRevenue,
-		"status_counts":  statusCounts,
+	return s.orderRepo.GetOrderStatistics(from, to, granularity, top)
+}
+
+// GetGameSales returns units sold and revenue per game over the last `hours` hours
+func (s *OrderService) GetGameSales(hours int) ([]models.GameSales, error) {
+	if hours < 1 || hours > 24*365 {
+		return nil, fmt.Errorf("hours must be between 1 and 8760")
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	sales, err := s.orderRepo.GetGameSalesSince(since)
+	if err != nil {
+		return nil, err
 	}
 
-	return stats, nil
+	return sales, nil
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"order-service/models"
+)
+
+// ErrInvalidStatusTransition is returned when an order cannot move from its
+// current status to the requested one
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// orderStatusTransitions lists the statuses each status may move to.
+// Orders can be cancelled until they ship; delivered and cancelled are final.
+var orderStatusTransitions = map[string][]string{
+	models.OrderStatusPending:    {models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusConfirmed:  {models.OrderStatusProcessing, models.OrderStatusCancelled},
+	models.OrderStatusProcessing: {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:    {models.OrderStatusDelivered},
+	models.OrderStatusDelivered:  {},
+	models.OrderStatusCancelled:  {},
+}
+
+// CanTransitionOrderStatus reports whether an order may move from one status to another
+func CanTransitionOrderStatus(from, to string) bool {
+	for _, allowed := range orderStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// statusTransitionValidator returns a validator for the repository that rejects
+// illegal transitions to the given status
+func statusTransitionValidator(to string) func(from string) error {
+	return func(from string) error {
+		if !CanTransitionOrderStatus(from, to) {
+			return fmt.Errorf("%w: cannot change status from %s to %s", ErrInvalidStatusTransition, from, to)
+		}
+		return nil
+	}
+}
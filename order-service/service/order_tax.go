@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"order-service/models"
+	"order-service/tax"
+)
+
+// applyTax levies the taxes of the order's jurisdiction on every line, after
+// discounts. Orders without a country are not taxed.
+func applyTax(table *tax.Table, order *models.Order, country, region string) error {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if country == "" {
+		if region != "" {
+			return fmt.Errorf("region requires a country")
+		}
+		return nil
+	}
+	if len(country) != 2 {
+		return fmt.Errorf("country must be an ISO 3166-1 alpha-2 code")
+	}
+
+	jurisdiction := table.Jurisdiction(country, region)
+	order.Country = jurisdiction.Country
+	order.Region = jurisdiction.Region
+	order.PricesIncludeTax = jurisdiction.Inclusive
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Tax = 0
+		item.Taxes = nil
+
+		for _, charge := range jurisdiction.Calculate(item.Subtotal - item.Discount) {
+			item.Taxes = append(item.Taxes, models.TaxLine{
+				Name:          charge.Rule.Name,
+				Country:       charge.Rule.Country,
+				Region:        charge.Rule.Region,
+				Rate:          charge.Rule.Rate,
+				TaxableAmount: charge.Taxable,
+				Amount:        charge.Amount,
+			})
+			item.Tax += charge.Amount
+		}
+	}
+
+	return nil
+}
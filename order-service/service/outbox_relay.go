@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"order-service/events"
+	"order-service/models"
+	"order-service/repository"
+)
+
+const (
+	// outboxBatchSize is how many events one pass publishes at most
+	outboxBatchSize = 100
+	// outboxLease is how long a replica has to publish the events it picked up
+	outboxLease = 5 * time.Minute
+	// maxOutboxRetryDelay caps the exponential backoff between attempts
+	maxOutboxRetryDelay = 5 * time.Minute
+	// outboxCleanupInterval is how often published events past their retention are deleted
+	outboxCleanupInterval = time.Hour
+)
+
+// OutboxRelay publishes the order events that OrderRepository records in the
+// outbox, in the transaction of each change, to the event broker. Events are
+// published at least once and, per order, in the order they happened; they
+// are retried until the broker takes them.
+type OutboxRelay struct {
+	outboxRepo     *repository.OutboxRepository
+	publisher      events.Publisher
+	pollInterval   time.Duration
+	retryDelay     time.Duration
+	publishTimeout time.Duration
+	retention      time.Duration
+}
+
+// NewOutboxRelay creates a new instance of OutboxRelay using the publisher
+// selected by EVENT_PUBLISHER. The outbox is polled every OUTBOX_POLL_INTERVAL
+// (default 1s) and each publish may take OUTBOX_PUBLISH_TIMEOUT (default 10s).
+// Failed events are retried after OUTBOX_RETRY_DELAY (default 5s), doubling
+// with every failure up to five minutes. Published events are deleted after
+// OUTBOX_RETENTION (default 168h).
+func NewOutboxRelay() *OutboxRelay {
+	publisher, err := events.NewPublisher()
+	if err != nil {
+		log.Fatalf("Failed to initialize event publisher: %v", err)
+	}
+
+	return &OutboxRelay{
+		outboxRepo:     repository.NewOutboxRepository(),
+		publisher:      publisher,
+		pollInterval:   durationFromEnv("OUTBOX_POLL_INTERVAL", time.Second),
+		retryDelay:     durationFromEnv("OUTBOX_RETRY_DELAY", 5*time.Second),
+		publishTimeout: durationFromEnv("OUTBOX_PUBLISH_TIMEOUT", 10*time.Second),
+		retention:      durationFromEnv("OUTBOX_RETENTION", 7*24*time.Hour),
+	}
+}
+
+// Run publishes due events every poll interval and cleans up published ones
+// every hour. It never returns and is meant to run in its own goroutine on
+// every replica.
+func (r *OutboxRelay) Run() {
+	log.Printf("Publishing order events through the %s publisher", r.publisher.Name())
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for range ticker.C {
+		// A pass only takes the oldest unpublished event of each order, so
+		// keep going while it publishes to catch up on orders with several
+		for r.ProcessDue() > 0 {
+		}
+
+		if time.Since(lastCleanup) >= outboxCleanupInterval {
+			r.Cleanup()
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// ProcessDue publishes the events that are due and returns how many were
+// published. A failure ends the pass: the broker is most likely unavailable,
+// so the rest of the batch waits as long as the event that failed.
+func (r *OutboxRelay) ProcessDue() int {
+	now := time.Now()
+	due, err := r.outboxRepo.LeaseDueEvents(now, now.Add(outboxLease), outboxBatchSize)
+	if err != nil {
+		log.Printf("Failed to pick up due outbox events: %v", err)
+		return 0
+	}
+
+	for i := range due {
+		if err := r.publish(&due[i]); err != nil {
+			retryAt := time.Now().Add(backoff(r.retryDelay, due[i].Attempts, maxOutboxRetryDelay))
+			log.Printf("Publishing %s event %s of order %s failed, retrying at %s: %v",
+				due[i].EventType, due[i].ID, due[i].AggregateID, retryAt.Format(time.RFC3339), err)
+			if err := r.outboxRepo.RecordEventFailure(due[i].ID, err.Error(), retryAt); err != nil {
+				log.Printf("Failed to record failure of outbox event %s: %v", due[i].ID, err)
+			}
+
+			rest := make([]string, 0, len(due)-i-1)
+			for _, event := range due[i+1:] {
+				rest = append(rest, event.ID)
+			}
+			if err := r.outboxRepo.ReleaseEvents(rest, retryAt); err != nil {
+				log.Printf("Failed to release outbox events: %v", err)
+			}
+			return i
+		}
+
+		if err := r.outboxRepo.MarkEventPublished(due[i].ID, time.Now()); err != nil {
+			// The event is published again once its lease runs out
+			log.Printf("Failed to mark outbox event %s published: %v", due[i].ID, err)
+		}
+	}
+
+	return len(due)
+}
+
+// Cleanup deletes the events published longer ago than the retention
+func (r *OutboxRelay) Cleanup() {
+	deleted, err := r.outboxRepo.DeletePublishedEvents(time.Now().Add(-r.retention))
+	if err != nil {
+		log.Printf("Failed to clean up the outbox: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d published outbox events", deleted)
+	}
+}
+
+// publish hands an event to the broker, keyed by its order
+func (r *OutboxRelay) publish(event *models.OutboxEvent) error {
+	ctx, cancel := context.WithTimeout(context.Background(), r.publishTimeout)
+	defer cancel()
+
+	return r.publisher.Publish(ctx, &events.Message{
+		ID:      event.ID,
+		Type:    event.EventType,
+		Key:     event.AggregateID,
+		Payload: event.Payload,
+	})
+}
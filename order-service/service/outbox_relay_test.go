@@ -0,0 +1,112 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-service/database"
+	"order-service/events"
+	"order-service/models"
+	"order-service/money"
+	"order-service/repository"
+)
+
+// The relay tests need the PostgreSQL database configured by the DB_*
+// environment variables and are skipped without one. They publish every due
+// event of that database, so don't point them at one a running service uses.
+
+func newTestOutboxRelay(t *testing.T) (*OutboxRelay, *events.MemoryPublisher) {
+	t.Helper()
+
+	if database.DB == nil || database.DB.Ping() != nil {
+		if err := database.InitDB(); err != nil {
+			t.Skipf("Database not available: %v", err)
+		}
+	}
+
+	publisher := events.NewMemoryPublisher()
+	return &OutboxRelay{
+		outboxRepo:     repository.NewOutboxRepository(),
+		publisher:      publisher,
+		retryDelay:     time.Millisecond,
+		publishTimeout: time.Second,
+		retention:      time.Hour,
+	}, publisher
+}
+
+func createTestOrder(t *testing.T, repo *repository.OrderRepository) *models.Order {
+	t.Helper()
+
+	order := &models.Order{
+		CustomerID: fmt.Sprintf("outbox_test_%d", time.Now().UnixNano()),
+		Items: []models.OrderItem{
+			{GameID: 1, GameName: "Outbox Test Game", Price: money.FromMinor(1999), Quantity: 1},
+		},
+	}
+	if err := repo.CreateOrder(order); err != nil {
+		t.Fatalf("Failed to create order: %v", err)
+	}
+	return order
+}
+
+func drainOutbox(relay *OutboxRelay) {
+	for relay.ProcessDue() > 0 {
+	}
+}
+
+// orderEventTypes returns the types of the messages published for an order, in order
+func orderEventTypes(publisher *events.MemoryPublisher, orderID string) []string {
+	var types []string
+	for _, message := range publisher.Messages() {
+		if message.Key == orderID {
+			types = append(types, message.Type)
+		}
+	}
+	return types
+}
+
+func TestOutboxRelayPublishesOrderEventsInOrder(t *testing.T) {
+	relay, publisher := newTestOutboxRelay(t)
+	repo := repository.NewOrderRepository()
+
+	order := createTestOrder(t, repo)
+	_, err := repo.UpdateOrderStatus(order.ID, models.OrderStatusCancelled, "test", "outbox test",
+		func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("Failed to cancel order: %v", err)
+	}
+	if err := repo.DeleteOrder(order.ID); err != nil {
+		t.Fatalf("Failed to delete order: %v", err)
+	}
+
+	drainOutbox(relay)
+
+	expected := []string{models.OrderEventCreated, models.OrderEventStatusChanged, models.OrderEventDeleted}
+	if got := orderEventTypes(publisher, order.ID); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("Expected events %v, got %v", expected, got)
+	}
+}
+
+func TestOutboxRelayRetriesWhenPublishingFails(t *testing.T) {
+	relay, publisher := newTestOutboxRelay(t)
+	repo := repository.NewOrderRepository()
+
+	publisher.Fail(errors.New("broker unavailable"))
+	order := createTestOrder(t, repo)
+	defer repo.DeleteOrder(order.ID)
+
+	drainOutbox(relay)
+	if got := orderEventTypes(publisher, order.ID); len(got) != 0 {
+		t.Fatalf("Expected nothing to be published while the broker fails, got %v", got)
+	}
+
+	publisher.Fail(nil)
+	time.Sleep(10 * time.Millisecond)
+	drainOutbox(relay)
+
+	if got := orderEventTypes(publisher, order.ID); len(got) != 1 || got[0] != models.OrderEventCreated {
+		t.Errorf("Expected the retry to publish OrderCreated, got %v", got)
+	}
+}
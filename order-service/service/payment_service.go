@@ -0,0 +1,372 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"order-service/models"
+	"order-service/money"
+	"order-service/payments"
+	"order-service/repository"
+)
+
+// ErrInvalidPaymentState is returned when an operation is not allowed in the
+// current status of a payment attempt or its order
+var ErrInvalidPaymentState = errors.New("invalid payment state")
+
+// ErrPaymentDeclined is returned when the provider declines an operation
+var ErrPaymentDeclined = errors.New("payment declined")
+
+type PaymentService struct {
+	paymentRepo        *repository.PaymentRepository
+	orderRepo          *repository.OrderRepository
+	provider           payments.PaymentProvider
+	invoiceService     *InvoiceService
+	fulfillmentService *FulfillmentService
+}
+
+// NewPaymentService creates a new instance of PaymentService using the
+// provider selected by PAYMENT_PROVIDER
+func NewPaymentService() *PaymentService {
+	provider, err := payments.NewProvider()
+	if err != nil {
+		log.Fatalf("Failed to initialize payment provider: %v", err)
+	}
+
+	return &PaymentService{
+		paymentRepo:        repository.NewPaymentRepository(),
+		orderRepo:          repository.NewOrderRepository(),
+		provider:           provider,
+		invoiceService:     NewInvoiceService(),
+		fulfillmentService: NewFulfillmentService(),
+	}
+}
+
+// CreatePayment starts a payment attempt for the full total of a pending order
+// and returns it with the client secret needed to complete the payment
+func (s *PaymentService) CreatePayment(orderID string) (*models.PaymentAttempt, string, error) {
+	order, err := s.orderRepo.GetOrderByID(orderID)
+	if err != nil {
+		return nil, "", err
+	}
+	if order.Status != models.OrderStatusPending {
+		return nil, "", fmt.Errorf("%w: order is %s", ErrInvalidPaymentState, order.Status)
+	}
+
+	attempts, err := s.paymentRepo.GetAttemptsByOrderID(orderID)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, attempt := range attempts {
+		switch attempt.Status {
+		case models.PaymentStatusAuthorized, models.PaymentStatusCaptured:
+			return nil, "", fmt.Errorf("%w: order already has a successful payment %s", ErrInvalidPaymentState, attempt.ID)
+		}
+	}
+
+	intent, err := s.provider.CreateIntent(payments.IntentRequest{
+		OrderID:  order.ID,
+		Amount:   order.TotalPrice,
+		Currency: order.Currency,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create payment intent: %w", err)
+	}
+
+	attempt := &models.PaymentAttempt{
+		OrderID:           order.ID,
+		Provider:          s.provider.Name(),
+		ProviderPaymentID: intent.ID,
+		Status:            models.PaymentStatusRequiresAuthorization,
+		Amount:            order.TotalPrice,
+		Currency:          order.Currency,
+	}
+	if err := s.paymentRepo.CreateAttempt(attempt); err != nil {
+		return nil, "", err
+	}
+
+	return attempt, intent.ClientSecret, nil
+}
+
+// GetPayment retrieves a payment attempt
+func (s *PaymentService) GetPayment(id string) (*models.PaymentAttempt, error) {
+	return s.paymentRepo.GetAttemptByID(id)
+}
+
+// GetOrderPayments retrieves every payment attempt of an order
+func (s *PaymentService) GetOrderPayments(orderID string) ([]models.PaymentAttempt, error) {
+	if _, err := s.orderRepo.GetOrderByID(orderID); err != nil {
+		return nil, err
+	}
+	return s.paymentRepo.GetAttemptsByOrderID(orderID)
+}
+
+// AuthorizePayment authorizes a payment attempt with a payment method. Only
+// attempts of pending orders can be authorized. A decline is stored on the
+// attempt and reported as ErrPaymentDeclined. The order status follows from
+// the provider's webhook.
+func (s *PaymentService) AuthorizePayment(id string, request *models.AuthorizePaymentRequest) (*models.PaymentAttempt, error) {
+	attempt, err := s.paymentRepo.WithLockedOrderAttempt(id, func(orderStatus string, attempt *models.PaymentAttempt) error {
+		if err := requireOrderStatus(orderStatus, models.OrderStatusPending); err != nil {
+			return err
+		}
+		if err := requirePaymentStatus(attempt, models.PaymentStatusRequiresAuthorization); err != nil {
+			return err
+		}
+
+		result, err := s.provider.Authorize(attempt.ProviderPaymentID, request.PaymentMethod, attempt.Amount)
+		if err != nil {
+			return fmt.Errorf("failed to authorize payment: %w", err)
+		}
+
+		method := request.PaymentMethod
+		attempt.PaymentMethod = &method
+		if !result.Succeeded {
+			reason := result.FailureReason
+			attempt.Status = models.PaymentStatusFailed
+			attempt.FailureReason = &reason
+			return nil
+		}
+		attempt.Status = models.PaymentStatusAuthorized
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if attempt.Status == models.PaymentStatusFailed {
+		return attempt, fmt.Errorf("%w: %s", ErrPaymentDeclined, *attempt.FailureReason)
+	}
+	return attempt, nil
+}
+
+// CapturePayment captures an authorized payment of a pending or confirmed
+// order, by default for the full amount. License keys are only issued once
+// captured payments cover the order's total, so a capture queues the
+// fulfillment of a confirmed order.
+func (s *PaymentService) CapturePayment(id string, request *models.PaymentAmountRequest) (*models.PaymentAttempt, error) {
+	attempt, err := s.paymentRepo.WithLockedOrderAttempt(id, func(orderStatus string, attempt *models.PaymentAttempt) error {
+		if err := requireOrderStatus(orderStatus, models.OrderStatusPending, models.OrderStatusConfirmed); err != nil {
+			return err
+		}
+		if err := requirePaymentStatus(attempt, models.PaymentStatusAuthorized); err != nil {
+			return err
+		}
+
+		amount, err := paymentAmount(request, attempt.Amount)
+		if err != nil {
+			return err
+		}
+
+		result, err := s.provider.Capture(attempt.ProviderPaymentID, amount)
+		if err != nil {
+			return fmt.Errorf("failed to capture payment: %w", err)
+		}
+		if !result.Succeeded {
+			return fmt.Errorf("%w: %s", ErrPaymentDeclined, result.FailureReason)
+		}
+
+		attempt.Status = models.PaymentStatusCaptured
+		attempt.CapturedAmount = amount
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.fulfillmentService.fulfillCaptured(attempt.OrderID)
+	return attempt, nil
+}
+
+// VoidPayment releases an authorized payment that was not captured
+func (s *PaymentService) VoidPayment(id string) (*models.PaymentAttempt, error) {
+	return s.paymentRepo.WithLockedAttempt(id, func(attempt *models.PaymentAttempt) error {
+		if err := requirePaymentStatus(attempt, models.PaymentStatusAuthorized); err != nil {
+			return err
+		}
+
+		result, err := s.provider.Void(attempt.ProviderPaymentID)
+		if err != nil {
+			return fmt.Errorf("failed to void payment: %w", err)
+		}
+		if !result.Succeeded {
+			return fmt.Errorf("%w: %s", ErrPaymentDeclined, result.FailureReason)
+		}
+
+		attempt.Status = models.PaymentStatusVoided
+		return nil
+	})
+}
+
+// RefundPayment refunds part or, by default, all of the remaining captured amount
+func (s *PaymentService) RefundPayment(id string, request *models.PaymentAmountRequest) (*models.PaymentAttempt, error) {
+	return s.paymentRepo.WithLockedAttempt(id, func(attempt *models.PaymentAttempt) error {
+		if err := requirePaymentStatus(attempt, models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded); err != nil {
+			return err
+		}
+
+		amount, err := paymentAmount(request, attempt.CapturedAmount-attempt.RefundedAmount)
+		if err != nil {
+			return err
+		}
+
+		result, err := s.provider.Refund(attempt.ProviderPaymentID, amount)
+		if err != nil {
+			return fmt.Errorf("failed to refund payment: %w", err)
+		}
+		if !result.Succeeded {
+			return fmt.Errorf("%w: %s", ErrPaymentDeclined, result.FailureReason)
+		}
+
+		attempt.RefundedAmount += amount
+		attempt.Status = models.PaymentStatusPartiallyRefunded
+		if attempt.RefundedAmount >= attempt.CapturedAmount {
+			attempt.Status = models.PaymentStatusRefunded
+		}
+		return nil
+	})
+}
+
+// HandleWebhook verifies and applies a payment event from the provider. A
+// successful authorization or capture confirms a pending order, and a capture
+// queues its fulfillment; a failed authorization or a void cancels it. Each
+// event is recorded together with its effect on the payment attempt, so it
+// is processed at most once even when redeliveries arrive concurrently; they
+// are acknowledged without effect.
+func (s *PaymentService) HandleWebhook(header http.Header, body []byte) error {
+	event, err := s.provider.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+
+	provider := s.provider.Name()
+	attempt, err := s.paymentRepo.WithLockedAttemptForEvent(provider, event.ID, event.Type, event.PaymentID,
+		func(attempt *models.PaymentAttempt) error {
+			applyPaymentEvent(attempt, event)
+			return nil
+		})
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateWebhookEvent) {
+			return nil
+		}
+		return err
+	}
+
+	if status, allowedFrom := orderStatusForPaymentEvent(event.Type); status != "" {
+		reason := fmt.Sprintf("%s (payment %s)", event.Type, attempt.ID)
+		if event.FailureReason != "" {
+			reason = fmt.Sprintf("%s: %s (payment %s)", event.Type, event.FailureReason, attempt.ID)
+		}
+
+		_, err := s.orderRepo.UpdateOrderStatus(attempt.OrderID, status, "payment:"+provider, reason,
+			paymentEventValidator(status, allowedFrom))
+		if err != nil && !errors.Is(err, ErrInvalidStatusTransition) {
+			// The attempt is already up to date, so applying a redelivery again only moves the order
+			if forgetErr := s.paymentRepo.ForgetWebhookEvent(provider, event.ID); forgetErr != nil {
+				log.Printf("Failed to forget webhook event %s: %v", event.ID, forgetErr)
+			}
+			return err
+		}
+		if err == nil && status == models.OrderStatusConfirmed {
+			s.invoiceService.renderInvoice(attempt.OrderID)
+		}
+	}
+	if event.Type == payments.EventPaymentCaptured {
+		s.fulfillmentService.fulfillCaptured(attempt.OrderID)
+	}
+
+	return nil
+}
+
+// applyPaymentEvent brings an attempt up to date with a provider event. The
+// synchronous API calls usually got there first, so only forward moves are
+// applied and amounts already recorded are kept.
+func applyPaymentEvent(attempt *models.PaymentAttempt, event *payments.WebhookEvent) {
+	switch event.Type {
+	case payments.EventPaymentAuthorized:
+		if attempt.Status == models.PaymentStatusRequiresAuthorization {
+			attempt.Status = models.PaymentStatusAuthorized
+		}
+	case payments.EventPaymentFailed:
+		if attempt.Status == models.PaymentStatusRequiresAuthorization {
+			reason := event.FailureReason
+			attempt.Status = models.PaymentStatusFailed
+			attempt.FailureReason = &reason
+		}
+	case payments.EventPaymentCaptured:
+		if attempt.Status == models.PaymentStatusAuthorized {
+			attempt.Status = models.PaymentStatusCaptured
+			attempt.CapturedAmount = event.Amount
+		}
+	case payments.EventPaymentVoided:
+		if attempt.Status == models.PaymentStatusAuthorized {
+			attempt.Status = models.PaymentStatusVoided
+		}
+	}
+}
+
+// orderStatusForPaymentEvent returns the order status a payment event leads
+// to and the order statuses it may be applied from
+func orderStatusForPaymentEvent(eventType string) (string, []string) {
+	switch eventType {
+	case payments.EventPaymentAuthorized, payments.EventPaymentCaptured:
+		return models.OrderStatusConfirmed, []string{models.OrderStatusPending}
+	case payments.EventPaymentFailed:
+		return models.OrderStatusCancelled, []string{models.OrderStatusPending}
+	case payments.EventPaymentVoided:
+		return models.OrderStatusCancelled, []string{models.OrderStatusPending, models.OrderStatusConfirmed}
+	default:
+		return "", nil
+	}
+}
+
+// paymentEventValidator only lets a payment event move an order that is still
+// in one of the given statuses, so late or stale events cannot undo later changes
+func paymentEventValidator(to string, allowedFrom []string) func(from string) error {
+	return func(from string) error {
+		for _, status := range allowedFrom {
+			if from == status && CanTransitionOrderStatus(from, to) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: payment event cannot change status from %s to %s", ErrInvalidStatusTransition, from, to)
+	}
+}
+
+// requirePaymentStatus fails with ErrInvalidPaymentState unless the attempt is in one of the given statuses
+func requirePaymentStatus(attempt *models.PaymentAttempt, statuses ...string) error {
+	for _, status := range statuses {
+		if attempt.Status == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: payment is %s", ErrInvalidPaymentState, attempt.Status)
+}
+
+// requireOrderStatus fails with ErrInvalidPaymentState unless the order is in one of the given statuses
+func requireOrderStatus(orderStatus string, statuses ...string) error {
+	for _, status := range statuses {
+		if orderStatus == status {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: order is %s", ErrInvalidPaymentState, orderStatus)
+}
+
+// paymentAmount returns the requested amount, defaulting to and bounded by max
+func paymentAmount(request *models.PaymentAmountRequest, max money.Amount) (money.Amount, error) {
+	if max <= 0 {
+		return 0, fmt.Errorf("%w: nothing left to process", ErrInvalidPaymentState)
+	}
+	if request == nil || request.Amount == nil {
+		return max, nil
+	}
+
+	amount := *request.Amount
+	if amount <= 0 || amount > max {
+		return 0, fmt.Errorf("amount must be greater than 0 and at most %s", max)
+	}
+	return amount, nil
+}
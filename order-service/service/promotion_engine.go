@@ -0,0 +1,246 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"order-service/models"
+	"order-service/money"
+)
+
+// promotionLine is an order line as seen by the promotion engine
+type promotionLine struct {
+	gameID   int
+	category string
+	quantity int
+	subtotal money.Amount
+	// remaining is what is left to pay on the line after the discounts applied so far
+	remaining money.Amount
+}
+
+// pricedPromotions is the outcome of applying a set of promotions to an order
+type pricedPromotions struct {
+	discounts []models.OrderDiscount
+	// lineDiscounts holds, per order line, the discount of each promotion
+	lineDiscounts [][]models.OrderItemDiscount
+	total         money.Amount
+}
+
+// selectPromotions decides which promotions an order gets and how much each
+// takes off. Coupons must all apply, or the order is rejected. Promotions that
+// are not stackable are only ever applied alone:
+//
+//   - a coupon that is not stackable cannot be combined with other coupons and
+//     replaces every automatic promotion;
+//   - stackable coupons are combined with the stackable automatic promotions;
+//   - without coupons the customer gets whichever is worth more: the best
+//     non-stackable automatic promotion, or all stackable ones together.
+//
+// Stacked promotions apply one after the other, highest priority first, each
+// on what is left to pay after the previous ones.
+func selectPromotions(lines []promotionLine, automatic, coupons []models.Promotion) (*pricedPromotions, error) {
+	var exclusiveCoupon *models.Promotion
+	for i := range coupons {
+		if !coupons[i].Stackable {
+			if len(coupons) > 1 {
+				return nil, fmt.Errorf("%w: coupon %s cannot be combined with other coupons", ErrPromotionNotApplicable, *coupons[i].Code)
+			}
+			exclusiveCoupon = &coupons[i]
+		}
+	}
+
+	stackable := make([]models.Promotion, 0, len(automatic)+len(coupons))
+	var candidates [][]models.Promotion
+	if exclusiveCoupon != nil {
+		candidates = append(candidates, []models.Promotion{*exclusiveCoupon})
+	} else {
+		stackable = append(stackable, coupons...)
+		for _, promotion := range automatic {
+			if promotion.Stackable {
+				stackable = append(stackable, promotion)
+			} else if len(coupons) == 0 {
+				candidates = append(candidates, []models.Promotion{promotion})
+			}
+		}
+		sortPromotions(stackable)
+		candidates = append(candidates, stackable)
+	}
+
+	var best *pricedPromotions
+	for _, candidate := range candidates {
+		priced, err := applyPromotions(lines, candidate)
+		if err != nil {
+			return nil, err
+		}
+		if best == nil || priced.total > best.total {
+			best = priced
+		}
+	}
+
+	return best, nil
+}
+
+// applyPromotions applies promotions in order. Coupons that end up taking
+// nothing off fail with ErrPromotionNotApplicable; automatic promotions that
+// do not apply are skipped.
+func applyPromotions(lines []promotionLine, promotions []models.Promotion) (*pricedPromotions, error) {
+	working := make([]promotionLine, len(lines))
+	copy(working, lines)
+
+	priced := &pricedPromotions{
+		discounts:     []models.OrderDiscount{},
+		lineDiscounts: make([][]models.OrderItemDiscount, len(lines)),
+	}
+
+	for _, promotion := range promotions {
+		amounts, reason := promotionDiscount(working, promotion)
+		amount := money.Sum(amounts...)
+
+		if amount <= 0 {
+			if promotion.Code != nil {
+				if reason == "" {
+					reason = "nothing in the order is left to discount"
+				}
+				return nil, fmt.Errorf("%w: coupon %s: %s", ErrPromotionNotApplicable, *promotion.Code, reason)
+			}
+			continue
+		}
+
+		for i, lineAmount := range amounts {
+			if lineAmount <= 0 {
+				continue
+			}
+			working[i].remaining -= lineAmount
+			priced.lineDiscounts[i] = append(priced.lineDiscounts[i], models.OrderItemDiscount{
+				PromotionID: promotion.ID,
+				Amount:      lineAmount,
+			})
+		}
+		priced.discounts = append(priced.discounts, models.OrderDiscount{
+			PromotionID: promotion.ID,
+			Code:        promotion.Code,
+			Description: promotion.Name,
+			Amount:      amount,
+		})
+		priced.total += amount
+	}
+
+	return priced, nil
+}
+
+// promotionDiscount computes what one promotion takes off each line, never
+// more than what is left to pay on it. When nothing is taken off, reason
+// explains why if the promotion's conditions were not met.
+func promotionDiscount(lines []promotionLine, promotion models.Promotion) ([]money.Amount, string) {
+	amounts := make([]money.Amount, len(lines))
+
+	var eligible []int
+	var spend money.Amount
+	for i, line := range lines {
+		if promotionCovers(promotion, line) {
+			eligible = append(eligible, i)
+			spend += line.subtotal
+		}
+	}
+	if len(eligible) == 0 {
+		return amounts, "no item in the order is eligible"
+	}
+	if spend < promotion.MinSpend {
+		return amounts, fmt.Sprintf("requires a minimum spend of %s on eligible items", promotion.MinSpend)
+	}
+
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		for _, i := range eligible {
+			amounts[i] = lines[i].remaining.Percent(promotion.Value.Float())
+		}
+
+	case models.PromotionTypeFixed:
+		var base money.Amount
+		for _, i := range eligible {
+			base += lines[i].remaining
+		}
+		total := money.Min(promotion.Value, base)
+		if total <= 0 {
+			return amounts, ""
+		}
+		// Split the amount in proportion to each line; the largest line absorbs the rounding
+		largest := eligible[0]
+		var allocated money.Amount
+		for _, i := range eligible {
+			amounts[i] = total.MulRat(lines[i].remaining.Minor(), base.Minor())
+			allocated += amounts[i]
+			if lines[i].remaining > lines[largest].remaining {
+				largest = i
+			}
+		}
+		amounts[largest] += total - allocated
+
+	case models.PromotionTypeBuyXGetY:
+		groupSize := promotion.BuyQuantity + promotion.GetQuantity
+		if groupSize <= 0 {
+			return amounts, ""
+		}
+
+		// units holds the line of every eligible unit
+		var units []int
+		for _, i := range eligible {
+			for n := 0; n < lines[i].quantity; n++ {
+				units = append(units, i)
+			}
+		}
+		if len(units) < groupSize {
+			return amounts, fmt.Sprintf("requires %d eligible items", groupSize)
+		}
+
+		// Most expensive first; in every full group the cheapest units are free.
+		// Unit prices are compared as remaining/quantity without dividing.
+		sort.SliceStable(units, func(a, b int) bool {
+			la, lb := lines[units[a]], lines[units[b]]
+			return la.remaining.Mul(lb.quantity) > lb.remaining.Mul(la.quantity)
+		})
+		free := make([]int64, len(lines))
+		for start := 0; start+groupSize <= len(units); start += groupSize {
+			for _, line := range units[start+promotion.BuyQuantity : start+groupSize] {
+				free[line]++
+			}
+		}
+		for _, i := range eligible {
+			amounts[i] = lines[i].remaining.MulRat(free[i], int64(lines[i].quantity))
+		}
+	}
+
+	for i := range amounts {
+		if amounts[i] > lines[i].remaining {
+			amounts[i] = lines[i].remaining
+		}
+	}
+
+	return amounts, ""
+}
+
+// promotionCovers reports whether a line matches a promotion's game and category restrictions
+func promotionCovers(promotion models.Promotion, line promotionLine) bool {
+	if len(promotion.GameIDs) == 0 && len(promotion.Categories) == 0 {
+		return true
+	}
+	for _, id := range promotion.GameIDs {
+		if id == line.gameID {
+			return true
+		}
+	}
+	for _, category := range promotion.Categories {
+		if strings.EqualFold(category, line.category) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortPromotions orders promotions by priority, highest first
+func sortPromotions(promotions []models.Promotion) {
+	sort.SliceStable(promotions, func(a, b int) bool {
+		return promotions[a].Priority > promotions[b].Priority
+	})
+}
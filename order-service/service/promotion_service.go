@@ -0,0 +1,310 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"order-service/clients"
+	"order-service/models"
+	"order-service/money"
+	"order-service/repository"
+)
+
+var (
+	// ErrInvalidPromotion is returned when a promotion's rules are inconsistent
+	ErrInvalidPromotion = errors.New("invalid promotion")
+	// ErrPromotionNotApplicable is returned when a coupon entered by the customer cannot be applied
+	ErrPromotionNotApplicable = errors.New("promotion not applicable")
+)
+
+// maxPromotionCodeLength matches the promotions.code column
+const maxPromotionCodeLength = 100
+
+type PromotionService struct {
+	promotionRepo *repository.PromotionRepository
+	gameClient    *clients.GameClient
+}
+
+// NewPromotionService creates a new instance of PromotionService
+func NewPromotionService() *PromotionService {
+	return &PromotionService{
+		promotionRepo: repository.NewPromotionRepository(),
+		gameClient:    clients.NewGameClient(),
+	}
+}
+
+// CreatePromotion validates and stores a new promotion
+func (s *PromotionService) CreatePromotion(request *models.PromotionRequest) (*models.Promotion, error) {
+	promotion, err := promotionFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.promotionRepo.CreatePromotion(promotion); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+// UpdatePromotion replaces the rules of a promotion. Orders placed earlier keep their discounts.
+func (s *PromotionService) UpdatePromotion(id string, request *models.PromotionRequest) (*models.Promotion, error) {
+	promotion, err := promotionFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	promotion.ID = id
+
+	if err := s.promotionRepo.UpdatePromotion(promotion); err != nil {
+		return nil, err
+	}
+
+	return promotion, nil
+}
+
+// GetPromotion retrieves a promotion
+func (s *PromotionService) GetPromotion(id string) (*models.Promotion, error) {
+	return s.promotionRepo.GetPromotionByID(id)
+}
+
+// GetPromotions retrieves every promotion
+func (s *PromotionService) GetPromotions() ([]models.Promotion, error) {
+	return s.promotionRepo.GetPromotions()
+}
+
+// Preview prices a basket from the catalog and applies promotions to it
+// without placing an order or using up any promotion
+func (s *PromotionService) Preview(request *models.PromotionPreviewRequest) (*models.PromotionPreviewResponse, error) {
+	order := &models.Order{
+		CustomerID: request.CustomerID,
+		Items:      make([]models.OrderItem, len(request.Items)),
+	}
+
+	gameIDs := make([]int, len(request.Items))
+	for i, item := range request.Items {
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity must be greater than 0 for game %d", item.GameID)
+		}
+		gameIDs[i] = item.GameID
+	}
+
+	catalog, err := lookupPurchasableGames(s.gameClient, gameIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range request.Items {
+		order.Items[i] = catalogOrderItem(catalog[item.GameID], item.Quantity)
+	}
+
+	if err := s.applyPromotions(order, catalog, request.CouponCodes); err != nil {
+		return nil, err
+	}
+
+	return &models.PromotionPreviewResponse{
+		Items:         order.Items,
+		Discounts:     order.Discounts,
+		Subtotal:      order.TotalPrice + order.DiscountTotal,
+		DiscountTotal: order.DiscountTotal,
+		TotalPrice:    order.TotalPrice,
+	}, nil
+}
+
+// applyPromotions works out the discounts of an order priced from the
+// catalog and sets them on the order and its lines. Usage limits are checked
+// here so customers get a clear answer, and enforced again when the order is
+// stored.
+func (s *PromotionService) applyPromotions(order *models.Order, catalog map[int]clients.CatalogGame, couponCodes []string) error {
+	now := time.Now()
+
+	coupons, err := s.lookupCoupons(couponCodes, now)
+	if err != nil {
+		return err
+	}
+
+	automatic, err := s.promotionRepo.GetAutomaticPromotions(now)
+	if err != nil {
+		return err
+	}
+
+	// Leave out promotions the customer has used as often as allowed
+	var limited []string
+	for _, group := range [][]models.Promotion{coupons, automatic} {
+		for _, promotion := range group {
+			if promotion.PerCustomerLimit != nil {
+				limited = append(limited, promotion.ID)
+			}
+		}
+	}
+	used, err := s.promotionRepo.CountCustomerRedemptions(order.CustomerID, limited)
+	if err != nil {
+		return err
+	}
+	for _, coupon := range coupons {
+		if coupon.PerCustomerLimit != nil && used[coupon.ID] >= *coupon.PerCustomerLimit {
+			return fmt.Errorf("%w: coupon %s can be used %d time(s) per customer", ErrPromotionNotApplicable, *coupon.Code, *coupon.PerCustomerLimit)
+		}
+	}
+	available := automatic[:0]
+	for _, promotion := range automatic {
+		if promotion.PerCustomerLimit == nil || used[promotion.ID] < *promotion.PerCustomerLimit {
+			available = append(available, promotion)
+		}
+	}
+
+	lines := make([]promotionLine, len(order.Items))
+	for i, item := range order.Items {
+		subtotal := item.Price.Mul(item.Quantity)
+		lines[i] = promotionLine{
+			gameID:    item.GameID,
+			category:  catalog[item.GameID].Category,
+			quantity:  item.Quantity,
+			subtotal:  subtotal,
+			remaining: subtotal,
+		}
+	}
+
+	priced, err := selectPromotions(lines, available, coupons)
+	if err != nil {
+		return err
+	}
+
+	order.Discounts = priced.discounts
+	order.TotalPrice, order.DiscountTotal = 0, 0
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.Subtotal = lines[i].subtotal
+		item.Discounts = priced.lineDiscounts[i]
+		item.Discount = 0
+		for _, discount := range item.Discounts {
+			item.Discount += discount.Amount
+		}
+		order.TotalPrice += item.Subtotal - item.Discount
+		order.DiscountTotal += item.Discount
+	}
+
+	return nil
+}
+
+// lookupCoupons resolves coupon codes and checks that each one can currently be used
+func (s *PromotionService) lookupCoupons(codes []string, at time.Time) ([]models.Promotion, error) {
+	normalized := make([]string, 0, len(codes))
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		code = normalizePromotionCode(code)
+		if code == "" || seen[code] {
+			continue
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+	if len(normalized) == 0 {
+		return nil, nil
+	}
+
+	promotions, err := s.promotionRepo.GetPromotionsByCodes(normalized)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string]models.Promotion, len(promotions))
+	for _, promotion := range promotions {
+		byCode[*promotion.Code] = promotion
+	}
+
+	coupons := make([]models.Promotion, 0, len(normalized))
+	for _, code := range normalized {
+		coupon, ok := byCode[code]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: coupon %s does not exist", ErrPromotionNotApplicable, code)
+		case !coupon.Active:
+			return nil, fmt.Errorf("%w: coupon %s is not active", ErrPromotionNotApplicable, code)
+		case coupon.StartsAt != nil && at.Before(*coupon.StartsAt):
+			return nil, fmt.Errorf("%w: coupon %s is not valid before %s", ErrPromotionNotApplicable, code, coupon.StartsAt.Format(time.RFC3339))
+		case coupon.EndsAt != nil && !at.Before(*coupon.EndsAt):
+			return nil, fmt.Errorf("%w: coupon %s expired on %s", ErrPromotionNotApplicable, code, coupon.EndsAt.Format(time.RFC3339))
+		case coupon.UsageLimit != nil && coupon.UsageCount >= *coupon.UsageLimit:
+			return nil, fmt.Errorf("%w: coupon %s has been used up", ErrPromotionNotApplicable, code)
+		}
+		coupons = append(coupons, coupon)
+	}
+
+	return coupons, nil
+}
+
+// promotionFromRequest validates a promotion request and turns it into a promotion
+func promotionFromRequest(request *models.PromotionRequest) (*models.Promotion, error) {
+	promotion := &models.Promotion{
+		Name:             strings.TrimSpace(request.Name),
+		Type:             request.Type,
+		Value:            request.Value,
+		BuyQuantity:      request.BuyQuantity,
+		GetQuantity:      request.GetQuantity,
+		MinSpend:         request.MinSpend,
+		GameIDs:          request.GameIDs,
+		Categories:       request.Categories,
+		StartsAt:         request.StartsAt,
+		EndsAt:           request.EndsAt,
+		UsageLimit:       request.UsageLimit,
+		PerCustomerLimit: request.PerCustomerLimit,
+		Stackable:        request.Stackable,
+		Priority:         request.Priority,
+		Active:           request.Active == nil || *request.Active,
+	}
+	if promotion.GameIDs == nil {
+		promotion.GameIDs = []int{}
+	}
+	if promotion.Categories == nil {
+		promotion.Categories = []string{}
+	}
+
+	if promotion.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidPromotion)
+	}
+
+	if request.Code != nil {
+		code := normalizePromotionCode(*request.Code)
+		if code == "" || len(code) > maxPromotionCodeLength {
+			return nil, fmt.Errorf("%w: code must be between 1 and %d characters", ErrInvalidPromotion, maxPromotionCodeLength)
+		}
+		promotion.Code = &code
+	}
+
+	switch promotion.Type {
+	case models.PromotionTypePercentage:
+		if promotion.Value <= 0 || promotion.Value > 100*money.MinorUnits {
+			return nil, fmt.Errorf("%w: percentage value must be between 0 and 100", ErrInvalidPromotion)
+		}
+	case models.PromotionTypeFixed:
+		if promotion.Value <= 0 {
+			return nil, fmt.Errorf("%w: fixed value must be greater than 0", ErrInvalidPromotion)
+		}
+	case models.PromotionTypeBuyXGetY:
+		if promotion.BuyQuantity < 1 || promotion.GetQuantity < 1 {
+			return nil, fmt.Errorf("%w: buy_quantity and get_quantity must be at least 1", ErrInvalidPromotion)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown type %s", ErrInvalidPromotion, promotion.Type)
+	}
+
+	// Timestamps are stored without a time zone, like every other timestamp of the service
+	if promotion.StartsAt != nil {
+		startsAt := promotion.StartsAt.Local()
+		promotion.StartsAt = &startsAt
+	}
+	if promotion.EndsAt != nil {
+		endsAt := promotion.EndsAt.Local()
+		promotion.EndsAt = &endsAt
+	}
+	if promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		return nil, fmt.Errorf("%w: ends_at must be after starts_at", ErrInvalidPromotion)
+	}
+
+	return promotion, nil
+}
+
+// normalizePromotionCode makes coupon codes case-insensitive
+func normalizePromotionCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"order-service/models"
+	"order-service/money"
+	"order-service/repository"
+)
+
+var (
+	// ErrInvalidRefund is returned when a refund request does not fit what is left to refund on the order
+	ErrInvalidRefund = errors.New("invalid refund")
+	// ErrRefundFailed is returned when an approved refund could not be paid back
+	ErrRefundFailed = errors.New("refund failed")
+)
+
+type RefundService struct {
+	refundRepo     *repository.RefundRepository
+	paymentRepo    *repository.PaymentRepository
+	paymentService *PaymentService
+}
+
+// NewRefundService creates a new instance of RefundService
+func NewRefundService() *RefundService {
+	return &RefundService{
+		refundRepo:     repository.NewRefundRepository(),
+		paymentRepo:    repository.NewPaymentRepository(),
+		paymentService: NewPaymentService(),
+	}
+}
+
+// RequestRefund records a refund request for an order or some of its lines.
+// The request waits for approval before any money is moved. Only money that
+// was captured can be refunded: payments that are only authorized are voided
+// instead. Paid orders can be cancelled until they ship, so cancelled orders
+// can be refunded as long as a captured payment still holds their money.
+// Orders paid before payments were taken through the provider have no
+// payment attempts at all and can still be refunded, to be settled outside
+// the provider, unless they were cancelled.
+func (s *RefundService) RequestRefund(orderID string, request *models.CreateRefundRequest) (*models.Refund, error) {
+	if len(request.Items) > 0 && request.Amount != nil {
+		return nil, fmt.Errorf("%w: amount cannot be combined with items; set the amount per item instead", ErrInvalidRefund)
+	}
+
+	attempts, err := s.paymentRepo.GetAttemptsByOrderID(orderID)
+	if err != nil {
+		return nil, err
+	}
+	captured, authorized := capturedPayments(attempts)
+	if captured == 0 && authorized != nil {
+		return nil, fmt.Errorf("%w: payment %s is only authorized; void it with POST /payments/%s/void instead of refunding",
+			ErrInvalidRefund, authorized.ID, authorized.ID)
+	}
+	if captured == 0 && len(attempts) > 0 {
+		return nil, fmt.Errorf("%w: order has no captured payment", ErrInvalidRefund)
+	}
+
+	requestedBy := request.RequestedBy
+	if requestedBy == "" {
+		requestedBy = "api"
+	}
+
+	return s.refundRepo.CreateRefund(orderID, func(order *models.Order, committed *models.RefundCommitments) (*models.Refund, error) {
+		switch order.Status {
+		case models.OrderStatusPending:
+			return nil, fmt.Errorf("%w: order has not been paid", ErrInvalidRefund)
+		case models.OrderStatusCancelled:
+			if len(attempts) == 0 {
+				return nil, fmt.Errorf("%w: order was cancelled", ErrInvalidRefund)
+			}
+		}
+
+		refund := &models.Refund{
+			Reason:      request.Reason,
+			RequestedBy: requestedBy,
+			Items:       []models.RefundItem{},
+		}
+
+		remaining := order.TotalPrice - committed.Amount
+		if len(attempts) > 0 {
+			// Completed refunds are already taken off the captured amounts; pending ones are not yet
+			pending := committed.Amount - order.RefundedAmount
+			if left := captured - pending; left < remaining {
+				remaining = left
+			}
+		}
+		if remaining <= 0 {
+			return nil, fmt.Errorf("%w: order is already fully refunded or has refunds pending", ErrInvalidRefund)
+		}
+
+		if len(request.Items) == 0 {
+			amount, err := paymentAmount(&models.PaymentAmountRequest{Amount: request.Amount}, remaining)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidRefund, err)
+			}
+			refund.Amount = amount
+			return refund, nil
+		}
+
+		items, err := buildRefundItems(order, committed, request.Items)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			refund.Amount += item.Amount
+		}
+		refund.Items = items
+
+		if refund.Amount > remaining {
+			return nil, fmt.Errorf("%w: refund of %s exceeds the %s left to refund on the order", ErrInvalidRefund, refund.Amount, remaining)
+		}
+
+		return refund, nil
+	})
+}
+
+// buildRefundItems validates line-level refund requests against the order
+// lines and what other refunds already hold on them
+func buildRefundItems(order *models.Order, committed *models.RefundCommitments, requests []models.CreateRefundItemRequest) ([]models.RefundItem, error) {
+	orderItems := make(map[string]models.OrderItem, len(order.Items))
+	for _, item := range order.Items {
+		orderItems[item.ID] = item
+	}
+
+	items := make([]models.RefundItem, 0, len(requests))
+	seen := make(map[string]bool, len(requests))
+	for _, requested := range requests {
+		orderItem, ok := orderItems[requested.OrderItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: item %s does not belong to the order", ErrInvalidRefund, requested.OrderItemID)
+		}
+		if seen[requested.OrderItemID] {
+			return nil, fmt.Errorf("%w: item %s is listed more than once", ErrInvalidRefund, requested.OrderItemID)
+		}
+		seen[requested.OrderItemID] = true
+
+		// Lines are refunded at what was paid for them, after discounts and with tax
+		paid := orderItem.Subtotal - orderItem.Discount
+		if !order.PricesIncludeTax {
+			paid += orderItem.Tax
+		}
+		remainingQuantity := orderItem.Quantity - committed.ItemQuantities[orderItem.ID]
+		remainingAmount := paid - committed.ItemAmounts[orderItem.ID]
+		if remainingQuantity <= 0 || remainingAmount <= 0 {
+			return nil, fmt.Errorf("%w: item %s is already fully refunded or has refunds pending", ErrInvalidRefund, orderItem.ID)
+		}
+
+		quantity := requested.Quantity
+		if quantity == 0 {
+			quantity = remainingQuantity
+		}
+		if quantity > remainingQuantity {
+			return nil, fmt.Errorf("%w: only %d unit(s) of item %s are left to refund", ErrInvalidRefund, remainingQuantity, orderItem.ID)
+		}
+
+		amount := paid.MulRat(int64(quantity), int64(orderItem.Quantity))
+		if amount > remainingAmount {
+			amount = remainingAmount
+		}
+		if requested.Amount != nil {
+			if *requested.Amount > amount {
+				return nil, fmt.Errorf("%w: at most %s can be refunded for %d unit(s) of item %s", ErrInvalidRefund, amount, quantity, orderItem.ID)
+			}
+			amount = *requested.Amount
+		}
+
+		items = append(items, models.RefundItem{
+			OrderItemID: orderItem.ID,
+			GameID:      orderItem.GameID,
+			Quantity:    quantity,
+			Amount:      amount,
+		})
+	}
+
+	return items, nil
+}
+
+// GetRefund retrieves a refund
+func (s *RefundService) GetRefund(id string) (*models.Refund, error) {
+	return s.refundRepo.GetRefundByID(id)
+}
+
+// GetOrderRefunds retrieves every refund of an order
+func (s *RefundService) GetOrderRefunds(orderID string) ([]models.Refund, error) {
+	return s.refundRepo.GetRefundsByOrderID(orderID)
+}
+
+// ApproveRefund approves a requested refund, or retries a failed one, and
+// pays it back through a captured payment of the order that covers it.
+// Orders paid before payments were taken through the provider have no
+// payment attempts; their refunds are only completed, without a payment
+// link, when the approval sets SettleOutsideProvider to confirm the money is
+// paid back by other means. If the provider refund fails, or no payment can
+// cover the refund, the refund is marked failed and ErrRefundFailed is
+// returned along with it.
+func (s *RefundService) ApproveRefund(id string, request *models.ReviewRefundRequest) (*models.Refund, error) {
+	refund, err := s.refundRepo.ReviewRefund(id,
+		[]string{models.RefundStatusRequested, models.RefundStatusFailed},
+		models.RefundStatusApproved, reviewActor(request), request.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts, err := s.paymentRepo.GetAttemptsByOrderID(refund.OrderID)
+	if err != nil {
+		return s.failRefund(refund.ID, err)
+	}
+
+	if len(attempts) == 0 {
+		if !request.SettleOutsideProvider {
+			return s.failRefund(refund.ID, fmt.Errorf("order has no payments; approve with settle_outside_provider once the money is paid back by other means"))
+		}
+		return s.refundRepo.CompleteRefund(refund.ID, nil)
+	}
+
+	var payment *models.PaymentAttempt
+	for i, attempt := range attempts {
+		if isRefundablePayment(attempt) && attempt.CapturedAmount-attempt.RefundedAmount >= refund.Amount {
+			payment = &attempts[i]
+			break
+		}
+	}
+	if payment == nil {
+		return s.failRefund(refund.ID, fmt.Errorf("no captured payment has %s left to refund", refund.Amount))
+	}
+
+	amount := refund.Amount
+	if _, err := s.paymentService.RefundPayment(payment.ID, &models.PaymentAmountRequest{Amount: &amount}); err != nil {
+		return s.failRefund(refund.ID, err)
+	}
+
+	return s.refundRepo.CompleteRefund(refund.ID, &payment.ID)
+}
+
+// RejectRefund rejects a requested refund, releasing its amount for other refunds
+func (s *RefundService) RejectRefund(id string, request *models.ReviewRefundRequest) (*models.Refund, error) {
+	return s.refundRepo.ReviewRefund(id, []string{models.RefundStatusRequested},
+		models.RefundStatusRejected, reviewActor(request), request.Note)
+}
+
+// failRefund records why an approved refund could not be paid back
+func (s *RefundService) failRefund(id string, cause error) (*models.Refund, error) {
+	refund, err := s.refundRepo.FailRefund(id, cause.Error())
+	if err != nil {
+		return nil, err
+	}
+	return refund, fmt.Errorf("%w: %v", ErrRefundFailed, cause)
+}
+
+// capturedPayments sums what is left to refund on the captured payments of
+// an order, and returns an attempt that is authorized but not captured, if any
+func capturedPayments(attempts []models.PaymentAttempt) (money.Amount, *models.PaymentAttempt) {
+	var captured money.Amount
+	var authorized *models.PaymentAttempt
+	for i, attempt := range attempts {
+		switch {
+		case isRefundablePayment(attempt):
+			captured += attempt.CapturedAmount - attempt.RefundedAmount
+		case attempt.Status == models.PaymentStatusAuthorized:
+			authorized = &attempts[i]
+		}
+	}
+	return captured, authorized
+}
+
+// isRefundablePayment reports whether an attempt holds captured money that can be refunded
+func isRefundablePayment(attempt models.PaymentAttempt) bool {
+	return attempt.Status == models.PaymentStatusCaptured || attempt.Status == models.PaymentStatusPartiallyRefunded
+}
+
+func reviewActor(request *models.ReviewRefundRequest) string {
+	if request.Actor == "" {
+		return "api"
+	}
+	return request.Actor
+}
@@ -0,0 +1,405 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"order-service/models"
+	"order-service/payments"
+	"order-service/repository"
+)
+
+// ErrInvalidWebhookSubscription is returned when a subscription's URL or event types are not accepted
+var ErrInvalidWebhookSubscription = errors.New("invalid webhook subscription")
+
+// errWebhookAddressNotAllowed fails deliveries to addresses inside the network
+var errWebhookAddressNotAllowed = errors.New("webhook address not allowed")
+
+// Headers of every webhook delivery. The signature has the same form as the
+// payment provider's: "t=<unix time>,v1=<hex HMAC-SHA256>" over the timestamp
+// and the body joined by a dot, keyed with the subscription's secret.
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	// webhookBatchSize is how many due deliveries one poll works through
+	webhookBatchSize = 50
+	// webhookLease is how long a replica has to finish a delivery it picked up
+	webhookLease = 5 * time.Minute
+	// maxWebhookRetryDelay caps the exponential backoff between attempts
+	maxWebhookRetryDelay = time.Hour
+
+	// defaultWebhookDeliveryLimit and maxWebhookDeliveryLimit bound a page of the delivery log
+	defaultWebhookDeliveryLimit = 50
+	maxWebhookDeliveryLimit     = 200
+)
+
+// webhookEventTypes are the event types a subscription can filter on
+var webhookEventTypes = map[string]bool{
+	models.WebhookEventOrderCreated:       true,
+	models.WebhookEventOrderStatusChanged: true,
+	models.WebhookEventOrderRefunded:      true,
+	models.WebhookEventOrderDeleted:       true,
+	models.WebhookEventAll:                true,
+}
+
+// WebhookService manages webhook subscriptions and delivers the order events
+// queued for them. Events are queued in the transaction of the order change;
+// the service posts them in the background and retries failures until a
+// delivery runs out of attempts and is dead.
+type WebhookService struct {
+	webhookRepo  *repository.WebhookRepository
+	httpClient   *http.Client
+	pollInterval time.Duration
+	retryDelay   time.Duration
+	maxAttempts  int
+}
+
+// NewWebhookService creates a new instance of WebhookService. Due deliveries
+// are polled every WEBHOOK_POLL_INTERVAL (default 5s) and posted with a
+// timeout of WEBHOOK_TIMEOUT (default 10s). Failed attempts are retried after
+// WEBHOOK_RETRY_DELAY (default 30s), doubling with every failure up to an
+// hour, until WEBHOOK_MAX_ATTEMPTS (default 10) attempts have failed.
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		webhookRepo: repository.NewWebhookRepository(),
+		httpClient: &http.Client{
+			Timeout: durationFromEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+			// Subscribers name arbitrary URLs, so every connection is checked
+			// against the address it actually dials, after DNS resolution. No
+			// proxy is used, as it would dial on the service's behalf.
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout: 5 * time.Second,
+					Control: webhookDialControl,
+				}).DialContext,
+				TLSHandshakeTimeout: 5 * time.Second,
+				MaxIdleConnsPerHost: 2,
+			},
+			// A redirect is a failed delivery; following it would turn the POST into a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		pollInterval: durationFromEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		retryDelay:   durationFromEnv("WEBHOOK_RETRY_DELAY", 30*time.Second),
+		maxAttempts:  intFromEnv("WEBHOOK_MAX_ATTEMPTS", 10),
+	}
+}
+
+// CreateSubscription validates and stores a new subscription. Its secret is
+// generated unless given, and only returned here.
+func (s *WebhookService) CreateSubscription(request *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	subscription, err := webhookSubscriptionFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if subscription.Secret == "" {
+		subscription.Secret, err = generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.webhookRepo.CreateSubscription(subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// UpdateSubscription replaces a subscription. The secret is only replaced,
+// and returned, when the request has one.
+func (s *WebhookService) UpdateSubscription(id string, request *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	subscription, err := webhookSubscriptionFromRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	subscription.ID = id
+
+	if err := s.webhookRepo.UpdateSubscription(subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// GetSubscription retrieves a subscription without its secret
+func (s *WebhookService) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	subscription, err := s.webhookRepo.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Secret = ""
+	return subscription, nil
+}
+
+// GetSubscriptions retrieves every subscription without their secrets
+func (s *WebhookService) GetSubscriptions() ([]models.WebhookSubscription, error) {
+	subscriptions, err := s.webhookRepo.GetSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription deletes a subscription and its delivery log
+func (s *WebhookService) DeleteSubscription(id string) error {
+	return s.webhookRepo.DeleteSubscription(id)
+}
+
+// GetDeliveries retrieves the latest deliveries of a subscription. status
+// optionally filters them and limit defaults to 50, at most 200.
+func (s *WebhookService) GetDeliveries(subscriptionID, status, limit string) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusDelivered, models.WebhookDeliveryStatusDead:
+	default:
+		return nil, fmt.Errorf("%w: status must be %s, %s or %s", ErrInvalidWebhookSubscription,
+			models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusDelivered, models.WebhookDeliveryStatusDead)
+	}
+
+	size := defaultWebhookDeliveryLimit
+	if limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed <= 0 || parsed > maxWebhookDeliveryLimit {
+			return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidWebhookSubscription, maxWebhookDeliveryLimit)
+		}
+		size = parsed
+	}
+
+	if _, err := s.webhookRepo.GetSubscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.GetDeliveries(subscriptionID, status, size)
+}
+
+// GetDelivery retrieves a delivery with the log of its attempts
+func (s *WebhookService) GetDelivery(id string) (*models.WebhookDelivery, error) {
+	return s.webhookRepo.GetDelivery(id)
+}
+
+// Redeliver queues a delivery to be sent again right away, with a fresh set
+// of attempts, whether it is dead, delivered or still pending
+func (s *WebhookService) Redeliver(id string) (*models.WebhookDelivery, error) {
+	return s.webhookRepo.Redeliver(id, time.Now())
+}
+
+// Run delivers due webhooks every poll interval. It never returns and is
+// meant to run in its own goroutine on every replica.
+func (s *WebhookService) Run() {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.ProcessDue()
+	}
+}
+
+// ProcessDue attempts every delivery that is due
+func (s *WebhookService) ProcessDue() {
+	now := time.Now()
+	due, err := s.webhookRepo.LeaseDueDeliveries(now, now.Add(webhookLease), webhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to pick up due webhook deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		s.attempt(&due[i])
+	}
+}
+
+// attempt posts a delivery and logs the outcome. Failures are retried with
+// exponential backoff; the delivery is dead once its attempts run out.
+func (s *WebhookService) attempt(due *models.DueWebhookDelivery) {
+	started := time.Now()
+	attempt := &models.WebhookDeliveryAttempt{
+		DeliveryID:  due.ID,
+		URL:         due.URL,
+		AttemptedAt: started,
+	}
+	err := s.post(due, attempt)
+	attempt.DurationMS = time.Since(started).Milliseconds()
+
+	status, nextAttemptAt := models.WebhookDeliveryStatusDelivered, started
+	if err != nil {
+		reason := err.Error()
+		attempt.Error = &reason
+
+		if due.Attempts+1 >= s.maxAttempts {
+			status = models.WebhookDeliveryStatusDead
+			log.Printf("Webhook delivery %s of %s to %s is dead after %d attempts: %v",
+				due.ID, due.EventType, due.URL, due.Attempts+1, err)
+		} else {
+			status = models.WebhookDeliveryStatusPending
+			delay := backoff(s.retryDelay, due.Attempts, maxWebhookRetryDelay)
+			nextAttemptAt = started.Add(delay)
+			log.Printf("Webhook delivery %s of %s to %s failed, retrying in %s: %v",
+				due.ID, due.EventType, due.URL, delay, err)
+		}
+	}
+
+	if err := s.webhookRepo.RecordDeliveryAttempt(attempt, status, nextAttemptAt); err != nil {
+		log.Printf("Failed to record attempt of webhook delivery %s: %v", due.ID, err)
+	}
+}
+
+// post sends a signed delivery and records the response status on the
+// attempt. Only a 2xx response counts as delivered. The response body is
+// neither read nor kept, so the delivery log cannot be used to read pages
+// the service can reach.
+func (s *WebhookService) post(due *models.DueWebhookDelivery, attempt *models.WebhookDeliveryAttempt) error {
+	request, err := http.NewRequest(http.MethodPost, due.URL, bytes.NewReader(due.Payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "lugx-order-service-webhooks")
+	request.Header.Set(WebhookEventHeader, due.EventType)
+	request.Header.Set(WebhookDeliveryHeader, due.ID)
+	request.Header.Set(WebhookSignatureHeader, payments.Sign(due.Secret, due.Payload, time.Now()))
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("request failed: %v", err)
+	}
+	response.Body.Close()
+
+	statusCode := response.StatusCode
+	attempt.ResponseStatus = &statusCode
+
+	if statusCode < 200 || statusCode > 299 {
+		return fmt.Errorf("endpoint responded %s", response.Status)
+	}
+	return nil
+}
+
+// webhookBlockedIPv4 are the IPv4 ranges webhooks are never delivered to:
+// "this network" (0.x.x.x reaches the local host on Linux), private, shared
+// carrier-grade NAT (some clouds serve metadata there), loopback, link-local
+// (including 169.254.169.254), IETF protocol assignments, documentation and
+// benchmarking, multicast, reserved and broadcast
+var webhookBlockedIPv4 = mustParseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.0.2.0/24", "192.168.0.0/16", "198.18.0.0/15", "198.51.100.0/24",
+	"203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+)
+
+// webhookBlockedIPv6 are the IPv6 ranges webhooks are never delivered to:
+// unspecified, loopback and IPv4-compatible, discard, local-use NAT64,
+// documentation, unique local, link-local, site-local and multicast
+var webhookBlockedIPv6 = mustParseCIDRs(
+	"::/96", "100::/64", "64:ff9b:1::/48", "2001:db8::/32", "fc00::/7", "fe80::/10", "fec0::/10", "ff00::/8",
+)
+
+// IPv6 ranges that carry an IPv4 address, which is checked instead
+var (
+	webhookNAT64Prefix = mustParseCIDRs("64:ff9b::/96")[0]
+	webhook6to4Prefix  = mustParseCIDRs("2002::/16")[0]
+)
+
+// webhookDialControl refuses connections to the addresses webhookAddressAllowed rejects
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !webhookAddressAllowed(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddressNotAllowed, host)
+	}
+	return nil
+}
+
+// webhookAddressAllowed reports whether ip is outside every blocked range.
+// IPv4-mapped, NAT64 and 6to4 addresses are judged by the IPv4 address they
+// lead to.
+func webhookAddressAllowed(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		return !anyNetworkContains(webhookBlockedIPv4, ip4)
+	}
+	switch {
+	case webhookNAT64Prefix.Contains(ip):
+		return webhookAddressAllowed(ip[12:16])
+	case webhook6to4Prefix.Contains(ip):
+		return webhookAddressAllowed(ip[2:6])
+	}
+	return !anyNetworkContains(webhookBlockedIPv6, ip)
+}
+
+func anyNetworkContains(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// mustParseCIDRs parses CIDR ranges known at compile time
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// webhookSubscriptionFromRequest validates a subscription request. Event
+// types are deduplicated; subscriptions are active unless told otherwise.
+func webhookSubscriptionFromRequest(request *models.WebhookSubscriptionRequest) (*models.WebhookSubscription, error) {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhookSubscription)
+	}
+
+	subscription := &models.WebhookSubscription{
+		URL:         request.URL,
+		Description: strings.TrimSpace(request.Description),
+		Active:      request.Active == nil || *request.Active,
+		Secret:      request.Secret,
+	}
+
+	seen := make(map[string]bool, len(request.EventTypes))
+	for _, eventType := range request.EventTypes {
+		if !webhookEventTypes[eventType] {
+			return nil, fmt.Errorf("%w: unknown event type %s", ErrInvalidWebhookSubscription, eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			subscription.EventTypes = append(subscription.EventTypes, eventType)
+		}
+	}
+
+	return subscription, nil
+}
+
+// generateWebhookSecret creates a random signing secret
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWebhookDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:4700::6810:85e5]:443", true},
+		{"100.63.255.255:80", true},
+		{"100.128.0.0:80", true},
+		{"[64:ff9b::5db8:d822]:443", true},
+		{"[2002:5db8:d822::1]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.1.2.3:80", false},
+		{"[::1]:80", false},
+		{"0.0.0.0:80", false},
+		{"0.1.2.3:80", false},
+		{"[::]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.10:8080", false},
+		{"100.64.0.1:80", false},
+		{"100.100.100.200:80", false},
+		{"169.254.169.254:80", false},
+		{"198.18.0.1:80", false},
+		{"224.0.0.1:80", false},
+		{"255.255.255.255:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[ff02::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:169.254.169.254]:80", false},
+		{"[::127.0.0.1]:80", false},
+		{"[64:ff9b::7f00:1]:80", false},
+		{"[64:ff9b::a9fe:a9fe]:80", false},
+		{"[64:ff9b:1::1]:80", false},
+		{"[2002:a00:1::1]:80", false},
+		{"not-an-ip:80", false},
+	}
+
+	for _, test := range tests {
+		err := webhookDialControl("tcp", test.address, nil)
+		if test.allowed && err != nil {
+			t.Errorf("Expected %s to be allowed, got %v", test.address, err)
+		}
+		if !test.allowed && !errors.Is(err, errWebhookAddressNotAllowed) {
+			t.Errorf("Expected %s to be refused, got %v", test.address, err)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package tax
+
+import (
+	"math"
+
+	"order-service/money"
+)
+
+// ratePrecision is the number of rate units per 1%, so rates with up to
+// four decimals, such as 9.975, are represented exactly
+const ratePrecision = 10000
+
+// Jurisdiction is the set of rules that apply to one country and region
+type Jurisdiction struct {
+	Country   string
+	Region    string
+	Inclusive bool
+	Rules     []Rule
+}
+
+// Charge is the tax one rule levies on one order line
+type Charge struct {
+	Rule Rule
+	// Taxable is the amount the rule is levied on, excluding tax
+	Taxable money.Amount
+	Amount  money.Amount
+}
+
+// Calculate computes the tax every rule levies on a line amount. For
+// tax-exclusive jurisdictions the amount is the net price and each charge is
+// rounded half up to the cent. For tax-inclusive jurisdictions the amount
+// already contains the tax: the net price is rounded first, and the charges
+// always add up to exactly amount minus the net price, with the last rule
+// absorbing the rounding difference.
+func (j Jurisdiction) Calculate(amount money.Amount) []Charge {
+	if len(j.Rules) == 0 {
+		return nil
+	}
+
+	cents := amount.Minor()
+	rates := make([]int64, len(j.Rules))
+	var totalRate int64
+	for i, rule := range j.Rules {
+		rates[i] = int64(math.Round(rule.Rate * ratePrecision))
+		totalRate += rates[i]
+	}
+
+	// whole is the rate value of 100%
+	const whole = 100 * ratePrecision
+	net := cents
+	if j.Inclusive {
+		net = divideRounded(cents*whole, whole+totalRate)
+	}
+
+	charges := make([]Charge, len(j.Rules))
+	var charged int64
+	for i, rule := range j.Rules {
+		taxCents := divideRounded(net*rates[i], whole)
+		if j.Inclusive && i == len(j.Rules)-1 {
+			taxCents = cents - net - charged
+		}
+		charged += taxCents
+
+		charges[i] = Charge{
+			Rule:    rule,
+			Taxable: money.FromMinor(net),
+			Amount:  money.FromMinor(taxCents),
+		}
+	}
+
+	return charges
+}
+
+// divideRounded divides non-negative integers, rounding half up
+func divideRounded(numerator, denominator int64) int64 {
+	return (2*numerator + denominator) / (2 * denominator)
+}
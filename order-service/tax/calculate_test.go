@@ -0,0 +1,115 @@
+package tax
+
+import (
+	"testing"
+
+	"order-service/money"
+)
+
+func TestCalculate(t *testing.T) {
+	table, err := ParseTable(defaultRules)
+	if err != nil {
+		t.Fatalf("Failed to parse the built-in rules: %v", err)
+	}
+
+	// splitVAT is a tax-inclusive jurisdiction with two rules, so that the
+	// last one has a rounding difference to absorb
+	splitVAT := Jurisdiction{
+		Country:   "XX",
+		Inclusive: true,
+		Rules: []Rule{
+			{Country: "XX", Name: "First", Rate: 5, Inclusive: true},
+			{Country: "XX", Name: "Second", Rate: 5, Inclusive: true},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		jurisdiction Jurisdiction
+		amount       money.Amount
+		taxable      money.Amount
+		charges      []money.Amount
+	}{
+		{
+			name:         "exclusive rounds half up",
+			jurisdiction: table.Jurisdiction("US", "CA"),
+			amount:       money.FromMinor(1000),
+			taxable:      money.FromMinor(1000),
+			charges:      []money.Amount{money.FromMinor(73)},
+		},
+		{
+			name:         "exclusive four decimal rate",
+			jurisdiction: table.Jurisdiction("ca", " qc "),
+			amount:       money.FromMinor(10000),
+			taxable:      money.FromMinor(10000),
+			charges:      []money.Amount{money.FromMinor(500), money.FromMinor(998)},
+		},
+		{
+			name:         "exclusive rules round separately",
+			jurisdiction: table.Jurisdiction("CA", "BC"),
+			amount:       money.FromMinor(1999),
+			taxable:      money.FromMinor(1999),
+			charges:      []money.Amount{money.FromMinor(100), money.FromMinor(140)},
+		},
+		{
+			name:         "inclusive rounds the net price half up",
+			jurisdiction: table.Jurisdiction("DE", ""),
+			amount:       money.FromMinor(5999),
+			taxable:      money.FromMinor(5041),
+			charges:      []money.Amount{money.FromMinor(958)},
+		},
+		{
+			name:         "inclusive last rule absorbs the rounding",
+			jurisdiction: splitVAT,
+			amount:       money.FromMinor(100),
+			taxable:      money.FromMinor(91),
+			charges:      []money.Amount{money.FromMinor(5), money.FromMinor(4)},
+		},
+		{
+			name:         "exclusive zero amount",
+			jurisdiction: table.Jurisdiction("CA", "QC"),
+			amount:       0,
+			taxable:      0,
+			charges:      []money.Amount{0, 0},
+		},
+		{
+			name:         "inclusive zero amount",
+			jurisdiction: splitVAT,
+			amount:       0,
+			taxable:      0,
+			charges:      []money.Amount{0, 0},
+		},
+		{
+			name:         "no rules",
+			jurisdiction: table.Jurisdiction("US", "OR"),
+			amount:       money.FromMinor(5999),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			charges := test.jurisdiction.Calculate(test.amount)
+			if len(charges) != len(test.charges) {
+				t.Fatalf("Expected %d charges, got %d", len(test.charges), len(charges))
+			}
+
+			var total money.Amount
+			for i, charge := range charges {
+				if charge.Rule != test.jurisdiction.Rules[i] {
+					t.Errorf("Expected charge %d to be levied by %s, got %s", i, test.jurisdiction.Rules[i].Name, charge.Rule.Name)
+				}
+				if charge.Taxable != test.taxable {
+					t.Errorf("Expected charge %d to be levied on %s, got %s", i, test.taxable, charge.Taxable)
+				}
+				if charge.Amount != test.charges[i] {
+					t.Errorf("Expected charge %d to be %s, got %s", i, test.charges[i], charge.Amount)
+				}
+				total += charge.Amount
+			}
+
+			if test.jurisdiction.Inclusive && len(charges) > 0 && test.taxable+total != test.amount {
+				t.Errorf("Expected %s net and %s tax to add up to %s", test.taxable, total, test.amount)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package tax
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// defaultRules is the rules table used when TAX_RULES_FILE is not set
+//
+//go:embed rules.json
+var defaultRules []byte
+
+// Rule is one tax levied in a jurisdiction. Rules without a region apply to
+// the whole country; rules with a region apply on top of them in that region.
+type Rule struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+	Name    string `json:"name"`
+	// Rate is a percentage, such as 19 or 9.975
+	Rate float64 `json:"rate"`
+	// Inclusive rules are already part of the catalog price; exclusive rules are added on top
+	Inclusive bool `json:"inclusive"`
+}
+
+// Table holds the tax rules of every supported jurisdiction
+type Table struct {
+	rules []Rule
+}
+
+// NewTable loads the rules file named by TAX_RULES_FILE, or the built-in
+// rules when it is not set
+func NewTable() (*Table, error) {
+	path := os.Getenv("TAX_RULES_FILE")
+	if path == "" {
+		return ParseTable(defaultRules)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax rules: %v", err)
+	}
+	return ParseTable(data)
+}
+
+// ParseTable parses and validates a JSON rules table. Every jurisdiction
+// must price either inclusive or exclusive of tax, not both.
+func ParseTable(data []byte) (*Table, error) {
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse tax rules: %v", err)
+	}
+
+	inclusive := make(map[string]bool)
+	for i := range file.Rules {
+		rule := &file.Rules[i]
+		rule.Country = normalizeCode(rule.Country)
+		rule.Region = normalizeCode(rule.Region)
+		if len(rule.Country) != 2 {
+			return nil, fmt.Errorf("tax rule %d: country must be an ISO 3166-1 alpha-2 code", i)
+		}
+		if rule.Name == "" {
+			return nil, fmt.Errorf("tax rule %d: name is required", i)
+		}
+		if rule.Rate < 0 || rule.Rate >= 100 {
+			return nil, fmt.Errorf("tax rule %d: rate must be between 0 and 100", i)
+		}
+
+		if existing, ok := inclusive[rule.Country]; ok && existing != rule.Inclusive {
+			return nil, fmt.Errorf("tax rule %d: %s mixes tax-inclusive and tax-exclusive rules", i, rule.Country)
+		}
+		inclusive[rule.Country] = rule.Inclusive
+	}
+
+	return &Table{rules: file.Rules}, nil
+}
+
+// Jurisdiction resolves the rules that apply to a country and, optionally,
+// a region within it. A jurisdiction without rules charges no tax.
+func (t *Table) Jurisdiction(country, region string) Jurisdiction {
+	jurisdiction := Jurisdiction{
+		Country: normalizeCode(country),
+		Region:  normalizeCode(region),
+	}
+
+	for _, rule := range t.rules {
+		if rule.Country != jurisdiction.Country {
+			continue
+		}
+		if rule.Region != "" && rule.Region != jurisdiction.Region {
+			continue
+		}
+		jurisdiction.Rules = append(jurisdiction.Rules, rule)
+		jurisdiction.Inclusive = rule.Inclusive
+	}
+
+	return jurisdiction
+}
+
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}